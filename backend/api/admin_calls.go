@@ -0,0 +1,74 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/gorilla/websocket"
+)
+
+// handleAdminTerminateCall ends an active call on behalf of an administrator.
+// Every participant is disconnected from the signaling server and the
+// conversation is told that the call was terminated.
+func (s *Server) handleAdminTerminateCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	adminID, _ := auth.GetUserID(r.Context())
+
+	callID, err := strconv.ParseInt(r.PathValue("callId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	callMutex.Lock()
+	// Checked under callMutex like a join, so the call cannot end in between.
+	call, err := s.queries.GetCallByID(r.Context(), callID)
+	if err != nil {
+		callMutex.Unlock()
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Call not found")
+		return
+	}
+
+	// The read pumps find their connections gone and leave the cleanup to us.
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "call terminated by administrator")
+	for _, conn := range callConnections[callID] {
+		close(conn.send)
+		conn.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+		conn.conn.Close()
+	}
+	delete(callConnections, callID)
+	delete(answeredCalls, callID)
+
+	messageID := s.commitEndCall(callID)
+	callMutex.Unlock()
+
+	if messageID == 0 {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to end call")
+		return
+	}
+
+	s.broadcastCallMessage(context.Background(), messageID)
+	go evtMgr.broadcastToConversation(s, call.ConversationID, Event{
+		Type: EventTypeCallAdminTerminated,
+		Data: map[string]int64{
+			"callId":         callID,
+			"conversationId": call.ConversationID,
+		},
+	})
+
+	s.auditLog(r, adminID, auditActionCallTerminate, "call", callID, map[string]any{
+		"conversationId": call.ConversationID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
@@ -0,0 +1,223 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+const maxInvitationBatchSize = 100
+
+type adminInvitationResponse struct {
+	ID                int64   `json:"id"`
+	Code              string  `json:"code"`
+	URL               string  `json:"url"`
+	CreatedBy         *int64  `json:"createdBy"`
+	CreatedByUsername *string `json:"createdByUsername"`
+	CreatedAt         string  `json:"createdAt"`
+	ExpiresAt         *string `json:"expiresAt"`
+}
+
+type invitationBatchRequest struct {
+	Count     int    `json:"count"`
+	ExpiresIn string `json:"expiresIn"`
+}
+
+type adminInvitationUseResponse struct {
+	ID                int64   `json:"id"`
+	InvitationID      int64   `json:"invitationId"`
+	Code              string  `json:"code"`
+	CreatedBy         *int64  `json:"createdBy"`
+	CreatedByUsername *string `json:"createdByUsername"`
+	CreatedAt         string  `json:"createdAt"`
+	UsedByUserID      int64   `json:"usedByUserId"`
+	UsedByUsername    string  `json:"usedByUsername"`
+	UsedAt            string  `json:"usedAt"`
+}
+
+// handleAdminInvitationUses lists every redeemed invitation code, newest
+// first. Codes without a creator are the bootstrap invitations created on
+// startup.
+func (s *Server) handleAdminInvitationUses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	uses, err := s.queries.ListInvitationUses(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := make([]adminInvitationUseResponse, len(uses))
+	for i, use := range uses {
+		response[i] = adminInvitationUseResponse{
+			ID:                use.ID,
+			InvitationID:      use.InvitationID,
+			Code:              use.Code,
+			CreatedBy:         use.CreatedBy,
+			CreatedByUsername: use.CreatedByUsername,
+			CreatedAt:         use.InvitationCreatedAt.Format(time.RFC3339),
+			UsedByUserID:      use.UsedByUserID,
+			UsedByUsername:    use.UsedByUsername,
+			UsedAt:            use.UsedAt.Format(time.RFC3339),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleAdminInvitations lists the invitation codes of all users that can
+// still be redeemed.
+func (s *Server) handleAdminInvitations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	now := time.Now()
+	invitations, err := s.queries.ListUnexpiredInvitations(r.Context(), &now)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := make([]adminInvitationResponse, len(invitations))
+	for i, inv := range invitations {
+		response[i] = adminInvitationResponse{
+			ID:                inv.ID,
+			Code:              inv.Code,
+			URL:               s.invitationURL(r, inv.Code),
+			CreatedBy:         inv.CreatedBy,
+			CreatedByUsername: inv.CreatedByUsername,
+			CreatedAt:         inv.CreatedAt.Format(time.RFC3339),
+			ExpiresAt:         formatExpiresAt(inv.ExpiresAt),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleAdminInvitationBatch creates up to 100 invitation codes at once,
+// optionally expiring after the Go duration expiresIn.
+func (s *Server) handleAdminInvitationBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	var req invitationBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	if req.Count < 1 || req.Count > maxInvitationBatchSize {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Count must be between 1 and 100", "count")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		expiresIn, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || expiresIn <= 0 {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Expiry must be a positive duration such as 72h", "expiresIn")
+			return
+		}
+		t := time.Now().Add(expiresIn)
+		expiresAt = &t
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	invitations := make([]db.InvitationCode, req.Count)
+	for i := range invitations {
+		code, err := auth.GenerateInvitationCode()
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+
+		invitations[i], err = tx.CreateInvitationCode(r.Context(), code, &userID, expiresAt)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	user, err := s.queries.GetUser(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	s.auditLog(r, userID, auditActionInvitationCreate, "", 0, map[string]any{"count": req.Count, "expiresAt": expiresAt})
+
+	response := make([]adminInvitationResponse, len(invitations))
+	for i, inv := range invitations {
+		response[i] = adminInvitationResponse{
+			ID:                inv.ID,
+			Code:              inv.Code,
+			URL:               s.invitationURL(r, inv.Code),
+			CreatedBy:         inv.CreatedBy,
+			CreatedByUsername: &user.Username,
+			CreatedAt:         inv.CreatedAt.Format(time.RFC3339),
+			ExpiresAt:         formatExpiresAt(inv.ExpiresAt),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// invitationURL returns the registration link for an invitation code, based
+// on the configured public URL or else on the host the request was sent to.
+func (s *Server) invitationURL(r *http.Request, code string) string {
+	base := s.publicURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = fmt.Sprintf("%s://%s", scheme, r.Host)
+	}
+	return base + "/register?invite=" + url.QueryEscape(code)
+}
+
+func invitationExpired(invitation db.InvitationCode) bool {
+	return invitation.ExpiresAt != nil && !time.Now().Before(*invitation.ExpiresAt)
+}
+
+func formatExpiresAt(expiresAt *time.Time) *string {
+	if expiresAt == nil {
+		return nil
+	}
+	str := expiresAt.Format(time.RFC3339)
+	return &str
+}
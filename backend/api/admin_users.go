@@ -0,0 +1,233 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	defaultAdminUsersLimit = 50
+	maxAdminUsersLimit     = 200
+)
+
+type adminUserResponse struct {
+	ID               int64   `json:"id"`
+	Username         string  `json:"username"`
+	ProfileImageURL  *string `json:"profileImageUrl"`
+	IsAdmin          bool    `json:"isAdmin"`
+	IsBot            bool    `json:"isBot"`
+	Suspended        bool    `json:"suspended"`
+	SuspendedUntil   *string `json:"suspendedUntil"`
+	SuspensionReason *string `json:"suspensionReason"`
+	CreatedAt        string  `json:"createdAt"`
+}
+
+type adminUsersResponse struct {
+	Users []adminUserResponse `json:"users"`
+	Page  int64               `json:"page"`
+	Limit int64               `json:"limit"`
+	Total int64               `json:"total"`
+}
+
+type suspendUserRequest struct {
+	Until  string `json:"until"`
+	Reason string `json:"reason"`
+}
+
+func (s *Server) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	page := int64(1)
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		parsed, err := strconv.ParseInt(pageStr, 10, 64)
+		if err != nil || parsed < 1 {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Page must be a positive number", "page")
+			return
+		}
+		page = parsed
+	}
+
+	limit := int64(defaultAdminUsersLimit)
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed < 1 {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Limit must be a positive number", "limit")
+			return
+		}
+		limit = min(parsed, maxAdminUsersLimit)
+	}
+
+	pattern := "%" + r.URL.Query().Get("search") + "%"
+
+	total, err := s.queries.CountUsersMatching(r.Context(), pattern)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	users, err := s.queries.ListUsersPage(r.Context(), pattern, limit, (page-1)*limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	now := time.Now()
+	response := adminUsersResponse{
+		Users: make([]adminUserResponse, len(users)),
+		Page:  page,
+		Limit: limit,
+		Total: total,
+	}
+
+	for i, user := range users {
+		var profileImageURL *string
+		if user.ProfileImageHash != nil {
+			url := fmt.Sprintf("/api/profile/image/%s?size=128", *user.ProfileImageHash)
+			profileImageURL = &url
+		}
+
+		var suspendedUntil *string
+		if user.SuspendedUntil != nil {
+			str := user.SuspendedUntil.Format("2006-01-02T15:04:05Z")
+			suspendedUntil = &str
+		}
+
+		response.Users[i] = adminUserResponse{
+			ID:               user.ID,
+			Username:         user.Username,
+			ProfileImageURL:  profileImageURL,
+			IsAdmin:          user.IsAdmin,
+			IsBot:            user.IsBot,
+			Suspended:        user.SuspendedUntil != nil && now.Before(*user.SuspendedUntil),
+			SuspendedUntil:   suspendedUntil,
+			SuspensionReason: user.SuspensionReason,
+			CreatedAt:        user.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleAdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	adminID, _ := auth.GetUserID(r.Context())
+
+	targetID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	if targetID == adminID {
+		WriteError(w, http.StatusBadRequest, ErrCodeValidation, "Cannot suspend yourself")
+		return
+	}
+
+	var req suspendUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	until, err := time.Parse(time.RFC3339, req.Until)
+	if err != nil || !until.After(time.Now()) {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Until must be a future RFC 3339 timestamp", "until")
+		return
+	}
+	until = until.UTC()
+
+	if _, err := s.queries.GetUser(r.Context(), targetID); err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	var reason *string
+	if trimmed := strings.TrimSpace(req.Reason); trimmed != "" {
+		reason = &trimmed
+	}
+
+	if err := s.queries.SuspendUser(r.Context(), &until, reason, targetID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to suspend user")
+		return
+	}
+
+	evtMgr.disconnectUser(targetID)
+	s.auditLog(r, adminID, auditActionUserSuspend, "user", targetID, map[string]any{
+		"until":  until.Format(time.RFC3339),
+		"reason": reason,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func (s *Server) handleAdminUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	adminID, _ := auth.GetUserID(r.Context())
+
+	targetID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	if _, err := s.queries.GetUser(r.Context(), targetID); err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	if err := s.queries.UnsuspendUser(r.Context(), targetID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to unsuspend user")
+		return
+	}
+
+	s.auditLog(r, adminID, auditActionUserUnsuspend, "user", targetID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func (s *Server) handleAdminRevokeTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	adminID, _ := auth.GetUserID(r.Context())
+
+	targetID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	if err := s.queries.DeleteUserTokens(r.Context(), targetID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to revoke tokens")
+		return
+	}
+
+	evtMgr.disconnectUser(targetID)
+	s.auditLog(r, adminID, auditActionSessionsRevoke, "user", targetID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
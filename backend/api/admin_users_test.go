@@ -0,0 +1,32 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestAdminUnsuspendUser(t *testing.T) {
+	s := newTestServer(t)
+	admin := createTestUser(t, s, "admin")
+	alice := createTestUser(t, s, "alice")
+
+	for _, tt := range []struct {
+		targetID int64
+		want     int
+	}{
+		{alice.ID, http.StatusOK},
+		{alice.ID + 100, http.StatusNotFound},
+	} {
+		id := strconv.FormatInt(tt.targetID, 10)
+		r := exportRequest(http.MethodPost, "/api/admin/users/"+id+"/unsuspend", admin.ID)
+		r.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		s.handleAdminUnsuspendUser(rec, r)
+		if rec.Code != tt.want {
+			t.Errorf("unsuspend user %d: status = %d, want %d: %s", tt.targetID, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
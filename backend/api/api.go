@@ -7,61 +7,367 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
-	"io/fs"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/config"
 	"github.com/bloodmagesoftware/teamsync/db"
-	"github.com/bloodmagesoftware/teamsync/public"
 	"github.com/bloodmagesoftware/teamsync/rtc"
+	"github.com/bloodmagesoftware/teamsync/storage"
 	"github.com/chai2010/webp"
 	"github.com/nfnt/resize"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+const (
+	defaultListenAddress = "127.0.0.1:8080"
+	defaultReadTimeout   = 15 * time.Second
+	defaultIdleTimeout   = 120 * time.Second
+
+	defaultSSEKeepAliveInterval = 30 * time.Second
+
+	defaultInvitationsPerUser = 10
+
+	// apiVersion is the current version prefix of all API routes.
+	apiVersion = "v1"
+
+	devProxyMaxFailures = 5
+	devProxyCooldown    = 30 * time.Second
+)
+
+// Config controls how the HTTP API accepts connections. Empty fields fall back
+// to defaults; WriteTimeout stays disabled when zero because event streams and
+// call signaling keep their connections open indefinitely.
+type Config struct {
+	ListenAddress string
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	IdleTimeout   time.Duration
+	// MessageRateLimit is the number of messages a user may send per minute.
+	MessageRateLimit int
+	// ConversationMessageRateLimit is the number of messages a user may send
+	// per minute to a single conversation.
+	ConversationMessageRateLimit int
+	// Web Push is disabled unless both VAPID keys are set.
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	VAPIDSubject    string
+	// SSEMaxClientsPerUser limits concurrent event streams of a single user.
+	SSEMaxClientsPerUser int
+	// SSEKeepAliveInterval is how often idle event streams receive a
+	// keepalive event. A stream whose write takes longer is closed.
+	SSEKeepAliveInterval time.Duration
+	// MaxJSONBodySize limits request bodies of API routes in bytes;
+	// MaxUploadBodySize applies to file uploads instead.
+	MaxJSONBodySize   int64
+	MaxUploadBodySize int64
+	// LDAP verifies passwords instead of the local password hashes when set.
+	LDAP *auth.LDAPAuthenticator
+	// MaxMessageLength limits message bodies in characters.
+	MaxMessageLength int
+	// Optional features that are enabled unless disabled here.
+	GroupCallsDisabled  bool
+	FileUploadsDisabled bool
+	MarkdownDisabled    bool
+	// InvitationsPerUser limits the unused, unexpired invitation codes a
+	// user other than an administrator may hold.
+	InvitationsPerUser int
+	// PublicURL is the address users open in the browser, used to build
+	// invitation links. The Host header of the request is used when empty.
+	PublicURL string
+	// CORSOrigins lists the origins besides the server's own and PublicURL
+	// that may open call signaling WebSockets.
+	CORSOrigins []string
+	// TURNHealth reports whether the TURN server answers; /api/health skips
+	// the check when it is nil.
+	TURNHealth func() error
+	// DecryptCacheSize is the number of decrypted message bodies kept in
+	// memory.
+	DecryptCacheSize int
+	// MaxPinnedConversations is the number of conversations a user may pin.
+	MaxPinnedConversations int
+	// IntegritySampleSize is the number of random messages whose ciphertext
+	// is verified every hour.
+	IntegritySampleSize int
+	// ReadQueries is a read-only querier that long-running readers such as
+	// exports use instead of the single writer connection. The writer is
+	// used when nil.
+	ReadQueries *db.Queries
+	// Storage holds profile images; nil stores them in
+	// storage.DefaultLocalDir.
+	Storage storage.Backend
+	// The server speaks HTTPS when both TLS files are set. Plain HTTP
+	// requests to HTTPRedirectAddress are then redirected to PublicURL if it
+	// is set. HSTSIncludeSubdomains and HSTSPreload add includeSubDomains and
+	// preload to the Strict-Transport-Security header.
+	TLSCertFile           string
+	TLSKeyFile            string
+	HTTPRedirectAddress   string
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+}
+
 type Server struct {
-	httpServer *http.Server
-	queries    *db.Queries
-	turnConfig rtc.Config
+	httpServer    *http.Server
+	queries       *db.Queries
+	readQueries   *db.Queries
+	listener      net.Listener
+	listenerMutex sync.Mutex
+	ready         atomic.Bool
+
+	// redirectServer redirects plain HTTP to HTTPS; it is nil without TLS
+	// or a public URL to redirect to.
+	redirectServer *http.Server
+	tlsCertFile    string
+	tlsKeyFile     string
+
+	turnConfig      rtc.Config
+	turnConfigMutex sync.RWMutex
+	turnHealth      func() error
+
+	messageRateLimit             int
+	messageLimiters              sync.Map
+	conversationMessageRateLimit int
+	conversationLimiters         sync.Map
+	introspectionLimiters        sync.Map
+	directoryLimiters            sync.Map
+	stopPruning                  chan struct{}
+	stopPruningOnce              sync.Once
+
+	auditEntries  chan auditEntry
+	stopAudit     chan struct{}
+	stopAuditOnce sync.Once
+	auditDone     chan struct{}
+
+	webhookDeliveries chan webhookDelivery
+	cancelWebhooks    context.CancelFunc
+	webhookWorkers    sync.WaitGroup
+
+	previewPrefetches chan *url.URL
+	cancelPreviews    context.CancelFunc
+	previewWorkers    sync.WaitGroup
+
+	vapidPublicKey  string
+	vapidPrivateKey string
+	vapidSubject    string
+
+	ldap *auth.LDAPAuthenticator
+
+	maxUploadBodySize   int64
+	maxMessageLength    int
+	groupCallsDisabled  bool
+	fileUploadsDisabled bool
+	markdownDisabled    bool
+	publicURL           string
+	invitationsPerUser  int
+
+	decryptCache           *decryptCache
+	maxPinnedConversations int
+	typing                 *typingState
+	corsOrigins            map[string]bool
+	storage                storage.Backend
+
+	devProxyClient   *http.Client
+	devProxyFailures atomic.Int32
+	devProxyRetryAt  atomic.Int64
+
+	sseKeepAliveInterval time.Duration
+	activeConnections    atomic.Int64
+
+	integritySampleSize int
+	integrityFailures   atomic.Int64
+	integrityScan       integrityScan
 }
 
-func New(queries *db.Queries, turnConfig rtc.Config) *Server {
+func New(queries *db.Queries, turnConfig rtc.Config, cfg Config) *Server {
 	s := &Server{
 		queries:    queries,
 		turnConfig: turnConfig,
 	}
 
+	if cfg.ListenAddress == "" {
+		cfg.ListenAddress = defaultListenAddress
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = defaultReadTimeout
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+	if cfg.MessageRateLimit <= 0 {
+		cfg.MessageRateLimit = defaultMessageRateLimit
+	}
+	if cfg.ConversationMessageRateLimit <= 0 {
+		cfg.ConversationMessageRateLimit = defaultConversationMessageRateLimit
+	}
+	if cfg.SSEMaxClientsPerUser <= 0 {
+		cfg.SSEMaxClientsPerUser = defaultSSEMaxClientsPerUser
+	}
+	if cfg.SSEKeepAliveInterval <= 0 {
+		cfg.SSEKeepAliveInterval = defaultSSEKeepAliveInterval
+	}
+	if cfg.MaxJSONBodySize <= 0 {
+		cfg.MaxJSONBodySize = defaultMaxJSONBodySize
+	}
+	if cfg.MaxUploadBodySize <= 0 {
+		cfg.MaxUploadBodySize = defaultMaxUploadBodySize
+	}
+	if cfg.MaxMessageLength <= 0 {
+		cfg.MaxMessageLength = config.DefaultMaxMessageLength
+	}
+	if cfg.InvitationsPerUser <= 0 {
+		cfg.InvitationsPerUser = defaultInvitationsPerUser
+	}
+	if cfg.DecryptCacheSize <= 0 {
+		cfg.DecryptCacheSize = defaultDecryptCacheSize
+	}
+	if cfg.MaxPinnedConversations <= 0 {
+		cfg.MaxPinnedConversations = defaultMaxPinnedConversations
+	}
+	if cfg.IntegritySampleSize <= 0 {
+		cfg.IntegritySampleSize = defaultIntegritySampleSize
+	}
+	if cfg.Storage == nil {
+		cfg.Storage = storage.NewLocalBackend(storage.DefaultLocalDir)
+	}
+	if cfg.ReadQueries == nil {
+		cfg.ReadQueries = queries
+	}
+
+	s.messageRateLimit = cfg.MessageRateLimit
+	s.conversationMessageRateLimit = cfg.ConversationMessageRateLimit
+	s.vapidPublicKey = cfg.VAPIDPublicKey
+	s.vapidPrivateKey = cfg.VAPIDPrivateKey
+	// webpush-go adds the mailto: scheme to subjects that are no HTTPS URL.
+	s.vapidSubject = strings.TrimPrefix(cfg.VAPIDSubject, "mailto:")
+	s.ldap = cfg.LDAP
+	s.maxUploadBodySize = cfg.MaxUploadBodySize
+	s.maxMessageLength = cfg.MaxMessageLength
+	s.groupCallsDisabled = cfg.GroupCallsDisabled
+	s.fileUploadsDisabled = cfg.FileUploadsDisabled
+	s.markdownDisabled = cfg.MarkdownDisabled
+	s.publicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	s.invitationsPerUser = cfg.InvitationsPerUser
+	s.decryptCache = newDecryptCache(cfg.DecryptCacheSize)
+	s.maxPinnedConversations = cfg.MaxPinnedConversations
+	s.integritySampleSize = cfg.IntegritySampleSize
+	s.typing = newTypingState()
+	s.turnHealth = cfg.TURNHealth
+	s.storage = cfg.Storage
+	s.readQueries = cfg.ReadQueries
+	s.corsOrigins = make(map[string]bool)
+	for _, origin := range append(cfg.CORSOrigins, s.publicURL) {
+		if origin != "" {
+			s.corsOrigins[normalizeOrigin(origin)] = true
+		}
+	}
+	evtMgr.maxClientsPerUser = cfg.SSEMaxClientsPerUser
+	s.sseKeepAliveInterval = cfg.SSEKeepAliveInterval
+	s.stopPruning = make(chan struct{})
+	s.auditEntries = make(chan auditEntry, auditLogBufferSize)
+	s.stopAudit = make(chan struct{})
+	s.auditDone = make(chan struct{})
+	go s.writeAuditLog(s.stopAudit, s.auditDone)
+	s.startWebhookWorkers()
+	s.startPreviewWorkers()
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/auth/login", s.handleLogin)
-	mux.HandleFunc("/api/auth/register", s.handleRegister)
-	mux.Handle("/api/auth/me", auth.RequireAuth(queries)(http.HandlerFunc(s.handleMe)))
-	mux.Handle("/api/invitations", auth.RequireAuth(queries)(http.HandlerFunc(s.handleInvitations)))
-	mux.Handle("/api/invitations/delete", auth.RequireAuth(queries)(http.HandlerFunc(s.handleDeleteInvitation)))
-	mux.Handle("/api/profile/image", auth.RequireAuth(queries)(http.HandlerFunc(s.handleProfileImageUpload)))
-	mux.HandleFunc("/api/profile/image/", s.handleProfileImageServe)
-	mux.Handle("/api/settings/chat", auth.RequireAuth(queries)(http.HandlerFunc(s.handleChatSettings)))
-	mux.Handle("/api/conversations", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversations)))
-	mux.Handle("/api/conversations/dm", auth.RequireAuth(queries)(http.HandlerFunc(s.handleGetOrCreateDM)))
-	mux.Handle("/api/messages", auth.RequireAuth(queries)(http.HandlerFunc(s.handleMessages)))
-	mux.Handle("/api/messages/send", auth.RequireAuth(queries)(http.HandlerFunc(s.handleSendMessage)))
-	mux.Handle("/api/messages/read", auth.RequireAuth(queries)(http.HandlerFunc(s.handleUpdateReadState)))
-	mux.Handle("/api/users/search", auth.RequireAuth(queries)(http.HandlerFunc(s.handleSearchUsers)))
-	mux.Handle("/api/events/stream", auth.RequireAuth(queries)(http.HandlerFunc(s.handleEventStream)))
-	mux.Handle("/api/calls/start", auth.RequireAuth(queries)(http.HandlerFunc(s.handleStartCall)))
-	mux.Handle("/api/calls/status", auth.RequireAuth(queries)(http.HandlerFunc(s.handleCallStatus)))
-	mux.Handle("/api/calls/config", auth.RequireAuth(queries)(http.HandlerFunc(s.handleCallConfig)))
-	mux.HandleFunc("/api/calls/signaling", s.handleCallSignaling)
+	routeVersion(mux, "/api/health", http.HandlerFunc(s.handleHealth))
+	routeVersion(mux, "/api/ready", http.HandlerFunc(s.handleReady))
+	routeVersion(mux, "/api/config", http.HandlerFunc(s.handleServerConfig))
+	routeVersion(mux, "/api/auth/login", http.HandlerFunc(s.handleLogin))
+	routeVersion(mux, "/api/auth/register", http.HandlerFunc(s.handleRegister))
+	routeVersion(mux, "/api/auth/introspect", http.HandlerFunc(s.handleIntrospect))
+	routeVersion(mux, "/api/auth/me", auth.RequireAuth(queries)(http.HandlerFunc(s.handleMe)))
+	routeVersion(mux, "/api/auth/account", auth.RequireAuth(queries)(http.HandlerFunc(s.handleDeleteAccount)))
+	routeVersion(mux, "/api/auth/sessions", auth.RequireAuth(queries)(http.HandlerFunc(s.handleSessions)))
+	routeVersion(mux, "/api/auth/sessions/revoke-device", auth.RequireAuth(queries)(http.HandlerFunc(s.handleRevokeDevice)))
+	routeVersion(mux, "/api/invitations", auth.RequireAuth(queries)(http.HandlerFunc(s.handleInvitations)))
+	routeVersion(mux, "/api/invitations/delete", auth.RequireAuth(queries)(http.HandlerFunc(s.handleDeleteInvitation)))
+	routeVersion(mux, "/api/profile", auth.RequireAuth(queries)(http.HandlerFunc(s.handleUpdateProfile)))
+	routeVersion(mux, "/api/profile/image", auth.RequireAuth(queries)(http.HandlerFunc(s.handleProfileImageUpload)))
+	routeVersion(mux, "/api/profile/image/{hash}", http.HandlerFunc(s.handleProfileImageServe))
+	routeVersion(mux, "/api/user/export", auth.RequireAuth(queries)(http.HandlerFunc(s.handleUserExport)))
+	routeVersion(mux, "/api/user/export/{jobId}", auth.RequireAuth(queries)(http.HandlerFunc(s.handleUserExportDownload)))
+	routeVersion(mux, "/api/notifications/unread", auth.RequireAuth(queries)(http.HandlerFunc(s.handleUnreadCount)))
+	routeVersion(mux, "/api/push/key", http.HandlerFunc(s.handlePushPublicKey))
+	routeVersion(mux, "/api/push/subscribe", auth.RequireAuth(queries)(http.HandlerFunc(s.handlePushSubscription)))
+	routeVersion(mux, "/api/bookmarks", auth.RequireAuth(queries)(http.HandlerFunc(s.handleBookmarks)))
+	routeVersion(mux, "/api/bookmarks/{messageId}", auth.RequireAuth(queries)(http.HandlerFunc(s.handleDeleteBookmark)))
+	routeVersion(mux, "/api/contacts", auth.RequireAuth(queries)(http.HandlerFunc(s.handleContacts)))
+	routeVersion(mux, "/api/contacts/{id}", auth.RequireAuth(queries)(http.HandlerFunc(s.handleDeleteContact)))
+	routeVersion(mux, "/api/webhooks", auth.RequireAuth(queries)(http.HandlerFunc(s.handleWebhooks)))
+	routeVersion(mux, "/api/webhooks/{id}", auth.RequireAuth(queries)(http.HandlerFunc(s.handleDeleteWebhook)))
+	routeVersion(mux, "/api/settings/chat", auth.RequireAuth(queries)(http.HandlerFunc(s.handleChatSettings)))
+	routeVersion(mux, "/api/conversations", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversations)))
+	routeVersion(mux, "/api/conversations/{id}", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversation)))
+	routeVersion(mux, "/api/conversations/{id}/settings", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversationSettings)))
+	routeVersion(mux, "/api/conversations/{id}/export", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversationExport)))
+	routeVersion(mux, "/api/conversations/{id}/notifications", auth.RequireAuth(queries)(http.HandlerFunc(s.handleNotificationLevel)))
+	routeVersion(mux, "/api/conversations/{id}/members", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversationMembers)))
+	routeVersion(mux, "/api/conversations/{id}/members/{userId}", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversationMember)))
+	routeVersion(mux, "/api/conversations/{id}/pin", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversationPin)))
+	routeVersion(mux, "/api/conversations/{id}/typing", auth.RequireAuth(queries)(http.HandlerFunc(s.handleTyping)))
+	routeVersion(mux, "/api/conversations/{id}/sync", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversationSync)))
+	routeVersion(mux, "/api/conversations/read-state", auth.RequireAuth(queries)(http.HandlerFunc(s.handleConversationReadStates)))
+	routeVersion(mux, "/api/conversations/search", auth.RequireAuth(queries)(http.HandlerFunc(s.handleSearchConversations)))
+	routeVersion(mux, "/api/conversations/dm", auth.RequireAuth(queries)(http.HandlerFunc(s.handleGetOrCreateDM)))
+	routeVersion(mux, "/api/messages", auth.RequireAuth(queries)(http.HandlerFunc(s.handleMessages)))
+	routeVersion(mux, "/api/messages/send", auth.RequireAuth(queries)(http.HandlerFunc(s.handleSendMessage)))
+	routeVersion(mux, "/api/messages/read", auth.RequireAuth(queries)(http.HandlerFunc(s.handleUpdateReadState)))
+	routeVersion(mux, "/api/messages/{id}/thread", auth.RequireAuth(queries)(http.HandlerFunc(s.handleMessageThread)))
+	routeVersion(mux, "/api/messages/{id}/seen-by", auth.RequireAuth(queries)(http.HandlerFunc(s.handleMessageSeenBy)))
+	routeVersion(mux, "/api/messages/{id}/forward", auth.RequireAuth(queries)(http.HandlerFunc(s.handleForwardMessage)))
+	routeVersion(mux, "/api/messages/{id}/vote", auth.RequireAuth(queries)(http.HandlerFunc(s.handlePollVote)))
+	routeVersion(mux, "/api/scheduled", auth.RequireAuth(queries)(http.HandlerFunc(s.handleScheduledMessages)))
+	routeVersion(mux, "/api/scheduled/{id}", auth.RequireAuth(queries)(http.HandlerFunc(s.handleScheduledMessage)))
+	routeVersion(mux, "/api/users", auth.RequireAuth(queries)(http.HandlerFunc(s.handleUserDirectory)))
+	routeVersion(mux, "/api/users/{id}", auth.RequireAuth(queries)(http.HandlerFunc(s.handleUserProfile)))
+	routeVersion(mux, "/api/users/blocks", auth.RequireAuth(queries)(http.HandlerFunc(s.handleListBlocks)))
+	routeVersion(mux, "/api/users/{id}/block", auth.RequireAuth(queries)(http.HandlerFunc(s.handleBlockUser)))
+	routeVersion(mux, "/api/preview", auth.RequireAuth(queries)(http.HandlerFunc(s.handleLinkPreview)))
+	routeVersion(mux, "/api/users/search", auth.RequireAuth(queries)(http.HandlerFunc(s.handleSearchUsers)))
+	routeVersion(mux, "/api/search", auth.RequireAuth(queries)(http.HandlerFunc(s.handleSearch)))
+	routeVersion(mux, "/api/events/stream", auth.RequireAuth(queries)(http.HandlerFunc(s.handleEventStream)))
+	routeVersion(mux, "/api/calls/start", auth.RequireAuth(queries)(http.HandlerFunc(s.handleStartCall)))
+	routeVersion(mux, "/api/calls/status", auth.RequireAuth(queries)(http.HandlerFunc(s.handleCallStatus)))
+	routeVersion(mux, "/api/calls/{callId}/reject", auth.RequireAuth(queries)(http.HandlerFunc(s.handleRejectCall)))
+	routeVersion(mux, "/api/calls/{callId}/stats", auth.RequireAuth(queries)(http.HandlerFunc(s.handleCallStats)))
+	routeVersion(mux, "/api/admin/backup", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminBackup))))
+	routeVersion(mux, "/api/admin/invitations", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminInvitations))))
+	routeVersion(mux, "/api/admin/invitations/batch", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminInvitationBatch))))
+	routeVersion(mux, "/api/admin/invitations/uses", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminInvitationUses))))
+	routeVersion(mux, "/api/admin/bots", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminCreateBot))))
+	routeVersion(mux, "/api/admin/users", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminUsers))))
+	routeVersion(mux, "/api/admin/users/{id}/suspend", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminSuspendUser))))
+	routeVersion(mux, "/api/admin/users/{id}/unsuspend", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminUnsuspendUser))))
+	routeVersion(mux, "/api/admin/users/{id}/tokens", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminRevokeTokens))))
+	routeVersion(mux, "/api/admin/metrics", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(promhttp.Handler())))
+	routeVersion(mux, "/api/admin/audit", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminAuditLog))))
+	routeVersion(mux, "/api/admin/audit/actions", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminAuditActions))))
+	routeVersion(mux, "/api/admin/migrations", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminMigrations))))
+	routeVersion(mux, "/api/admin/migrations/pending", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminPendingMigrations))))
+	routeVersion(mux, "/api/admin/crypto/verify", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminVerifyMessages))))
+	routeVersion(mux, "/api/admin/calls/{callId}", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminTerminateCall))))
+	routeVersion(mux, "/api/admin/calls/{callId}/stats", auth.RequireAuth(queries)(auth.RequireAdmin(queries)(http.HandlerFunc(s.handleAdminCallStats))))
+	routeVersion(mux, "/api/calls/history", auth.RequireAuth(queries)(http.HandlerFunc(s.handleCallHistory)))
+	routeVersion(mux, "/api/calls/config", auth.RequireAuth(queries)(http.HandlerFunc(s.handleCallConfig)))
+	routeVersion(mux, "/api/calls/signaling", http.HandlerFunc(s.handleCallSignaling))
 
 	if frontendDevURL, ok := os.LookupEnv("FRONTEND_DEV_URL"); ok {
 		log.Printf("development mode: proxying frontend requests to %s", frontendDevURL)
@@ -71,24 +377,112 @@ func New(queries *db.Queries, turnConfig rtc.Config) *Server {
 		mux.HandleFunc("/", s.handleStaticFiles)
 	}
 
+	// Body limits wrap the whole mux; the more specific upload pattern takes
+	// precedence over the API-wide limit.
+	root := http.NewServeMux()
+	root.Handle("/api/", MaxBodyMiddleware(cfg.MaxJSONBodySize)(mux))
+	root.Handle("/api/profile/image", MaxBodyMiddleware(cfg.MaxUploadBodySize)(mux))
+	root.Handle("/api/"+apiVersion+"/profile/image", MaxBodyMiddleware(cfg.MaxUploadBodySize)(mux))
+	root.Handle("/", mux)
+
+	var handler http.Handler = root
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+		if cfg.HTTPRedirectAddress == "" {
+			cfg.HTTPRedirectAddress = defaultHTTPRedirectAddress
+		}
+		s.tlsCertFile = cfg.TLSCertFile
+		s.tlsKeyFile = cfg.TLSKeyFile
+		if publicURL, err := url.Parse(s.publicURL); err == nil && publicURL.Host != "" {
+			s.redirectServer = &http.Server{
+				Addr:        cfg.HTTPRedirectAddress,
+				Handler:     redirectToHTTPS(publicURL),
+				ReadTimeout: cfg.ReadTimeout,
+				IdleTimeout: cfg.IdleTimeout,
+			}
+		} else {
+			log.Printf("PUBLIC_URL is not set, plain HTTP requests are not redirected to HTTPS")
+		}
+		handler = HSTSMiddleware(cfg.HSTSIncludeSubdomains, cfg.HSTSPreload)(handler)
+	}
+
 	s.httpServer = &http.Server{
-		Addr:         "0.0.0.0:8080",
-		Handler:      mux,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 0,
-		IdleTimeout:  120 * time.Second,
+		Addr:         cfg.ListenAddress,
+		Handler:      RequestIDMiddleware(handler),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		ConnState:    s.trackConnState,
 	}
 
 	return s
 }
 
+// trackConnState counts the open connections of the API listener. Hijacked
+// connections, such as call signaling WebSockets, are no longer counted.
+func (s *Server) trackConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.activeConnections.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		s.activeConnections.Add(-1)
+	}
+}
+
+// ActiveConnections returns the number of open HTTP connections.
+func (s *Server) ActiveConnections() int64 {
+	return s.activeConnections.Load()
+}
+
+// routeVersion registers handler at /api/v1/... and at the given unversioned
+// /api/... pattern. The unversioned routes are deprecated and will be
+// removed; until then they answer with a Deprecation header.
+func routeVersion(mux *http.ServeMux, pattern string, handler http.Handler) {
+	path := strings.TrimPrefix(pattern, "/api/")
+	mux.Handle("/api/"+apiVersion+"/"+path, handler)
+	mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// devProxyAllow reports whether the frontend dev server is tried. After
+// devProxyMaxFailures consecutive failures it is left alone for
+// devProxyCooldown, then a single failure suffices to pause again.
+func (s *Server) devProxyAllow() bool {
+	return time.Now().UnixNano() >= s.devProxyRetryAt.Load()
+}
+
+// devProxyResult records the outcome of a request to the frontend dev server.
+func (s *Server) devProxyResult(err error) {
+	if err == nil {
+		s.devProxyFailures.Store(0)
+		s.devProxyRetryAt.Store(0)
+		return
+	}
+
+	if failures := s.devProxyFailures.Add(1); failures >= devProxyMaxFailures {
+		s.devProxyRetryAt.Store(time.Now().Add(devProxyCooldown).UnixNano())
+		if failures == devProxyMaxFailures {
+			log.Printf("frontend dev server unreachable, retrying in %s: %v", devProxyCooldown, err)
+		}
+	}
+}
+
 func (s *Server) handleDevProxy(frontendURL string) http.HandlerFunc {
 	target, err := url.Parse(frontendURL)
 	if err != nil {
 		log.Fatalf("invalid FRONTEND_DEV_URL: %v", err)
 	}
 
+	s.devProxyClient = &http.Client{Timeout: 30 * time.Second}
+
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.devProxyAllow() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(devProxyCooldown.Seconds())))
+			WriteError(w, http.StatusServiceUnavailable, ErrCodeInternal, "frontend dev server unreachable")
+			return
+		}
+
 		if r.Header.Get("Upgrade") == "websocket" {
 			s.proxyWebSocket(w, r, target)
 			return
@@ -98,7 +492,7 @@ func (s *Server) handleDevProxy(frontendURL string) http.HandlerFunc {
 		proxyURL.Path = r.URL.Path
 		proxyURL.RawQuery = r.URL.RawQuery
 
-		proxyReq, err := http.NewRequest(r.Method, proxyURL.String(), r.Body)
+		proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, proxyURL.String(), r.Body)
 		if err != nil {
 			http.Error(w, "proxy error", http.StatusInternalServerError)
 			return
@@ -109,9 +503,12 @@ func (s *Server) handleDevProxy(frontendURL string) http.HandlerFunc {
 				proxyReq.Header.Add(key, value)
 			}
 		}
+		proxyReq.Header.Set("X-Request-ID", db.RequestIDFromContext(r.Context()))
 
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(proxyReq)
+		resp, err := s.devProxyClient.Do(proxyReq)
+		if r.Context().Err() == nil {
+			s.devProxyResult(err)
+		}
 		if err != nil {
 			http.Error(w, "proxy error", http.StatusBadGateway)
 			return
@@ -136,17 +533,21 @@ func (s *Server) proxyWebSocket(w http.ResponseWriter, r *http.Request, target *
 		return
 	}
 
-	targetConn, err := net.Dial("tcp", target.Host)
+	targetConn, err := net.DialTimeout("tcp", target.Host, 5*time.Second)
+	s.devProxyResult(err)
 	if err != nil {
 		http.Error(w, "failed to connect to backend", http.StatusBadGateway)
 		return
 	}
 	defer targetConn.Close()
 
+	header := r.Header.Clone()
+	header.Set("X-Request-ID", db.RequestIDFromContext(r.Context()))
+
 	req := &http.Request{
 		Method: "GET",
 		URL:    &url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery},
-		Header: r.Header,
+		Header: header,
 		Host:   target.Host,
 	}
 
@@ -190,72 +591,107 @@ func (s *Server) proxyWebSocket(w http.ResponseWriter, r *http.Request, target *
 	<-done
 }
 
-func (s *Server) handleStaticFiles(w http.ResponseWriter, r *http.Request) {
-	fsPath := strings.TrimPrefix(r.URL.Path, "/")
-
-	_, err := fs.Stat(public.Public, fsPath)
-	if os.IsNotExist(err) {
-		w.Header().Set("Content-Type", "text/html")
-		http.ServeFileFS(w, r, public.Public, "index.html")
-		return
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
 	}
 
-	if strings.HasPrefix(r.URL.Path, "/assets/") {
-		w.Header().Set("Cache-Control", "public, max-age=2592000") // 30 days
+	s.listenerMutex.Lock()
+	s.listener = listener
+	s.listenerMutex.Unlock()
+
+	s.startBackgroundTasks()
+
+	if s.tlsCertFile == "" {
+		log.Printf("starting API server on %s", listener.Addr())
+		if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+		return nil
 	}
 
-	switch ext := filepath.Ext(fsPath); ext {
-	case ".js":
-		w.Header().Set("Content-Type", "application/javascript")
-	case ".css":
-		w.Header().Set("Content-Type", "text/css")
-	case ".svg":
-		w.Header().Set("Content-Type", "image/svg+xml")
-	case ".png":
-		w.Header().Set("Content-Type", "image/png")
-	case ".jpg", ".jpeg":
-		w.Header().Set("Content-Type", "image/jpeg")
-	case ".webp":
-		w.Header().Set("Content-Type", "image/webp")
-	case ".mp4":
-		w.Header().Set("Content-Type", "video/mp4")
-	case ".webm":
-		w.Header().Set("Content-Type", "video/webm")
-	default:
-		fmt.Printf("unknown file extension: %s\n", ext)
+	if err := warnSelfSignedCertificate(s.tlsCertFile, s.tlsKeyFile); err != nil {
+		listener.Close()
+		return err
 	}
 
-	http.ServeFileFS(w, r, public.Public, fsPath)
-}
+	if s.redirectServer != nil {
+		go func() {
+			log.Printf("redirecting HTTP on %s to HTTPS", s.redirectServer.Addr)
+			if err := s.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("HTTP redirect server error: %v", err)
+			}
+		}()
+	}
 
-func (s *Server) Start() error {
-	log.Printf("starting API server on %s", s.httpServer.Addr)
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	log.Printf("starting API server with TLS on %s", listener.Addr())
+	if err := s.httpServer.ServeTLS(listener, s.tlsCertFile, s.tlsKeyFile); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 	return nil
 }
 
+// startBackgroundTasks starts the periodic jobs of the server. They run until
+// Shutdown closes stopPruning.
+func (s *Server) startBackgroundTasks() {
+	go s.pruneMessageLimiters(s.stopPruning)
+	go s.expireMessages(s.stopPruning)
+	go s.restorePendingDeletions(s.stopPruning)
+	go s.expireClientMessageIDs(s.stopPruning)
+	go s.checkMessageIntegrity(s.stopPruning)
+	go s.expireTyping(s.stopPruning)
+	go s.deliverScheduledMessages(s.stopPruning)
+	go s.expireExportJobs(s.stopPruning)
+}
+
+// Addr returns the address the server is bound to. Before Start has opened the
+// listener it returns the configured address, which may still use port 0.
+func (s *Server) Addr() string {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.httpServer.Addr
+}
+
 func (s *Server) Shutdown(ctx context.Context) error {
 	log.Printf("shutting down API server")
+	s.stopPruningOnce.Do(func() { close(s.stopPruning) })
 	evtMgr.shutdownAll()
-	return s.httpServer.Shutdown(ctx)
+	err := s.httpServer.Shutdown(ctx)
+	if s.redirectServer != nil {
+		if redirectErr := s.redirectServer.Shutdown(ctx); redirectErr != nil {
+			log.Printf("error shutting down HTTP redirect server: %v", redirectErr)
+		}
+	}
+	shutdownCalls(ctx)
+	s.stopWebhooks()
+	s.stopPreviews()
+
+	// Handlers have returned, so no new audit entries are queued.
+	s.stopAuditOnce.Do(func() { close(s.stopAudit) })
+	<-s.auditDone
+	return err
 }
 
 type loginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	DeviceName string `json:"deviceName"`
 }
 
 type registerRequest struct {
 	Username       string `json:"username"`
 	Password       string `json:"password"`
 	InvitationCode string `json:"invitationCode"`
+	DeviceName     string `json:"deviceName"`
 }
 
 type authResponse struct {
 	Success         bool    `json:"success"`
-	Message         string  `json:"message,omitempty"`
 	UserID          int64   `json:"userId,omitempty"`
 	Username        string  `json:"username,omitempty"`
 	ProfileImageURL *string `json:"profileImageUrl,omitempty"`
@@ -265,49 +701,74 @@ type authResponse struct {
 
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req loginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Invalid request"})
+		WriteDecodeError(w, err)
 		return
 	}
 
-	user, err := s.queries.GetUserByUsername(r.Context(), req.Username)
+	deviceName, err := parseDeviceName(req.DeviceName)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Invalid credentials"})
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, err.Error(), "deviceName")
 		return
 	}
 
-	valid, err := auth.VerifyPassword(req.Password, user.PasswordSalt, user.PasswordHash)
-	if err != nil || !valid {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Invalid credentials"})
+	var user db.User
+	if s.ldap != nil {
+		user, err = s.ldapLogin(r.Context(), req.Username, req.Password)
+		if err != nil {
+			if !errors.Is(err, errInvalidCredentials) {
+				log.Printf("LDAP login of %q failed: %v", req.Username, err)
+			}
+			s.auditLog(r, 0, auditActionLoginFailed, "", 0, map[string]any{"username": req.Username})
+			WriteError(w, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Invalid credentials")
+			return
+		}
+	} else {
+		user, err = s.queries.GetUserByUsername(r.Context(), strings.TrimSpace(req.Username))
+		if err != nil {
+			s.auditLog(r, 0, auditActionLoginFailed, "", 0, map[string]any{"username": req.Username})
+			WriteError(w, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Invalid credentials")
+			return
+		}
+
+		valid, err := auth.VerifyPassword(req.Password, user.PasswordSalt, user.PasswordHash)
+		if err != nil || !valid {
+			s.auditLog(r, 0, auditActionLoginFailed, "user", user.ID, map[string]any{"username": req.Username})
+			WriteError(w, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Invalid credentials")
+			return
+		}
+	}
+
+	if user.IsBot {
+		WriteError(w, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Invalid credentials")
 		return
 	}
 
 	tokenPair, err := auth.GenerateTokenPair()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Server error"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
 		return
 	}
 
-	if err := s.queries.DeleteUserTokens(r.Context(), user.ID); err != nil {
+	// Logging in again from the same device replaces its previous session
+	// instead of accumulating tokens.
+	if _, err := s.queries.DeleteUserDeviceTokens(r.Context(), user.ID, deviceName); err != nil {
 		log.Printf("warning: failed to delete old tokens: %v", err)
 	}
 
-	_, err = s.queries.CreateOAuthToken(r.Context(), user.ID, tokenPair.AccessToken, tokenPair.RefreshToken, tokenPair.AccessTokenExpiresAt, tokenPair.RefreshTokenExpiresAt)
+	_, err = s.queries.CreateOAuthToken(r.Context(), user.ID, tokenPair.AccessToken, tokenPair.RefreshToken, tokenPair.AccessTokenExpiresAt, tokenPair.RefreshTokenExpiresAt, deviceName)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Server error"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
 		return
 	}
 
+	s.auditLog(r, user.ID, auditActionLogin, "user", user.ID, nil)
+
 	var profileImageURL *string
 	if user.ProfileImageHash != nil {
 		url := fmt.Sprintf("/api/profile/image/%s", *user.ProfileImageHash)
@@ -327,66 +788,114 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req registerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Invalid request"})
+		WriteDecodeError(w, err)
+		return
+	}
+
+	deviceName, err := parseDeviceName(req.DeviceName)
+	if err != nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, err.Error(), "deviceName")
 		return
 	}
 
-	_, err := s.queries.GetInvitationByCode(r.Context(), req.InvitationCode)
+	username, err := auth.NormalizeUsername(req.Username)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Invalid invitation code"})
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, err.Error(), "username")
+		return
+	}
+
+	invitation, err := s.queries.GetInvitationByCode(r.Context(), req.InvitationCode)
+	if err != nil || invitationExpired(invitation) {
+		WriteFieldError(w, http.StatusUnauthorized, ErrCodeValidation, "Invalid invitation code", "invitationCode")
 		return
 	}
 
 	salt, err := auth.GenerateSalt()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Server error"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
 		return
 	}
 
 	hash, err := auth.HashPassword(req.Password, salt)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Server error"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
 		return
 	}
 
-	user, err := s.queries.CreateUser(r.Context(), req.Username, hash, salt)
+	tx, err := s.queries.Begin()
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Username already taken"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Looked up again inside the transaction so that a code cannot be
+	// redeemed twice.
+	invitation, err = tx.GetInvitationByCode(r.Context(), req.InvitationCode)
+	if err != nil || invitationExpired(invitation) {
+		WriteFieldError(w, http.StatusUnauthorized, ErrCodeValidation, "Invalid invitation code", "invitationCode")
+		return
+	}
+
+	// The first account of an instance administers it.
+	userCount, err := tx.CountUsers(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
+		return
+	}
+
+	// Accounts created before usernames were normalized may differ from
+	// the new username in case only.
+	if _, err := tx.GetUserByUsername(r.Context(), username); err == nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Username already taken", "username")
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
+		return
+	}
+
+	user, err := tx.CreateUser(r.Context(), username, hash, salt, userCount == 0)
+	if err != nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Username already taken", "username")
 		return
 	}
 
 	// Create default user settings for the new user
-	_, err = s.queries.CreateUserSettings(r.Context(), user.ID, false, true)
+	_, err = tx.CreateUserSettings(r.Context(), user.ID, false, true)
 	if err != nil {
 		log.Printf("warning: failed to create user settings for user %d: %v", user.ID, err)
 	}
 
-	if err := s.queries.DeleteInvitationCode(r.Context(), req.InvitationCode); err != nil {
-		log.Printf("warning: failed to delete invitation code: %v", err)
+	if err := tx.RecordInvitationUse(r.Context(), invitation.ID, invitation.Code, invitation.CreatedBy, invitation.CreatedAt, user.ID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
+		return
+	}
+
+	if err := tx.DeleteInvitationCode(r.Context(), req.InvitationCode); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
+		return
 	}
 
 	tokenPair, err := auth.GenerateTokenPair()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Server error"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
 		return
 	}
 
-	_, err = s.queries.CreateOAuthToken(r.Context(), user.ID, tokenPair.AccessToken, tokenPair.RefreshToken, tokenPair.AccessTokenExpiresAt, tokenPair.RefreshTokenExpiresAt)
+	_, err = s.queries.CreateOAuthToken(r.Context(), user.ID, tokenPair.AccessToken, tokenPair.RefreshToken, tokenPair.AccessTokenExpiresAt, tokenPair.RefreshTokenExpiresAt, deviceName)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(authResponse{Success: false, Message: "Server error"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
 		return
 	}
 
@@ -411,23 +920,29 @@ type userResponse struct {
 	ID              int64   `json:"id"`
 	Username        string  `json:"username"`
 	ProfileImageURL *string `json:"profileImageUrl"`
+	IsAdmin         bool    `json:"isAdmin"`
 }
 
 func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	user, err := s.queries.GetUser(r.Context(), userID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if user.DeletedAt != nil {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Account deleted")
 		return
 	}
 
@@ -442,19 +957,59 @@ func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 		ID:              user.ID,
 		Username:        user.Username,
 		ProfileImageURL: profileImageURL,
+		IsAdmin:         user.IsAdmin,
 	})
 }
 
+// handleDeleteAccount marks the account of the current user as deleted. The
+// row is kept so that messages sent by the user can still be rendered.
+func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	if err := s.queries.SoftDeleteUser(r.Context(), userID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if err := s.queries.DeleteUserTokens(r.Context(), userID); err != nil {
+		log.Printf("warning: failed to delete tokens of deleted user %d: %v", userID, err)
+	}
+
+	evtMgr.disconnectUser(userID)
+	s.auditLog(r, userID, auditActionAccountDelete, "user", userID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
 type invitationResponse struct {
-	ID        int64  `json:"id"`
-	Code      string `json:"code"`
-	CreatedAt string `json:"createdAt"`
+	ID        int64   `json:"id"`
+	Code      string  `json:"code"`
+	CreatedAt string  `json:"createdAt"`
+	ExpiresAt *string `json:"expiresAt,omitempty"`
+	// UsedBy is null until the code was redeemed.
+	UsedBy *invitationUseResponse `json:"usedBy"`
+}
+
+type invitationUseResponse struct {
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+	UsedAt   string `json:"usedAt"`
 }
 
 func (s *Server) handleInvitations(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
@@ -462,35 +1017,76 @@ func (s *Server) handleInvitations(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		invitations, err := s.queries.ListInvitationsByUser(r.Context(), &userID)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+
+		uses, err := s.queries.ListInvitationUsesByCreator(r.Context(), &userID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 			return
 		}
 
-		response := make([]invitationResponse, len(invitations))
-		for i, inv := range invitations {
-			response[i] = invitationResponse{
+		// Redeemed codes no longer exist and are listed after the open ones.
+		response := make([]invitationResponse, 0, len(invitations)+len(uses))
+		for _, inv := range invitations {
+			response = append(response, invitationResponse{
 				ID:        inv.ID,
 				Code:      inv.Code,
 				CreatedAt: inv.CreatedAt.Format(time.RFC3339),
-			}
+				ExpiresAt: formatExpiresAt(inv.ExpiresAt),
+			})
+		}
+		for _, use := range uses {
+			response = append(response, invitationResponse{
+				ID:        use.InvitationID,
+				Code:      use.Code,
+				CreatedAt: use.InvitationCreatedAt.Format(time.RFC3339),
+				UsedBy: &invitationUseResponse{
+					UserID:   use.UsedByUserID,
+					Username: use.UsedByUsername,
+					UsedAt:   use.UsedAt.Format(time.RFC3339),
+				},
+			})
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 
 	case http.MethodPost:
+		user, err := s.queries.GetUser(r.Context(), userID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+
+		if !user.IsAdmin {
+			now := time.Now()
+			active, err := s.queries.CountActiveInvitationsByUser(r.Context(), &userID, &now)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+				return
+			}
+			if active >= int64(s.invitationsPerUser) {
+				WriteError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "Invitation limit reached")
+				return
+			}
+		}
+
 		code, err := auth.GenerateInvitationCode()
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 			return
 		}
 
-		invitation, err := s.queries.CreateInvitationCode(r.Context(), code, &userID)
+		invitation, err := s.queries.CreateInvitationCode(r.Context(), code, &userID, nil)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 			return
 		}
 
+		s.auditLog(r, userID, auditActionInvitationCreate, "invitation", invitation.ID, nil)
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(invitationResponse{
 			ID:        invitation.ID,
@@ -499,7 +1095,7 @@ func (s *Server) handleInvitations(w http.ResponseWriter, r *http.Request) {
 		})
 
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 	}
 }
 
@@ -509,61 +1105,79 @@ type deleteInvitationRequest struct {
 
 func (s *Server) handleDeleteInvitation(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	var req deleteInvitationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteDecodeError(w, err)
 		return
 	}
 
 	if err := s.queries.DeleteInvitationById(r.Context(), req.ID, &userID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
+	s.auditLog(r, userID, auditActionInvitationDelete, "invitation", req.ID, nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
 
 func (s *Server) handleProfileImageUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	if s.fileUploadsDisabled {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "File uploads are disabled")
 		return
 	}
 
 	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "File too large"})
+		status := http.StatusBadRequest
+		if isBodyTooLarge(err) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		WriteFieldError(w, status, ErrCodeValidation, "File too large", "image")
 		return
 	}
 
 	file, _, err := r.FormFile("image")
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid file"})
+		WriteError(w, http.StatusBadRequest, ErrCodeValidation, "Invalid file")
 		return
 	}
 	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	fileData, err := io.ReadAll(file)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid image format"})
+		WriteError(w, http.StatusBadRequest, ErrCodeValidation, "Invalid file")
+		return
+	}
+
+	// Decoding drops all metadata, so the orientation has to be read up front.
+	// The WebP encoder below never writes EXIF data back.
+	orientation := exifOrientation(fileData)
+
+	img, _, err := image.Decode(bytes.NewReader(fileData))
+	if err != nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Invalid image format", "image")
 		return
 	}
 
@@ -587,49 +1201,59 @@ func (s *Server) handleProfileImageUpload(w http.ResponseWriter, r *http.Request
 		bounds.Min.Y+offsetY+size,
 	))
 
-	targetSize := 512
-	if size < targetSize {
-		targetSize = size
-	}
+	variantHashes := make(map[int]string, len(profileImageSizes))
+	for _, variantSize := range profileImageSizes {
+		targetSize := variantSize
+		if size < targetSize {
+			targetSize = size
+		}
 
-	resizedImg := resize.Resize(uint(targetSize), uint(targetSize), croppedImg, resize.Lanczos3)
+		resizedImg := resize.Resize(uint(targetSize), uint(targetSize), croppedImg, resize.Lanczos3)
 
-	var buf bytes.Buffer
-	if err := webp.Encode(&buf, resizedImg, &webp.Options{Lossless: false, Quality: 85}); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to process image"})
-		return
-	}
+		// Rotating the centered square crop is equivalent to cropping the rotated
+		// image, and much cheaper after resizing.
+		orientedImg := applyOrientation(resizedImg, orientation)
 
-	imageData := buf.Bytes()
-	hashStr, err := saveProfileImage(imageData)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save image"})
-		return
+		var buf bytes.Buffer
+		if err := webp.Encode(&buf, orientedImg, &webp.Options{Lossless: false, Quality: 85}); err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to process image")
+			return
+		}
+
+		hashStr, err := s.saveProfileImage(r.Context(), buf.Bytes(), fmt.Sprintf("-%d", variantSize))
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to save image")
+			return
+		}
+		variantHashes[variantSize] = hashStr
 	}
 
-	oldHashPtr, err := s.queries.GetOldUserProfileImageHash(r.Context(), userID)
+	hash512 := variantHashes[512]
+	hash128 := variantHashes[128]
+	hash32 := variantHashes[32]
+
+	oldHashes, err := s.queries.GetOldUserProfileImageHash(r.Context(), userID)
 	if err != nil && err != sql.ErrNoRows {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve old image"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve old image")
 		return
 	}
 
-	if err := s.queries.UpdateUserProfileImageHash(r.Context(), &hashStr, userID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update profile"})
+	if err := s.queries.UpdateUserProfileImageHash(r.Context(), &hash512, &hash32, &hash128, userID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update profile")
 		return
 	}
 
-	if oldHashPtr != nil && *oldHashPtr != hashStr {
+	for _, oldHashPtr := range []*string{oldHashes.ProfileImageHash, oldHashes.ProfileImageHash32, oldHashes.ProfileImageHash128} {
+		if oldHashPtr == nil || *oldHashPtr == hash512 || *oldHashPtr == hash128 || *oldHashPtr == hash32 {
+			continue
+		}
 		count, err := s.queries.CountProfileImageUsage(r.Context(), oldHashPtr)
 		if err == nil && count == 0 {
-			deleteProfileImage(*oldHashPtr)
+			deleteProfileImage(r.Context(), s.storage, *oldHashPtr)
 		}
 	}
 
-	profileImageURL := fmt.Sprintf("/api/profile/image/%s", hashStr)
+	profileImageURL := fmt.Sprintf("/api/profile/image/%s", hash512)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -640,41 +1264,92 @@ func (s *Server) handleProfileImageUpload(w http.ResponseWriter, r *http.Request
 
 func (s *Server) handleProfileImageServe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	hash := strings.TrimPrefix(r.URL.Path, "/api/profile/image/")
+	hash := r.PathValue("hash")
 	if hash == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 		return
 	}
 
-	imageData, err := loadProfileImage(hash)
+	user, err := s.queries.GetUserByProfileImageHash(r.Context(), &hash)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		if err == sql.ErrNoRows {
+			WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/webp")
+	variantHash := hash
+	switch r.URL.Query().Get("size") {
+	case "", "512":
+	case "128":
+		if user.ProfileImageHash128 != nil {
+			variantHash = *user.ProfileImageHash128
+		}
+	case "32":
+		if user.ProfileImageHash32 != nil {
+			variantHash = *user.ProfileImageHash32
+		}
+	default:
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	etag := `"` + variantHash + `"`
+	w.Header().Set("ETag", etag)
 	w.Header().Set("Cache-Control", "public, max-age=2592000")
+	w.Header().Set("Last-Modified", user.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	imageData, err := s.loadProfileImage(r.Context(), variantHash)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/webp")
 	w.Write(imageData)
 }
 
+// etagMatches reports whether an If-None-Match header value matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
 type chatSettingsResponse struct {
-	EnterSendsMessage bool `json:"enterSendsMessage"`
-	MarkdownEnabled   bool `json:"markdownEnabled"`
+	EnterSendsMessage bool   `json:"enterSendsMessage"`
+	MarkdownEnabled   bool   `json:"markdownEnabled"`
+	ConversationSort  string `json:"conversationSort"`
 }
 
 type updateChatSettingsRequest struct {
-	EnterSendsMessage *bool `json:"enterSendsMessage,omitempty"`
-	MarkdownEnabled   *bool `json:"markdownEnabled,omitempty"`
+	EnterSendsMessage *bool   `json:"enterSendsMessage,omitempty"`
+	MarkdownEnabled   *bool   `json:"markdownEnabled,omitempty"`
+	ConversationSort  *string `json:"conversationSort,omitempty"`
 }
 
 func (s *Server) handleChatSettings(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
@@ -687,10 +1362,11 @@ func (s *Server) handleChatSettings(w http.ResponseWriter, r *http.Request) {
 				json.NewEncoder(w).Encode(chatSettingsResponse{
 					EnterSendsMessage: false,
 					MarkdownEnabled:   true,
+					ConversationSort:  conversationSortLastActivity,
 				})
 				return
 			}
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 			return
 		}
 
@@ -698,21 +1374,29 @@ func (s *Server) handleChatSettings(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(chatSettingsResponse{
 			EnterSendsMessage: settings.EnterSendsMessage,
 			MarkdownEnabled:   settings.MarkdownEnabled,
+			ConversationSort:  settings.ConversationSort,
 		})
 
 	case http.MethodPost:
 		var req updateChatSettingsRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			WriteDecodeError(w, err)
+			return
+		}
+
+		if req.ConversationSort != nil && !validConversationSort(*req.ConversationSort) {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Sort must be last_activity, unread_count or name", "conversationSort")
 			return
 		}
 
 		currentSettings, err := s.queries.GetUserSettings(r.Context(), userID)
 		enterSendsMessage := false
 		markdownEnabled := true
+		conversationSort := conversationSortLastActivity
 		if err == nil {
 			enterSendsMessage = currentSettings.EnterSendsMessage
 			markdownEnabled = currentSettings.MarkdownEnabled
+			conversationSort = currentSettings.ConversationSort
 		}
 
 		if req.EnterSendsMessage != nil {
@@ -721,10 +1405,13 @@ func (s *Server) handleChatSettings(w http.ResponseWriter, r *http.Request) {
 		if req.MarkdownEnabled != nil {
 			markdownEnabled = *req.MarkdownEnabled
 		}
+		if req.ConversationSort != nil {
+			conversationSort = *req.ConversationSort
+		}
 
-		settings, err := s.queries.UpsertUserSettings(r.Context(), userID, enterSendsMessage, markdownEnabled)
+		settings, err := s.queries.UpsertUserSettings(r.Context(), userID, enterSendsMessage, markdownEnabled, conversationSort)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 			return
 		}
 
@@ -732,9 +1419,10 @@ func (s *Server) handleChatSettings(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(chatSettingsResponse{
 			EnterSendsMessage: settings.EnterSendsMessage,
 			MarkdownEnabled:   settings.MarkdownEnabled,
+			ConversationSort:  settings.ConversationSort,
 		})
 
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 	}
 }
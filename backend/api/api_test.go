@@ -0,0 +1,252 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/rtc"
+	"github.com/bloodmagesoftware/teamsync/storage"
+)
+
+func TestDevProxyPausedReturnsErrorResponse(t *testing.T) {
+	s := &Server{}
+	handler := s.handleDevProxy("http://127.0.0.1:1")
+	s.devProxyRetryAt.Store(time.Now().Add(time.Minute).UnixNano())
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	var body ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if body.Error.Code != ErrCodeInternal || body.Error.Message != "frontend dev server unreachable" {
+		t.Errorf("error = %+v", body.Error)
+	}
+}
+
+// newTestServer returns a server backed by a fresh database.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	queries, err := db.Init(filepath.Join(t.TempDir(), "teamsync.db"))
+	if err != nil {
+		t.Fatalf("db.Init: %v", err)
+	}
+	t.Cleanup(func() { queries.Close() })
+	return &Server{queries: queries, readQueries: queries}
+}
+
+func createTestInvitation(t *testing.T, s *Server, code string) {
+	t.Helper()
+	if _, err := s.queries.CreateInvitationCode(context.Background(), code, nil, nil); err != nil {
+		t.Fatalf("CreateInvitationCode: %v", err)
+	}
+}
+
+func register(s *Server, username, invitationCode string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(registerRequest{
+		Username:       username,
+		Password:       "correct horse battery staple",
+		InvitationCode: invitationCode,
+	})
+	rec := httptest.NewRecorder()
+	s.handleRegister(rec, httptest.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewReader(body)))
+	return rec
+}
+
+func TestRegisterNormalizesUsername(t *testing.T) {
+	s := newTestServer(t)
+	createTestInvitation(t, s, "first")
+
+	rec := register(s, "  Alice ", "first")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp authResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if resp.Username != "alice" {
+		t.Errorf("username = %q, want %q", resp.Username, "alice")
+	}
+}
+
+func TestRegisterRejectsDuplicateAfterNormalization(t *testing.T) {
+	s := newTestServer(t)
+	createTestInvitation(t, s, "first")
+	if rec := register(s, "alice", "first"); rec.Code != http.StatusOK {
+		t.Fatalf("first registration: status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	for _, username := range []string{"alice", "Alice", " ALICE\t"} {
+		createTestInvitation(t, s, "second")
+		rec := register(s, username, "second")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("register %q: status = %d, want %d", username, rec.Code, http.StatusBadRequest)
+		}
+		var body ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding body: %v", err)
+		}
+		if body.Error.Field != "username" || body.Error.Message != "Username already taken" {
+			t.Errorf("register %q: error = %+v", username, body.Error)
+		}
+		// The rejected registration must not consume the invitation.
+		if _, err := s.queries.GetInvitationByCode(context.Background(), "second"); err != nil {
+			t.Fatalf("invitation consumed by rejected registration: %v", err)
+		}
+		if err := s.queries.DeleteInvitationCode(context.Background(), "second"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoginMatchesUsernameRegardlessOfCase(t *testing.T) {
+	s := newTestServer(t)
+	createTestInvitation(t, s, "first")
+	if rec := register(s, "alice", "first"); rec.Code != http.StatusOK {
+		t.Fatalf("registration: status = %d, body %s", rec.Code, rec.Body)
+	}
+	// A second account that differs in case only cannot be created, not
+	// even past the check in handleRegister.
+	if _, err := s.queries.CreateUser(context.Background(), "Alice", "", "", false); err == nil {
+		t.Fatal("created a user that differs from an existing one in case only")
+	}
+
+	body, _ := json.Marshal(loginRequest{Username: "ALICE", Password: "correct horse battery staple"})
+	rec := httptest.NewRecorder()
+	s.handleLogin(rec, httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp authResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if resp.Username != "alice" {
+		t.Errorf("username = %q, want %q", resp.Username, "alice")
+	}
+}
+
+func TestServerListensOnConfiguredAddress(t *testing.T) {
+	queries := newTestServer(t).queries
+	s := New(queries, rtc.Config{}, Config{
+		ListenAddress: "127.0.0.1:0",
+		ReadTimeout:   3 * time.Second,
+		WriteTimeout:  4 * time.Second,
+		Storage:       storage.NewLocalBackend(t.TempDir()),
+	})
+	t.Cleanup(func() {
+		// Shutdown ends the event streams of the whole process.
+		evtMgr.mu.Lock()
+		evtMgr.shutdown = make(chan struct{})
+		evtMgr.mu.Unlock()
+	})
+
+	if s.httpServer.ReadTimeout != 3*time.Second || s.httpServer.WriteTimeout != 4*time.Second {
+		t.Errorf("timeouts = %s, %s, want 3s, 4s", s.httpServer.ReadTimeout, s.httpServer.WriteTimeout)
+	}
+	if s.httpServer.IdleTimeout != defaultIdleTimeout {
+		t.Errorf("idle timeout = %s, want the default %s", s.httpServer.IdleTimeout, defaultIdleTimeout)
+	}
+
+	started := make(chan error, 1)
+	go func() { started <- s.Start() }()
+
+	var addr string
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		addr = s.Addr()
+		if _, port, _ := net.SplitHostPort(addr); port != "0" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if host, _, _ := net.SplitHostPort(addr); host != "127.0.0.1" {
+		t.Errorf("listening on %s, want 127.0.0.1", addr)
+	}
+
+	resp, err := http.Get("http://" + addr + "/api/v1/health")
+	if err != nil {
+		t.Fatalf("GET health: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("health status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-started; err != nil {
+		t.Errorf("Start: %v", err)
+	}
+
+	// A second call, e.g. from a signal handler racing a failed Start, must
+	// not panic.
+	s.Shutdown(ctx)
+}
+
+func TestRouteVersion(t *testing.T) {
+	mux := http.NewServeMux()
+	routeVersion(mux, "/api/items/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"id": r.PathValue("id"), "method": r.Method})
+	}))
+
+	versioned := httptest.NewRecorder()
+	mux.ServeHTTP(versioned, httptest.NewRequest(http.MethodPost, "/api/v1/items/42", nil))
+	legacy := httptest.NewRecorder()
+	mux.ServeHTTP(legacy, httptest.NewRequest(http.MethodPost, "/api/items/42", nil))
+
+	if versioned.Code != http.StatusOK || legacy.Code != http.StatusOK {
+		t.Fatalf("status = %d (versioned), %d (legacy), want %d", versioned.Code, legacy.Code, http.StatusOK)
+	}
+	if versioned.Body.String() != legacy.Body.String() {
+		t.Errorf("bodies differ: %q (versioned), %q (legacy)", versioned.Body, legacy.Body)
+	}
+	if got := versioned.Header().Get("Deprecation"); got != "" {
+		t.Errorf("versioned route has Deprecation %q", got)
+	}
+	if got := legacy.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("legacy route has Deprecation %q, want %q", got, "true")
+	}
+	if got := legacy.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("legacy Content-Type = %q", got)
+	}
+}
+
+func TestLDAPLoginRejectsInvalidUsername(t *testing.T) {
+	ldapAuth, err := auth.NewLDAPAuthenticator(auth.LDAPConfig{Host: "127.0.0.1", Port: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	s.ldap = ldapAuth
+
+	// The directory is unreachable, so only a rejection before dialing
+	// returns errInvalidCredentials.
+	for _, username := range []string{"", "a", "admin)(uid=*", "name with spaces"} {
+		if _, err := s.ldapLogin(context.Background(), username, "secret"); !errors.Is(err, errInvalidCredentials) {
+			t.Errorf("ldapLogin(%q) = %v, want errInvalidCredentials", username, err)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	auditLogBufferSize     = 256
+	defaultAdminAuditLimit = 50
+	maxAdminAuditLimit     = 200
+	auditExportPageSize    = 500
+)
+
+const (
+	auditActionLogin              = "login"
+	auditActionLoginFailed        = "login_failed"
+	auditActionAccountDelete      = "account_delete"
+	auditActionDataExport         = "data_export"
+	auditActionInvitationCreate   = "invitation_create"
+	auditActionInvitationDelete   = "invitation_delete"
+	auditActionUserSuspend        = "user_suspend"
+	auditActionUserUnsuspend      = "user_unsuspend"
+	auditActionSessionsRevoke     = "sessions_revoke"
+	auditActionBotCreate          = "bot_create"
+	auditActionDatabaseBackup     = "database_backup"
+	auditActionConversationDelete = "conversation_delete"
+	auditActionCallTerminate      = "call_terminate"
+	auditActionMessageVerify      = "message_verify"
+)
+
+type auditEntry struct {
+	userID     *int64
+	action     string
+	targetType *string
+	targetID   *int64
+	ipAddress  *string
+	metadata   *string
+}
+
+type auditLogResponse struct {
+	ID         int64           `json:"id"`
+	UserID     *int64          `json:"userId"`
+	Username   *string         `json:"username"`
+	Action     string          `json:"action"`
+	TargetType *string         `json:"targetType"`
+	TargetID   *int64          `json:"targetId"`
+	IPAddress  *string         `json:"ipAddress"`
+	CreatedAt  string          `json:"createdAt"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+type auditLogPageResponse struct {
+	Entries []auditLogResponse `json:"entries"`
+	Page    int64              `json:"page"`
+	Limit   int64              `json:"limit"`
+	Total   int64              `json:"total"`
+}
+
+// auditLog queues an audit entry for the background writer. userID is the
+// acting user and may be zero for anonymous requests; targetType and targetID
+// are omitted when empty. The request never waits for the database, so an
+// entry is written to the server log instead when the queue is full.
+func (s *Server) auditLog(r *http.Request, userID int64, action, targetType string, targetID int64, metadata map[string]any) {
+	entry := auditEntry{action: action}
+
+	if userID != 0 {
+		entry.userID = &userID
+	}
+	if targetType != "" {
+		entry.targetType = &targetType
+		entry.targetID = &targetID
+	}
+	if ip := clientIP(r); ip != "" {
+		entry.ipAddress = &ip
+	}
+	if metadata != nil {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			log.Printf("failed to encode audit metadata for %s: %v", action, err)
+		} else {
+			str := string(data)
+			entry.metadata = &str
+		}
+	}
+
+	select {
+	case s.auditEntries <- entry:
+	default:
+		log.Printf("audit log queue full, dropping %s by user %d on %s %d", action, userID, targetType, targetID)
+	}
+}
+
+// writeAuditLog persists queued audit entries until stop is closed, then
+// drains the queue and closes done.
+func (s *Server) writeAuditLog(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case entry := <-s.auditEntries:
+			s.insertAuditEntry(entry)
+		case <-stop:
+			for {
+				select {
+				case entry := <-s.auditEntries:
+					s.insertAuditEntry(entry)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) insertAuditEntry(entry auditEntry) {
+	err := s.queries.CreateAuditLogEntry(context.Background(), entry.userID, entry.action, entry.targetType, entry.targetID, entry.ipAddress, entry.metadata)
+	if err != nil {
+		log.Printf("failed to write audit entry %s: %v", entry.action, err)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handleAdminAuditLog lists audit entries, newest first, filtered by user,
+// action and time range. With format=csv all matching entries are exported
+// instead of a single page. Audit entries cannot be deleted through the API.
+func (s *Server) handleAdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Format must be json or csv", "format")
+		return
+	}
+
+	page := int64(1)
+	if pageStr := query.Get("page"); pageStr != "" {
+		parsed, err := strconv.ParseInt(pageStr, 10, 64)
+		if err != nil || parsed < 1 {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Page must be a positive number", "page")
+			return
+		}
+		page = parsed
+	}
+
+	limit := int64(defaultAdminAuditLimit)
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed < 1 {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Limit must be a positive number", "limit")
+			return
+		}
+		limit = min(parsed, maxAdminAuditLimit)
+	}
+
+	// user is the older name of the userId parameter.
+	var userID *int64
+	userStr := query.Get("userId")
+	if userStr == "" {
+		userStr = query.Get("user")
+	}
+	if userStr != "" {
+		parsed, err := strconv.ParseInt(userStr, 10, 64)
+		if err != nil {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "User must be a user ID", "userId")
+			return
+		}
+		userID = &parsed
+	}
+
+	var action *string
+	if actionStr := query.Get("action"); actionStr != "" {
+		action = &actionStr
+	}
+
+	from, err := parseAuditTime(query.Get("from"))
+	if err != nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "From must be an RFC 3339 timestamp", "from")
+		return
+	}
+	to, err := parseAuditTime(query.Get("to"))
+	if err != nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "To must be an RFC 3339 timestamp", "to")
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit-log.csv"`)
+		if err := s.writeAuditLogCSV(w, r, userID, action, from, to); err != nil {
+			log.Printf("Failed to export audit log: %v", err)
+		}
+		return
+	}
+
+	total, err := s.queries.CountAuditLogEntries(r.Context(), userID, action, from, to)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	entries, err := s.queries.ListAuditLogEntries(r.Context(), userID, action, from, to, limit, (page-1)*limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := auditLogPageResponse{
+		Entries: make([]auditLogResponse, len(entries)),
+		Page:    page,
+		Limit:   limit,
+		Total:   total,
+	}
+
+	for i, entry := range entries {
+		var metadata json.RawMessage
+		if entry.Metadata != nil {
+			metadata = json.RawMessage(*entry.Metadata)
+		}
+
+		response.Entries[i] = auditLogResponse{
+			ID:         entry.ID,
+			UserID:     entry.UserID,
+			Username:   entry.Username,
+			Action:     entry.Action,
+			TargetType: entry.TargetType,
+			TargetID:   entry.TargetID,
+			IPAddress:  entry.IpAddress,
+			CreatedAt:  entry.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			Metadata:   metadata,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeAuditLogCSV writes all audit entries matching the filters, page by
+// page.
+func (s *Server) writeAuditLogCSV(w io.Writer, r *http.Request, userID *int64, action *string, from, to *time.Time) error {
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+
+	if err := writer.Write([]string{"id", "created_at", "user_id", "username", "action", "target_type", "target_id", "ip_address", "metadata"}); err != nil {
+		return err
+	}
+
+	optional := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return csvCell(*value)
+	}
+	optionalID := func(value *int64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatInt(*value, 10)
+	}
+
+	for offset := int64(0); ; offset += auditExportPageSize {
+		entries, err := s.queries.ListAuditLogEntries(r.Context(), userID, action, from, to, auditExportPageSize, offset)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			err := writer.Write([]string{
+				strconv.FormatInt(entry.ID, 10),
+				entry.CreatedAt.Format("2006-01-02T15:04:05Z"),
+				optionalID(entry.UserID),
+				optional(entry.Username),
+				csvCell(entry.Action),
+				optional(entry.TargetType),
+				optionalID(entry.TargetID),
+				optional(entry.IpAddress),
+				optional(entry.Metadata),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+
+		if int64(len(entries)) < auditExportPageSize {
+			return nil
+		}
+	}
+}
+
+// parseAuditTime parses an RFC 3339 filter value and converts it to UTC, the
+// time zone created_at is stored in, so that the timestamps compare correctly.
+func parseAuditTime(value string) (*time.Time, error) {
+	t, err := parseSearchTime(value)
+	if t != nil {
+		utc := t.UTC()
+		t = &utc
+	}
+	return t, err
+}
+
+// csvCell prefixes values that spreadsheet applications would evaluate as a
+// formula with a single quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// handleAdminAuditActions lists the distinct actions found in the audit log.
+func (s *Server) handleAdminAuditActions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	actions, err := s.queries.ListAuditLogActions(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	if actions == nil {
+		actions = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actions)
+}
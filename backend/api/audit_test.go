@@ -0,0 +1,44 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":                    "",
+		"alice":               "alice",
+		"=HYPERLINK(\"x\")":   "'=HYPERLINK(\"x\")",
+		"+1":                  "'+1",
+		"-1":                  "'-1",
+		"@SUM(A1)":            "'@SUM(A1)",
+		"\tcmd":               "'\tcmd",
+		"user=admin":          "user=admin",
+		"{\"reason\":\"=1\"}": "{\"reason\":\"=1\"}",
+	}
+	for value, want := range tests {
+		if got := csvCell(value); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestParseAuditTimeConvertsToUTC(t *testing.T) {
+	got, err := parseAuditTime("2025-03-01T12:00:00+02:00")
+	if err != nil {
+		t.Fatalf("parseAuditTime: %v", err)
+	}
+	if got.Location() != time.UTC {
+		t.Errorf("location = %v, want UTC", got.Location())
+	}
+	if want := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC); !got.Equal(want) || got.Hour() != 10 {
+		t.Errorf("time = %v, want %v", got, want)
+	}
+
+	got, err = parseAuditTime("")
+	if err != nil || got != nil {
+		t.Errorf("parseAuditTime(\"\") = %v, %v, want nil, nil", got, err)
+	}
+}
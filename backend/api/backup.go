@@ -0,0 +1,93 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const backupInterval = 5 * time.Minute
+
+var (
+	backupMutex  sync.Mutex
+	lastBackupAt time.Time
+)
+
+func (s *Server) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if secret := os.Getenv("BACKUP_SECRET"); secret != "" {
+		provided := r.Header.Get("X-Backup-Secret")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+			return
+		}
+	}
+
+	backupMutex.Lock()
+	if wait := backupInterval - time.Since(lastBackupAt); wait > 0 {
+		backupMutex.Unlock()
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		WriteError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many requests")
+		return
+	}
+	lastBackupAt = time.Now()
+	backupMutex.Unlock()
+
+	if err := os.MkdirAll("./data", 0755); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	tmpFile, err := os.CreateTemp("./data", "backup-*.db")
+	if err != nil {
+		log.Printf("failed to create backup file: %v", err)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpPath)
+
+	if err := s.readQueries.Backup(r.Context(), tmpPath); err != nil {
+		log.Printf("database backup failed: %v", err)
+		backupMutex.Lock()
+		lastBackupAt = time.Time{}
+		backupMutex.Unlock()
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	adminID, _ := auth.GetUserID(r.Context())
+	s.auditLog(r, adminID, auditActionDatabaseBackup, "", 0, nil)
+
+	backupFile, err := os.Open(tmpPath)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer backupFile.Close()
+
+	filename := fmt.Sprintf("teamsync-%s.db", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	if info, err := backupFile.Stat(); err == nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+
+	if _, err := io.Copy(w, backupFile); err != nil {
+		log.Printf("failed to stream backup: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+func (s *Server) handleBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	otherUserID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if otherUserID == userID {
+			WriteError(w, http.StatusBadRequest, ErrCodeValidation, "Cannot block yourself")
+			return
+		}
+
+		if _, err := s.queries.GetUser(r.Context(), otherUserID); err != nil {
+			WriteError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+			return
+		}
+
+		if err := s.queries.BlockUser(r.Context(), userID, otherUserID); err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to block user")
+			return
+		}
+
+	case http.MethodDelete:
+		if err := s.queries.UnblockUser(r.Context(), userID, otherUserID); err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to unblock user")
+			return
+		}
+
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func (s *Server) handleListBlocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	blockedIDs, err := s.queries.ListBlockedUserIDs(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if blockedIDs == nil {
+		blockedIDs = []int64{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blockedIDs)
+}
+
+// blockRelatedUsers returns the users that blocked userID or were blocked by
+// them. Blocks hide users from each other in both directions.
+func (s *Server) blockRelatedUsers(userID int64) map[int64]bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids, err := s.queries.ListBlockRelatedUserIDs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load blocks of user %d: %v", userID, err)
+		return nil
+	}
+
+	related := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		related[id] = true
+	}
+	return related
+}
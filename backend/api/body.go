@@ -0,0 +1,38 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+const (
+	defaultMaxJSONBodySize   = 64 << 10
+	defaultMaxUploadBodySize = 25 << 20
+)
+
+// MaxBodyMiddleware limits request bodies to bytes. Reading past the limit
+// fails with *http.MaxBytesError, which WriteDecodeError reports as 413.
+func MaxBodyMiddleware(bytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, bytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteDecodeError reports a request body that could not be decoded. Bodies
+// cut off by MaxBodyMiddleware are answered with 413.
+func WriteDecodeError(w http.ResponseWriter, err error) {
+	if isBodyTooLarge(err) {
+		WriteError(w, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, "Request body too large")
+		return
+	}
+	WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+}
+
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,277 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+const (
+	maxBookmarksPerUser     = 500
+	maxBookmarkNoteLength   = 1000
+	defaultBookmarkPageSize = 20
+	maxBookmarkPageSize     = 100
+)
+
+// bookmarkResponse is a bookmarked message together with the name of its
+// conversation. Deleted messages stay bookmarked; their body is empty.
+type bookmarkResponse struct {
+	messageResponse
+	ConversationName string       `json:"conversationName"`
+	Deleted          bool         `json:"deleted,omitempty"`
+	Bookmark         bookmarkInfo `json:"bookmark"`
+}
+
+type bookmarkInfo struct {
+	Note      *string `json:"note"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// bookmarkPage is the paginated response of GET /api/bookmarks. NextCursor is
+// the cursor of the next page.
+type bookmarkPage struct {
+	Bookmarks  []bookmarkResponse `json:"bookmarks"`
+	NextCursor *int64             `json:"nextCursor"`
+	HasMore    bool               `json:"hasMore"`
+}
+
+type createBookmarkRequest struct {
+	MessageID int64  `json:"messageId"`
+	Note      string `json:"note"`
+}
+
+// handleBookmarks lists the bookmarks of the current user, newest first, or
+// bookmarks a message. Bookmarking a message again replaces its note.
+func (s *Server) handleBookmarks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListBookmarks(w, r)
+	case http.MethodPost:
+		s.handleCreateBookmark(w, r)
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) handleListBookmarks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := int64(defaultBookmarkPageSize)
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxBookmarkPageSize {
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Limit must be between 1 and 100")
+			return
+		}
+		limit = parsedLimit
+	}
+
+	var cursor *int64
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		parsedCursor, err := strconv.ParseInt(cursorStr, 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid cursor")
+			return
+		}
+		cursor = &parsedCursor
+	}
+
+	// One more row than requested tells whether there is another page.
+	rows, err := s.queries.GetUserBookmarks(r.Context(), userID, cursor, limit+1)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	hasMore := int64(len(rows)) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	bookmarks := make([]bookmarkResponse, len(rows))
+	messages := make([]messageResponse, len(rows))
+	for i, row := range rows {
+		bookmarks[i] = s.convertToBookmarkResponse(row)
+		messages[i] = bookmarks[i].messageResponse
+	}
+	if err := s.attachThreadSummaries(r.Context(), userID, messages); err != nil {
+		log.Printf("Failed to attach thread summaries: %v", err)
+	}
+	for i := range bookmarks {
+		bookmarks[i].messageResponse = messages[i]
+	}
+
+	page := bookmarkPage{Bookmarks: bookmarks, HasMore: hasMore}
+	if hasMore {
+		page.NextCursor = &rows[len(rows)-1].BookmarkID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (s *Server) handleCreateBookmark(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	var req createBookmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	var note *string
+	if trimmed := strings.TrimSpace(req.Note); trimmed != "" {
+		if utf8.RuneCountInString(trimmed) > maxBookmarkNoteLength {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Note must be at most 1000 characters", "note")
+			return
+		}
+		note = &trimmed
+	}
+
+	// Deleted messages can be bookmarked as well.
+	message, err := s.queries.GetMessageByID(r.Context(), req.MessageID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Message not found")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), message.ConversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	existing, err := tx.IsBookmarked(r.Context(), userID, message.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if existing == 0 {
+		count, err := tx.CountUserBookmarks(r.Context(), userID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+		if count >= maxBookmarksPerUser {
+			WriteError(w, http.StatusConflict, ErrCodeConflict, "At most 500 messages can be bookmarked")
+			return
+		}
+	}
+
+	if err := tx.UpsertBookmark(r.Context(), userID, message.ID, note); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to bookmark message")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to bookmark message")
+		return
+	}
+
+	row, err := s.queries.GetUserBookmark(r.Context(), userID, message.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	bookmark := s.convertToBookmarkResponse(row)
+	messages := []messageResponse{bookmark.messageResponse}
+	if err := s.attachThreadSummaries(r.Context(), userID, messages); err != nil {
+		log.Printf("Failed to attach thread summaries: %v", err)
+	}
+	bookmark.messageResponse = messages[0]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookmark)
+}
+
+// handleDeleteBookmark removes the bookmark of the current user on a message.
+func (s *Server) handleDeleteBookmark(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	messageID, err := strconv.ParseInt(r.PathValue("messageId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid message ID")
+		return
+	}
+
+	deleted, err := s.queries.DeleteBookmark(r.Context(), userID, messageID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	if deleted == 0 {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Bookmark not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func (s *Server) convertToBookmarkResponse(row db.GetUserBookmarkRow) bookmarkResponse {
+	body := row.Body
+	if row.DeletedAt != nil {
+		body = ""
+	}
+
+	return bookmarkResponse{
+		messageResponse: s.convertToMessageResponse(row.ID, row.ConversationID, row.Seq, row.SenderID,
+			row.SenderUsername, row.SenderProfileImageHash, row.CreatedAt, row.EditedAt,
+			row.ContentType, body, row.ReplyToID, row.ReactionsJson, row.UserReactionsJson),
+		ConversationName: row.ConversationName,
+		Deleted:          row.DeletedAt != nil,
+		Bookmark: bookmarkInfo{
+			Note:      row.BookmarkNote,
+			CreatedAt: row.BookmarkedAt.Format(time.RFC3339),
+		},
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+type createBotRequest struct {
+	Username string `json:"username"`
+}
+
+type createBotResponse struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	APIKey   string `json:"apiKey"`
+}
+
+func (s *Server) handleAdminCreateBot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req createBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	username, err := auth.NormalizeUsername(req.Username)
+	if err != nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, err.Error(), "username")
+		return
+	}
+
+	// Accounts created before usernames were normalized may differ from
+	// the new username in case only.
+	if _, err := s.queries.GetUserByUsername(r.Context(), username); err == nil {
+		WriteFieldError(w, http.StatusConflict, ErrCodeConflict, "Username already taken", "username")
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
+		return
+	}
+
+	apiKey, err := auth.GenerateToken()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Server error")
+		return
+	}
+
+	// Only the hash is stored, like a password.
+	apiKeyHash := auth.HashAPIKey(apiKey)
+	bot, err := s.queries.CreateBotUser(r.Context(), username, &apiKeyHash)
+	if err != nil {
+		WriteFieldError(w, http.StatusConflict, ErrCodeConflict, "Username already taken", "username")
+		return
+	}
+
+	if _, err := s.queries.CreateUserSettings(r.Context(), bot.ID, false, true); err != nil {
+		log.Printf("warning: failed to create user settings for bot %d: %v", bot.ID, err)
+	}
+
+	adminID, _ := auth.GetUserID(r.Context())
+	s.auditLog(r, adminID, auditActionBotCreate, "user", bot.ID, map[string]any{"username": bot.Username})
+
+	// The key is only ever shown in this response.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createBotResponse{
+		ID:       bot.ID,
+		Username: bot.Username,
+		APIKey:   apiKey,
+	})
+}
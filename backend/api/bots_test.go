@@ -0,0 +1,89 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+func createBot(s *Server, username string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(createBotRequest{Username: username})
+	rec := httptest.NewRecorder()
+	s.handleAdminCreateBot(rec, httptest.NewRequest(http.MethodPost, "/api/admin/bots", bytes.NewReader(body)))
+	return rec
+}
+
+func TestAdminCreateBotNormalizesUsername(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := createBot(s, " Deploy-Bot ")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp createBotResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if resp.Username != "deploy-bot" {
+		t.Errorf("username = %q, want %q", resp.Username, "deploy-bot")
+	}
+
+	tests := []struct {
+		username string
+		status   int
+	}{
+		{"DEPLOY-BOT", http.StatusConflict},
+		{"deploy-bot\n", http.StatusConflict},
+		{"deploy bot", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := createBot(s, tt.username)
+		if rec.Code != tt.status {
+			t.Errorf("create bot %q: status = %d, want %d", tt.username, rec.Code, tt.status)
+			continue
+		}
+		var body ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding body: %v", err)
+		}
+		if body.Error.Field != "username" {
+			t.Errorf("create bot %q: error = %+v, want a username field error", tt.username, body.Error)
+		}
+	}
+}
+
+func TestAdminCreateBotStoresKeyHash(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := createBot(s, "deploy-bot")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp createBotResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if resp.APIKey == "" {
+		t.Fatal("response lacks the API key")
+	}
+
+	bot, err := s.queries.GetUser(context.Background(), resp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bot.ApiKey == nil || *bot.ApiKey == resp.APIKey || *bot.ApiKey != auth.HashAPIKey(resp.APIKey) {
+		t.Errorf("stored key = %v, want the hash of the returned key", bot.ApiKey)
+	}
+
+	hash := auth.HashAPIKey(resp.APIKey)
+	if user, err := s.queries.GetUserByAPIKey(context.Background(), &hash); err != nil || user.ID != resp.ID {
+		t.Errorf("bot not found by key hash: %v", err)
+	}
+}
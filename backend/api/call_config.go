@@ -7,22 +7,42 @@ import (
 	"net"
 	"net/http"
 	"strings"
+
+	"github.com/bloodmagesoftware/teamsync/rtc"
 )
 
 type callICEConfig struct {
 	Urls []string `json:"urls"`
 }
 
+// callPortRange is the inclusive range of relay ports, for firewall rules.
+type callPortRange struct {
+	Min uint16 `json:"min"`
+	Max uint16 `json:"max"`
+}
+
 type callConfigResponse struct {
 	ICEServers     []callICEConfig `json:"iceServers"`
 	UsernamePrefix string          `json:"usernamePrefix"`
 	Realm          string          `json:"realm"`
 	RelayAddress   string          `json:"relayAddress"`
 	Port           string          `json:"port"`
+	// PortRange is only set when relay ports are limited to a range.
+	PortRange *callPortRange `json:"portRange,omitempty"`
+}
+
+// SetTURNConfig replaces the TURN configuration handed to clients, e.g.
+// after the TURN server was reloaded with a new relay address.
+func (s *Server) SetTURNConfig(cfg rtc.Config) {
+	s.turnConfigMutex.Lock()
+	defer s.turnConfigMutex.Unlock()
+	s.turnConfig = cfg
 }
 
 func (s *Server) handleCallConfig(w http.ResponseWriter, r *http.Request) {
+	s.turnConfigMutex.RLock()
 	config := s.turnConfig
+	s.turnConfigMutex.RUnlock()
 
 	host := hostFromRequest(r)
 	if ip := config.RelayAddress; ip != nil {
@@ -40,17 +60,23 @@ func (s *Server) handleCallConfig(w http.ResponseWriter, r *http.Request) {
 	turnUDPURL := "turn:" + formattedHost + ":" + port + "?transport=udp"
 	turnTCPURL := "turn:" + formattedHost + ":" + port + "?transport=tcp"
 
+	iceServers := []callICEConfig{{Urls: []string{stunURL}}}
+	if config.Mode != rtc.ModeSTUNOnly {
+		iceServers = append(iceServers, callICEConfig{Urls: []string{turnUDPURL, turnTCPURL}})
+	}
+
 	response := callConfigResponse{
-		ICEServers: []callICEConfig{
-			{Urls: []string{stunURL}},
-			{Urls: []string{turnUDPURL, turnTCPURL}},
-		},
+		ICEServers:     iceServers,
 		UsernamePrefix: config.UsernamePrefix,
 		Realm:          config.Realm,
 		RelayAddress:   host,
 		Port:           port,
 	}
 
+	if config.Mode != rtc.ModeSTUNOnly && config.RelayPortMin != 0 {
+		response.PortRange = &callPortRange{Min: config.RelayPortMin, Max: config.RelayPortMax}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
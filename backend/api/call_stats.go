@@ -0,0 +1,117 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+// maxCallStatsSize limits a single stats report. Browser getStats() reports
+// filtered to the ICE transport are a few kilobytes at most.
+const maxCallStatsSize = 64 << 10
+
+type callStatsEntry struct {
+	ID          int64           `json:"id"`
+	UserID      int64           `json:"userId"`
+	CollectedAt string          `json:"collectedAt"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+func (s *Server) handleCallStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	callID, err := strconv.ParseInt(r.PathValue("callId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCallStatsSize))
+	if err != nil {
+		WriteError(w, http.StatusRequestEntityTooLarge, ErrCodeInvalidInput, "Stats report too large")
+		return
+	}
+
+	if !json.Valid(payload) {
+		WriteError(w, http.StatusBadRequest, ErrCodeValidation, "Stats report must be valid JSON")
+		return
+	}
+
+	conversationID, err := s.queries.GetCallConversationID(r.Context(), callID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if err := s.queries.CreateCallStats(r.Context(), callID, userID, string(payload)); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func (s *Server) handleAdminCallStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	callID, err := strconv.ParseInt(r.PathValue("callId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	stats, err := s.queries.ListCallStats(r.Context(), callID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := make([]callStatsEntry, len(stats))
+	for i, stat := range stats {
+		response[i] = callStatsEntry{
+			ID:          stat.ID,
+			UserID:      stat.UserID,
+			CollectedAt: stat.CollectedAt.Format("2006-01-02T15:04:05Z"),
+			Payload:     json.RawMessage(stat.Payload),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -5,13 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/messaging"
 	"github.com/gorilla/websocket"
 )
 
@@ -28,60 +32,83 @@ type callStatusResponse struct {
 	Active bool `json:"active"`
 }
 
+type callHistoryEntry struct {
+	CallID           int64  `json:"callId"`
+	StartedAt        string `json:"startedAt"`
+	EndedAt          string `json:"endedAt"`
+	DurationSeconds  int64  `json:"durationSeconds"`
+	InitiatorID      *int64 `json:"initiatorId"`
+	ParticipantCount int64  `json:"participantCount"`
+}
+
+type callHistoryResponse struct {
+	Calls      []callHistoryEntry `json:"calls"`
+	NextCursor *string            `json:"nextCursor"`
+}
+
+// callSignalMessage is relayed between the peers of a call. From is filled in
+// by the server; a non-zero To addresses a single peer, which is required for
+// pairwise offer/answer exchange in group calls.
 type callSignalMessage struct {
 	Type    string          `json:"type"`
+	From    int64           `json:"from,omitempty"`
+	To      int64           `json:"to,omitempty"`
 	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
+type callPeer struct {
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+}
+
 type callConnection struct {
-	userID int64
-	conn   *websocket.Conn
-	send   chan callSignalMessage
+	userID   int64
+	username string
+	conn     *websocket.Conn
+	send     chan callSignalMessage
 }
 
 var (
-	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
-	}
 	callConnections = make(map[int64][]*callConnection)
-	callMutex       sync.RWMutex
+	// callHandlers counts the running signaling handlers, which outlive
+	// httpServer.Shutdown because their connections are hijacked.
+	callHandlers sync.WaitGroup
+	// answeredCalls holds the calls a participant other than the initiator
+	// has connected to. Guarded by callMutex.
+	answeredCalls = make(map[int64]bool)
+	callMutex     sync.RWMutex
+
+	// missedCallTimeout is how long a call may ring before it is ended as missed.
+	missedCallTimeout = 30 * time.Second
 )
 
 func (s *Server) handleStartCall(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	var req startCallRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteDecodeError(w, err)
 		return
 	}
 
 	conv, err := s.queries.GetConversationByID(r.Context(), req.ConversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	if conv.Type != "dm" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Calls are only supported in DMs"})
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
 		return
 	}
 
 	participants, err := s.queries.GetConversationParticipants(r.Context(), req.ConversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -94,62 +121,72 @@ func (s *Server) handleStartCall(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !isParticipant {
-		w.WriteHeader(http.StatusForbidden)
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if conv.Type != "dm" && s.groupCallsDisabled {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Group calls are disabled")
 		return
 	}
 
 	activeCall, err := s.queries.GetActiveCallByConversation(r.Context(), req.ConversationID)
 	if err == nil && activeCall.ID != 0 {
 		log.Printf("Call already active in conversation %d: call ID %d, message ID %d", req.ConversationID, activeCall.ID, activeCall.MessageID)
-		w.WriteHeader(http.StatusConflict)
-		json.NewEncoder(w).Encode(map[string]string{"error": "A call is already active", "messageId": strconv.FormatInt(activeCall.MessageID, 10)})
+		writeErrorResponse(w, http.StatusConflict, struct {
+			ErrorResponse
+			MessageID string `json:"messageId"`
+		}{
+			ErrorResponse: ErrorResponse{Error: ErrorDetail{Code: ErrCodeConflict, Message: "A call is already active"}},
+			MessageID:     strconv.FormatInt(activeCall.MessageID, 10),
+		})
 		return
 	}
 
 	tx, err := s.queries.Begin()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 	defer tx.Rollback()
 
 	if err := tx.UpdateConversationSeq(r.Context(), req.ConversationID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	conv, err = tx.GetConversationByID(r.Context(), req.ConversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
-	message, err := tx.CreateMessage(r.Context(), req.ConversationID, conv.LastMessageSeq, userID, "application/call", "", nil)
+	message, err := tx.CreateMessage(r.Context(), req.ConversationID, conv.LastMessageSeq, userID, messaging.ContentTypeCall, "", nil, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
-	call, err := tx.CreateCall(r.Context(), req.ConversationID, message.ID)
+	call, err := tx.CreateCall(r.Context(), req.ConversationID, message.ID, &userID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	if err := tx.Commit(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	sender, err := s.queries.GetUser(r.Context(), userID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	var profileImageURL *string
 	if sender.ProfileImageHash != nil {
-		url := "/api/profile/image/" + *sender.ProfileImageHash
+		url := "/api/profile/image/" + *sender.ProfileImageHash + "?size=128"
 		profileImageURL = &url
 	}
 
@@ -167,6 +204,8 @@ func (s *Server) handleStartCall(w http.ResponseWriter, r *http.Request) {
 
 	go s.BroadcastMessageToConversation(req.ConversationID, msgResp)
 
+	s.scheduleMissedCall(call.ID, req.ConversationID, userID)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(startCallResponse{
 		CallID:    call.ID,
@@ -174,6 +213,150 @@ func (s *Server) handleStartCall(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// scheduleMissedCall expires the call after missedCallTimeout unless somebody
+// answered it by then.
+func (s *Server) scheduleMissedCall(callID, conversationID, initiatorID int64) *time.Timer {
+	return time.AfterFunc(missedCallTimeout, func() {
+		s.expireUnansweredCall(callID, conversationID, initiatorID)
+	})
+}
+
+// expireUnansweredCall ends a call nobody but the initiator joined and tells
+// the other participants they missed it.
+func (s *Server) expireUnansweredCall(callID, conversationID, initiatorID int64) {
+	callMutex.RLock()
+	answered := answeredCalls[callID]
+	callMutex.RUnlock()
+	if answered {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.queries.GetCallByID(ctx, callID); err != nil {
+		// already ended
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(ctx, conversationID)
+	if err != nil {
+		log.Printf("error loading participants for missed call %d: %v", callID, err)
+		return
+	}
+
+	for _, p := range participants {
+		if p.ID == initiatorID {
+			continue
+		}
+		evtMgr.broadcast(p.ID, Event{
+			Type: EventTypeCallMissed,
+			Data: map[string]int64{
+				"callId":         callID,
+				"conversationId": conversationID,
+				"initiatorId":    initiatorID,
+			},
+		})
+	}
+
+	log.Printf("Call %d was not answered within %s", callID, missedCallTimeout)
+	s.finishCall(callID)
+	closeCallConnections(callID)
+}
+
+func (s *Server) handleRejectCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	callID, err := strconv.ParseInt(r.PathValue("callId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	call, err := s.queries.GetCallByID(r.Context(), callID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), call.ConversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if call.InitiatorID != nil && *call.InitiatorID == userID {
+		WriteError(w, http.StatusBadRequest, ErrCodeValidation, "Cannot reject your own call")
+		return
+	}
+
+	if call.InitiatorID != nil {
+		evtMgr.broadcast(*call.InitiatorID, Event{
+			Type: EventTypeCallRejected,
+			Data: map[string]int64{
+				"callId":     call.ID,
+				"rejectedBy": userID,
+			},
+		})
+	}
+
+	s.finishCall(call.ID)
+	closeCallConnections(call.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// checkOrigin allows WebSocket upgrades from the server's own origin, from
+// PublicURL and from the configured CORS origins. Clients that send no Origin
+// header are not browsers and are allowed.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return s.corsOrigins[normalizeOrigin(origin)]
+}
+
+// normalizeOrigin reduces a URL to its lower-case scheme and host.
+func normalizeOrigin(origin string) string {
+	u, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || u.Host == "" {
+		return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
 func (s *Server) handleCallSignaling(w http.ResponseWriter, r *http.Request) {
 	var accessToken string
 
@@ -190,18 +373,18 @@ func (s *Server) handleCallSignaling(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if accessToken == "" {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	token, err := s.queries.GetTokenByAccessToken(r.Context(), accessToken)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	if time.Now().After(token.AccessTokenExpiresAt) {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
@@ -209,41 +392,49 @@ func (s *Server) handleCallSignaling(w http.ResponseWriter, r *http.Request) {
 
 	messageIDStr := r.URL.Query().Get("messageId")
 	if messageIDStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 		return
 	}
 
 	messageID, err := strconv.ParseInt(messageIDStr, 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 		return
 	}
 
 	call, err := s.queries.GetCallByMessageID(r.Context(), messageID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
 		return
 	}
 
 	participants, err := s.queries.GetConversationParticipants(r.Context(), call.ConversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	isParticipant := false
+	var username string
 	for _, p := range participants {
 		if p.ID == userID {
 			isParticipant = true
+			username = p.Username
 			break
 		}
 	}
 
 	if !isParticipant {
-		w.WriteHeader(http.StatusForbidden)
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
 		return
 	}
 
+	// Registered before the upgrade, while httpServer.Shutdown still waits
+	// for this handler, so that shutdownCalls sees every connection.
+	callHandlers.Add(1)
+	defer callHandlers.Done()
+
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkOrigin}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("websocket upgrade error: %v", err)
@@ -251,28 +442,33 @@ func (s *Server) handleCallSignaling(w http.ResponseWriter, r *http.Request) {
 	}
 
 	callConn := &callConnection{
-		userID: userID,
-		conn:   conn,
-		send:   make(chan callSignalMessage, 256),
+		userID:   userID,
+		username: username,
+		conn:     conn,
+		send:     make(chan callSignalMessage, 256),
 	}
 
 	callMutex.Lock()
-	callConnections[call.ID] = append(callConnections[call.ID], callConn)
-	connections := callConnections[call.ID]
-	log.Printf("User %d connected to call %d. Total connections: %d", userID, call.ID, len(connections))
-
-	if len(connections) == 2 {
-		for _, conn := range connections {
-			select {
-			case conn.send <- callSignalMessage{Type: "peer-joined"}:
-				log.Printf("Sent peer-joined to user %d", conn.userID)
-			default:
-				log.Printf("Failed to send peer-joined to user %d", conn.userID)
-			}
-		}
+	// The call may have ended since it was looked up; ending happens while
+	// callMutex is held, so this check cannot race with it.
+	if _, err := s.queries.GetCallByID(context.Background(), call.ID); err != nil {
+		callMutex.Unlock()
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "call ended"), time.Now().Add(time.Second))
+		conn.Close()
+		return
 	}
+	connectionCount := addCallConnection(call.ID, callConn)
+	if call.InitiatorID == nil || *call.InitiatorID != userID {
+		answeredCalls[call.ID] = true
+	}
+	log.Printf("User %d connected to call %d. Total connections: %d", userID, call.ID, connectionCount)
 	callMutex.Unlock()
 
+	if err := s.queries.UpdateCallParticipantCount(context.Background(), int64(connectionCount), call.ID); err != nil {
+		log.Printf("error updating participant count for call %d: %v", call.ID, err)
+	}
+
 	go s.writePump(callConn)
 
 	s.readPump(call.ID, callConn)
@@ -283,100 +479,261 @@ func (s *Server) readPump(callID int64, c *callConnection) {
 		c.conn.Close()
 
 		callMutex.Lock()
-		connections := callConnections[callID]
-		var otherConnections []*callConnection
-		for _, conn := range connections {
-			if conn != c {
-				otherConnections = append(otherConnections, conn)
-			}
+		remaining, found := removeCallConnection(callID, c)
+		if !found {
+			// An administrator terminated the call and already cleaned up.
+			callMutex.Unlock()
+			return
 		}
-		delete(callConnections, callID)
-		callMutex.Unlock()
-
-		log.Printf("User %d disconnected from call %d. Closing %d other connection(s)", c.userID, callID, len(otherConnections))
 
-		for _, conn := range otherConnections {
-			close(conn.send)
-			conn.conn.Close()
-			log.Printf("Closed connection for user %d", conn.userID)
+		// The last participant ends the call before the lock is released, so
+		// nobody can join a call that is about to end.
+		var endedMessageID int64
+		if remaining == 0 {
+			endedMessageID = s.commitEndCall(callID)
 		}
+		callMutex.Unlock()
 
-		ctx := context.Background()
+		log.Printf("User %d disconnected from call %d. Remaining connections: %d", c.userID, callID, remaining)
 
-		callInfo, err := s.queries.GetCallByID(ctx, callID)
-		if err != nil {
-			if endErr := s.queries.EndCall(ctx, callID); endErr != nil {
-				log.Printf("error ending call: %v", endErr)
-			}
-			return
+		if endedMessageID != 0 {
+			s.broadcastCallMessage(context.Background(), endedMessageID)
 		}
+	}()
 
-		if err := s.queries.EndCall(ctx, callID); err != nil {
-			log.Printf("error ending call: %v", err)
-			return
+	for {
+		var msg callSignalMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			log.Printf("Read error from user %d: %v", c.userID, err)
+			break
 		}
 
-		message, err := s.queries.GetMessageByID(ctx, callInfo.MessageID)
-		if err != nil {
-			log.Printf("error loading call message %d: %v", callInfo.MessageID, err)
-			return
+		log.Printf("Received %s from user %d in call %d", msg.Type, c.userID, callID)
+
+		msg.From = c.userID
+		forwardCallSignal(callID, msg)
+	}
+}
+
+// addCallConnection registers c with the call and returns the number of
+// connections. The newcomer learns who is already in the call and initiates
+// one offer per existing peer; everybody else is told who joined. callMutex
+// must be held.
+func addCallConnection(callID int64, c *callConnection) int {
+	existing := callConnections[callID]
+
+	peers := make([]callPeer, 0, len(existing))
+	joinedPayload, _ := json.Marshal(callPeer{UserID: c.userID, Username: c.username})
+	for _, other := range existing {
+		peers = append(peers, callPeer{UserID: other.userID, Username: other.username})
+		select {
+		case other.send <- callSignalMessage{Type: "peer-joined", From: c.userID, Payload: joinedPayload}:
+			log.Printf("Sent peer-joined to user %d", other.userID)
+		default:
+			log.Printf("Failed to send peer-joined to user %d", other.userID)
 		}
+	}
+	peersPayload, _ := json.Marshal(peers)
+	c.send <- callSignalMessage{Type: "participants", Payload: peersPayload}
 
-		if err := s.queries.UpdateMessage(ctx, message.Body, message.ID, message.SenderID); err != nil {
-			log.Printf("error marking call message %d as edited: %v", message.ID, err)
-			return
+	callConnections[callID] = append(existing, c)
+	return len(callConnections[callID])
+}
+
+// removeCallConnection unregisters c, closes its send channel and tells the
+// remaining peers that it left. It returns the number of remaining
+// connections and reports false if c was no longer registered. callMutex must
+// be held.
+func removeCallConnection(callID int64, c *callConnection) (int, bool) {
+	var remaining []*callConnection
+	found := false
+	for _, conn := range callConnections[callID] {
+		if conn != c {
+			remaining = append(remaining, conn)
+		} else {
+			found = true
 		}
+	}
+	if !found {
+		return 0, false
+	}
+	if len(remaining) > 0 {
+		callConnections[callID] = remaining
+	} else {
+		delete(callConnections, callID)
+		delete(answeredCalls, callID)
+	}
+	close(c.send)
 
-		updatedMessage, err := s.queries.GetMessageByID(ctx, callInfo.MessageID)
-		if err != nil {
-			log.Printf("error reloading updated call message %d: %v", callInfo.MessageID, err)
-			return
+	leftPayload, _ := json.Marshal(callPeer{UserID: c.userID, Username: c.username})
+	for _, conn := range remaining {
+		select {
+		case conn.send <- callSignalMessage{Type: "peer-left", From: c.userID, Payload: leftPayload}:
+		default:
+			log.Printf("Failed to send peer-left to user %d", conn.userID)
 		}
+	}
+	return len(remaining), true
+}
 
-		sender, err := s.queries.GetUser(ctx, updatedMessage.SenderID)
-		if err != nil {
-			log.Printf("error loading sender %d for call message %d: %v", updatedMessage.SenderID, updatedMessage.ID, err)
-			return
+// forwardCallSignal relays msg from msg.From to the peer addressed by msg.To,
+// or to every other peer of the call when msg.To is zero.
+func forwardCallSignal(callID int64, msg callSignalMessage) {
+	callMutex.RLock()
+	defer callMutex.RUnlock()
+
+	for _, conn := range callConnections[callID] {
+		if conn.userID == msg.From || (msg.To != 0 && conn.userID != msg.To) {
+			continue
+		}
+		log.Printf("Forwarding %s to user %d", msg.Type, conn.userID)
+		select {
+		case conn.send <- msg:
+		default:
+			log.Printf("Send channel full for user %d, dropping message", conn.userID)
 		}
+	}
+}
 
-		msgResp := s.convertToMessageResponse(
-			updatedMessage.ID,
-			updatedMessage.ConversationID,
-			updatedMessage.Seq,
-			updatedMessage.SenderID,
-			sender.Username,
-			sender.ProfileImageHash,
-			updatedMessage.CreatedAt,
-			updatedMessage.EditedAt,
-			updatedMessage.ContentType,
-			updatedMessage.Body,
-			updatedMessage.ReplyToID,
-		)
-
-		go s.BroadcastMessageToConversation(updatedMessage.ConversationID, msgResp)
-	}()
+// finishCall marks the call as ended, stores its duration in the body of its
+// message and re-broadcasts the message so clients update the call bubble. It
+// is safe to call more than once.
+func (s *Server) finishCall(callID int64) {
+	if messageID := s.commitEndCall(callID); messageID != 0 {
+		s.broadcastCallMessage(context.Background(), messageID)
+	}
+}
 
-	for {
-		var msg callSignalMessage
-		if err := c.conn.ReadJSON(&msg); err != nil {
-			log.Printf("Read error from user %d: %v", c.userID, err)
-			break
+// endCall marks the call as ended within tx and stores its duration in the
+// body of its message. It returns the ID of the message and reports false when
+// the call had already ended or could not be ended; the caller commits tx
+// otherwise.
+func endCall(ctx context.Context, tx *db.QuerierTx, callID int64) (int64, bool) {
+	callInfo, err := tx.GetCallByID(ctx, callID)
+	if err != nil {
+		// already ended
+		return 0, false
+	}
+
+	ended, err := tx.EndCall(ctx, callID)
+	if err != nil {
+		log.Printf("error ending call: %v", err)
+		return 0, false
+	}
+	if ended == 0 {
+		return 0, false
+	}
+
+	durationSeconds := int64(time.Since(callInfo.CreatedAt).Seconds())
+	if err := tx.UpdateCallMessage(ctx, callID, max(durationSeconds, 0)); err != nil {
+		log.Printf("error updating call message %d: %v", callInfo.MessageID, err)
+		return 0, false
+	}
+
+	return callInfo.MessageID, true
+}
+
+// commitEndCall ends a call in its own transaction and returns the ID of its
+// message, or 0 if it had already ended. The last participant leaving calls it
+// with callMutex held, so that nobody joins a call that is about to end.
+func (s *Server) commitEndCall(callID int64) int64 {
+	tx, err := s.queries.Begin()
+	if err != nil {
+		log.Printf("error ending call: %v", err)
+		return 0
+	}
+	defer tx.Rollback()
+
+	messageID, ended := endCall(context.Background(), tx, callID)
+	if !ended {
+		return 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("error ending call: %v", err)
+		return 0
+	}
+	return messageID
+}
+
+// broadcastCallMessage re-broadcasts the message of an ended call.
+func (s *Server) broadcastCallMessage(ctx context.Context, messageID int64) {
+	updatedMessage, err := s.queries.GetMessageByID(ctx, messageID)
+	if err != nil {
+		log.Printf("error reloading updated call message %d: %v", messageID, err)
+		return
+	}
+
+	sender, err := s.queries.GetUser(ctx, updatedMessage.SenderID)
+	if err != nil {
+		log.Printf("error loading sender %d for call message %d: %v", updatedMessage.SenderID, updatedMessage.ID, err)
+		return
+	}
+
+	msgResp := s.convertToMessageResponse(
+		updatedMessage.ID,
+		updatedMessage.ConversationID,
+		updatedMessage.Seq,
+		updatedMessage.SenderID,
+		sender.Username,
+		sender.ProfileImageHash,
+		updatedMessage.CreatedAt,
+		updatedMessage.EditedAt,
+		updatedMessage.ContentType,
+		updatedMessage.Body,
+		updatedMessage.ReplyToID,
+		"",
+		"",
+	)
+
+	go evtMgr.broadcastToConversation(s, updatedMessage.ConversationID, Event{
+		Type: EventTypeMessageEdited,
+		Data: msgResp,
+	})
+}
+
+// closeCallConnections disconnects every signaling connection of the call.
+// The read pumps take care of the remaining cleanup.
+func closeCallConnections(callID int64) {
+	callMutex.RLock()
+	connections := append([]*callConnection(nil), callConnections[callID]...)
+	callMutex.RUnlock()
+
+	for _, conn := range connections {
+		conn.conn.Close()
+	}
+}
+
+// shutdownCalls asks every call participant to disconnect and waits until
+// all calls have ended. Connections still open when ctx expires are closed.
+func shutdownCalls(ctx context.Context) {
+	callMutex.RLock()
+	var connections []*callConnection
+	for _, conns := range callConnections {
+		connections = append(connections, conns...)
+	}
+	callMutex.RUnlock()
+
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+	for _, conn := range connections {
+		if err := conn.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second)); err != nil {
+			conn.conn.Close()
 		}
+	}
 
-		log.Printf("Received %s from user %d in call %d", msg.Type, c.userID, callID)
+	done := make(chan struct{})
+	go func() {
+		callHandlers.Wait()
+		close(done)
+	}()
 
-		callMutex.RLock()
-		for _, conn := range callConnections[callID] {
-			if conn.userID != c.userID {
-				log.Printf("Forwarding %s to user %d", msg.Type, conn.userID)
-				select {
-				case conn.send <- msg:
-				default:
-					log.Printf("Send channel full for user %d, dropping message", conn.userID)
-				}
-			}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("closing call connections that did not disconnect")
+		for _, conn := range connections {
+			conn.conn.Close()
 		}
-		callMutex.RUnlock()
 	}
 }
 
@@ -391,25 +748,25 @@ func (s *Server) writePump(c *callConnection) {
 
 func (s *Server) handleCallStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	messageIDStr := r.URL.Query().Get("messageId")
 	if messageIDStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 		return
 	}
 
 	messageID, err := strconv.ParseInt(messageIDStr, 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 		return
 	}
 
@@ -422,13 +779,13 @@ func (s *Server) handleCallStatus(w http.ResponseWriter, r *http.Request) {
 
 	msg, err := s.queries.GetMessageByID(r.Context(), messageID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
 		return
 	}
 
 	participants, err := s.queries.GetConversationParticipants(r.Context(), msg.ConversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -441,10 +798,94 @@ func (s *Server) handleCallStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !isParticipant {
-		w.WriteHeader(http.StatusForbidden)
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(callStatusResponse{Active: call.DeletedAt == nil})
 }
+
+func (s *Server) handleCallHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.URL.Query().Get("conversationId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	limit := int64(20)
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			limit = parsedLimit
+		}
+	}
+
+	cursor := int64(math.MaxInt64)
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		parsedCursor, err := strconv.ParseInt(cursorStr, 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+			return
+		}
+		cursor = parsedCursor
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	calls, err := s.queries.GetCallHistory(r.Context(), conversationID, cursor, limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := callHistoryResponse{Calls: make([]callHistoryEntry, 0, len(calls))}
+	for _, call := range calls {
+		entry := callHistoryEntry{
+			CallID:           call.ID,
+			StartedAt:        call.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			InitiatorID:      call.InitiatorID,
+			ParticipantCount: call.ParticipantCount,
+		}
+		if call.EndedAt != nil {
+			entry.EndedAt = call.EndedAt.Format("2006-01-02T15:04:05Z")
+			entry.DurationSeconds = int64(call.EndedAt.Sub(call.CreatedAt).Seconds())
+		}
+		response.Calls = append(response.Calls, entry)
+	}
+
+	if int64(len(calls)) == limit {
+		nextCursor := strconv.FormatInt(calls[len(calls)-1].ID, 10)
+		response.NextCursor = &nextCursor
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
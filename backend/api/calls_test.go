@@ -0,0 +1,311 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+func newTestCallConnection(userID int64, username string) *callConnection {
+	return &callConnection{
+		userID:   userID,
+		username: username,
+		send:     make(chan callSignalMessage, 16),
+	}
+}
+
+// drainSignals returns the messages queued for c.
+func drainSignals(c *callConnection) []callSignalMessage {
+	var messages []callSignalMessage
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				return messages
+			}
+			messages = append(messages, msg)
+		default:
+			return messages
+		}
+	}
+}
+
+func joinTestCall(t *testing.T, callID int64, c *callConnection) {
+	t.Helper()
+	callMutex.Lock()
+	addCallConnection(callID, c)
+	callMutex.Unlock()
+}
+
+func TestGroupCallSignalRouting(t *testing.T) {
+	const callID = -1
+	t.Cleanup(func() {
+		callMutex.Lock()
+		delete(callConnections, callID)
+		delete(answeredCalls, callID)
+		callMutex.Unlock()
+	})
+
+	alice := newTestCallConnection(1, "alice")
+	bob := newTestCallConnection(2, "bob")
+	carol := newTestCallConnection(3, "carol")
+
+	joinTestCall(t, callID, alice)
+	joinTestCall(t, callID, bob)
+	drainSignals(alice)
+	drainSignals(bob)
+	joinTestCall(t, callID, carol)
+
+	// The third participant learns about both existing peers.
+	messages := drainSignals(carol)
+	if len(messages) != 1 || messages[0].Type != "participants" {
+		t.Fatalf("carol received %+v, want a single participants message", messages)
+	}
+	var peers []callPeer
+	if err := json.Unmarshal(messages[0].Payload, &peers); err != nil {
+		t.Fatalf("decoding participants: %v", err)
+	}
+	if len(peers) != 2 || peers[0].UserID != alice.userID || peers[1].UserID != bob.userID {
+		t.Fatalf("participants = %+v, want alice and bob", peers)
+	}
+
+	for _, c := range []*callConnection{alice, bob} {
+		messages := drainSignals(c)
+		if len(messages) != 1 || messages[0].Type != "peer-joined" || messages[0].From != carol.userID {
+			t.Fatalf("user %d received %+v, want peer-joined from carol", c.userID, messages)
+		}
+	}
+
+	// Carol sends one offer per existing peer; each reaches only its addressee.
+	forwardCallSignal(callID, callSignalMessage{Type: "offer", From: carol.userID, To: alice.userID})
+	forwardCallSignal(callID, callSignalMessage{Type: "offer", From: carol.userID, To: bob.userID})
+	forwardCallSignal(callID, callSignalMessage{Type: "answer", From: bob.userID, To: carol.userID})
+	forwardCallSignal(callID, callSignalMessage{Type: "ice-candidate", From: alice.userID, To: carol.userID})
+
+	tests := []struct {
+		conn *callConnection
+		want []callSignalMessage
+	}{
+		{alice, []callSignalMessage{{Type: "offer", From: carol.userID, To: alice.userID}}},
+		{bob, []callSignalMessage{{Type: "offer", From: carol.userID, To: bob.userID}}},
+		{carol, []callSignalMessage{
+			{Type: "answer", From: bob.userID, To: carol.userID},
+			{Type: "ice-candidate", From: alice.userID, To: carol.userID},
+		}},
+	}
+	for _, tt := range tests {
+		got := drainSignals(tt.conn)
+		if len(got) != len(tt.want) {
+			t.Fatalf("user %d received %+v, want %+v", tt.conn.userID, got, tt.want)
+		}
+		for i := range got {
+			if got[i].Type != tt.want[i].Type || got[i].From != tt.want[i].From || got[i].To != tt.want[i].To {
+				t.Errorf("user %d message %d = %+v, want %+v", tt.conn.userID, i, got[i], tt.want[i])
+			}
+		}
+	}
+
+	// Unaddressed signals reach every other peer but not the sender.
+	forwardCallSignal(callID, callSignalMessage{Type: "renegotiate", From: alice.userID})
+	if got := drainSignals(alice); len(got) != 0 {
+		t.Errorf("sender received its own signal: %+v", got)
+	}
+	for _, c := range []*callConnection{bob, carol} {
+		if got := drainSignals(c); len(got) != 1 || got[0].Type != "renegotiate" {
+			t.Errorf("user %d received %+v, want renegotiate", c.userID, got)
+		}
+	}
+
+	// A leaving peer only disconnects itself.
+	callMutex.Lock()
+	remaining, found := removeCallConnection(callID, bob)
+	callMutex.Unlock()
+	if !found || remaining != 2 {
+		t.Fatalf("removeCallConnection = %d, %v, want 2, true", remaining, found)
+	}
+	for _, c := range []*callConnection{alice, carol} {
+		got := drainSignals(c)
+		if len(got) != 1 || got[0].Type != "peer-left" || got[0].From != bob.userID {
+			t.Errorf("user %d received %+v, want peer-left from bob", c.userID, got)
+		}
+	}
+	if _, ok := <-bob.send; ok {
+		t.Error("send channel of the leaving peer is still open")
+	}
+
+	forwardCallSignal(callID, callSignalMessage{Type: "ice-candidate", From: carol.userID, To: alice.userID})
+	if got := drainSignals(alice); len(got) != 1 {
+		t.Errorf("alice received %+v after bob left, want the candidate from carol", got)
+	}
+}
+
+func createTestUser(t *testing.T, s *Server, username string) db.User {
+	t.Helper()
+	user, err := s.queries.CreateUser(context.Background(), username, "", "", false)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// createTestCall starts a call of initiator in a new conversation of
+// participants, without going through handleStartCall.
+func createTestCall(t *testing.T, s *Server, initiator db.User, participants ...db.User) db.Call {
+	t.Helper()
+	ctx := context.Background()
+	conv, err := s.queries.CreateConversation(ctx, "group", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range append([]db.User{initiator}, participants...) {
+		if err := s.queries.AddConversationParticipant(ctx, conv.ID, p.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	message, err := s.queries.CreateMessage(ctx, conv.ID, 1, initiator.ID, messaging.ContentTypeCall, "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, err := s.queries.CreateCall(ctx, conv.ID, message.ID, &initiator.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return call
+}
+
+func subscribeTestEvents(t *testing.T, userID int64) chan Event {
+	t.Helper()
+	ch := make(chan Event, 16)
+	evtMgr.addClient(userID, ch)
+	t.Cleanup(func() { evtMgr.removeClient(userID, ch) })
+	return ch
+}
+
+// waitForEvent returns the first event of type eventType sent to ch, skipping
+// others.
+func waitForEvent(t *testing.T, ch chan Event, eventType EventType) Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-ch:
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event received", eventType)
+		}
+	}
+}
+
+func rejectCall(s *Server, userID, callID int64) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/calls/"+strconv.FormatInt(callID, 10)+"/reject", nil)
+	r.SetPathValue("callId", strconv.FormatInt(callID, 10))
+	r = r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, userID))
+	rec := httptest.NewRecorder()
+	s.handleRejectCall(rec, r)
+	return rec
+}
+
+func TestRejectCall(t *testing.T) {
+	s := newTestServer(t)
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	carol := createTestUser(t, s, "carol")
+	call := createTestCall(t, s, alice, bob)
+
+	tests := []struct {
+		name   string
+		userID int64
+		callID int64
+		status int
+	}{
+		{"unknown call", bob.ID, call.ID + 100, http.StatusNotFound},
+		{"not a participant", carol.ID, call.ID, http.StatusForbidden},
+		{"own call", alice.ID, call.ID, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := rejectCall(s, tt.userID, tt.callID); rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+	if _, err := s.queries.GetCallByID(context.Background(), call.ID); err != nil {
+		t.Fatalf("call ended by a refused rejection: %v", err)
+	}
+
+	aliceEvents := subscribeTestEvents(t, alice.ID)
+	if rec := rejectCall(s, bob.ID, call.ID); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	event := waitForEvent(t, aliceEvents, EventTypeCallRejected)
+	if data := event.Data.(map[string]int64); data["callId"] != call.ID || data["rejectedBy"] != bob.ID {
+		t.Errorf("call.rejected data = %v", data)
+	}
+	if _, err := s.queries.GetCallByID(context.Background(), call.ID); err == nil {
+		t.Error("rejected call is still active")
+	}
+	waitForEvent(t, aliceEvents, EventTypeMessageEdited)
+}
+
+func TestMissedCallTimer(t *testing.T) {
+	s := newTestServer(t)
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	defer func(timeout time.Duration) { missedCallTimeout = timeout }(missedCallTimeout)
+	missedCallTimeout = 10 * time.Millisecond
+
+	t.Run("unanswered", func(t *testing.T) {
+		call := createTestCall(t, s, alice, bob)
+		aliceEvents := subscribeTestEvents(t, alice.ID)
+		bobEvents := subscribeTestEvents(t, bob.ID)
+
+		s.scheduleMissedCall(call.ID, call.ConversationID, alice.ID)
+
+		event := waitForEvent(t, bobEvents, EventTypeCallMissed)
+		data := event.Data.(map[string]int64)
+		if data["callId"] != call.ID || data["conversationId"] != call.ConversationID || data["initiatorId"] != alice.ID {
+			t.Errorf("call.missed data = %v", data)
+		}
+		waitForEvent(t, aliceEvents, EventTypeMessageEdited)
+		for len(aliceEvents) > 0 {
+			if event := <-aliceEvents; event.Type == EventTypeCallMissed {
+				t.Error("initiator was told they missed their own call")
+			}
+		}
+		if _, err := s.queries.GetCallByID(context.Background(), call.ID); err == nil {
+			t.Error("missed call is still active")
+		}
+	})
+
+	t.Run("answered", func(t *testing.T) {
+		call := createTestCall(t, s, alice, bob)
+		callMutex.Lock()
+		answeredCalls[call.ID] = true
+		callMutex.Unlock()
+		t.Cleanup(func() {
+			callMutex.Lock()
+			delete(answeredCalls, call.ID)
+			callMutex.Unlock()
+		})
+
+		timer := s.scheduleMissedCall(call.ID, call.ConversationID, alice.ID)
+		time.Sleep(10 * missedCallTimeout)
+		if timer.Stop() {
+			t.Fatal("timer did not fire")
+		}
+		if _, err := s.queries.GetCallByID(context.Background(), call.ID); err != nil {
+			t.Errorf("answered call was ended: %v", err)
+		}
+	})
+}
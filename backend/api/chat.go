@@ -2,16 +2,24 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bloodmagesoftware/teamsync/auth"
 	"github.com/bloodmagesoftware/teamsync/crypto"
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+	"github.com/bloodmagesoftware/teamsync/sanitize"
 )
 
 type conversationResponse struct {
@@ -20,32 +28,87 @@ type conversationResponse struct {
 	Name           *string `json:"name"`
 	LastMessageSeq int64   `json:"lastMessageSeq"`
 	UnreadCount    int64   `json:"unreadCount"`
-	OtherUser      *struct {
-		ID              int64   `json:"id"`
-		Username        string  `json:"username"`
-		ProfileImageURL *string `json:"profileImageUrl"`
-	} `json:"otherUser,omitempty"`
+	RetentionDays  *int64  `json:"retentionDays"`
+	ReadOnly       bool    `json:"readOnly"`
+	// MemberCount is only set for group conversations; direct messages
+	// always have two members.
+	MemberCount int64 `json:"memberCount,omitempty"`
+	// NotificationLevel is all, mentions or none.
+	NotificationLevel string `json:"notificationLevel,omitempty"`
+	// LastMessage is nil for conversations without messages.
+	LastMessage *lastMessagePreview `json:"lastMessage,omitempty"`
+	// OtherUser is only set for direct messages.
+	OtherUser *conversationUserResponse `json:"otherUser,omitempty"`
+	// ActiveParticipants lists the connected members of group conversations.
+	ActiveParticipants []int64 `json:"activeParticipants,omitempty"`
+	// Pinned conversations are listed first for the user who pinned them.
+	Pinned   bool    `json:"pinned"`
+	PinnedAt *string `json:"pinnedAt,omitempty"`
+}
+
+// lastMessagePreview is a short plain-text snippet of the last message of a
+// conversation. Calls and polls get a fixed snippet.
+type lastMessagePreview struct {
+	SenderUsername string `json:"senderUsername"`
+	Snippet        string `json:"snippet"`
+	CreatedAt      string `json:"createdAt"`
+	ContentType    string `json:"contentType"`
+}
+
+type conversationUserResponse struct {
+	ID              int64   `json:"id"`
+	Username        string  `json:"username"`
+	ProfileImageURL *string `json:"profileImageUrl"`
+	Online          bool    `json:"online"`
+	LastSeen        *string `json:"lastSeen"`
 }
 
 type messageResponse struct {
-	ID                    int64   `json:"id"`
-	ConversationID        int64   `json:"conversationId"`
-	Seq                   int64   `json:"seq"`
-	SenderID              int64   `json:"senderId"`
-	SenderUsername        string  `json:"senderUsername"`
-	SenderProfileImageURL *string `json:"senderProfileImageUrl"`
-	CreatedAt             string  `json:"createdAt"`
-	EditedAt              *string `json:"editedAt,omitempty"`
-	ContentType           string  `json:"contentType"`
-	Body                  string  `json:"body"`
-	ReplyToID             *int64  `json:"replyToId,omitempty"`
+	ID                    int64               `json:"id"`
+	ConversationID        int64               `json:"conversationId"`
+	Seq                   int64               `json:"seq"`
+	SenderID              int64               `json:"senderId"`
+	SenderUsername        string              `json:"senderUsername"`
+	SenderProfileImageURL *string             `json:"senderProfileImageUrl"`
+	CreatedAt             string              `json:"createdAt"`
+	EditedAt              *string             `json:"editedAt,omitempty"`
+	ContentType           string              `json:"contentType"`
+	Body                  string              `json:"body"`
+	ReplyToID             *int64              `json:"replyToId,omitempty"`
+	ReplyCount            int64               `json:"replyCount"`
+	Reactions             map[string]int64    `json:"reactions,omitempty"`
+	CurrentUserReactions  []string            `json:"currentUserReactions,omitempty"`
+	ThreadParticipants    []threadParticipant `json:"threadParticipants,omitempty"`
+	// Mentions lists the participants mentioned by @username. It is only
+	// set on message.new events.
+	Mentions []int64 `json:"mentions,omitempty"`
+	// SeenByCount is the number of other participants who read the message.
+	// It is only set on conversation pages.
+	SeenByCount *int64 `json:"seenByCount,omitempty"`
+	// ForwardedFrom is set on messages forwarded from another message.
+	ForwardedFrom *forwardedFromResponse `json:"forwardedFrom,omitempty"`
+	// SystemEvent is the parsed body of application/system messages, whose
+	// body is left empty.
+	SystemEvent json.RawMessage `json:"systemEvent,omitempty"`
+}
+
+// messagePage is the response of GET /api/messages with readState=true.
+type messagePage struct {
+	Messages      []messageResponse `json:"messages"`
+	MyLastReadSeq int64             `json:"myLastReadSeq"`
 }
 
 type sendMessageRequest struct {
 	ConversationID int64  `json:"conversationId,omitempty"`
 	OtherUserID    *int64 `json:"otherUserId,omitempty"`
 	Body           string `json:"body"`
+	ContentType    string `json:"contentType,omitempty"`
 	ReplyToID      *int64 `json:"replyToId,omitempty"`
+	// ClientMessageID is generated by the client so that retrying a send
+	// returns the message created before instead of a duplicate.
+	ClientMessageID string `json:"clientMessageId,omitempty"`
+	// SendAt schedules the message instead of sending it right away.
+	SendAt *time.Time `json:"sendAt,omitempty"`
 }
 
 type updateReadStateRequest struct {
@@ -63,55 +126,202 @@ type getOrCreateDMRequest struct {
 	OtherUserID int64 `json:"otherUserId"`
 }
 
+// conversationPage is the paginated response of GET /api/conversations.
+// NextCursor is the before_id of the next page.
+type conversationPage struct {
+	Conversations []conversationResponse `json:"conversations"`
+	NextCursor    *int64                 `json:"nextCursor"`
+	HasMore       bool                   `json:"hasMore"`
+	// TotalUnread counts the unread messages of all conversations, not only
+	// those on this page.
+	TotalUnread int64 `json:"totalUnread"`
+}
+
+const (
+	defaultConversationPageSize = 20
+	maxConversationPageSize     = 100
+)
+
+// Orders of the conversation list. Pinned conversations always come first.
+// With conversationSortLastActivity, the default, conversations with unread
+// messages come next, each group ordered by the time of the last message.
+const (
+	conversationSortLastActivity = "last_activity"
+	conversationSortUnreadCount  = "unread_count"
+	conversationSortName         = "name"
+)
+
+func validConversationSort(sort string) bool {
+	switch sort {
+	case conversationSortLastActivity, conversationSortUnreadCount, conversationSortName:
+		return true
+	}
+	return false
+}
+
+// conversationSort returns the order requested with the sort query parameter,
+// falling back to the order saved in the settings of the user.
+func (s *Server) conversationSort(w http.ResponseWriter, r *http.Request, userID int64) (string, bool) {
+	if sort := r.URL.Query().Get("sort"); sort != "" {
+		if !validConversationSort(sort) {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Sort must be last_activity, unread_count or name", "sort")
+			return "", false
+		}
+		return sort, true
+	}
+
+	settings, err := s.queries.GetUserSettings(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return conversationSortLastActivity, true
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return "", false
+	}
+	return settings.ConversationSort, true
+}
+
 func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
-	conversations, err := s.queries.GetUserConversations(r.Context(), userID, userID)
+	// Conversations are ordered by the sort parameter or the saved
+	// preference: most recent activity, unread count or name. Requests
+	// without limit or before_id get every conversation as a bare array,
+	// which is deprecated in favor of conversationPage.
+	query := r.URL.Query()
+	paginated := query.Has("limit") || query.Has("before_id")
+
+	limit := int64(-1)
+	var beforeID int64
+	if paginated {
+		var ok bool
+		limit, beforeID, ok = parseConversationPage(w, query)
+		if !ok {
+			return
+		}
+	}
+
+	sort, ok := s.conversationSort(w, r, userID)
+	if !ok {
+		return
+	}
+
+	// contacts_only=true narrows the list to direct messages with contacts.
+	contactsOnly := query.Get("contacts_only") == "true"
+
+	conversations, err := s.queries.GetUserConversations(r.Context(), userID, contactsOnly, beforeID, sort, limit)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	var totalUnread int64
+	if paginated {
+		unread, err := s.unreadCounts(r.Context(), userID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+		totalUnread = unread.TotalUnread
+	}
+
+	hasMore := paginated && int64(len(conversations)) == limit
+	if hasMore {
+		conversations = conversations[:len(conversations)-1]
+	}
+
+	response := s.conversationResponses(r.Context(), userID, conversations)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !paginated {
+		w.Header().Set("Deprecation", "true")
+		json.NewEncoder(w).Encode(response)
 		return
 	}
 
+	page := conversationPage{Conversations: response, HasMore: hasMore, TotalUnread: totalUnread}
+	if hasMore {
+		page.NextCursor = &response[len(response)-1].ID
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseConversationPage reads limit and before_id of a conversation page. The
+// returned limit is one more than requested, which tells whether there is
+// another page.
+func parseConversationPage(w http.ResponseWriter, query url.Values) (int64, int64, bool) {
+	limit := int64(defaultConversationPageSize)
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxConversationPageSize {
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Limit must be between 1 and 100")
+			return 0, 0, false
+		}
+		limit = parsedLimit
+	}
+
+	var beforeID int64
+	if beforeStr := query.Get("before_id"); beforeStr != "" {
+		parsedID, err := strconv.ParseInt(beforeStr, 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid before_id")
+			return 0, 0, false
+		}
+		beforeID = parsedID
+	}
+
+	return limit + 1, beforeID, true
+}
+
+// conversationResponses converts conversations of a user into responses with
+// the other user of direct messages and the presence of all members.
+func (s *Server) conversationResponses(ctx context.Context, userID int64, conversations []db.GetUserConversationsRow) []conversationResponse {
 	response := make([]conversationResponse, 0, len(conversations))
+	groupMembers := make(map[int][]int64)
+	var memberIDs []int64
+
 	for _, conv := range conversations {
 		resp := conversationResponse{
-			ID:             conv.ID,
-			Type:           conv.Type,
-			Name:           conv.Name,
-			LastMessageSeq: conv.LastMessageSeq,
-			UnreadCount:    conv.UnreadCount,
-		}
-
-		if conv.Type == "dm" {
-			participants, err := s.queries.GetConversationParticipants(r.Context(), conv.ID)
-			if err == nil {
-				for _, p := range participants {
-					if p.ID != userID {
-						var profileImageURL *string
-						if p.ProfileImageHash != nil {
-							url := fmt.Sprintf("/api/profile/image/%s", *p.ProfileImageHash)
-							profileImageURL = &url
-						}
-						resp.OtherUser = &struct {
-							ID              int64   `json:"id"`
-							Username        string  `json:"username"`
-							ProfileImageURL *string `json:"profileImageUrl"`
-						}{
-							ID:              p.ID,
-							Username:        p.Username,
-							ProfileImageURL: profileImageURL,
-						}
-						break
-					}
+			ID:                conv.ID,
+			Type:              conv.Type,
+			Name:              conv.Name,
+			LastMessageSeq:    conv.LastMessageSeq,
+			UnreadCount:       conv.UnreadCount,
+			RetentionDays:     conv.RetentionDays,
+			ReadOnly:          conv.ReadonlyForMembers,
+			NotificationLevel: conv.NotificationLevel,
+		}
+		if conv.Type != "dm" {
+			resp.MemberCount = conv.MemberCount
+		}
+		if conv.LastMessageCreatedAt != nil {
+			resp.LastMessage = s.newLastMessagePreview(conv)
+		}
+		if conv.PinnedAt != nil {
+			pinnedAt := conv.PinnedAt.Format("2006-01-02T15:04:05Z")
+			resp.Pinned = true
+			resp.PinnedAt = &pinnedAt
+		}
+
+		participants, err := s.queries.GetConversationParticipants(ctx, conv.ID)
+		if err == nil {
+			for _, p := range participants {
+				if conv.Type != "dm" {
+					groupMembers[len(response)] = append(groupMembers[len(response)], p.ID)
+					memberIDs = append(memberIDs, p.ID)
+				} else if p.ID != userID {
+					resp.OtherUser = newConversationUser(p.ID, p.Username, p.ProfileImageHash)
+					memberIDs = append(memberIDs, p.ID)
+					break
 				}
 			}
 		}
@@ -119,37 +329,234 @@ func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
 		response = append(response, resp)
 	}
 
+	// Look up the presence of all members at once to keep the event manager
+	// locked as briefly as possible.
+	online := make(map[int64]bool)
+	for _, id := range evtMgr.GetOnlineUserIDs(memberIDs) {
+		online[id] = true
+	}
+	lastSeen := evtMgr.lastSeenTimes(memberIDs)
+
+	for i := range response {
+		if other := response[i].OtherUser; other != nil {
+			setPresence(other, online[other.ID], lastSeen)
+		}
+		for _, id := range groupMembers[i] {
+			if online[id] {
+				response[i].ActiveParticipants = append(response[i].ActiveParticipants, id)
+			}
+		}
+	}
+
+	return response
+}
+
+const lastMessageSnippetLength = 100
+
+// newLastMessagePreview summarizes the last message of a conversation for the
+// conversation list.
+func (s *Server) newLastMessagePreview(conv db.GetUserConversationsRow) *lastMessagePreview {
+	preview := &lastMessagePreview{
+		CreatedAt: conv.LastMessageCreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if conv.LastMessageSenderUsername != nil {
+		preview.SenderUsername = *conv.LastMessageSenderUsername
+	}
+	if conv.LastMessageContentType != nil {
+		preview.ContentType = *conv.LastMessageContentType
+	}
+
+	var body string
+	if conv.LastMessageBody != nil {
+		body = *conv.LastMessageBody
+	}
+
+	switch preview.ContentType {
+	case messaging.ContentTypeCall:
+		if body == "" {
+			preview.Snippet = "📞 Call started"
+		} else {
+			preview.Snippet = "📞 Call ended"
+		}
+		return preview
+	case messaging.ContentTypePoll:
+		preview.Snippet = "📊 Poll"
+		return preview
+	case messaging.ContentTypeSystem:
+		var event messaging.SystemEvent
+		if err := json.Unmarshal([]byte(body), &event); err == nil {
+			preview.Snippet = event.Summary()
+		}
+		return preview
+	}
+
+	if crypto.IsEncrypted(body) && conv.LastMessageID != nil {
+		decrypted, err := s.decryptCache.decrypt(*conv.LastMessageID, conv.ID, body)
+		if err != nil {
+			log.Printf("Failed to decrypt last message of conversation %d: %v", conv.ID, err)
+			return preview
+		}
+		body = decrypted
+	}
+
+	snippet := strings.Join(strings.Fields(body), " ")
+	if utf8.RuneCountInString(snippet) > lastMessageSnippetLength {
+		snippet = string([]rune(snippet)[:lastMessageSnippetLength]) + "…"
+	}
+	preview.Snippet = snippet
+	return preview
+}
+
+func (s *Server) handleConversation(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGetConversation(w, r)
+	case http.MethodPatch:
+		s.handleRenameConversation(w, r)
+	case http.MethodDelete:
+		s.handleDeleteConversation(w, r)
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	conv, err := s.queries.GetConversationByID(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Conversation not found")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	resp := conversationResponse{
+		ID:             conv.ID,
+		Type:           conv.Type,
+		Name:           conv.Name,
+		LastMessageSeq: conv.LastMessageSeq,
+		RetentionDays:  conv.RetentionDays,
+		ReadOnly:       conv.ReadonlyForMembers,
+	}
+
+	resp.NotificationLevel, err = s.notificationLevel(r.Context(), conv.ID, userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	unread, err := s.unreadCounts(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	for _, count := range unread.Conversations {
+		if count.ConversationID == conv.ID {
+			resp.UnreadCount = count.UnreadCount
+			break
+		}
+	}
+
+	memberIDs := make([]int64, 0, len(participants))
+	for _, p := range participants {
+		if conv.Type != "dm" {
+			memberIDs = append(memberIDs, p.ID)
+		} else if p.ID != userID {
+			resp.OtherUser = newConversationUser(p.ID, p.Username, p.ProfileImageHash)
+			memberIDs = append(memberIDs, p.ID)
+		}
+	}
+
+	online := evtMgr.GetOnlineUserIDs(memberIDs)
+	if resp.OtherUser != nil {
+		setPresence(resp.OtherUser, len(online) > 0, evtMgr.lastSeenTimes(memberIDs))
+	} else {
+		resp.ActiveParticipants = online
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func newConversationUser(id int64, username string, profileImageHash *string) *conversationUserResponse {
+	var profileImageURL *string
+	if profileImageHash != nil {
+		url := fmt.Sprintf("/api/profile/image/%s?size=128", *profileImageHash)
+		profileImageURL = &url
+	}
+
+	return &conversationUserResponse{
+		ID:              id,
+		Username:        username,
+		ProfileImageURL: profileImageURL,
+	}
+}
+
+// setPresence fills in whether user has an open event stream and, if not,
+// when their last one was closed.
+func setPresence(user *conversationUserResponse, online bool, lastSeen map[int64]time.Time) {
+	user.Online = online
+	if seen, ok := lastSeen[user.ID]; ok && !online {
+		str := seen.UTC().Format("2006-01-02T15:04:05Z")
+		user.LastSeen = &str
+	}
 }
 
 func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	conversationIDStr := r.URL.Query().Get("conversationId")
 	if conversationIDStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 		return
 	}
 
 	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 		return
 	}
 
 	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -162,7 +569,7 @@ func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !isParticipant {
-		w.WriteHeader(http.StatusForbidden)
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
 		return
 	}
 
@@ -182,36 +589,36 @@ func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
 	if sinceStr != "" {
 		sinceTime, err := time.Parse(time.RFC3339, sinceStr)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 			return
 		}
-		msgs, err := s.queries.GetMessagesSince(r.Context(), conversationID, sinceTime)
+		msgs, err := s.queries.GetMessagesSince(r.Context(), userID, conversationID, sinceTime, limit)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 			return
 		}
 		response = make([]messageResponse, len(msgs))
 		for i, msg := range msgs {
 			response[i] = s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
 				msg.SenderUsername, msg.SenderProfileImageHash, msg.CreatedAt, msg.EditedAt,
-				msg.ContentType, msg.Body, msg.ReplyToID)
+				msg.ContentType, msg.Body, msg.ReplyToID, msg.ReactionsJson, msg.UserReactionsJson)
 		}
 	} else if beforeStr != "" {
 		beforeTime, err := time.Parse(time.RFC3339, beforeStr)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
 			return
 		}
-		msgs, err := s.queries.GetMessagesBefore(r.Context(), conversationID, beforeTime, limit)
+		msgs, err := s.queries.GetMessagesBefore(r.Context(), userID, conversationID, beforeTime, limit)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 			return
 		}
 		response = make([]messageResponse, len(msgs))
 		for i, msg := range msgs {
 			response[i] = s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
 				msg.SenderUsername, msg.SenderProfileImageHash, msg.CreatedAt, msg.EditedAt,
-				msg.ContentType, msg.Body, msg.ReplyToID)
+				msg.ContentType, msg.Body, msg.ReplyToID, msg.ReactionsJson, msg.UserReactionsJson)
 		}
 	} else {
 		offsetStr := r.URL.Query().Get("offset")
@@ -221,30 +628,76 @@ func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
 				offset = parsedOffset
 			}
 		}
-		msgs, err := s.queries.GetConversationMessages(r.Context(), conversationID, limit, offset)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		response = make([]messageResponse, len(msgs))
-		for i, msg := range msgs {
-			response[i] = s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
-				msg.SenderUsername, msg.SenderProfileImageHash, msg.CreatedAt, msg.EditedAt,
-				msg.ContentType, msg.Body, msg.ReplyToID)
+		if r.URL.Query().Get("threaded") == "true" {
+			msgs, err := s.queries.GetConversationRootMessages(r.Context(), userID, conversationID, limit, offset)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+				return
+			}
+			response = make([]messageResponse, len(msgs))
+			for i, msg := range msgs {
+				response[i] = s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
+					msg.SenderUsername, msg.SenderProfileImageHash, msg.CreatedAt, msg.EditedAt,
+					msg.ContentType, msg.Body, msg.ReplyToID, msg.ReactionsJson, msg.UserReactionsJson)
+			}
+		} else {
+			msgs, err := s.queries.GetConversationMessages(r.Context(), userID, conversationID, limit, offset)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+				return
+			}
+			response = make([]messageResponse, len(msgs))
+			for i, msg := range msgs {
+				response[i] = s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
+					msg.SenderUsername, msg.SenderProfileImageHash, msg.CreatedAt, msg.EditedAt,
+					msg.ContentType, msg.Body, msg.ReplyToID, msg.ReactionsJson, msg.UserReactionsJson)
+			}
 		}
 	}
 
+	if err := s.attachThreadSummaries(r.Context(), userID, response); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if err := s.attachSeenByCounts(r.Context(), conversationID, response); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if err := s.attachForwardedFrom(r.Context(), response); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if err := s.attachPollTallies(r.Context(), response); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if r.URL.Query().Get("readState") != "true" {
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// readState=true wraps the messages together with the read state of the
+	// caller, so that the first load shows what is unread.
+	myLastReadSeq, err := s.queries.GetReadSeq(r.Context(), conversationID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	json.NewEncoder(w).Encode(messagePage{Messages: response, MyLastReadSeq: myLastReadSeq})
 }
 
 func (s *Server) convertToMessageResponse(id, conversationID, seq, senderID int64,
 	senderUsername string, senderProfileImageHash *string, createdAt time.Time, editedAt *time.Time,
-	contentType, encryptedBody string, replyToID *int64) messageResponse {
+	contentType, encryptedBody string, replyToID *int64, reactionsJSON, userReactionsJSON string) messageResponse {
 
 	var profileImageURL *string
 	if senderProfileImageHash != nil {
-		url := fmt.Sprintf("/api/profile/image/%s", *senderProfileImageHash)
+		url := fmt.Sprintf("/api/profile/image/%s?size=128", *senderProfileImageHash)
 		profileImageURL = &url
 	}
 
@@ -254,9 +707,30 @@ func (s *Server) convertToMessageResponse(id, conversationID, seq, senderID int6
 		editedAtStr = &str
 	}
 
+	// Reactions are aggregated by the query as JSON; callers without them
+	// pass empty strings.
+	var reactions map[string]int64
+	if reactionsJSON != "" {
+		if err := json.Unmarshal([]byte(reactionsJSON), &reactions); err != nil {
+			log.Printf("Failed to parse reactions of message %d: %v", id, err)
+		}
+	}
+
+	var userReactions []string
+	if userReactionsJSON != "" {
+		if err := json.Unmarshal([]byte(userReactionsJSON), &userReactions); err != nil {
+			log.Printf("Failed to parse own reactions of message %d: %v", id, err)
+		}
+	}
+	// Call messages hold the unencrypted call summary once the call ended,
+	// system messages their unencrypted event.
 	messageBody := encryptedBody
-	if crypto.IsEncrypted(encryptedBody) {
-		decrypted, err := crypto.DecryptMessage(encryptedBody, conversationID)
+	var systemEvent json.RawMessage
+	if contentType == messaging.ContentTypeSystem {
+		systemEvent = json.RawMessage(encryptedBody)
+		messageBody = ""
+	} else if contentType != messaging.ContentTypeCall && crypto.IsEncrypted(encryptedBody) {
+		decrypted, err := s.decryptCache.decrypt(id, conversationID, encryptedBody)
 		if err != nil {
 			log.Printf("Failed to decrypt message %d in conversation %d: %v", id, conversationID, err)
 			messageBody = "[Message could not be decrypted]"
@@ -277,43 +751,124 @@ func (s *Server) convertToMessageResponse(id, conversationID, seq, senderID int6
 		ContentType:           contentType,
 		Body:                  messageBody,
 		ReplyToID:             replyToID,
+		Reactions:             reactions,
+		CurrentUserReactions:  userReactions,
+		SystemEvent:           systemEvent,
+	}
+}
+
+// checkMessageLength writes a 400 response and returns false when body,
+// ignoring surrounding whitespace, is longer than the configured maximum.
+// Messages have to pass it before they are stored, including edited ones.
+func (s *Server) checkMessageLength(w http.ResponseWriter, body string) bool {
+	length := utf8.RuneCountInString(strings.TrimSpace(body))
+	if length <= s.maxMessageLength {
+		return true
+	}
+
+	writeErrorResponse(w, http.StatusBadRequest, struct {
+		ErrorResponse
+		MaxLength    int `json:"maxLength"`
+		ActualLength int `json:"actualLength"`
+	}{
+		ErrorResponse: ErrorResponse{Error: ErrorDetail{
+			Code:    ErrCodeValidation,
+			Message: fmt.Sprintf("Message body must be at most %d characters", s.maxMessageLength),
+			Field:   "body",
+		}},
+		MaxLength:    s.maxMessageLength,
+		ActualLength: length,
+	})
+	return false
+}
+
+// sanitizeMessageBody strips script from markdown bodies before they are
+// stored; other content types are returned unchanged. It writes an error and
+// reports false if nothing is left of the body.
+func sanitizeMessageBody(w http.ResponseWriter, contentType, body string) (string, bool) {
+	if contentType != messaging.ContentTypeMarkdown {
+		return body, true
+	}
+
+	body = sanitize.SanitizeMarkdown(body)
+	if strings.TrimSpace(body) == "" {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Message body cannot be empty", "body")
+		return "", false
 	}
+	return body, true
 }
 
 func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	var req sendMessageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteDecodeError(w, err)
 		return
 	}
 
 	if strings.TrimSpace(req.Body) == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Message body cannot be empty"})
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Message body cannot be empty", "body")
+		return
+	}
+
+	if !s.checkMessageLength(w, req.Body) {
+		return
+	}
+
+	if len(req.ClientMessageID) > maxClientMessageIDLength {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Client message ID is too long", "clientMessageId")
+		return
+	}
+
+	if req.ContentType != "" && !messaging.ValidateUserContentType(req.ContentType) {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Unsupported content type", "contentType")
+		return
+	}
+
+	if req.ContentType == messaging.ContentTypePoll {
+		poll, err := parsePoll(req.Body)
+		if err != nil {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, err.Error(), "body")
+			return
+		}
+		normalized, _ := json.Marshal(poll)
+		req.Body = string(normalized)
+	}
+
+	// Requests that are rejected as invalid do not use up the quota.
+	if !s.allowMessage(w, userID) {
 		return
 	}
 
 	conversationID := req.ConversationID
 
 	if conversationID == 0 && req.OtherUserID != nil {
+		if *req.OtherUserID == userID {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Cannot send messages to yourself", "otherUserId")
+			return
+		}
+
+		if _, ok := s.dmRecipient(w, r, userID, *req.OtherUserID); !ok {
+			return
+		}
+
 		existingConv, err := s.queries.GetOrCreateDMConversation(r.Context(), userID, *req.OtherUserID)
 		if err == nil {
 			conversationID = existingConv.ID
 		} else {
 			tx, err := s.queries.Begin()
 			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+				WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 				return
 			}
 			defer tx.Rollback()
@@ -321,22 +876,22 @@ func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 			name := ""
 			conv, err := tx.CreateConversation(r.Context(), "dm", &name)
 			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+				WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 				return
 			}
 
 			if err := tx.AddConversationParticipant(r.Context(), conv.ID, userID); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+				WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 				return
 			}
 
 			if err := tx.AddConversationParticipant(r.Context(), conv.ID, *req.OtherUserID); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+				WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 				return
 			}
 
 			if err := tx.Commit(); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+				WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 				return
 			}
 
@@ -345,14 +900,13 @@ func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if conversationID == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "conversationId or otherUserId required"})
+		WriteError(w, http.StatusBadRequest, ErrCodeValidation, "conversationId or otherUserId required")
 		return
 	}
 
 	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -365,61 +919,145 @@ func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !isParticipant {
-		w.WriteHeader(http.StatusForbidden)
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
 		return
 	}
 
-	tx, err := s.queries.Begin()
+	conv, err := s.queries.GetConversationByID(r.Context(), conversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
-	defer tx.Rollback()
 
-	if err := tx.UpdateConversationSeq(r.Context(), conversationID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	if conv.ReadonlyForMembers {
+		sender, err := s.queries.GetConversationMember(r.Context(), conversationID, userID)
+		if err != nil || sender.Role != memberRoleAdmin {
+			WriteError(w, http.StatusForbidden, ErrCodeForbidden, "This conversation is read-only")
+			return
+		}
 	}
 
-	conv, err := tx.GetConversationByID(r.Context(), conversationID)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	if req.ReplyToID != nil {
+		parent, err := s.queries.GetMessageByID(r.Context(), *req.ReplyToID)
+		if err != nil || parent.ConversationID != conversationID || parent.DeletedAt != nil {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Reply target not found in this conversation", "replyToId")
+			return
+		}
+	}
+
+	if !s.allowConversationMessage(w, userID, conversationID) {
 		return
 	}
 
-	contentType := "text/markdown"
+	// Markdown is only requested explicitly or by the user's settings while
+	// the server allows it.
+	contentType := messaging.ContentTypeMarkdown
 	settings, err := s.queries.GetUserSettings(r.Context(), userID)
-	if err == nil && !settings.MarkdownEnabled {
-		contentType = "text/plain"
+	if req.ContentType != "" {
+		contentType = req.ContentType
+	} else if err == nil && !settings.MarkdownEnabled {
+		contentType = messaging.ContentTypePlain
+	}
+	if contentType == messaging.ContentTypeMarkdown && s.markdownDisabled {
+		contentType = messaging.ContentTypePlain
 	}
 
-	encryptedBody, err := crypto.EncryptMessage(req.Body, conversationID)
+	req.Body, ok = sanitizeMessageBody(w, contentType, req.Body)
+	if !ok {
+		return
+	}
+
+	if req.SendAt != nil && req.SendAt.After(time.Now().Add(minScheduleDelay)) {
+		s.scheduleMessage(w, r, userID, conversationID, contentType, req)
+		return
+	}
+
+	tx, err := s.queries.Begin()
 	if err != nil {
-		log.Printf("Error encrypting message: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
+	defer tx.Rollback()
 
-	message, err := tx.CreateMessage(r.Context(), conversationID, conv.LastMessageSeq, userID, contentType, encryptedBody, req.ReplyToID)
+	var clientMessageID *string
+	if req.ClientMessageID != "" {
+		clientMessageID = &req.ClientMessageID
+
+		existing, err := tx.GetMessageByClientID(r.Context(), clientMessageID)
+		if err == nil {
+			if existing.SenderID != userID || existing.ConversationID != conversationID {
+				WriteFieldError(w, http.StatusConflict, ErrCodeConflict, "Client message ID already in use", "clientMessageId")
+				return
+			}
+			tx.Rollback()
+			s.writeExistingMessage(w, r, existing)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+	}
+
+	message, err := storeMessage(r.Context(), tx, conversationID, userID, contentType, req.Body, req.ReplyToID, clientMessageID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	if err := tx.Commit(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
-	sender, err := s.queries.GetUser(r.Context(), userID)
+	msgResp, err := s.announceMessage(r.Context(), message, req.Body)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msgResp)
+}
+
+// storeMessage appends a message with the plain text body to a conversation
+// within tx. The body is encrypted before it is stored.
+func storeMessage(ctx context.Context, tx *db.QuerierTx, conversationID, senderID int64, contentType, body string, replyToID *int64, clientMessageID *string) (db.Message, error) {
+	encryptedBody, err := crypto.EncryptMessage(body, conversationID)
+	if err != nil {
+		log.Printf("Error encrypting message: %v", err)
+		return db.Message{}, err
+	}
+
+	return appendMessage(ctx, tx, conversationID, senderID, contentType, encryptedBody, replyToID, clientMessageID)
+}
+
+// appendMessage stores a message with the next sequence number of its
+// conversation within tx. The body is stored as given.
+func appendMessage(ctx context.Context, tx *db.QuerierTx, conversationID, senderID int64, contentType, body string, replyToID *int64, clientMessageID *string) (db.Message, error) {
+	if err := tx.UpdateConversationSeq(ctx, conversationID); err != nil {
+		return db.Message{}, err
+	}
+
+	conv, err := tx.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return db.Message{}, err
+	}
+
+	return tx.CreateMessage(ctx, conversationID, conv.LastMessageSeq, senderID, contentType, body, replyToID, clientMessageID)
+}
+
+// announceMessage delivers a stored message with the plain text body to the
+// participants of its conversation and returns the response for its sender.
+func (s *Server) announceMessage(ctx context.Context, message db.Message, body string) (messageResponse, error) {
+	sender, err := s.queries.GetUser(ctx, message.SenderID)
+	if err != nil {
+		return messageResponse{}, err
+	}
+
 	var profileImageURL *string
 	if sender.ProfileImageHash != nil {
-		url := "/api/profile/image/" + *sender.ProfileImageHash
+		url := "/api/profile/image/" + *sender.ProfileImageHash + "?size=128"
 		profileImageURL = &url
 	}
 
@@ -432,37 +1070,54 @@ func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 		SenderProfileImageURL: profileImageURL,
 		CreatedAt:             message.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		ContentType:           message.ContentType,
-		Body:                  req.Body,
-		ReplyToID:             req.ReplyToID,
+		Body:                  body,
+		ReplyToID:             message.ReplyToID,
+	}
+	if message.ContentType == messaging.ContentTypePoll {
+		var poll pollContent
+		if err := json.Unmarshal([]byte(body), &poll); err == nil {
+			msgResp.Body = poll.body(nil)
+		}
+	}
+	if message.ContentType == messaging.ContentTypeSystem {
+		msgResp.Body = ""
+		msgResp.SystemEvent = json.RawMessage(body)
+	}
+	if message.ForwardedFromMessageID != nil {
+		forwards := []messageResponse{msgResp}
+		if err := s.attachForwardedFrom(ctx, forwards); err != nil {
+			return messageResponse{}, err
+		}
+		msgResp = forwards[0]
 	}
 
-	go s.BroadcastMessageToConversation(conversationID, msgResp)
+	go s.BroadcastMessageToConversation(message.ConversationID, msgResp)
+	s.prefetchLinkPreview(body)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(msgResp)
+	return msgResp, nil
 }
 
 func (s *Server) handleUpdateReadState(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	var req updateReadStateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteDecodeError(w, err)
 		return
 	}
 
 	participants, err := s.queries.GetConversationParticipants(r.Context(), req.ConversationID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -475,30 +1130,31 @@ func (s *Server) handleUpdateReadState(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !isParticipant {
-		w.WriteHeader(http.StatusForbidden)
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
 		return
 	}
 
 	if err := s.queries.UpdateReadState(r.Context(), req.ConversationID, userID, req.LastReadSeq); err != nil {
 		log.Printf("Failed to update read state for user %d in conversation %d: %v", userID, req.ConversationID, err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update read state"})
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update read state")
 		return
 	}
 
+	go s.broadcastUnreadCount(userID)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
 
 func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
@@ -509,9 +1165,11 @@ func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := s.queries.SearchUsers(r.Context(), "%"+query+"%", userID)
+	includeBots := r.URL.Query().Get("includeBots") == "true"
+
+	users, err := s.queries.SearchUsers(r.Context(), "%"+query+"%", userID, includeBots)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -519,7 +1177,7 @@ func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
 	for i, user := range users {
 		var profileImageURL *string
 		if user.ProfileImageHash != nil {
-			url := fmt.Sprintf("/api/profile/image/%s", *user.ProfileImageHash)
+			url := fmt.Sprintf("/api/profile/image/%s?size=128", *user.ProfileImageHash)
 			profileImageURL = &url
 		}
 
@@ -534,34 +1192,54 @@ func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(results)
 }
 
+// dmRecipient returns the other user of a direct conversation of userID. It
+// writes an error response and returns false when the other user does not
+// exist, is deleted or either user has blocked the other.
+func (s *Server) dmRecipient(w http.ResponseWriter, r *http.Request, userID, otherUserID int64) (db.User, bool) {
+	otherUser, err := s.queries.GetUser(r.Context(), otherUserID)
+	if err != nil || otherUser.DeletedAt != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return db.User{}, false
+	}
+
+	blocks, err := s.queries.IsBlockedBetween(r.Context(), userID, otherUserID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return db.User{}, false
+	}
+
+	if blocks > 0 {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "User is blocked")
+		return db.User{}, false
+	}
+	return otherUser, true
+}
+
 func (s *Server) handleGetOrCreateDM(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
 	var req getOrCreateDMRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteDecodeError(w, err)
 		return
 	}
 
 	if req.OtherUserID == userID {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Cannot create conversation with yourself"})
+		WriteError(w, http.StatusBadRequest, ErrCodeValidation, "Cannot create conversation with yourself")
 		return
 	}
 
-	otherUser, err := s.queries.GetUser(r.Context(), req.OtherUserID)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+	otherUser, ok := s.dmRecipient(w, r, userID, req.OtherUserID)
+	if !ok {
 		return
 	}
 
@@ -569,32 +1247,15 @@ func (s *Server) handleGetOrCreateDM(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		participants, err := s.queries.GetConversationParticipants(r.Context(), existingConv.ID)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 			return
 		}
 
-		var otherUserInfo *struct {
-			ID              int64   `json:"id"`
-			Username        string  `json:"username"`
-			ProfileImageURL *string `json:"profileImageUrl"`
-		}
-
+		var otherUserInfo *conversationUserResponse
 		for _, p := range participants {
 			if p.ID != userID {
-				var profileImageURL *string
-				if p.ProfileImageHash != nil {
-					url := fmt.Sprintf("/api/profile/image/%s", *p.ProfileImageHash)
-					profileImageURL = &url
-				}
-				otherUserInfo = &struct {
-					ID              int64   `json:"id"`
-					Username        string  `json:"username"`
-					ProfileImageURL *string `json:"profileImageUrl"`
-				}{
-					ID:              p.ID,
-					Username:        p.Username,
-					ProfileImageURL: profileImageURL,
-				}
+				otherUserInfo = newConversationUser(p.ID, p.Username, p.ProfileImageHash)
+				setPresence(otherUserInfo, evtMgr.isConnected(p.ID), evtMgr.lastSeenTimes([]int64{p.ID}))
 				break
 			}
 		}
@@ -606,6 +1267,8 @@ func (s *Server) handleGetOrCreateDM(w http.ResponseWriter, r *http.Request) {
 			Name:           existingConv.Name,
 			LastMessageSeq: existingConv.LastMessageSeq,
 			UnreadCount:    0,
+			RetentionDays:  existingConv.RetentionDays,
+			ReadOnly:       existingConv.ReadonlyForMembers,
 			OtherUser:      otherUserInfo,
 		})
 		return
@@ -613,7 +1276,7 @@ func (s *Server) handleGetOrCreateDM(w http.ResponseWriter, r *http.Request) {
 
 	tx, err := s.queries.Begin()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 	defer tx.Rollback()
@@ -621,30 +1284,27 @@ func (s *Server) handleGetOrCreateDM(w http.ResponseWriter, r *http.Request) {
 	name := ""
 	conv, err := tx.CreateConversation(r.Context(), "dm", &name)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	if err := tx.AddConversationParticipant(r.Context(), conv.ID, userID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	if err := tx.AddConversationParticipant(r.Context(), conv.ID, req.OtherUserID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	if err := tx.Commit(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
-	var profileImageURL *string
-	if otherUser.ProfileImageHash != nil {
-		url := fmt.Sprintf("/api/profile/image/%s", *otherUser.ProfileImageHash)
-		profileImageURL = &url
-	}
+	otherUserInfo := newConversationUser(otherUser.ID, otherUser.Username, otherUser.ProfileImageHash)
+	setPresence(otherUserInfo, evtMgr.isConnected(otherUser.ID), evtMgr.lastSeenTimes([]int64{otherUser.ID}))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(conversationResponse{
@@ -653,14 +1313,6 @@ func (s *Server) handleGetOrCreateDM(w http.ResponseWriter, r *http.Request) {
 		Name:           conv.Name,
 		LastMessageSeq: conv.LastMessageSeq,
 		UnreadCount:    0,
-		OtherUser: &struct {
-			ID              int64   `json:"id"`
-			Username        string  `json:"username"`
-			ProfileImageURL *string `json:"profileImageUrl"`
-		}{
-			ID:              otherUser.ID,
-			Username:        otherUser.Username,
-			ProfileImageURL: profileImageURL,
-		},
+		OtherUser:      otherUserInfo,
 	})
 }
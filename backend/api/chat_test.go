@@ -0,0 +1,165 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+func TestSanitizeMessageBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantStatus  int
+	}{
+		{"markdown is sanitized", messaging.ContentTypeMarkdown, "hi <img src=x onerror=alert(1)>", `hi <img src="x">`, http.StatusOK},
+		{"markdown link scheme", messaging.ContentTypeMarkdown, "[x](javascript:alert(1))", "[x](#(1))", http.StatusOK},
+		{"plain text is kept", messaging.ContentTypePlain, "<script>alert(1)</script>", "<script>alert(1)</script>", http.StatusOK},
+		{"nothing left", messaging.ContentTypeMarkdown, "<script></script>", "", http.StatusBadRequest},
+		{"only whitespace left", messaging.ContentTypeMarkdown, " <iframe src=\"https://evil.example\"></iframe>\n", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			got, ok := sanitizeMessageBody(rec, tt.contentType, tt.body)
+
+			if tt.wantStatus != http.StatusOK {
+				if ok {
+					t.Fatalf("sanitizeMessageBody accepted %q as %q", tt.body, got)
+				}
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+				}
+				var resp ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("decoding error: %v", err)
+				}
+				if resp.Error.Code != ErrCodeValidation || resp.Error.Field != "body" {
+					t.Errorf("error = %+v, want a validation error for body", resp.Error)
+				}
+				return
+			}
+
+			if !ok {
+				t.Fatalf("sanitizeMessageBody rejected %q: %s", tt.body, rec.Body)
+			}
+			if got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMessageLength(t *testing.T) {
+	s := &Server{maxMessageLength: 5}
+	tests := []struct {
+		name string
+		body string
+		ok   bool
+	}{
+		{"at the limit", "hello", true},
+		{"runes, not bytes", "héllö", true},
+		{"surrounding whitespace ignored", "  hello\n", true},
+		{"one over", "hello!", false},
+		{"multibyte over", "héllö!", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if ok := s.checkMessageLength(rec, tt.body); ok != tt.ok {
+				t.Fatalf("checkMessageLength(%q) = %v, want %v", tt.body, ok, tt.ok)
+			}
+			if tt.ok {
+				return
+			}
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			var resp struct {
+				ErrorResponse
+				MaxLength    int `json:"maxLength"`
+				ActualLength int `json:"actualLength"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding error: %v", err)
+			}
+			if resp.Error.Code != ErrCodeValidation || resp.Error.Field != "body" {
+				t.Errorf("error = %+v, want a validation error for body", resp.Error)
+			}
+			if resp.MaxLength != 5 || resp.ActualLength != 6 {
+				t.Errorf("maxLength = %d, actualLength = %d, want 5 and 6", resp.MaxLength, resp.ActualLength)
+			}
+		})
+	}
+}
+
+func TestDMRecipient(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	sender := createTestUser(t, s, "sender")
+	recipient := createTestUser(t, s, "recipient")
+	deleted := createTestUser(t, s, "deleted")
+	blocking := createTestUser(t, s, "blocking")
+
+	if err := s.queries.SoftDeleteUser(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.queries.BlockUser(ctx, blocking.ID, sender.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		otherID    int64
+		wantStatus int
+	}{
+		{"recipient", recipient.ID, http.StatusOK},
+		{"unknown user", recipient.ID + 100, http.StatusNotFound},
+		{"deleted user", deleted.ID, http.StatusNotFound},
+		{"blocked by recipient", blocking.ID, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/messages/send", nil)
+			_, ok := s.dmRecipient(rec, r, sender.ID, tt.otherID)
+			if ok != (tt.wantStatus == http.StatusOK) || rec.Code != tt.wantStatus {
+				t.Fatalf("ok = %v, status = %d, want %d", ok, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSendMessageValidatesBeforeRateLimit(t *testing.T) {
+	s := newTestServer(t)
+	s.messageRateLimit = 5
+	user := createTestUser(t, s, "alice")
+
+	for range s.messageRateLimit + 1 {
+		r := exportRequest(http.MethodPost, "/api/messages/send", user.ID)
+		r.Body = io.NopCloser(strings.NewReader(`{"conversationId":1,"body":"  "}`))
+		rec := httptest.NewRecorder()
+		s.handleSendMessage(rec, r)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	if tokens := s.limiterFor(user.ID).Tokens(); tokens < float64(s.messageRateLimit)-1 {
+		t.Errorf("limiter has %.0f tokens left, want the full quota of %d", tokens, s.messageRateLimit)
+	}
+}
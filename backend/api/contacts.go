@@ -0,0 +1,182 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const maxContactsPerUser = 200
+
+type contactResponse struct {
+	UserID          int64   `json:"userId"`
+	Username        string  `json:"username"`
+	ProfileImageURL *string `json:"profileImageUrl"`
+	Online          bool    `json:"online"`
+	StatusMessage   string  `json:"statusMessage"`
+}
+
+type addContactRequest struct {
+	UserID int64 `json:"userId"`
+}
+
+// handleContacts lists the contacts of the current user alphabetically or
+// adds a contact. Contacts are private, so the added user is not notified.
+func (s *Server) handleContacts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListContacts(w, r)
+	case http.MethodPost:
+		s.handleAddContact(w, r)
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) handleListContacts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	contacts, err := s.queries.ListContacts(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := make([]contactResponse, len(contacts))
+	for i, c := range contacts {
+		response[i] = newContactResponse(c.ID, c.Username, c.ProfileImageHash, c.StatusMessage)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleAddContact(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	var req addContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	if req.UserID == userID {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Cannot add yourself as a contact", "userId")
+		return
+	}
+
+	contact, err := s.queries.GetUser(r.Context(), req.UserID)
+	if err != nil || contact.DeletedAt != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	blocked, err := s.queries.IsBlockedBetween(r.Context(), userID, contact.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	if blocked > 0 {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	existing, err := tx.IsContact(r.Context(), userID, contact.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if existing == 0 {
+		count, err := tx.CountContacts(r.Context(), userID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+		if count >= maxContactsPerUser {
+			WriteError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("At most %d contacts can be added", maxContactsPerUser))
+			return
+		}
+	}
+
+	if err := tx.AddContact(r.Context(), userID, contact.ID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to add contact")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to add contact")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newContactResponse(contact.ID, contact.Username, contact.ProfileImageHash, contact.StatusMessage))
+}
+
+// handleDeleteContact removes a user from the contacts of the current user.
+func (s *Server) handleDeleteContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	contactID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid user ID")
+		return
+	}
+
+	deleted, err := s.queries.RemoveContact(r.Context(), userID, contactID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	if deleted == 0 {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Contact not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func newContactResponse(id int64, username string, profileImageHash *string, statusMessage string) contactResponse {
+	var profileImageURL *string
+	if profileImageHash != nil {
+		url := fmt.Sprintf("/api/profile/image/%s?size=128", *profileImageHash)
+		profileImageURL = &url
+	}
+
+	return contactResponse{
+		UserID:          id,
+		Username:        username,
+		ProfileImageURL: profileImageURL,
+		Online:          evtMgr.isConnected(id),
+		StatusMessage:   statusMessage,
+	}
+}
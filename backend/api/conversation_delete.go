@@ -0,0 +1,204 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	// pendingDeletionWindow is how long an archive token can confirm the
+	// deletion of a conversation.
+	pendingDeletionWindow = 24 * time.Hour
+	// pendingDeletionCleanup is the age after which unconfirmed deletions are
+	// dropped by the background job.
+	pendingDeletionCleanup = 25 * time.Hour
+)
+
+type deleteConversationRequest struct {
+	ArchiveToken string `json:"archiveToken"`
+}
+
+// conversationDeletedEvent is the payload of conversation.deleted events.
+type conversationDeletedEvent struct {
+	ConversationID int64 `json:"conversationId"`
+}
+
+type pendingDeletionResponse struct {
+	ArchiveToken string `json:"archiveToken"`
+	ExportURL    string `json:"exportUrl"`
+	ExpiresAt    string `json:"expiresAt"`
+}
+
+// handleDeleteConversation deletes a conversation in two steps so that it can
+// be exported first. A request without an archive token marks the
+// conversation as pending deletion and answers with a token and the export
+// URL. Repeating the request with that token within pendingDeletionWindow
+// deletes the conversation with all its messages and participants. The same
+// rights as for changing the conversation settings are required.
+func (s *Server) handleDeleteConversation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	// The body is optional on the first request.
+	var req deleteConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	conv, err := s.queries.GetConversationByID(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Conversation not found")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if conv.Type != "dm" {
+		requester, err := s.queries.GetConversationMember(r.Context(), conversationID, userID)
+		if err != nil || requester.Role != memberRoleAdmin {
+			WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Conversation admin rights required")
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+
+	if req.ArchiveToken == "" {
+		token, err := auth.GenerateToken()
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+
+		if err := s.queries.SetConversationPendingDeletion(r.Context(), &now, &token, conversationID); err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete conversation")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(pendingDeletionResponse{
+			ArchiveToken: token,
+			ExportURL:    fmt.Sprintf("/api/%s/conversations/%d/export", apiVersion, conversationID),
+			ExpiresAt:    now.Add(pendingDeletionWindow).Format(time.RFC3339),
+		})
+		return
+	}
+
+	if conv.ArchiveToken == nil || conv.PendingDeletionAt == nil ||
+		now.Sub(*conv.PendingDeletionAt) > pendingDeletionWindow ||
+		subtle.ConstantTimeCompare([]byte(req.ArchiveToken), []byte(*conv.ArchiveToken)) != 1 {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Invalid or expired archive token", "archiveToken")
+		return
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	if err := tx.DeleteConversationMessages(r.Context(), conversationID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete conversation")
+		return
+	}
+
+	if err := tx.DeleteConversationParticipants(r.Context(), conversationID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete conversation")
+		return
+	}
+
+	if err := tx.DeleteConversation(r.Context(), conversationID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete conversation")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete conversation")
+		return
+	}
+
+	s.decryptCache.removeConversation(conversationID)
+
+	// The participants are gone from the database, so the event is sent to
+	// the list loaded before the deletion.
+	for _, p := range participants {
+		evtMgr.broadcast(p.ID, Event{
+			Type: EventTypeConversationDeleted,
+			Data: conversationDeletedEvent{ConversationID: conversationID},
+		})
+	}
+
+	s.auditLog(r, userID, auditActionConversationDelete, "conversation", conversationID, map[string]any{
+		"type":         conv.Type,
+		"participants": len(participants),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// restorePendingDeletions drops deletions that were never confirmed every
+// retentionTick until stop is closed, so the conversations return to normal.
+func (s *Server) restorePendingDeletions(stop <-chan struct{}) {
+	ticker := time.NewTicker(retentionTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			cutoff := time.Now().UTC().Add(-pendingDeletionCleanup)
+			restored, err := s.queries.ClearStalePendingDeletions(ctx, &cutoff)
+			cancel()
+			if err != nil {
+				log.Printf("failed to restore conversations pending deletion: %v", err)
+				continue
+			}
+			if restored > 0 {
+				log.Printf("restored %d conversations pending deletion", restored)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+const conversationExportPageSize = 500
+
+type conversationExportMetadata struct {
+	ID        int64   `json:"id"`
+	Type      string  `json:"type"`
+	Name      *string `json:"name"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+func (s *Server) handleConversationExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Format must be json or csv", "format")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	conversation, err := s.queries.GetConversationByID(r.Context(), conversationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	filename := fmt.Sprintf("conversation-%d-%s.%s", conversationID, time.Now().UTC().Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = s.writeConversationCSV(w, r, conversationID)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = s.writeConversationJSON(w, r, conversation, participants)
+	}
+
+	if err != nil {
+		log.Printf("Failed to export conversation %d: %v", conversationID, err)
+	}
+}
+
+// forEachExportMessage pages through all messages of a conversation in
+// chronological order so large conversations never have to be held in memory.
+func (s *Server) forEachExportMessage(r *http.Request, conversationID int64, fn func(messageResponse) error) error {
+	afterSeq := int64(0)
+	for {
+		messages, err := s.readQueries.GetConversationMessagesForExport(r.Context(), conversationID, afterSeq, conversationExportPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range messages {
+			resp := s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
+				msg.SenderUsername, msg.SenderProfileImageHash, msg.CreatedAt, msg.EditedAt,
+				msg.ContentType, msg.Body, msg.ReplyToID, "", "")
+
+			if msg.DeletedAt != nil {
+				resp.Body = "[deleted]"
+			}
+
+			if err := fn(resp); err != nil {
+				return err
+			}
+			afterSeq = msg.Seq
+		}
+
+		if len(messages) < conversationExportPageSize {
+			return nil
+		}
+	}
+}
+
+func (s *Server) writeConversationJSON(w http.ResponseWriter, r *http.Request, conversation db.Conversation,
+	participants []db.GetConversationParticipantsRow) error {
+
+	metadata, err := json.Marshal(conversationExportMetadata{
+		ID:        conversation.ID,
+		Type:      conversation.Type,
+		Name:      conversation.Name,
+		CreatedAt: conversation.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	})
+	if err != nil {
+		return err
+	}
+
+	participantResults := make([]userSearchResult, len(participants))
+	for i, p := range participants {
+		participantResults[i] = userSearchResult{ID: p.ID, Username: p.Username}
+	}
+
+	participantData, err := json.Marshal(participantResults)
+	if err != nil {
+		return err
+	}
+
+	io.WriteString(w, `{"conversation":`)
+	w.Write(metadata)
+	io.WriteString(w, `,"participants":`)
+	w.Write(participantData)
+	io.WriteString(w, `,"messages":[`)
+
+	first := true
+	err = s.forEachExportMessage(r, conversation.ID, func(msg messageResponse) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if !first {
+			io.WriteString(w, ",")
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+func (s *Server) writeConversationCSV(w http.ResponseWriter, r *http.Request, conversationID int64) error {
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+
+	if err := writer.Write([]string{"timestamp", "sender_username", "content_type", "body"}); err != nil {
+		return err
+	}
+
+	err := s.forEachExportMessage(r, conversationID, func(msg messageResponse) error {
+		if err := writer.Write([]string{msg.CreatedAt, msg.SenderUsername, msg.ContentType, msg.Body}); err != nil {
+			return err
+		}
+		if msg.Seq%conversationExportPageSize == 0 {
+			writer.Flush()
+		}
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
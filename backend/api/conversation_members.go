@@ -0,0 +1,217 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	memberRoleAdmin  = "admin"
+	memberRoleMember = "member"
+)
+
+type conversationMemberResponse struct {
+	UserID          int64   `json:"userId"`
+	Username        string  `json:"username"`
+	ProfileImageURL *string `json:"profileImageUrl"`
+	Role            string  `json:"role"`
+	JoinedAt        string  `json:"joinedAt"`
+}
+
+// memberUpdatedEvent is the payload of conversation.member.updated events.
+type memberUpdatedEvent struct {
+	ConversationID int64 `json:"conversationId"`
+	conversationMemberResponse
+}
+
+type updateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+func newConversationMemberResponse(id int64, username string, profileImageHash *string, role string, joinedAt time.Time) conversationMemberResponse {
+	var profileImageURL *string
+	if profileImageHash != nil {
+		url := fmt.Sprintf("/api/profile/image/%s?size=128", *profileImageHash)
+		profileImageURL = &url
+	}
+
+	return conversationMemberResponse{
+		UserID:          id,
+		Username:        username,
+		ProfileImageURL: profileImageURL,
+		Role:            role,
+		JoinedAt:        joinedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// handleConversationMembers lists the participants of a conversation with
+// their role. Every participant may see the list.
+func (s *Server) handleConversationMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	members, err := s.queries.ListConversationMembers(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, m := range members {
+		if m.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	response := make([]conversationMemberResponse, len(members))
+	for i, m := range members {
+		response[i] = newConversationMemberResponse(m.ID, m.Username, m.ProfileImageHash, m.Role, m.JoinedAt)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleConversationMember changes the role of a participant of a group
+// conversation. Only admins of the conversation may do so, and the last admin
+// cannot give up the role.
+func (s *Server) handleConversationMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	memberID, err := strconv.ParseInt(r.PathValue("userId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid user ID")
+		return
+	}
+
+	var req updateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	if req.Role != memberRoleAdmin && req.Role != memberRoleMember {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Role must be admin or member", "role")
+		return
+	}
+
+	conv, err := s.queries.GetConversationByID(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Conversation not found")
+		return
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	requester, err := tx.GetConversationMember(r.Context(), conversationID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if conv.Type == "dm" {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Direct messages have no roles")
+		return
+	}
+
+	if requester.Role != memberRoleAdmin {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Conversation admin rights required")
+		return
+	}
+
+	member, err := tx.GetConversationMember(r.Context(), conversationID, memberID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Member not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if member.Role == memberRoleAdmin && req.Role == memberRoleMember {
+		admins, err := tx.CountConversationAdmins(r.Context(), conversationID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+		if admins <= 1 {
+			WriteError(w, http.StatusConflict, ErrCodeConflict, "A conversation needs at least one admin")
+			return
+		}
+	}
+
+	if err := tx.SetConversationMemberRole(r.Context(), req.Role, conversationID, memberID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update role")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update role")
+		return
+	}
+
+	response := newConversationMemberResponse(member.ID, member.Username, member.ProfileImageHash, req.Role, member.JoinedAt)
+
+	if member.Role != req.Role {
+		go evtMgr.broadcastToConversation(s, conversationID, Event{
+			Type: EventTypeConversationMemberUpdated,
+			Data: memberUpdatedEvent{ConversationID: conversationID, conversationMemberResponse: response},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
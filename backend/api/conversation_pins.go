@@ -0,0 +1,116 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const defaultMaxPinnedConversations = 5
+
+type pinResponse struct {
+	Pinned   bool    `json:"pinned"`
+	PinnedAt *string `json:"pinnedAt,omitempty"`
+}
+
+// handleConversationPin pins a conversation to the top of the current user's
+// conversation list or unpins it. Pins are private to the user, so the other
+// participants are not notified.
+func (s *Server) handleConversationPin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := s.queries.UnpinConversation(r.Context(), userID, conversationID); err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to unpin conversation")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pinResponse{Pinned: false})
+		return
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.GetConversationPin(r.Context(), userID, conversationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		count, err := tx.CountUserPins(r.Context(), userID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+		if count >= int64(s.maxPinnedConversations) {
+			WriteError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("At most %d conversations can be pinned", s.maxPinnedConversations))
+			return
+		}
+	} else if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if err := tx.PinConversation(r.Context(), userID, conversationID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to pin conversation")
+		return
+	}
+
+	pin, err := tx.GetConversationPin(r.Context(), userID, conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to pin conversation")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to pin conversation")
+		return
+	}
+
+	pinnedAt := pin.PinnedAt.Format("2006-01-02T15:04:05Z")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pinResponse{Pinned: true, PinnedAt: &pinnedAt})
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+const maxConversationNameLength = 100
+
+type renameConversationRequest struct {
+	Name string `json:"name"`
+}
+
+// handleRenameConversation changes the name of a group conversation. Only
+// admins of the conversation may do so. The rename is recorded as a system
+// message, and every participant, including the one who renamed it, receives
+// a conversation.updated event.
+func (s *Server) handleRenameConversation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	var req renameConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Name is required", "name")
+		return
+	}
+	if utf8.RuneCountInString(name) > maxConversationNameLength {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Name must be at most 100 characters", "name")
+		return
+	}
+
+	conv, err := s.queries.GetConversationByID(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Conversation not found")
+		return
+	}
+
+	requester, err := s.queries.GetConversationMember(r.Context(), conversationID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if conv.Type == "dm" {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Direct messages cannot be renamed")
+		return
+	}
+
+	if requester.Role != memberRoleAdmin {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Conversation admin rights required")
+		return
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	if err := tx.UpdateConversationName(r.Context(), &name, conversationID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to rename conversation")
+		return
+	}
+
+	message, err := storeSystemMessage(r.Context(), tx, conversationID, messaging.SystemEvent{
+		Action:  messaging.ActionConversationRenamed,
+		ActorID: userID,
+		Name:    name,
+	})
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to rename conversation")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to rename conversation")
+		return
+	}
+
+	if _, err := s.announceMessage(r.Context(), message, message.Body); err != nil {
+		log.Printf("Failed to announce rename of conversation %d: %v", conversationID, err)
+	}
+	go s.BroadcastConversationUpdate(conversationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"name":    name,
+	})
+}
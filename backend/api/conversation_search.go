@@ -0,0 +1,90 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+// handleSearchConversations finds conversations of the current user by the
+// name of group conversations or the username of the other user of direct
+// messages. type narrows the search to dm or group conversations. Results are
+// paginated and ordered like GET /api/conversations.
+func (s *Server) handleSearchConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	query := r.URL.Query()
+
+	var conversationType *string
+	switch typeStr := query.Get("type"); typeStr {
+	case "", "all":
+	case "dm", "group":
+		conversationType = &typeStr
+	default:
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Type must be dm, group or all", "type")
+		return
+	}
+
+	limit, beforeID, ok := parseConversationPage(w, query)
+	if !ok {
+		return
+	}
+
+	sort, ok := s.conversationSort(w, r, userID)
+	if !ok {
+		return
+	}
+
+	pattern := "%" + escapeLike(strings.TrimSpace(query.Get("q"))) + "%"
+	rows, err := s.queries.SearchUserConversations(r.Context(), userID, conversationType, pattern, beforeID, sort, limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	unread, err := s.unreadCounts(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	hasMore := int64(len(rows)) == limit
+	if hasMore {
+		rows = rows[:len(rows)-1]
+	}
+
+	conversations := make([]db.GetUserConversationsRow, len(rows))
+	for i, row := range rows {
+		conversations[i] = db.GetUserConversationsRow(row)
+	}
+	response := s.conversationResponses(r.Context(), userID, conversations)
+
+	page := conversationPage{Conversations: response, HasMore: hasMore, TotalUnread: unread.TotalUnread}
+	if hasMore {
+		page.NextCursor = &response[len(response)-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike escapes the wildcards of a LIKE pattern so that s matches
+// literally. The query has to declare ESCAPE '\'.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,18 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"team":      "team",
+		"100%":      `100\%`,
+		"a_b":       `a\_b`,
+		`back\path`: `back\\path`,
+	}
+	for in, want := range tests {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
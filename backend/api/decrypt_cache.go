@@ -0,0 +1,95 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/bloodmagesoftware/teamsync/crypto"
+)
+
+const defaultDecryptCacheSize = 1000
+
+type decryptCacheKey struct {
+	messageID      int64
+	conversationID int64
+}
+
+type decryptCacheEntry struct {
+	key        decryptCacheKey
+	ciphertext string
+	plaintext  string
+}
+
+// decryptCache holds the plaintext of recently decrypted messages, least
+// recently used first out. Entries remember their ciphertext, so an edited
+// message is decrypted again even if its entry was not removed.
+type decryptCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[decryptCacheKey]*list.Element
+}
+
+func newDecryptCache(capacity int) *decryptCache {
+	return &decryptCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[decryptCacheKey]*list.Element),
+	}
+}
+
+// decrypt returns the plaintext of a message body, decrypting it only when it
+// is not cached.
+func (c *decryptCache) decrypt(messageID, conversationID int64, ciphertext string) (string, error) {
+	key := decryptCacheKey{messageID: messageID, conversationID: conversationID}
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*decryptCacheEntry)
+		if entry.ciphertext == ciphertext {
+			c.order.MoveToFront(element)
+			c.mu.Unlock()
+			return entry.plaintext, nil
+		}
+	}
+	c.mu.Unlock()
+
+	plaintext, err := crypto.DecryptMessage(ciphertext, conversationID)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*decryptCacheEntry)
+		entry.ciphertext = ciphertext
+		entry.plaintext = plaintext
+		c.order.MoveToFront(element)
+		return plaintext, nil
+	}
+
+	c.entries[key] = c.order.PushFront(&decryptCacheEntry{key: key, ciphertext: ciphertext, plaintext: plaintext})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*decryptCacheEntry).key)
+	}
+	return plaintext, nil
+}
+
+// removeConversation drops the cached plaintext of all messages of a
+// conversation.
+func (c *decryptCache) removeConversation(conversationID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.entries {
+		if key.conversationID == conversationID {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
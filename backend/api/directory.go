@@ -0,0 +1,161 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	defaultDirectoryLimit = 50
+	maxDirectoryLimit     = 100
+)
+
+type directoryUserResponse struct {
+	*conversationUserResponse
+	StatusMessage string `json:"statusMessage"`
+}
+
+type userProfileResponse struct {
+	*conversationUserResponse
+	Bio           string `json:"bio"`
+	StatusMessage string `json:"statusMessage"`
+	StatusEmoji   string `json:"statusEmoji"`
+}
+
+// handleUserDirectory lists the users of the server alphabetically, page by
+// page, with the total number in the X-Total-Count header. Users blocking or
+// blocked by the current user are left out. Administrators also see bots and
+// suspended users.
+func (s *Server) handleUserDirectory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	if !s.allowDirectory(w, userID) {
+		return
+	}
+
+	page := int64(1)
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		parsed, err := strconv.ParseInt(pageStr, 10, 64)
+		if err != nil || parsed < 1 {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Page must be a positive number", "page")
+			return
+		}
+		page = parsed
+	}
+
+	limit := int64(defaultDirectoryLimit)
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed < 1 {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Limit must be a positive number", "limit")
+			return
+		}
+		limit = min(parsed, maxDirectoryLimit)
+	}
+
+	user, err := s.queries.GetUser(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	now := time.Now().UTC()
+
+	total, err := s.queries.CountDirectoryUsers(r.Context(), user.IsAdmin, &now, userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	users, err := s.queries.ListDirectoryUsers(r.Context(), user.IsAdmin, &now, userID, limit, (page-1)*limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	ids := make([]int64, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	online := make(map[int64]bool)
+	for _, id := range evtMgr.GetOnlineUserIDs(ids) {
+		online[id] = true
+	}
+	lastSeen := evtMgr.lastSeenTimes(ids)
+
+	response := make([]directoryUserResponse, len(users))
+	for i, u := range users {
+		response[i] = directoryUserResponse{
+			conversationUserResponse: newConversationUser(u.ID, u.Username, u.ProfileImageHash),
+			StatusMessage:            u.StatusMessage,
+		}
+		setPresence(response[i].conversationUserResponse, online[u.ID], lastSeen)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleUserProfile returns the public profile of a user, which any signed in
+// user may see. Deleted users and users who blocked the current user are
+// reported as not found.
+func (s *Server) handleUserProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	targetID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid user ID")
+		return
+	}
+
+	target, err := s.queries.GetUser(r.Context(), targetID)
+	if err != nil || target.DeletedAt != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	blocked, err := s.queries.HasBlocked(r.Context(), targetID, userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	if blocked > 0 {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	profile := userProfileResponse{
+		conversationUserResponse: newConversationUser(target.ID, target.Username, target.ProfileImageHash),
+		Bio:                      target.Bio,
+		StatusMessage:            target.StatusMessage,
+		StatusEmoji:              target.StatusEmoji,
+	}
+	setPresence(profile.conversationUserResponse, evtMgr.isConnected(target.ID), evtMgr.lastSeenTimes([]int64{target.ID}))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
@@ -0,0 +1,52 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes returned in ErrorResponse. Clients switch on these instead of
+// parsing messages.
+const (
+	ErrCodeAuthRequired       = "AUTH_REQUIRED"
+	ErrCodeInvalidCredentials = "INVALID_CREDENTIALS"
+	ErrCodeForbidden          = "FORBIDDEN"
+	ErrCodeAccountSuspended   = "ACCOUNT_SUSPENDED"
+	ErrCodeNotFound           = "NOT_FOUND"
+	ErrCodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+	ErrCodeInvalidInput       = "INVALID_INPUT"
+	ErrCodeValidation         = "VALIDATION_ERROR"
+	ErrCodeConflict           = "CONFLICT"
+	ErrCodeRateLimited        = "RATE_LIMITED"
+	ErrCodePayloadTooLarge    = "PAYLOAD_TOO_LARGE"
+	ErrCodeInternal           = "INTERNAL_ERROR"
+)
+
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+// ErrorResponse is the body of every error returned by the API.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// WriteError writes an ErrorResponse with the given status code.
+func WriteError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorResponse(w, status, ErrorResponse{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+// WriteFieldError writes an ErrorResponse that points at the request field
+// which failed validation.
+func WriteFieldError(w http.ResponseWriter, status int, code, message, field string) {
+	writeErrorResponse(w, status, ErrorResponse{Error: ErrorDetail{Code: code, Message: message, Field: field}})
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -5,72 +5,144 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/messaging"
 )
 
 type EventType string
 
 const (
-	EventTypeMessageNew EventType = "message.new"
-	EventTypeKeepAlive  EventType = "keepalive"
+	EventTypeMessageNew     EventType = "message.new"
+	EventTypeMessageEdited  EventType = "message.edited"
+	EventTypeCallRejected   EventType = "call.rejected"
+	EventTypeCallMissed     EventType = "call.missed"
+	EventTypeKeepAlive      EventType = "keepalive"
+	EventTypeEvicted        EventType = "evicted"
+	EventTypeUnreadCount    EventType = "notification.unread"
+	EventTypeThreadReply    EventType = "thread.reply"
+	EventTypePollVote       EventType = "poll.vote"
+	EventTypeServerShutdown EventType = "server.shutdown"
+
+	EventTypeCallAdminTerminated       EventType = "call.admin_terminated"
+	EventTypeConversationUpdated       EventType = "conversation.updated"
+	EventTypeConversationMemberUpdated EventType = "conversation.member.updated"
+	EventTypeConversationDeleted       EventType = "conversation.deleted"
+	EventTypeTyping                    EventType = "conversation.typing"
+	EventTypeStoppedTyping             EventType = "conversation.stopped_typing"
 )
 
+const defaultSSEMaxClientsPerUser = 5
+
 type Event struct {
 	Type EventType   `json:"type"`
 	Data interface{} `json:"data"`
 }
 
 type eventManager struct {
-	mu       sync.RWMutex
-	clients  map[int64]map[chan Event]bool
+	mu sync.RWMutex
+	// clients holds the streams of each user in the order they connected.
+	clients           map[int64][]chan Event
+	maxClientsPerUser int
+	// lastSeen holds when the last stream of a user was closed.
+	lastSeen map[int64]time.Time
 	shutdown chan struct{}
 }
 
 var evtMgr = &eventManager{
-	clients:  make(map[int64]map[chan Event]bool),
-	shutdown: make(chan struct{}),
+	clients:           make(map[int64][]chan Event),
+	lastSeen:          make(map[int64]time.Time),
+	maxClientsPerUser: defaultSSEMaxClientsPerUser,
+	shutdown:          make(chan struct{}),
 }
 
+// addClient registers a stream of a user. When the user already has
+// maxClientsPerUser streams, the oldest one receives an evicted event and is
+// closed.
 func (em *eventManager) addClient(userID int64, ch chan Event) {
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
-	if em.clients[userID] == nil {
-		em.clients[userID] = make(map[chan Event]bool)
+	select {
+	case <-em.shutdown:
+		ch <- Event{Type: EventTypeServerShutdown}
+		close(ch)
+		return
+	default:
+	}
+
+	clients := append(em.clients[userID], ch)
+	for len(clients) > em.maxClientsPerUser {
+		oldest := clients[0]
+		select {
+		case oldest <- Event{Type: EventTypeEvicted}:
+		default:
+		}
+		close(oldest)
+		clients = clients[1:]
 	}
-	em.clients[userID][ch] = true
+	em.clients[userID] = clients
 }
 
 func (em *eventManager) removeClient(userID int64, ch chan Event) {
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
-	if clients, ok := em.clients[userID]; ok {
-		if _, exists := clients[ch]; exists {
-			delete(clients, ch)
-			close(ch)
-			if len(clients) == 0 {
-				delete(em.clients, userID)
-			}
-		}
+	clients := em.clients[userID]
+	index := slices.Index(clients, ch)
+	if index < 0 {
+		return
+	}
+
+	close(ch)
+	clients = slices.Delete(clients, index, index+1)
+	if len(clients) == 0 {
+		delete(em.clients, userID)
+		em.lastSeen[userID] = time.Now()
+	} else {
+		em.clients[userID] = clients
 	}
 }
 
-func (em *eventManager) shutdownAll() {
-	close(em.shutdown)
+// disconnectUser closes all event streams of a user.
+func (em *eventManager) disconnectUser(userID int64) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
 
+	for _, ch := range em.clients[userID] {
+		close(ch)
+	}
+	delete(em.clients, userID)
+	em.lastSeen[userID] = time.Now()
+}
+
+// shutdownAll ends every stream with a server.shutdown event. Streams opened
+// afterwards are ended right away.
+func (em *eventManager) shutdownAll() {
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
+	select {
+	case <-em.shutdown:
+		return
+	default:
+	}
+
+	close(em.shutdown)
 	for userID, clients := range em.clients {
-		for ch := range clients {
+		for _, ch := range clients {
+			select {
+			case ch <- Event{Type: EventTypeServerShutdown}:
+			default:
+			}
 			close(ch)
-			delete(clients, ch)
 		}
 		delete(em.clients, userID)
 	}
@@ -81,7 +153,7 @@ func (em *eventManager) broadcast(userID int64, event Event) {
 	defer em.mu.RUnlock()
 
 	if clients, ok := em.clients[userID]; ok {
-		for ch := range clients {
+		for _, ch := range clients {
 			select {
 			case ch <- event:
 			case <-time.After(time.Second):
@@ -90,7 +162,51 @@ func (em *eventManager) broadcast(userID int64, event Event) {
 	}
 }
 
+func (em *eventManager) isConnected(userID int64) bool {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	return len(em.clients[userID]) > 0
+}
+
+// GetOnlineUserIDs returns the subset of userIDs with at least one open event
+// stream.
+func (em *eventManager) GetOnlineUserIDs(userIDs []int64) []int64 {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	var online []int64
+	for _, userID := range userIDs {
+		if len(em.clients[userID]) > 0 {
+			online = append(online, userID)
+		}
+	}
+	return online
+}
+
+// lastSeenTimes returns when the last event stream of each of userIDs was
+// closed. Users that have not disconnected since the server started are
+// missing from the result.
+func (em *eventManager) lastSeenTimes(userIDs []int64) map[int64]time.Time {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	times := make(map[int64]time.Time)
+	for _, userID := range userIDs {
+		if seen, ok := em.lastSeen[userID]; ok {
+			times[userID] = seen
+		}
+	}
+	return times
+}
+
 func (em *eventManager) broadcastToConversation(s *Server, conversationID int64, event Event) {
+	em.broadcastToConversationExcept(s, conversationID, event, nil)
+}
+
+// broadcastToConversationExcept sends event to all participants of a
+// conversation whose IDs are not in exclude.
+func (em *eventManager) broadcastToConversationExcept(s *Server, conversationID int64, event Event, exclude map[int64]bool) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -103,8 +219,11 @@ func (em *eventManager) broadcastToConversation(s *Server, conversationID int64,
 	defer em.mu.RUnlock()
 
 	for _, p := range participants {
+		if exclude[p.ID] {
+			continue
+		}
 		if clients, ok := em.clients[p.ID]; ok {
-			for ch := range clients {
+			for _, ch := range clients {
 				select {
 				case ch <- event:
 				case <-time.After(time.Second):
@@ -117,7 +236,7 @@ func (em *eventManager) broadcastToConversation(s *Server, conversationID int64,
 func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 		return
 	}
 
@@ -128,7 +247,7 @@ func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -136,11 +255,17 @@ func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
 	evtMgr.addClient(userID, eventChan)
 	defer evtMgr.removeClient(userID, eventChan)
 
+	// Every write gets its own deadline instead of the server's
+	// WriteTimeout, which would end the stream. A stalled connection, such
+	// as a half-open one, fails its next write and the stream is closed.
+	controller := http.NewResponseController(w)
+
 	writeEvent := func(event Event) error {
 		data, err := json.Marshal(event)
 		if err != nil {
 			return err
 		}
+		controller.SetWriteDeadline(time.Now().Add(s.sseKeepAliveInterval))
 		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
 			return err
 		}
@@ -168,6 +293,8 @@ func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
 						msg.ContentType,
 						msg.Body,
 						msg.ReplyToID,
+						msg.ReactionsJson,
+						msg.UserReactionsJson,
 					)
 					if err := writeEvent(Event{
 						Type: EventTypeMessageNew,
@@ -180,7 +307,7 @@ func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	keepAliveTicker := time.NewTicker(30 * time.Second)
+	keepAliveTicker := time.NewTicker(s.sseKeepAliveInterval)
 	defer keepAliveTicker.Stop()
 
 	ctx := r.Context()
@@ -190,6 +317,13 @@ func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
 		case <-ctx.Done():
 			return
 		case <-evtMgr.shutdown:
+			// Deliver what was queued before the stream was closed, which
+			// ends with the server.shutdown event.
+			for event := range eventChan {
+				if err := writeEvent(event); err != nil {
+					return
+				}
+			}
 			return
 		case event, ok := <-eventChan:
 			if !ok {
@@ -217,9 +351,101 @@ func (s *Server) BroadcastMessage(userID int64, message messageResponse) {
 	})
 }
 
+// newMessageEvent is the payload of message.new events sent to conversation
+// participants. UnreadCount is the recipient's unread count of the
+// conversation including the message, so badges can be updated in place.
+type newMessageEvent struct {
+	messageResponse
+	UnreadCount int64 `json:"unreadCount"`
+}
+
+// broadcastNewMessage sends every connected participant not in exclude their
+// own copy of a message.new event.
+func (s *Server) broadcastNewMessage(conversationID int64, message messageResponse, exclude map[int64]bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	participants, err := s.queries.GetConversationParticipants(ctx, conversationID)
+	if err != nil {
+		return
+	}
+
+	for _, p := range participants {
+		if exclude[p.ID] || !evtMgr.isConnected(p.ID) {
+			continue
+		}
+
+		unreadCount, err := s.conversationUnreadCount(ctx, p.ID, conversationID)
+		if err != nil {
+			log.Printf("failed to count unread messages of user %d: %v", p.ID, err)
+			continue
+		}
+
+		evtMgr.broadcast(p.ID, Event{
+			Type: EventTypeMessageNew,
+			Data: newMessageEvent{messageResponse: message, UnreadCount: unreadCount},
+		})
+	}
+}
+
 func (s *Server) BroadcastMessageToConversation(conversationID int64, message messageResponse) {
-	evtMgr.broadcastToConversation(s, conversationID, Event{
-		Type: EventTypeMessageNew,
-		Data: message,
-	})
+	blocked := s.blockRelatedUsers(message.SenderID)
+
+	// Participants who only want to hear about some messages still receive
+	// unread counts, so they see that something was posted.
+	excluded := s.silencedUsers(conversationID, &message)
+	for userID := range blocked {
+		excluded[userID] = true
+	}
+
+	s.broadcastNewMessage(conversationID, message, excluded)
+
+	if message.ReplyToID != nil {
+		evtMgr.broadcastToConversationExcept(s, conversationID, Event{
+			Type: EventTypeThreadReply,
+			Data: message,
+		}, excluded)
+	}
+
+	// System messages are part of the history but not worth a notification.
+	if s.pushEnabled() && message.ContentType != messaging.ContentTypeSystem {
+		go s.sendPushNotifications(conversationID, message, excluded)
+	}
+
+	go s.deliverWebhooks(conversationID, message)
+	go s.broadcastConversationUnreadCounts(conversationID, blocked)
+}
+
+// BroadcastConversationUpdate sends every connected participant, including
+// the one who changed the conversation, their own view of it in a
+// conversation.updated event. The unread count is always 0, as the update is
+// not about unread messages.
+func (s *Server) BroadcastConversationUpdate(conversationID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	participants, err := s.queries.GetConversationParticipants(ctx, conversationID)
+	if err != nil {
+		return
+	}
+
+	for _, p := range participants {
+		if !evtMgr.isConnected(p.ID) {
+			continue
+		}
+
+		row, err := s.queries.GetUserConversation(ctx, p.ID, conversationID)
+		if err != nil {
+			log.Printf("failed to load conversation %d for user %d: %v", conversationID, p.ID, err)
+			continue
+		}
+
+		response := s.conversationResponses(ctx, p.ID, []db.GetUserConversationsRow{db.GetUserConversationsRow(row)})[0]
+		response.UnreadCount = 0
+
+		evtMgr.broadcast(p.ID, Event{
+			Type: EventTypeConversationUpdated,
+			Data: response,
+		})
+	}
 }
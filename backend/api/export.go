@@ -0,0 +1,387 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	exportDir     = "./data/exports"
+	exportJobTTL  = time.Hour
+	exportTimeout = 5 * time.Minute
+	// exportJobPruneTick is how often finished jobs older than exportJobTTL
+	// are removed, even if nobody starts another export.
+	exportJobPruneTick = 10 * time.Minute
+	// maxPendingExportsPerUser limits the exports a user may have running at
+	// the same time.
+	maxPendingExportsPerUser = 1
+	// exportMessagePageSize is the number of messages loaded at a time while
+	// they are written to the archive.
+	exportMessagePageSize = 500
+)
+
+type exportJob struct {
+	userID    int64
+	done      bool
+	err       error
+	path      string
+	createdAt time.Time
+}
+
+var (
+	exportJobs  = make(map[string]*exportJob)
+	exportMutex sync.Mutex
+)
+
+type exportProfile struct {
+	ID              int64   `json:"id"`
+	Username        string  `json:"username"`
+	ProfileImageURL *string `json:"profileImageUrl"`
+	IsAdmin         bool    `json:"isAdmin"`
+	CreatedAt       string  `json:"createdAt"`
+	UpdatedAt       string  `json:"updatedAt"`
+}
+
+type exportMessage struct {
+	messageResponse
+	DeletedAt *string `json:"deletedAt,omitempty"`
+}
+
+type exportConversation struct {
+	ID           int64              `json:"id"`
+	Type         string             `json:"type"`
+	Name         *string            `json:"name"`
+	CreatedAt    string             `json:"createdAt"`
+	Participants []userSearchResult `json:"participants"`
+}
+
+func (s *Server) handleUserExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	jobID := hex.EncodeToString(idBytes)
+
+	job := &exportJob{
+		userID:    userID,
+		path:      filepath.Join(exportDir, jobID+".zip"),
+		createdAt: time.Now(),
+	}
+
+	exportMutex.Lock()
+	pruneExportJobs()
+	if pendingExportJobs(userID) >= maxPendingExportsPerUser {
+		exportMutex.Unlock()
+		WriteError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "An export is already running")
+		return
+	}
+	exportJobs[jobID] = job
+	exportMutex.Unlock()
+
+	s.auditLog(r, userID, auditActionDataExport, "user", userID, map[string]any{"jobId": jobID})
+
+	go func() {
+		err := s.buildUserExport(userID, job.path)
+		if err != nil {
+			log.Printf("data export %s for user %d failed: %v", jobID, userID, err)
+			os.Remove(job.path)
+		}
+
+		exportMutex.Lock()
+		job.done = true
+		job.err = err
+		exportMutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/user/export/"+jobID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"jobId": jobID})
+}
+
+func (s *Server) handleUserExportDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	jobID := r.PathValue("jobId")
+
+	exportMutex.Lock()
+	job, ok := exportJobs[jobID]
+	var done bool
+	var jobErr error
+	if ok {
+		done = job.done
+		jobErr = job.err
+	}
+	exportMutex.Unlock()
+
+	if !ok || job.userID != userID {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+		return
+	}
+
+	if !done {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "pending"})
+		return
+	}
+
+	if jobErr != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Export failed")
+		return
+	}
+
+	file, err := os.Open(job.path)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+		return
+	}
+
+	filename := fmt.Sprintf("teamsync-export-%s.zip", job.createdAt.UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	_, err = io.Copy(w, file)
+	file.Close()
+	if err != nil {
+		// The client may retry an interrupted download until the job expires.
+		log.Printf("data export %s download for user %d failed: %v", jobID, userID, err)
+		return
+	}
+
+	// The archive holds all messages of the user, so it is not kept around
+	// once it was delivered.
+	exportMutex.Lock()
+	if exportJobs[jobID] == job {
+		delete(exportJobs, jobID)
+	}
+	exportMutex.Unlock()
+	os.Remove(job.path)
+}
+
+// pendingExportJobs returns the number of exports of userID that are still
+// being built. exportMutex must be held.
+func pendingExportJobs(userID int64) int {
+	pending := 0
+	for _, job := range exportJobs {
+		if job.userID == userID && !job.done {
+			pending++
+		}
+	}
+	return pending
+}
+
+// pruneExportJobs removes expired export jobs and their archives.
+// exportMutex must be held.
+func pruneExportJobs() {
+	for id, job := range exportJobs {
+		if job.done && time.Since(job.createdAt) > exportJobTTL {
+			os.Remove(job.path)
+			delete(exportJobs, id)
+		}
+	}
+}
+
+// expireExportJobs prunes export jobs every exportJobPruneTick until stop is
+// closed.
+func (s *Server) expireExportJobs(stop <-chan struct{}) {
+	ticker := time.NewTicker(exportJobPruneTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			exportMutex.Lock()
+			pruneExportJobs()
+			exportMutex.Unlock()
+		}
+	}
+}
+
+func (s *Server) buildUserExport(userID int64, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export archive: %w", err)
+	}
+	defer file.Close()
+
+	zw := zip.NewWriter(file)
+
+	user, err := s.readQueries.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+
+	var profileImageURL *string
+	if user.ProfileImageHash != nil {
+		url := fmt.Sprintf("/api/profile/image/%s", *user.ProfileImageHash)
+		profileImageURL = &url
+	}
+
+	if err := writeZipJSON(zw, "profile.json", exportProfile{
+		ID:              user.ID,
+		Username:        user.Username,
+		ProfileImageURL: profileImageURL,
+		IsAdmin:         user.IsAdmin,
+		CreatedAt:       user.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:       user.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}); err != nil {
+		return err
+	}
+
+	entry, err := zw.Create("messages.json")
+	if err != nil {
+		return fmt.Errorf("failed to create messages.json: %w", err)
+	}
+	io.WriteString(entry, "[\n")
+	// Messages are loaded page by page, so that only one page is held in
+	// memory no matter how many messages the user sent.
+	written := 0
+	for afterID := int64(0); ; {
+		messages, err := s.readQueries.ListMessagesBySender(ctx, userID, afterID, exportMessagePageSize)
+		if err != nil {
+			return fmt.Errorf("failed to load messages: %w", err)
+		}
+
+		for _, msg := range messages {
+			exported := exportMessage{
+				messageResponse: s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
+					user.Username, user.ProfileImageHash, msg.CreatedAt, msg.EditedAt,
+					msg.ContentType, msg.Body, msg.ReplyToID, "", ""),
+			}
+			if msg.DeletedAt != nil {
+				deletedAt := msg.DeletedAt.Format("2006-01-02T15:04:05Z")
+				exported.DeletedAt = &deletedAt
+			}
+			if written > 0 {
+				io.WriteString(entry, ",\n")
+			}
+			data, err := json.Marshal(exported)
+			if err != nil {
+				return fmt.Errorf("failed to encode message %d: %w", msg.ID, err)
+			}
+			if _, err := entry.Write(data); err != nil {
+				return fmt.Errorf("failed to write messages.json: %w", err)
+			}
+			written++
+		}
+
+		if len(messages) < exportMessagePageSize {
+			break
+		}
+		afterID = messages[len(messages)-1].ID
+	}
+	io.WriteString(entry, "\n]\n")
+
+	// A limit of -1 returns all conversations.
+	conversations, err := s.readQueries.GetUserConversations(ctx, userID, false, 0, conversationSortLastActivity, -1)
+	if err != nil {
+		return fmt.Errorf("failed to load conversations: %w", err)
+	}
+
+	exportedConversations := make([]exportConversation, 0, len(conversations))
+	for _, conv := range conversations {
+		participants, err := s.readQueries.GetConversationParticipants(ctx, conv.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load participants of conversation %d: %w", conv.ID, err)
+		}
+
+		exported := exportConversation{
+			ID:           conv.ID,
+			Type:         conv.Type,
+			Name:         conv.Name,
+			CreatedAt:    conv.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			Participants: make([]userSearchResult, len(participants)),
+		}
+		for i, p := range participants {
+			exported.Participants[i] = userSearchResult{ID: p.ID, Username: p.Username}
+		}
+		exportedConversations = append(exportedConversations, exported)
+	}
+
+	if err := writeZipJSON(zw, "conversations.json", exportedConversations); err != nil {
+		return err
+	}
+
+	invitations, err := s.readQueries.ListInvitationsByUser(ctx, &userID)
+	if err != nil {
+		return fmt.Errorf("failed to load invitations: %w", err)
+	}
+
+	exportedInvitations := make([]invitationResponse, len(invitations))
+	for i, inv := range invitations {
+		exportedInvitations[i] = invitationResponse{
+			ID:        inv.ID,
+			Code:      inv.Code,
+			CreatedAt: inv.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	if err := writeZipJSON(zw, "invitations.json", exportedInvitations); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize export archive: %w", err)
+	}
+
+	return nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	entry, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return nil
+}
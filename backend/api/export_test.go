@@ -0,0 +1,181 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+// addTestExportJob registers job under id for the duration of the test.
+func addTestExportJob(t *testing.T, id string, job *exportJob) {
+	t.Helper()
+	exportMutex.Lock()
+	exportJobs[id] = job
+	exportMutex.Unlock()
+	t.Cleanup(func() {
+		exportMutex.Lock()
+		delete(exportJobs, id)
+		exportMutex.Unlock()
+	})
+}
+
+func exportJobExists(id string) bool {
+	exportMutex.Lock()
+	defer exportMutex.Unlock()
+	_, ok := exportJobs[id]
+	return ok
+}
+
+func exportRequest(method, target string, userID int64) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	return r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, userID))
+}
+
+func TestUserExportLimitsPendingJobs(t *testing.T) {
+	s := &Server{}
+	addTestExportJob(t, "running", &exportJob{userID: 1, createdAt: time.Now()})
+
+	rec := httptest.NewRecorder()
+	s.handleUserExport(rec, exportRequest(http.MethodGet, "/api/user/export", 1))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	exportMutex.Lock()
+	defer exportMutex.Unlock()
+	if got := pendingExportJobs(1); got != 1 {
+		t.Errorf("user 1 has %d pending exports, want 1", got)
+	}
+	if got := pendingExportJobs(2); got != 0 {
+		t.Errorf("user 2 has %d pending exports, want 0", got)
+	}
+}
+
+func TestUserExportDownloadRemovesArchive(t *testing.T) {
+	s := &Server{}
+	path := filepath.Join(t.TempDir(), "export.zip")
+	if err := os.WriteFile(path, []byte("archive"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	addTestExportJob(t, "finished", &exportJob{userID: 1, done: true, path: path, createdAt: time.Now()})
+
+	download := func(userID int64) *httptest.ResponseRecorder {
+		r := exportRequest(http.MethodGet, "/api/user/export/finished", userID)
+		r.SetPathValue("jobId", "finished")
+		rec := httptest.NewRecorder()
+		s.handleUserExportDownload(rec, r)
+		return rec
+	}
+
+	if rec := download(2); rec.Code != http.StatusNotFound {
+		t.Fatalf("download by another user: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if !exportJobExists("finished") {
+		t.Fatal("job removed by a refused download")
+	}
+
+	rec := download(1)
+	if rec.Code != http.StatusOK || rec.Body.String() != "archive" {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body)
+	}
+	if exportJobExists("finished") {
+		t.Error("job kept after download")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("archive kept after download: %v", err)
+	}
+	if rec := download(1); rec.Code != http.StatusNotFound {
+		t.Errorf("second download: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestPruneExportJobs(t *testing.T) {
+	dir := t.TempDir()
+	expiredPath := filepath.Join(dir, "expired.zip")
+	if err := os.WriteFile(expiredPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-exportJobTTL - time.Minute)
+
+	addTestExportJob(t, "expired", &exportJob{userID: 1, done: true, path: expiredPath, createdAt: old})
+	addTestExportJob(t, "fresh", &exportJob{userID: 1, done: true, path: filepath.Join(dir, "fresh.zip"), createdAt: time.Now()})
+	addTestExportJob(t, "slow", &exportJob{userID: 2, path: filepath.Join(dir, "slow.zip"), createdAt: old})
+
+	exportMutex.Lock()
+	pruneExportJobs()
+	exportMutex.Unlock()
+
+	if exportJobExists("expired") {
+		t.Error("expired job kept")
+	}
+	if _, err := os.Stat(expiredPath); !os.IsNotExist(err) {
+		t.Errorf("archive of expired job kept: %v", err)
+	}
+	if !exportJobExists("fresh") {
+		t.Error("fresh job removed")
+	}
+	if !exportJobExists("slow") {
+		t.Error("job that is still running removed")
+	}
+}
+
+func TestBuildUserExportPagesMessages(t *testing.T) {
+	t.Chdir(t.TempDir())
+	s := newTestServer(t)
+	s.decryptCache = newDecryptCache(16)
+	ctx := context.Background()
+
+	user := createTestUser(t, s, "alice")
+	conv, err := s.queries.CreateConversation(ctx, "group", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.queries.AddConversationParticipant(ctx, conv.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	total := exportMessagePageSize + 1
+	for seq := range int64(total) {
+		if _, err := s.queries.CreateMessage(ctx, conv.ID, seq+1, user.ID, messaging.ContentTypePlain, "body", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "export.zip")
+	if err := s.buildUserExport(user.ID, path); err != nil {
+		t.Fatalf("buildUserExport: %v", err)
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	file, err := archive.Open("messages.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var messages []messageResponse
+	if err := json.NewDecoder(file).Decode(&messages); err != nil {
+		t.Fatalf("decode messages.json: %v", err)
+	}
+	if len(messages) != total {
+		t.Fatalf("exported %d messages, want %d", len(messages), total)
+	}
+	for i, msg := range messages {
+		if msg.Seq != int64(i+1) {
+			t.Fatalf("message %d has seq %d, want %d", i, msg.Seq, i+1)
+		}
+	}
+}
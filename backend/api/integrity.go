@@ -0,0 +1,204 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/crypto"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+const (
+	integrityCheckTick         = time.Hour
+	defaultIntegritySampleSize = 100
+	integrityScanBatchSize     = 500
+	// maxReportedIntegrityFailures caps the message IDs a scan remembers;
+	// failures beyond it are only counted.
+	maxReportedIntegrityFailures = 1000
+)
+
+// integrityScan is the progress of the last full verification of the stored
+// messages.
+type integrityScan struct {
+	mu         sync.Mutex
+	running    bool
+	checked    int64
+	failed     int64
+	failedIDs  []int64
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+type integrityScanResponse struct {
+	Running    bool    `json:"running"`
+	Checked    int64   `json:"checked"`
+	Failed     int64   `json:"failed"`
+	FailedIDs  []int64 `json:"failedIds"`
+	StartedAt  *string `json:"startedAt"`
+	FinishedAt *string `json:"finishedAt"`
+}
+
+func (scan *integrityScan) response() integrityScanResponse {
+	scan.mu.Lock()
+	defer scan.mu.Unlock()
+
+	response := integrityScanResponse{
+		Running:   scan.running,
+		Checked:   scan.checked,
+		Failed:    scan.failed,
+		FailedIDs: append([]int64{}, scan.failedIDs...),
+	}
+	if !scan.startedAt.IsZero() {
+		startedAt := scan.startedAt.UTC().Format("2006-01-02T15:04:05Z")
+		response.StartedAt = &startedAt
+	}
+	if !scan.finishedAt.IsZero() {
+		finishedAt := scan.finishedAt.UTC().Format("2006-01-02T15:04:05Z")
+		response.FinishedAt = &finishedAt
+	}
+	return response
+}
+
+// verifyStoredMessage reports whether an encrypted message body still passes
+// the AES-GCM authentication. Bodies of content types stored in plain text,
+// such as those of calls and system messages, are not checked; a plain text
+// body of any other content type fails.
+func (s *Server) verifyStoredMessage(id, conversationID int64, contentType, body string) bool {
+	if !messaging.StoredEncrypted(contentType) {
+		return true
+	}
+
+	if !crypto.IsEncrypted(body) {
+		slog.Error("message stored without encryption", "messageId", id, "conversationId", conversationID, "contentType", contentType)
+		s.integrityFailures.Add(1)
+		return false
+	}
+
+	if _, err := crypto.DecryptMessage(body, conversationID); err != nil {
+		slog.Error("message failed integrity check", "messageId", id, "conversationId", conversationID, "error", err)
+		s.integrityFailures.Add(1)
+		return false
+	}
+	return true
+}
+
+// IntegrityFailures returns the number of stored messages that failed an
+// integrity check since the server started.
+func (s *Server) IntegrityFailures() int64 {
+	return s.integrityFailures.Load()
+}
+
+// checkMessageIntegrity verifies a random sample of stored messages every
+// integrityCheckTick until stop is closed.
+func (s *Server) checkMessageIntegrity(stop <-chan struct{}) {
+	ticker := time.NewTicker(integrityCheckTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			rows, err := s.queries.SampleStoredMessages(ctx, int64(s.integritySampleSize))
+			cancel()
+			if err != nil {
+				log.Printf("failed to sample messages for integrity check: %v", err)
+				continue
+			}
+			for _, row := range rows {
+				s.verifyStoredMessage(row.ID, row.ConversationID, row.ContentType, row.Body)
+			}
+		}
+	}
+}
+
+// handleAdminVerifyMessages starts a verification of every stored message
+// in the background (POST) or reports the progress of the last one (GET).
+func (s *Server) handleAdminVerifyMessages(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.integrityScan.response())
+	case http.MethodPost:
+		s.integrityScan.mu.Lock()
+		if s.integrityScan.running {
+			s.integrityScan.mu.Unlock()
+			WriteError(w, http.StatusConflict, ErrCodeConflict, "A verification is already running")
+			return
+		}
+		s.integrityScan.running = true
+		s.integrityScan.checked = 0
+		s.integrityScan.failed = 0
+		s.integrityScan.failedIDs = nil
+		s.integrityScan.startedAt = time.Now()
+		s.integrityScan.finishedAt = time.Time{}
+		s.integrityScan.mu.Unlock()
+
+		adminID, _ := auth.GetUserID(r.Context())
+		s.auditLog(r, adminID, auditActionMessageVerify, "", 0, nil)
+
+		go s.scanMessageIntegrity(s.stopPruning)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(s.integrityScan.response())
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// scanMessageIntegrity verifies every stored message in batches of
+// integrityScanBatchSize, recording the progress in s.integrityScan. It gives
+// up when stop is closed.
+func (s *Server) scanMessageIntegrity(stop <-chan struct{}) {
+	scan := &s.integrityScan
+	defer func() {
+		scan.mu.Lock()
+		scan.running = false
+		scan.finishedAt = time.Now()
+		scan.mu.Unlock()
+	}()
+
+	var afterID int64
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		rows, err := s.readQueries.ListStoredMessagesAfter(ctx, afterID, integrityScanBatchSize)
+		cancel()
+		if err != nil {
+			log.Printf("failed to load messages for integrity check: %v", err)
+			return
+		}
+		if len(rows) == 0 {
+			return
+		}
+
+		for _, row := range rows {
+			ok := s.verifyStoredMessage(row.ID, row.ConversationID, row.ContentType, row.Body)
+
+			scan.mu.Lock()
+			scan.checked++
+			if !ok {
+				scan.failed++
+				if len(scan.failedIDs) < maxReportedIntegrityFailures {
+					scan.failedIDs = append(scan.failedIDs, row.ID)
+				}
+			}
+			scan.mu.Unlock()
+		}
+		afterID = rows[len(rows)-1].ID
+	}
+}
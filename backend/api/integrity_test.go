@@ -0,0 +1,32 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"testing"
+
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+func TestVerifyStoredMessagePlainText(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        bool
+	}{
+		{messaging.ContentTypeCall, "", true},
+		{messaging.ContentTypeSystem, `{"action":"member_added"}`, true},
+		{messaging.ContentTypeMarkdown, "hello", false},
+		{messaging.ContentTypePlain, "hello", false},
+		{messaging.ContentTypePoll, `{"question":"?"}`, false},
+	}
+
+	s := &Server{}
+	for _, tt := range tests {
+		if got := s.verifyStoredMessage(1, 1, tt.contentType, tt.body); got != tt.want {
+			t.Errorf("verifyStoredMessage(%s, %q) = %v, want %v", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+	if got := s.IntegrityFailures(); got != 3 {
+		t.Errorf("IntegrityFailures() = %d, want 3", got)
+	}
+}
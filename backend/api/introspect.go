@@ -0,0 +1,63 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// introspectionResponse follows RFC 7662: anything but a valid, unexpired
+// access token is reported as inactive without further details.
+type introspectionResponse struct {
+	Active    bool       `json:"active"`
+	UserID    int64      `json:"userId,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	IssuedAt  *time.Time `json:"issuedAt,omitempty"`
+}
+
+// handleIntrospect reports whether the bearer token of the request is a valid
+// access token. The token is the only credential, so invalid and expired
+// tokens are answered with active false instead of 401. Tokens of deleted or
+// suspended users are inactive, just like RequireAuth rejects them.
+func (s *Server) handleIntrospect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !s.allowIntrospection(w, r) {
+		return
+	}
+
+	response := introspectionResponse{}
+
+	accessToken, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if found && accessToken != "" {
+		token, err := s.queries.GetTokenByAccessToken(r.Context(), accessToken)
+		if err == nil && time.Now().Before(token.AccessTokenExpiresAt) && s.userActive(r.Context(), token.UserID) {
+			response = introspectionResponse{
+				Active:    true,
+				UserID:    token.UserID,
+				ExpiresAt: &token.AccessTokenExpiresAt,
+				IssuedAt:  token.CreatedAt,
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// userActive reports whether userID exists, is not deleted and is not
+// currently suspended.
+func (s *Server) userActive(ctx context.Context, userID int64) bool {
+	user, err := s.queries.GetUser(ctx, userID)
+	if err != nil || user.DeletedAt != nil {
+		return false
+	}
+	return user.SuspendedUntil == nil || !time.Now().Before(*user.SuspendedUntil)
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func introspect(t *testing.T, s *Server, accessToken string) introspectionResponse {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/introspect", nil)
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	s.handleIntrospect(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var resp introspectionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	return resp
+}
+
+func TestIntrospectInactiveUsers(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+
+	for _, tt := range []struct {
+		username string
+		disable  func(userID int64) error
+	}{
+		{"suspended", func(userID int64) error {
+			until := time.Now().Add(time.Hour)
+			return s.queries.SuspendUser(ctx, &until, nil, userID)
+		}},
+		{"deleted", func(userID int64) error {
+			return s.queries.SoftDeleteUser(ctx, userID)
+		}},
+	} {
+		user := createTestUser(t, s, tt.username)
+		accessToken := tt.username + "-access"
+		if _, err := s.queries.CreateOAuthToken(ctx, user.ID, accessToken, tt.username+"-refresh", expires, expires, nil); err != nil {
+			t.Fatalf("CreateOAuthToken: %v", err)
+		}
+		if resp := introspect(t, s, accessToken); !resp.Active || resp.UserID != user.ID {
+			t.Fatalf("%s: before disabling the user: %+v", tt.username, resp)
+		}
+
+		if err := tt.disable(user.ID); err != nil {
+			t.Fatal(err)
+		}
+		if resp := introspect(t, s, accessToken); resp.Active || resp.UserID != 0 {
+			t.Errorf("%s: introspection = %+v, want inactive", tt.username, resp)
+		}
+	}
+}
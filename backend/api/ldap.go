@@ -0,0 +1,66 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// ldapLogin verifies the password of username against the LDAP directory and
+// returns the matching local user. A user without a local account gets one on
+// the first successful login, with a random password that cannot be used once
+// LDAP is disabled. Usernames that are not valid TeamSync usernames are
+// rejected before the directory is asked.
+func (s *Server) ldapLogin(ctx context.Context, username, password string) (db.User, error) {
+	username, err := auth.NormalizeUsername(username)
+	if err != nil {
+		return db.User{}, errInvalidCredentials
+	}
+
+	valid, err := s.ldap.Authenticate(username, password)
+	if err != nil {
+		return db.User{}, err
+	}
+	if !valid {
+		return db.User{}, errInvalidCredentials
+	}
+
+	user, err := s.queries.GetUserByUsername(ctx, username)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return db.User{}, err
+	}
+
+	salt, hash, err := auth.UnusablePassword()
+	if err != nil {
+		return db.User{}, err
+	}
+
+	// The first account of an instance administers it.
+	userCount, err := s.queries.CountUsers(ctx)
+	if err != nil {
+		return db.User{}, err
+	}
+
+	user, err = s.queries.CreateUser(ctx, username, hash, salt, userCount == 0)
+	if err != nil {
+		return db.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if _, err := s.queries.CreateUserSettings(ctx, user.ID, false, true); err != nil {
+		log.Printf("warning: failed to create user settings for user %d: %v", user.ID, err)
+	}
+
+	log.Printf("created user %d for LDAP user %q", user.ID, username)
+	return user, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+const (
+	maxClientMessageIDLength = 64
+	// clientMessageIDLifetime is how long a retried send is recognized by its
+	// client message ID.
+	clientMessageIDLifetime = 24 * time.Hour
+)
+
+// writeExistingMessage answers a retried send with the message that was
+// created by the first attempt.
+func (s *Server) writeExistingMessage(w http.ResponseWriter, r *http.Request, message db.Message) {
+	sender, err := s.queries.GetUser(r.Context(), message.SenderID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := []messageResponse{s.convertToMessageResponse(message.ID, message.ConversationID, message.Seq, message.SenderID,
+		sender.Username, sender.ProfileImageHash, message.CreatedAt, message.EditedAt,
+		message.ContentType, message.Body, message.ReplyToID, "", "")}
+
+	if err := s.attachPollTallies(r.Context(), response); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response[0])
+}
+
+// expireClientMessageIDs forgets client message IDs older than
+// clientMessageIDLifetime every retentionTick until stop is closed.
+func (s *Server) expireClientMessageIDs(stop <-chan struct{}) {
+	ticker := time.NewTicker(retentionTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			cutoff := time.Now().UTC().Add(-clientMessageIDLifetime)
+			if _, err := s.queries.ClearExpiredClientMessageIDs(ctx, cutoff); err != nil {
+				log.Printf("failed to expire client message IDs: %v", err)
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/crypto"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+type forwardMessageRequest struct {
+	ToConversationID int64 `json:"toConversationId"`
+}
+
+// forwardedFromResponse names the message a forwarded message was copied
+// from, so that clients can show where it came from.
+type forwardedFromResponse struct {
+	MessageID      int64  `json:"messageId"`
+	ConversationID int64  `json:"conversationId"`
+	SenderUsername string `json:"senderUsername"`
+}
+
+// handleForwardMessage copies a message the current user can read into
+// another conversation they participate in. The copy is sent by the current
+// user and refers to the original.
+func (s *Server) handleForwardMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	if !s.allowMessage(w, userID) {
+		return
+	}
+
+	messageID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid message ID")
+		return
+	}
+
+	var req forwardMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	source, err := s.queries.GetMessageByID(r.Context(), messageID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Message not found")
+		return
+	}
+
+	sourceParticipants, err := s.queries.GetConversationParticipants(r.Context(), source.ConversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range sourceParticipants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if source.DeletedAt != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Deleted messages cannot be forwarded")
+		return
+	}
+	if source.ContentType == messaging.ContentTypeCall {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Calls cannot be forwarded")
+		return
+	}
+	if source.ContentType == messaging.ContentTypeSystem {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "System messages cannot be forwarded")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), req.ToConversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant = false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	conv, err := s.queries.GetConversationByID(r.Context(), req.ToConversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if conv.ReadonlyForMembers {
+		sender, err := s.queries.GetConversationMember(r.Context(), req.ToConversationID, userID)
+		if err != nil || sender.Role != memberRoleAdmin {
+			WriteError(w, http.StatusForbidden, ErrCodeForbidden, "This conversation is read-only")
+			return
+		}
+	}
+
+	if !s.allowConversationMessage(w, userID, req.ToConversationID) {
+		return
+	}
+
+	body := source.Body
+	if crypto.IsEncrypted(body) {
+		body, err = s.decryptCache.decrypt(source.ID, source.ConversationID, source.Body)
+		if err != nil {
+			log.Printf("Failed to decrypt message %d in conversation %d: %v", source.ID, source.ConversationID, err)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	message, err := storeMessage(r.Context(), tx, req.ToConversationID, userID, source.ContentType, body, nil, nil)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if err := tx.SetMessageForwardedFrom(r.Context(), &source.ID, message.ID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	message.ForwardedFromMessageID = &source.ID
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	msgResp, err := s.announceMessage(r.Context(), message, body)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msgResp)
+}
+
+// attachForwardedFrom sets the origin of every forwarded message.
+func (s *Server) attachForwardedFrom(ctx context.Context, messages []messageResponse) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	rows, err := s.queries.GetForwardedFrom(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	origins := make(map[int64]*forwardedFromResponse, len(rows))
+	for _, row := range rows {
+		origins[row.ID] = &forwardedFromResponse{
+			MessageID:      row.SourceID,
+			ConversationID: row.SourceConversationID,
+			SenderUsername: row.SourceSenderUsername,
+		}
+	}
+
+	for i := range messages {
+		messages[i].ForwardedFrom = origins[messages[i].ID]
+	}
+	return nil
+}
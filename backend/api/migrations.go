@@ -0,0 +1,131 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/bloodmagesoftware/teamsync/crypto"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+type migrationResponse struct {
+	Name      string  `json:"name"`
+	AppliedAt *string `json:"appliedAt"`
+}
+
+func (s *Server) handleAdminMigrations(w http.ResponseWriter, r *http.Request) {
+	s.writeMigrations(w, r, false)
+}
+
+func (s *Server) handleAdminPendingMigrations(w http.ResponseWriter, r *http.Request) {
+	s.writeMigrations(w, r, true)
+}
+
+func (s *Server) writeMigrations(w http.ResponseWriter, r *http.Request, pendingOnly bool) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	statuses, err := s.queries.MigrationStatuses(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to load migrations")
+		return
+	}
+
+	response := make([]migrationResponse, 0, len(statuses))
+	for _, status := range statuses {
+		if pendingOnly && status.AppliedAt != nil {
+			continue
+		}
+
+		var appliedAt *string
+		if status.AppliedAt != nil {
+			str := status.AppliedAt.Format("2006-01-02T15:04:05Z")
+			appliedAt = &str
+		}
+		response = append(response, migrationResponse{Name: status.Name, AppliedAt: appliedAt})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// latestMigration returns the name of the most recently applied migration.
+func latestMigration(statuses []db.MigrationStatus) string {
+	latest := ""
+	for _, status := range statuses {
+		if status.AppliedAt != nil && status.Name > latest {
+			latest = status.Name
+		}
+	}
+	return latest
+}
+
+type healthResponse struct {
+	Status          string `json:"status"`
+	LatestMigration string `json:"latestMigration"`
+	// TURN is ok or unavailable; it is left out when no check is configured.
+	TURN string `json:"turn,omitempty"`
+}
+
+type readinessResponse struct {
+	Status string `json:"status"`
+}
+
+// SetReady marks whether the server should receive traffic. main sets it once
+// the database is migrated and clears it again when shutting down.
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// handleReady answers readiness probes without touching the database, unlike
+// handleHealth which checks liveness.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !s.ready.Load() || !crypto.Initialized() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(readinessResponse{Status: "unavailable"})
+		return
+	}
+	json.NewEncoder(w).Encode(readinessResponse{Status: "ready"})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	statuses, err := s.queries.MigrationStatuses(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusServiceUnavailable, ErrCodeInternal, "Database unavailable")
+		return
+	}
+
+	response := healthResponse{
+		Status:          "ok",
+		LatestMigration: latestMigration(statuses),
+	}
+
+	// An unreachable TURN server breaks calls but not chat, so the server is
+	// reported as degraded rather than down.
+	if s.turnHealth != nil {
+		response.TURN = "ok"
+		if err := s.turnHealth(); err != nil {
+			log.Printf("TURN health check failed: %v", err)
+			response.Status = "degraded"
+			response.TURN = "unavailable"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+const (
+	notificationLevelAll      = "all"
+	notificationLevelMentions = "mentions"
+	notificationLevelNone     = "none"
+)
+
+type notificationLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// handleNotificationLevel sets which messages of a conversation the current
+// user is notified about: all of them, only those mentioning the user, or
+// none.
+func (s *Server) handleNotificationLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	var req notificationLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	switch req.Level {
+	case notificationLevelAll, notificationLevelMentions, notificationLevelNone:
+	default:
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Level must be all, mentions or none", "level")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if err := s.queries.SetNotificationLevel(r.Context(), conversationID, userID, req.Level); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update notification level")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"level":   req.Level,
+	})
+}
+
+// notificationLevel returns the notification level of a user in a
+// conversation.
+func (s *Server) notificationLevel(ctx context.Context, conversationID, userID int64) (string, error) {
+	level, err := s.queries.GetNotificationLevel(ctx, conversationID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notificationLevelAll, nil
+	}
+	return level, err
+}
+
+// silencedUsers fills in the mentions of message and returns the
+// participants who should not be notified about it because of their
+// notification level.
+func (s *Server) silencedUsers(conversationID int64, message *messageResponse) map[int64]bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	silenced := make(map[int64]bool)
+
+	participants, err := s.queries.GetConversationParticipants(ctx, conversationID)
+	if err != nil {
+		log.Printf("Failed to load participants of conversation %d: %v", conversationID, err)
+		return silenced
+	}
+	message.Mentions = mentionedUsers(message.Body, message.SenderID, participants)
+
+	levels, err := s.queries.GetConversationNotificationLevels(ctx, conversationID)
+	if err != nil {
+		log.Printf("Failed to load notification levels of conversation %d: %v", conversationID, err)
+		return silenced
+	}
+
+	for _, pref := range levels {
+		// The other sessions of the sender still need the message.
+		if pref.UserID == message.SenderID {
+			continue
+		}
+		switch pref.Level {
+		case notificationLevelNone:
+			silenced[pref.UserID] = true
+		case notificationLevelMentions:
+			if !slices.Contains(message.Mentions, pref.UserID) {
+				silenced[pref.UserID] = true
+			}
+		}
+	}
+	return silenced
+}
+
+// mentionedUsers returns the participants other than the sender whose
+// username appears as @username in body.
+func mentionedUsers(body string, senderID int64, participants []db.GetConversationParticipantsRow) []int64 {
+	var mentions []int64
+	for _, p := range participants {
+		if p.ID == senderID {
+			continue
+		}
+		pattern := regexp.MustCompile(`(?i)(?:^|[^\w@])@` + regexp.QuoteMeta(p.Username) + `(?:$|[^\w])`)
+		if pattern.MatchString(body) {
+			mentions = append(mentions, p.ID)
+		}
+	}
+	return mentions
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+type unreadConversationResponse struct {
+	ConversationID int64 `json:"conversationId"`
+	UnreadCount    int64 `json:"unreadCount"`
+}
+
+type unreadCountResponse struct {
+	TotalUnread   int64                        `json:"totalUnread"`
+	Conversations []unreadConversationResponse `json:"conversations"`
+}
+
+func (s *Server) unreadCounts(ctx context.Context, userID int64) (unreadCountResponse, error) {
+	counts, err := s.queries.GetUnreadCounts(ctx, userID, nil)
+	if err != nil {
+		return unreadCountResponse{}, err
+	}
+
+	response := unreadCountResponse{
+		Conversations: make([]unreadConversationResponse, len(counts)),
+	}
+	for i, count := range counts {
+		response.TotalUnread += count.UnreadCount
+		response.Conversations[i] = unreadConversationResponse{
+			ConversationID: count.ConversationID,
+			UnreadCount:    count.UnreadCount,
+		}
+	}
+
+	return response, nil
+}
+
+// conversationUnreadCount returns the number of unread messages of userID in
+// one conversation.
+func (s *Server) conversationUnreadCount(ctx context.Context, userID, conversationID int64) (int64, error) {
+	counts, err := s.queries.GetUnreadCounts(ctx, userID, &conversationID)
+	if err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0].UnreadCount, nil
+}
+
+func (s *Server) handleUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	response, err := s.unreadCounts(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// broadcastUnreadCount sends the current unread counts of a user to all of
+// their event streams.
+func (s *Server) broadcastUnreadCount(userID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := s.unreadCounts(ctx, userID)
+	if err != nil {
+		log.Printf("failed to count unread messages of user %d: %v", userID, err)
+		return
+	}
+
+	evtMgr.broadcast(userID, Event{
+		Type: EventTypeUnreadCount,
+		Data: response,
+	})
+}
+
+// broadcastConversationUnreadCounts updates the unread counts of the connected
+// participants of a conversation whose IDs are not in exclude.
+func (s *Server) broadcastConversationUnreadCounts(conversationID int64, exclude map[int64]bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	participants, err := s.queries.GetConversationParticipants(ctx, conversationID)
+	if err != nil {
+		return
+	}
+
+	userIDs := make([]int64, 0, len(participants))
+	for _, p := range participants {
+		if !exclude[p.ID] {
+			userIDs = append(userIDs, p.ID)
+		}
+	}
+
+	for _, userID := range evtMgr.GetOnlineUserIDs(userIDs) {
+		s.broadcastUnreadCount(userID)
+	}
+}
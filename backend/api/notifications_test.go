@@ -0,0 +1,79 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+func TestUnreadCounts(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	reader := createTestUser(t, s, "reader")
+	writer := createTestUser(t, s, "writer")
+	blocked := createTestUser(t, s, "blocked")
+
+	var conversations []db.Conversation
+	for range 2 {
+		conv, err := s.queries.CreateConversation(ctx, "group", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range []db.User{reader, writer, blocked} {
+			if err := s.queries.AddConversationParticipant(ctx, conv.ID, u.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		conversations = append(conversations, conv)
+	}
+
+	send := func(conv db.Conversation, seq int64, sender db.User, contentType string) db.Message {
+		t.Helper()
+		message, err := s.queries.CreateMessage(ctx, conv.ID, seq, sender.ID, contentType, "body", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+
+	first, second := conversations[0], conversations[1]
+	send(first, 1, writer, messaging.ContentTypePlain)
+	send(first, 2, writer, messaging.ContentTypeSystem)
+	send(first, 3, blocked, messaging.ContentTypePlain)
+	deleted := send(first, 4, writer, messaging.ContentTypePlain)
+	send(second, 1, writer, messaging.ContentTypePlain)
+	send(second, 2, writer, messaging.ContentTypePlain)
+
+	if err := s.queries.DeleteMessage(ctx, deleted.ID, writer.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.queries.BlockUser(ctx, reader.ID, blocked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := s.unreadCounts(ctx, reader.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.TotalUnread != 3 || len(counts.Conversations) != 2 ||
+		counts.Conversations[0].UnreadCount != 1 || counts.Conversations[1].UnreadCount != 2 {
+		t.Errorf("unreadCounts = %+v, want 1 in the first and 2 in the second conversation", counts)
+	}
+
+	for _, tt := range []struct {
+		conv db.Conversation
+		want int64
+	}{{first, 1}, {second, 2}} {
+		count, err := s.conversationUnreadCount(ctx, reader.ID, tt.conv.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != tt.want {
+			t.Errorf("conversationUnreadCount(%d) = %d, want %d", tt.conv.ID, count, tt.want)
+		}
+	}
+}
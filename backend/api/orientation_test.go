@@ -0,0 +1,117 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// jpegWithOrientation encodes a small JPEG and inserts an APP1 segment with
+// an EXIF orientation tag, like cameras write it.
+func jpegWithOrientation(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Big-endian TIFF header followed by an IFD with the orientation entry.
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, uint16(0x0112)) // Orientation
+	binary.Write(&tiff, binary.BigEndian, uint16(3))      // SHORT
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, orientation)
+	binary.Write(&tiff, binary.BigEndian, uint16(0))
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	data := encoded.Bytes()
+	var out bytes.Buffer
+	out.Write(data[:2]) // SOI
+	out.Write([]byte{0xff, 0xe1})
+	binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func TestExifOrientation(t *testing.T) {
+	for orientation := uint16(1); orientation <= 8; orientation++ {
+		data := jpegWithOrientation(t, orientation)
+		if got := exifOrientation(data); got != int(orientation) {
+			t.Errorf("exifOrientation = %d, want %d", got, orientation)
+		}
+		if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("fixture with orientation %d does not decode: %v", orientation, err)
+		}
+	}
+
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no EXIF data", plain.Bytes()},
+		{"invalid tag", jpegWithOrientation(t, 9)},
+		{"zero tag", jpegWithOrientation(t, 0)},
+		{"not an image", []byte("hello")},
+	}
+	for _, tt := range tests {
+		if got := exifOrientation(tt.data); got != 1 {
+			t.Errorf("%s: exifOrientation = %d, want 1", tt.name, got)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+
+	// A 3x2 image as stored, with red and green as the first two pixels of
+	// the top row.
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(0, 0, red)
+	src.Set(1, 0, green)
+
+	tests := []struct {
+		orientation int
+		width       int
+		height      int
+		red, green  image.Point
+	}{
+		{1, 3, 2, image.Pt(0, 0), image.Pt(1, 0)},
+		{2, 3, 2, image.Pt(2, 0), image.Pt(1, 0)},
+		{3, 3, 2, image.Pt(2, 1), image.Pt(1, 1)},
+		{4, 3, 2, image.Pt(0, 1), image.Pt(1, 1)},
+		{5, 2, 3, image.Pt(0, 0), image.Pt(0, 1)},
+		{6, 2, 3, image.Pt(1, 0), image.Pt(1, 1)},
+		{7, 2, 3, image.Pt(1, 2), image.Pt(1, 1)},
+		{8, 2, 3, image.Pt(0, 2), image.Pt(0, 1)},
+	}
+	for _, tt := range tests {
+		got := applyOrientation(src, tt.orientation)
+		bounds := got.Bounds()
+		if bounds.Dx() != tt.width || bounds.Dy() != tt.height {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, bounds.Dx(), bounds.Dy(), tt.width, tt.height)
+			continue
+		}
+		if c := color.NRGBAModel.Convert(got.At(bounds.Min.X+tt.red.X, bounds.Min.Y+tt.red.Y)); c != red {
+			t.Errorf("orientation %d: pixel at %v = %v, want red", tt.orientation, tt.red, c)
+		}
+		if c := color.NRGBAModel.Convert(got.At(bounds.Min.X+tt.green.X, bounds.Min.Y+tt.green.Y)); c != green {
+			t.Errorf("orientation %d: pixel at %v = %v, want green", tt.orientation, tt.green, c)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/crypto"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 10
+	maxPollQuestionSize = 500
+	maxPollOptionSize   = 200
+)
+
+// pollContent is the stored body of a poll message.
+type pollContent struct {
+	Question    string   `json:"question"`
+	Options     []string `json:"options"`
+	MultiSelect bool     `json:"multiSelect"`
+	ExpiresAt   *string  `json:"expiresAt,omitempty"`
+}
+
+type pollOptionTally struct {
+	Text  string `json:"text"`
+	Votes int64  `json:"votes"`
+}
+
+// pollBody is the body of a poll message as sent to clients, with the stored
+// options replaced by their current tallies.
+type pollBody struct {
+	Question    string            `json:"question"`
+	Options     []pollOptionTally `json:"options"`
+	MultiSelect bool              `json:"multiSelect"`
+	ExpiresAt   *string           `json:"expiresAt,omitempty"`
+}
+
+type pollVoteRequest struct {
+	OptionIndex *int64 `json:"optionIndex"`
+}
+
+type pollVoteEvent struct {
+	MessageID      int64             `json:"messageId"`
+	ConversationID int64             `json:"conversationId"`
+	Options        []pollOptionTally `json:"options"`
+}
+
+// parsePoll validates a poll body sent by a client and returns it in the
+// normalized form that is stored.
+func parsePoll(body string) (pollContent, error) {
+	var poll pollContent
+	if err := json.Unmarshal([]byte(body), &poll); err != nil {
+		return pollContent{}, errors.New("Poll body must be a JSON object")
+	}
+
+	poll.Question = strings.TrimSpace(poll.Question)
+	if poll.Question == "" || len(poll.Question) > maxPollQuestionSize {
+		return pollContent{}, errors.New("Poll question must be between 1 and 500 characters")
+	}
+
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return pollContent{}, errors.New("Poll must have between 2 and 10 options")
+	}
+	for i, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > maxPollOptionSize {
+			return pollContent{}, errors.New("Poll options must be between 1 and 200 characters")
+		}
+		poll.Options[i] = option
+	}
+
+	if poll.ExpiresAt != nil {
+		expiresAt, err := time.Parse(time.RFC3339, *poll.ExpiresAt)
+		if err != nil {
+			return pollContent{}, errors.New("Poll expiry must be an RFC 3339 timestamp")
+		}
+		if !expiresAt.After(time.Now()) {
+			return pollContent{}, errors.New("Poll expiry must be in the future")
+		}
+		str := expiresAt.UTC().Format("2006-01-02T15:04:05Z")
+		poll.ExpiresAt = &str
+	}
+
+	return poll, nil
+}
+
+func (p pollContent) expired(now time.Time) bool {
+	if p.ExpiresAt == nil {
+		return false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, *p.ExpiresAt)
+	return err == nil && !now.Before(expiresAt)
+}
+
+func (p pollContent) tallies(votes map[int64]int64) []pollOptionTally {
+	tallies := make([]pollOptionTally, len(p.Options))
+	for i, option := range p.Options {
+		tallies[i] = pollOptionTally{Text: option, Votes: votes[int64(i)]}
+	}
+	return tallies
+}
+
+func (p pollContent) body(votes map[int64]int64) string {
+	data, _ := json.Marshal(pollBody{
+		Question:    p.Question,
+		Options:     p.tallies(votes),
+		MultiSelect: p.MultiSelect,
+		ExpiresAt:   p.ExpiresAt,
+	})
+	return string(data)
+}
+
+// attachPollTallies replaces the stored body of every poll message with its
+// current vote counts.
+func (s *Server) attachPollTallies(ctx context.Context, messages []messageResponse) error {
+	var ids []int64
+	for _, msg := range messages {
+		if msg.ContentType == messaging.ContentTypePoll {
+			ids = append(ids, msg.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := s.queries.GetPollVoteCounts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	votes := make(map[int64]map[int64]int64, len(ids))
+	for _, row := range rows {
+		if votes[row.PollMessageID] == nil {
+			votes[row.PollMessageID] = make(map[int64]int64)
+		}
+		votes[row.PollMessageID][row.OptionIndex] = row.Votes
+	}
+
+	for i, msg := range messages {
+		if msg.ContentType != messaging.ContentTypePoll {
+			continue
+		}
+		var poll pollContent
+		if err := json.Unmarshal([]byte(msg.Body), &poll); err != nil {
+			log.Printf("Failed to parse poll message %d: %v", msg.ID, err)
+			continue
+		}
+		messages[i].Body = poll.body(votes[msg.ID])
+	}
+	return nil
+}
+
+func (s *Server) handlePollVote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	messageID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid message ID")
+		return
+	}
+
+	var req pollVoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	message, err := s.queries.GetMessageByID(r.Context(), messageID)
+	if err != nil || message.DeletedAt != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Message not found")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), message.ConversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if message.ContentType != messaging.ContentTypePoll {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Message is not a poll")
+		return
+	}
+
+	body := message.Body
+	if crypto.IsEncrypted(body) {
+		body, err = crypto.DecryptMessage(body, message.ConversationID)
+		if err != nil {
+			log.Printf("Failed to decrypt poll %d in conversation %d: %v", message.ID, message.ConversationID, err)
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+	}
+
+	var poll pollContent
+	if err := json.Unmarshal([]byte(body), &poll); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if req.OptionIndex == nil || *req.OptionIndex < 0 || *req.OptionIndex >= int64(len(poll.Options)) {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Invalid poll option", "optionIndex")
+		return
+	}
+
+	if poll.expired(time.Now()) {
+		WriteError(w, http.StatusConflict, ErrCodeConflict, "Poll has expired")
+		return
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	if !poll.MultiSelect {
+		if err := tx.DeletePollVotesByUser(r.Context(), message.ID, userID); err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+	}
+
+	if err := tx.UpsertPollVote(r.Context(), message.ID, userID, *req.OptionIndex); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	rows, err := tx.GetPollVoteCounts(r.Context(), []int64{message.ID})
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	votes := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		votes[row.OptionIndex] = row.Votes
+	}
+
+	event := pollVoteEvent{
+		MessageID:      message.ID,
+		ConversationID: message.ConversationID,
+		Options:        poll.tallies(votes),
+	}
+
+	go evtMgr.broadcastToConversation(s, message.ConversationID, Event{
+		Type: EventTypePollVote,
+		Data: event,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// pollVotesSince returns the current tallies of the polls of a conversation
+// that received votes after since.
+func (s *Server) pollVotesSince(ctx context.Context, conversationID int64, since time.Time) ([]pollVoteEvent, error) {
+	polls, err := s.queries.GetPollsVotedSince(ctx, conversationID, since)
+	if err != nil || len(polls) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int64, len(polls))
+	for i, poll := range polls {
+		ids[i] = poll.ID
+	}
+	rows, err := s.queries.GetPollVoteCounts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make(map[int64]map[int64]int64, len(ids))
+	for _, row := range rows {
+		if votes[row.PollMessageID] == nil {
+			votes[row.PollMessageID] = make(map[int64]int64)
+		}
+		votes[row.PollMessageID][row.OptionIndex] = row.Votes
+	}
+
+	events := make([]pollVoteEvent, 0, len(polls))
+	for _, row := range polls {
+		body := row.Body
+		if crypto.IsEncrypted(body) {
+			body, err = crypto.DecryptMessage(body, row.ConversationID)
+			if err != nil {
+				log.Printf("Failed to decrypt poll %d in conversation %d: %v", row.ID, row.ConversationID, err)
+				continue
+			}
+		}
+
+		var poll pollContent
+		if err := json.Unmarshal([]byte(body), &poll); err != nil {
+			log.Printf("Failed to parse poll message %d: %v", row.ID, err)
+			continue
+		}
+
+		events = append(events, pollVoteEvent{
+			MessageID:      row.ID,
+			ConversationID: row.ConversationID,
+			Options:        poll.tallies(votes[row.ID]),
+		})
+	}
+	return events, nil
+}
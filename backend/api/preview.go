@@ -0,0 +1,422 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/db"
+	"golang.org/x/net/html"
+)
+
+const (
+	previewTimeout      = 5 * time.Second
+	previewTTL          = 24 * time.Hour
+	previewUserAgent    = "TeamsyncBot"
+	maxPreviewRedirects = 5
+	maxPreviewBodySize  = 1 << 20
+	maxRobotsSize       = 512 << 10
+	previewWorkers      = 4
+	previewQueueSize    = 256
+)
+
+var (
+	errPrivateAddress = errors.New("address is not publicly routable")
+	errNotHTTPS       = errors.New("only HTTPS URLs are followed")
+)
+
+var messageURLPattern = regexp.MustCompile(`https://[^\s<>"'()\[\]]+`)
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which
+// net.IP.IsPrivate does not cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// previewClient refuses to connect to private addresses. The check runs on
+// the resolved address of every connection, so it also covers redirects and
+// hostnames that resolve to internal networks.
+var previewClient = &http.Client{
+	Timeout: previewTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: previewTimeout,
+			Control: denyPrivateAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: previewTimeout,
+		MaxIdleConns:        16,
+		IdleConnTimeout:     time.Minute,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxPreviewRedirects {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "https" {
+			return errNotHTTPS
+		}
+		return nil
+	},
+}
+
+type linkPreviewResponse struct {
+	URL         string  `json:"url"`
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	ImageURL    *string `json:"imageUrl"`
+	SiteName    *string `json:"siteName"`
+	FetchedAt   string  `json:"fetchedAt"`
+}
+
+type openGraph struct {
+	title       *string
+	description *string
+	imageURL    *string
+	siteName    *string
+}
+
+func (s *Server) handleLinkPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	target, err := url.Parse(r.URL.Query().Get("url"))
+	if err != nil || target.Scheme != "https" || target.Host == "" {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "URL must be an absolute HTTPS URL", "url")
+		return
+	}
+
+	preview, err := s.linkPreview(r.Context(), target)
+	if err != nil {
+		log.Printf("failed to fetch link preview for %s: %v", target, err)
+		WriteError(w, http.StatusBadGateway, ErrCodeInternal, "Failed to fetch link preview")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(linkPreviewResponse{
+		URL:         preview.Url,
+		Title:       preview.Title,
+		Description: preview.Description,
+		ImageURL:    preview.ImageUrl,
+		SiteName:    preview.SiteName,
+		FetchedAt:   preview.FetchedAt.Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// prefetchLinkPreview queues the first link of a message body, so that the
+// preview cache is warm when clients render the message. Links are dropped
+// when the queue is full rather than holding up the sender.
+func (s *Server) prefetchLinkPreview(body string) {
+	link := messageURLPattern.FindString(body)
+	if link == "" {
+		return
+	}
+
+	target, err := url.Parse(link)
+	if err != nil || target.Host == "" {
+		return
+	}
+
+	select {
+	case s.previewPrefetches <- target:
+	default:
+		log.Printf("Link preview prefetch for %s dropped: queue full", target)
+	}
+}
+
+// startPreviewWorkers starts previewWorkers goroutines that prefetch queued
+// links until stopPreviews is called.
+func (s *Server) startPreviewWorkers() {
+	s.previewPrefetches = make(chan *url.URL, previewQueueSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelPreviews = cancel
+
+	for range previewWorkers {
+		s.previewWorkers.Add(1)
+		go func() {
+			defer s.previewWorkers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case target := <-s.previewPrefetches:
+					if _, err := s.linkPreview(ctx, target); err != nil {
+						log.Printf("failed to prefetch link preview for %s: %v", target, err)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// stopPreviews cancels prefetches in progress, drops queued ones and waits
+// for the workers to return.
+func (s *Server) stopPreviews() {
+	if s.cancelPreviews == nil {
+		return
+	}
+	s.cancelPreviews()
+	s.previewWorkers.Wait()
+}
+
+// linkPreview returns the cached preview of target or fetches it when the
+// cache entry is missing or older than previewTTL.
+func (s *Server) linkPreview(ctx context.Context, target *url.URL) (db.LinkPreview, error) {
+	rawURL := target.String()
+	hash := sha256.Sum256([]byte(rawURL))
+	urlHash := hex.EncodeToString(hash[:])
+
+	cached, err := s.queries.GetLinkPreview(ctx, urlHash)
+	if err == nil && time.Since(cached.FetchedAt) < previewTTL {
+		return cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, previewTimeout)
+	defer cancel()
+
+	// Pages that disallow crawling are cached without metadata so that
+	// robots.txt is not requested again for every message.
+	var og openGraph
+	if robotsAllowed(ctx, target) {
+		og, err = fetchOpenGraph(ctx, target)
+		if err != nil {
+			return db.LinkPreview{}, err
+		}
+	}
+
+	return s.queries.UpsertLinkPreview(ctx, urlHash, rawURL, og.title, og.description, og.imageURL, og.siteName)
+}
+
+func previewRequest(ctx context.Context, target *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", previewUserAgent)
+
+	return previewClient.Do(req)
+}
+
+func fetchOpenGraph(ctx context.Context, target *url.URL) (openGraph, error) {
+	resp, err := previewRequest(ctx, target)
+	if err != nil {
+		return openGraph{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return openGraph{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return openGraph{}, nil
+	}
+
+	return parseOpenGraph(io.LimitReader(resp.Body, maxPreviewBodySize), resp.Request.URL), nil
+}
+
+// parseOpenGraph reads og: meta tags until the end of the document head.
+// Relative image URLs are resolved against base, the final URL after redirects.
+func parseOpenGraph(r io.Reader, base *url.URL) openGraph {
+	var og openGraph
+	tokenizer := html.NewTokenizer(r)
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return og
+
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "head" {
+				return og
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) == "body" {
+				return og
+			}
+			if string(name) != "meta" || !hasAttr {
+				continue
+			}
+
+			var property, content string
+			for {
+				key, value, more := tokenizer.TagAttr()
+				switch string(key) {
+				case "property", "name":
+					property = strings.ToLower(string(value))
+				case "content":
+					content = strings.TrimSpace(string(value))
+				}
+				if !more {
+					break
+				}
+			}
+
+			if content == "" {
+				continue
+			}
+
+			switch property {
+			case "og:title":
+				og.title = &content
+			case "og:description":
+				og.description = &content
+			case "og:site_name":
+				og.siteName = &content
+			case "og:image":
+				if image, err := base.Parse(content); err == nil {
+					imageURL := image.String()
+					og.imageURL = &imageURL
+				}
+			}
+		}
+	}
+}
+
+type robotsRule struct {
+	allow  bool
+	prefix string
+}
+
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// robotsAllowed reports whether robots.txt of the target host permits
+// previewUserAgent to fetch the target path. A missing or unreadable robots.txt
+// allows everything. Only plain path prefixes are supported; the longest
+// matching rule wins.
+func robotsAllowed(ctx context.Context, target *url.URL) bool {
+	robotsURL := &url.URL{Scheme: target.Scheme, Host: target.Host, Path: "/robots.txt"}
+
+	resp, err := previewRequest(ctx, robotsURL)
+	if err != nil {
+		return true
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return true
+	}
+
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inRules := false
+
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxRobotsSize))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || inRules {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if value != "" {
+				current.rules = append(current.rules, robotsRule{allow: key == "allow", prefix: value})
+			}
+		}
+	}
+
+	var specific, wildcard []robotsRule
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			switch agent {
+			case strings.ToLower(previewUserAgent):
+				specific = append(specific, group.rules...)
+			case "*":
+				wildcard = append(wildcard, group.rules...)
+			}
+		}
+	}
+
+	rules := wildcard
+	if specific != nil {
+		rules = specific
+	}
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	allowed, matched := true, -1
+	for _, rule := range rules {
+		if !strings.HasPrefix(path, rule.prefix) {
+			continue
+		}
+		if len(rule.prefix) > matched || (len(rule.prefix) == matched && rule.allow) {
+			allowed, matched = rule.allow, len(rule.prefix)
+		}
+	}
+	return allowed
+}
+
+func denyPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !isPublicIP(net.ParseIP(host)) {
+		return fmt.Errorf("%s: %w", address, errPrivateAddress)
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip may be reached by requests the server makes on
+// behalf of users.
+func isPublicIP(ip net.IP) bool {
+	return ip != nil && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsMulticast() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// checkPublicHost resolves host and returns errPrivateAddress unless all of
+// its addresses are public. Connections are checked again when they are
+// dialed, as the host may resolve differently by then.
+func checkPublicHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("%s: %w", host, errPrivateAddress)
+		}
+	}
+	return nil
+}
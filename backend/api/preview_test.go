@@ -0,0 +1,23 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPrefetchLinkPreviewDropsWhenQueueIsFull(t *testing.T) {
+	s := &Server{previewPrefetches: make(chan *url.URL, 1)}
+
+	s.prefetchLinkPreview("see https://example.com/first and more")
+	s.prefetchLinkPreview("no link here")
+	// The queue is full, so this must return without waiting for a worker.
+	s.prefetchLinkPreview("https://example.com/second")
+
+	if len(s.previewPrefetches) != 1 {
+		t.Fatalf("queued prefetches = %d, want 1", len(s.previewPrefetches))
+	}
+	if target := <-s.previewPrefetches; target.String() != "https://example.com/first" {
+		t.Errorf("queued %s, want https://example.com/first", target)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	maxBioLength           = 500
+	maxStatusMessageLength = 100
+	maxStatusEmojiLength   = 16
+)
+
+type updateProfileRequest struct {
+	Bio           string `json:"bio"`
+	StatusMessage string `json:"statusMessage"`
+	StatusEmoji   string `json:"statusEmoji"`
+}
+
+// handleUpdateProfile replaces the bio and status of the current user. Empty
+// strings clear a field. The updated profile is returned like GET
+// /api/users/{id}.
+func (s *Server) handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	var req updateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	bio := strings.TrimSpace(req.Bio)
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Bio must be at most 500 characters", "bio")
+		return
+	}
+	statusMessage := strings.TrimSpace(req.StatusMessage)
+	if utf8.RuneCountInString(statusMessage) > maxStatusMessageLength {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Status message must be at most 100 characters", "statusMessage")
+		return
+	}
+	statusEmoji := strings.TrimSpace(req.StatusEmoji)
+	if utf8.RuneCountInString(statusEmoji) > maxStatusEmojiLength {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Status emoji must be at most 16 characters", "statusEmoji")
+		return
+	}
+
+	if err := s.queries.UpdateUserProfileStatus(r.Context(), bio, statusMessage, statusEmoji, userID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update profile")
+		return
+	}
+
+	user, err := s.queries.GetUser(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	profile := userProfileResponse{
+		conversationUserResponse: newConversationUser(user.ID, user.Username, user.ProfileImageHash),
+		Bio:                      user.Bio,
+		StatusMessage:            user.StatusMessage,
+		StatusEmoji:              user.StatusEmoji,
+	}
+	setPresence(profile.conversationUserResponse, evtMgr.isConnected(user.ID), evtMgr.lastSeenTimes([]int64{user.ID}))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
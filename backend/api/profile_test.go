@@ -0,0 +1,64 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func getTestProfile(t *testing.T, s *Server, viewerID, targetID int64) map[string]any {
+	t.Helper()
+
+	r := exportRequest(http.MethodGet, "/api/users/"+strconv.FormatInt(targetID, 10), viewerID)
+	r.SetPathValue("id", strconv.FormatInt(targetID, 10))
+	rec := httptest.NewRecorder()
+	s.handleUserProfile(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var profile map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&profile); err != nil {
+		t.Fatalf("decode profile: %v", err)
+	}
+	return profile
+}
+
+func TestUserProfileStatus(t *testing.T) {
+	s := newTestServer(t)
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	profile := getTestProfile(t, s, bob.ID, alice.ID)
+	for _, field := range []string{"bio", "statusMessage", "statusEmoji"} {
+		if profile[field] != "" {
+			t.Errorf("%s = %#v, want empty string", field, profile[field])
+		}
+	}
+
+	r := exportRequest(http.MethodPut, "/api/profile", alice.ID)
+	r.Body = io.NopCloser(strings.NewReader(`{"bio":" Backend ","statusMessage":"In a meeting","statusEmoji":"📅"}`))
+	rec := httptest.NewRecorder()
+	s.handleUpdateProfile(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	profile = getTestProfile(t, s, bob.ID, alice.ID)
+	if profile["bio"] != "Backend" || profile["statusMessage"] != "In a meeting" || profile["statusEmoji"] != "📅" {
+		t.Errorf("profile = %v, want updated bio and status", profile)
+	}
+
+	r = exportRequest(http.MethodPut, "/api/profile", alice.ID)
+	r.Body = io.NopCloser(strings.NewReader(`{"statusMessage":"` + strings.Repeat("x", maxStatusMessageLength+1) + `"}`))
+	rec = httptest.NewRecorder()
+	s.handleUpdateProfile(rec, r)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("long status message: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -2,51 +2,47 @@
 package api
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
-)
-
-const profileImageDir = "./data/objects"
-
-func ensureProfileImageDir() error {
-	return os.MkdirAll(profileImageDir, 0755)
-}
+	"log"
+	"time"
 
-func getProfileImagePath(hash string) string {
-	return filepath.Join(profileImageDir, hash)
-}
-
-func saveProfileImage(imageData []byte) (string, error) {
-	if err := ensureProfileImageDir(); err != nil {
-		return "", fmt.Errorf("failed to create profile image directory: %w", err)
-	}
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/storage"
+)
 
-	hashBytes := sha256.Sum256(imageData)
-	hash := base64.URLEncoding.EncodeToString(hashBytes[:])
+// orphanedProfileImageGrace protects images of uploads whose hashes are not
+// stored in the database yet.
+const orphanedProfileImageGrace = 10 * time.Minute
 
-	path := getProfileImagePath(hash)
+// profileImageSizes lists the square resolutions generated for every upload.
+// The largest one is the canonical image referenced by profile_image_hash.
+var profileImageSizes = []int{512, 128, 32}
 
-	if _, err := os.Stat(path); err == nil {
-		return hash, nil
-	}
+// saveProfileImage stores an encoded profile image variant. The suffix (e.g.
+// "-128") is mixed into the content hash so every resolution gets its own key.
+func (s *Server) saveProfileImage(ctx context.Context, imageData []byte, suffix string) (string, error) {
+	hasher := sha256.New()
+	hasher.Write(imageData)
+	hasher.Write([]byte(suffix))
+	hash := base64.URLEncoding.EncodeToString(hasher.Sum(nil))
 
-	if err := os.WriteFile(path, imageData, 0644); err != nil {
+	// An existing object is saved again as well, which makes it recent, so
+	// that the orphan cleanup does not remove it before the upload is stored.
+	if err := s.storage.Save(ctx, hash, imageData); err != nil {
 		return "", fmt.Errorf("failed to write profile image: %w", err)
 	}
 
 	return hash, nil
 }
 
-func loadProfileImage(hash string) ([]byte, error) {
-	path := getProfileImagePath(hash)
-	data, err := os.ReadFile(path)
+func (s *Server) loadProfileImage(ctx context.Context, hash string) ([]byte, error) {
+	data, err := s.storage.Load(ctx, hash)
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
+		if errors.Is(err, storage.ErrNotFound) {
 			return nil, fmt.Errorf("profile image not found")
 		}
 		return nil, fmt.Errorf("failed to read profile image: %w", err)
@@ -54,10 +50,80 @@ func loadProfileImage(hash string) ([]byte, error) {
 	return data, nil
 }
 
-func deleteProfileImage(hash string) error {
-	path := getProfileImagePath(hash)
-	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+func deleteProfileImage(ctx context.Context, backend storage.Backend, hash string) error {
+	if err := backend.Delete(ctx, hash); err != nil {
 		return fmt.Errorf("failed to delete profile image: %w", err)
 	}
 	return nil
 }
+
+// isProfileImageKey reports whether key has the form of the keys
+// saveProfileImage generates, the URL-safe base64 encoding of a SHA-256 hash.
+func isProfileImageKey(key string) bool {
+	if len(key) != base64.URLEncoding.EncodedLen(sha256.Size) {
+		return false
+	}
+	decoded, err := base64.URLEncoding.DecodeString(key)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// orphanedProfileImages returns the objects that look like profile images,
+// are not in use and were saved before cutoff. Objects with other keys are
+// never returned, since the backend may hold unrelated data.
+func orphanedProfileImages(objects []storage.Object, inUse map[string]bool, cutoff time.Time) []storage.Object {
+	var orphaned []storage.Object
+	for _, object := range objects {
+		if !isProfileImageKey(object.Key) || inUse[object.Key] || object.ModTime.After(cutoff) {
+			continue
+		}
+		orphaned = append(orphaned, object)
+	}
+	return orphaned
+}
+
+// CleanupOrphanedProfileImages deletes the profile images in backend that no
+// user refers to anymore, except for images saved within the last ten
+// minutes. It returns the number of images deleted and the bytes reclaimed.
+// With dryRun set it only logs the images it would delete and counts them.
+// Backends that cannot list their objects are skipped.
+func CleanupOrphanedProfileImages(ctx context.Context, queries *db.Queries, backend storage.Backend, dryRun bool) (int, int64, error) {
+	lister, ok := backend.(storage.Lister)
+	if !ok {
+		return 0, 0, nil
+	}
+
+	objects, err := lister.List(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list profile images: %w", err)
+	}
+
+	// The objects are listed first so that images of uploads finishing after
+	// the hashes are read are recent enough to be skipped.
+	rows, err := queries.ListProfileImageHashes(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list profile image hashes: %w", err)
+	}
+
+	inUse := make(map[string]bool)
+	for _, row := range rows {
+		for _, hash := range []*string{row.ProfileImageHash, row.ProfileImageHash32, row.ProfileImageHash128} {
+			if hash != nil {
+				inUse[*hash] = true
+			}
+		}
+	}
+
+	deleted, reclaimed := 0, int64(0)
+	for _, object := range orphanedProfileImages(objects, inUse, time.Now().Add(-orphanedProfileImageGrace)) {
+		if dryRun {
+			log.Printf("would delete orphaned profile image %s (%d bytes)", object.Key, object.Size)
+		} else if err := deleteProfileImage(ctx, backend, object.Key); err != nil {
+			log.Printf("failed to delete orphaned profile image %s: %v", object.Key, err)
+			continue
+		}
+		deleted++
+		reclaimed += object.Size
+	}
+
+	return deleted, reclaimed, nil
+}
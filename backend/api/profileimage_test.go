@@ -0,0 +1,59 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/storage"
+)
+
+func testProfileImageKey(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return base64.URLEncoding.EncodeToString(sum[:])
+}
+
+func TestOrphanedProfileImagesKeepsUnrelatedKeys(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-time.Hour)
+	cutoff := now.Add(-orphanedProfileImageGrace)
+
+	inUseKey := testProfileImageKey("in use")
+	orphanKey := testProfileImageKey("orphan")
+	recentKey := testProfileImageKey("recent")
+
+	objects := []storage.Object{
+		{Key: inUseKey, ModTime: old},
+		{Key: orphanKey, ModTime: old, Size: 42},
+		{Key: recentKey, ModTime: now},
+		{Key: "backups/teamsync.db", ModTime: old},
+		{Key: "README.txt", ModTime: old},
+		{Key: orphanKey[:len(orphanKey)-1], ModTime: old},
+		{Key: "not-base64!" + orphanKey[11:], ModTime: old},
+	}
+
+	got := orphanedProfileImages(objects, map[string]bool{inUseKey: true}, cutoff)
+	if len(got) != 1 || got[0].Key != orphanKey {
+		t.Fatalf("orphanedProfileImages = %+v, want only %s", got, orphanKey)
+	}
+}
+
+func TestIsProfileImageKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{testProfileImageKey("image"), true},
+		{"", false},
+		{"teamsync.db", false},
+		{base64.URLEncoding.EncodeToString(make([]byte, 31)), false},
+		{base64.StdEncoding.EncodeToString([]byte{0xfb, 0xff, 0xbf}) + testProfileImageKey("x")[4:], false},
+	}
+	for _, tt := range tests {
+		if got := isProfileImageKey(tt.key); got != tt.want {
+			t.Errorf("isProfileImageKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	pushTTL            = 24 * 60 * 60
+	pushBodyPreviewLen = 200
+	pushSendTimeout    = 30 * time.Second
+)
+
+type pushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// pushNotification is the payload handed to the service worker, which opens
+// the conversation at the message when the notification is clicked.
+type pushNotification struct {
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+	Icon           string `json:"icon,omitempty"`
+	ConversationID int64  `json:"conversationId"`
+	MessageID      int64  `json:"messageId"`
+}
+
+func (s *Server) pushEnabled() bool {
+	return s.vapidPublicKey != "" && s.vapidPrivateKey != ""
+}
+
+func (s *Server) handlePushPublicKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !s.pushEnabled() {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Push notifications are not configured")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"publicKey": s.vapidPublicKey})
+}
+
+func (s *Server) handlePushSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if !s.pushEnabled() {
+			WriteError(w, http.StatusServiceUnavailable, ErrCodeInternal, "Push notifications are not configured")
+			return
+		}
+
+		var req pushSubscriptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteDecodeError(w, err)
+			return
+		}
+
+		if field, message := validatePushSubscription(req); field != "" {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, message, field)
+			return
+		}
+
+		saved, err := s.queries.UpsertPushSubscription(r.Context(), userID, req.Endpoint, req.Keys.P256dh, req.Keys.Auth)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to save subscription")
+			return
+		}
+		// The endpoint belongs to another user, who has to unsubscribe first.
+		// Taking it over would redirect that user's notifications.
+		if saved == 0 {
+			WriteFieldError(w, http.StatusConflict, ErrCodeConflict, "Subscription belongs to another user", "endpoint")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
+
+	case http.MethodDelete:
+		var req pushSubscriptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Endpoint == "" {
+			WriteDecodeError(w, err)
+			return
+		}
+
+		if err := s.queries.DeletePushSubscription(r.Context(), req.Endpoint, userID); err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete subscription")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
+
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// validatePushSubscription returns the offending field and a message if the
+// subscription is unusable.
+func validatePushSubscription(req pushSubscriptionRequest) (string, string) {
+	endpoint, err := url.Parse(req.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return "endpoint", "Endpoint must be an HTTPS URL"
+	}
+
+	// The P-256 public key is an uncompressed point and the auth secret 16 bytes.
+	if key, err := decodePushKey(req.Keys.P256dh); err != nil || len(key) != 65 {
+		return "keys.p256dh", "Invalid p256dh key"
+	}
+	if secret, err := decodePushKey(req.Keys.Auth); err != nil || len(secret) != 16 {
+		return "keys.auth", "Invalid auth secret"
+	}
+
+	return "", ""
+}
+
+func decodePushKey(key string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(key, "="))
+}
+
+// sendPushNotifications notifies participants without a live event stream
+// about a new message. Users in exclude are skipped.
+func (s *Server) sendPushNotifications(conversationID int64, message messageResponse, exclude map[int64]bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), pushSendTimeout)
+	defer cancel()
+
+	participants, err := s.queries.GetConversationParticipants(ctx, conversationID)
+	if err != nil {
+		log.Printf("Failed to load participants for push notifications: %v", err)
+		return
+	}
+
+	body := message.Body
+	if runes := []rune(body); len(runes) > pushBodyPreviewLen {
+		body = string(runes[:pushBodyPreviewLen]) + "…"
+	}
+
+	notification := pushNotification{
+		Title:          "New message from " + message.SenderUsername,
+		Body:           body,
+		ConversationID: conversationID,
+		MessageID:      message.ID,
+	}
+	if message.SenderProfileImageURL != nil {
+		notification.Icon = *message.SenderProfileImageURL
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+
+	for _, p := range participants {
+		if p.ID == message.SenderID || exclude[p.ID] || evtMgr.isConnected(p.ID) {
+			continue
+		}
+
+		subscriptions, err := s.queries.ListPushSubscriptionsByUser(ctx, p.ID)
+		if err != nil {
+			log.Printf("Failed to load push subscriptions of user %d: %v", p.ID, err)
+			continue
+		}
+
+		for _, sub := range subscriptions {
+			s.sendPushNotification(ctx, sub.Endpoint, sub.P256dh, sub.Auth, payload)
+		}
+	}
+}
+
+// sendPushNotification delivers payload to one subscription, signed with a
+// VAPID (RFC 8292) token. The token's audience is the origin of the endpoint
+// and its subject the configured contact address.
+func (s *Server) sendPushNotification(ctx context.Context, endpoint, p256dh, authSecret string, payload []byte) {
+	resp, err := webpush.SendNotificationWithContext(ctx, payload, &webpush.Subscription{
+		Endpoint: endpoint,
+		Keys:     webpush.Keys{P256dh: p256dh, Auth: authSecret},
+	}, &webpush.Options{
+		Subscriber:      s.vapidSubject,
+		VAPIDPublicKey:  s.vapidPublicKey,
+		VAPIDPrivateKey: s.vapidPrivateKey,
+		TTL:             pushTTL,
+	})
+	if err != nil {
+		log.Printf("Failed to send push notification: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// The push service reports subscriptions that expired or were revoked.
+	if resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound {
+		if err := s.queries.DeletePushSubscriptionByEndpoint(ctx, endpoint); err != nil {
+			log.Printf("Failed to prune push subscription: %v", err)
+		}
+		return
+	}
+
+	if resp.StatusCode >= 400 {
+		log.Printf("Push service rejected notification with status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func subscribePush(s *Server, userID int64, method, endpoint string) *httptest.ResponseRecorder {
+	var req pushSubscriptionRequest
+	req.Endpoint = endpoint
+	req.Keys.P256dh = base64.RawURLEncoding.EncodeToString(make([]byte, 65))
+	req.Keys.Auth = base64.RawURLEncoding.EncodeToString(make([]byte, 16))
+	body, _ := json.Marshal(req)
+
+	r := exportRequest(method, "/api/push/subscribe", userID)
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+	s.handlePushSubscription(rec, r)
+	return rec
+}
+
+func TestPushSubscriptionCannotBeTakenOver(t *testing.T) {
+	s := newTestServer(t)
+	s.vapidPublicKey = "public"
+	s.vapidPrivateKey = "private"
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	const endpoint = "https://push.example.com/send/abc"
+
+	if rec := subscribePush(s, alice.ID, http.MethodPost, endpoint); rec.Code != http.StatusCreated {
+		t.Fatalf("alice subscribes: status = %d: %s", rec.Code, rec.Body)
+	}
+	// Subscribing again refreshes the keys of the own subscription.
+	if rec := subscribePush(s, alice.ID, http.MethodPost, endpoint); rec.Code != http.StatusCreated {
+		t.Fatalf("alice subscribes again: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := subscribePush(s, bob.ID, http.MethodPost, endpoint); rec.Code != http.StatusConflict {
+		t.Fatalf("bob takes over the endpoint: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	subscriptions, err := s.queries.ListPushSubscriptionsByUser(t.Context(), alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subscriptions) != 1 || subscriptions[0].Endpoint != endpoint {
+		t.Errorf("subscriptions of alice = %+v", subscriptions)
+	}
+
+	if rec := subscribePush(s, alice.ID, http.MethodDelete, endpoint); rec.Code != http.StatusOK {
+		t.Fatalf("alice unsubscribes: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := subscribePush(s, bob.ID, http.MethodPost, endpoint); rec.Code != http.StatusCreated {
+		t.Errorf("bob subscribes after alice left: status = %d: %s", rec.Code, rec.Body)
+	}
+}
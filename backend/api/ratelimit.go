@@ -0,0 +1,136 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultMessageRateLimit             = 30
+	defaultConversationMessageRateLimit = 10
+	introspectionRateLimit              = 60
+	directoryRateLimit                  = 30
+	messageLimiterIdleTTL               = 10 * time.Minute
+	messageLimiterPruneTick             = time.Minute
+)
+
+type messageLimiter struct {
+	limiter    *rate.Limiter
+	lastAccess atomic.Int64
+}
+
+type conversationLimiterKey struct {
+	userID         int64
+	conversationID int64
+}
+
+// loadLimiter returns the token bucket stored under key, creating one that
+// allows perMinute messages per minute if there is none yet.
+func loadLimiter(limiters *sync.Map, key any, perMinute int) *rate.Limiter {
+	if value, ok := limiters.Load(key); ok {
+		entry := value.(*messageLimiter)
+		entry.lastAccess.Store(time.Now().UnixNano())
+		return entry.limiter
+	}
+
+	entry := &messageLimiter{
+		limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute),
+	}
+	entry.lastAccess.Store(time.Now().UnixNano())
+
+	value, _ := limiters.LoadOrStore(key, entry)
+	return value.(*messageLimiter).limiter
+}
+
+// limiterFor returns the token bucket shared by all conversations of a user.
+func (s *Server) limiterFor(userID int64) *rate.Limiter {
+	return loadLimiter(&s.messageLimiters, userID, s.messageRateLimit)
+}
+
+// conversationLimiterFor returns the token bucket of a user within a single
+// conversation.
+func (s *Server) conversationLimiterFor(userID, conversationID int64) *rate.Limiter {
+	key := conversationLimiterKey{userID: userID, conversationID: conversationID}
+	return loadLimiter(&s.conversationLimiters, key, s.conversationMessageRateLimit)
+}
+
+// allowIntrospection consumes a token for the client address of r, so that
+// access tokens cannot be guessed through the introspection endpoint. When the
+// quota is exhausted it writes a 429 response and returns false.
+func (s *Server) allowIntrospection(w http.ResponseWriter, r *http.Request) bool {
+	return allow(w, loadLimiter(&s.introspectionLimiters, clientIP(r), introspectionRateLimit))
+}
+
+// allowDirectory consumes a token for userID, so that the user directory
+// cannot be enumerated with many small pages. When the quota is exhausted it
+// writes a 429 response and returns false.
+func (s *Server) allowDirectory(w http.ResponseWriter, userID int64) bool {
+	return allow(w, loadLimiter(&s.directoryLimiters, userID, directoryRateLimit))
+}
+
+// allowMessage consumes a token for userID. When the quota is exhausted it
+// writes a 429 response and returns false.
+func (s *Server) allowMessage(w http.ResponseWriter, userID int64) bool {
+	return allow(w, s.limiterFor(userID))
+}
+
+// allowConversationMessage consumes a token for userID in conversationID, so
+// that a single conversation cannot be flooded while the user stays within
+// the overall quota. When the quota is exhausted it writes a 429 response and
+// returns false.
+func (s *Server) allowConversationMessage(w http.ResponseWriter, userID, conversationID int64) bool {
+	return allow(w, s.conversationLimiterFor(userID, conversationID))
+}
+
+func allow(w http.ResponseWriter, limiter *rate.Limiter) bool {
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true
+	}
+	reservation.Cancel()
+
+	retryAfter := int(math.Ceil(delay.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeErrorResponse(w, http.StatusTooManyRequests, struct {
+		ErrorResponse
+		RetryAfter int `json:"retryAfter"`
+	}{
+		ErrorResponse: ErrorResponse{Error: ErrorDetail{Code: ErrCodeRateLimited, Message: "rate limit exceeded"}},
+		RetryAfter:    retryAfter,
+	})
+	return false
+}
+
+func (s *Server) pruneMessageLimiters(stop <-chan struct{}) {
+	ticker := time.NewTicker(messageLimiterPruneTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-messageLimiterIdleTTL).UnixNano()
+			prune := func(limiters *sync.Map) {
+				limiters.Range(func(key, value any) bool {
+					if value.(*messageLimiter).lastAccess.Load() < cutoff {
+						limiters.Delete(key)
+					}
+					return true
+				})
+			}
+			prune(&s.messageLimiters)
+			prune(&s.conversationLimiters)
+			prune(&s.introspectionLimiters)
+			prune(&s.directoryLimiters)
+		}
+	}
+}
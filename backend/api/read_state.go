@@ -0,0 +1,49 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+type readStateResponse struct {
+	ConversationID int64   `json:"conversationId"`
+	LastReadSeq    int64   `json:"lastReadSeq"`
+	LastReadAt     *string `json:"lastReadAt"`
+}
+
+// handleConversationReadStates returns how far the current user has read
+// each of their conversations. Clients start from it after reconnecting the
+// event stream instead of reloading the conversation list.
+func (s *Server) handleConversationReadStates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	rows, err := s.queries.ListUserReadStates(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := make([]readStateResponse, len(rows))
+	for i, row := range rows {
+		response[i] = readStateResponse{ConversationID: row.ConversationID, LastReadSeq: row.LastReadSeq}
+		if row.LastReadAt != nil {
+			lastReadAt := row.LastReadAt.Format("2006-01-02T15:04:05Z")
+			response[i].LastReadAt = &lastReadAt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
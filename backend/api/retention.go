@@ -0,0 +1,155 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	retentionTick    = time.Hour
+	maxRetentionDays = 36500
+)
+
+type conversationSettingsRequest struct {
+	RetentionDays *int64 `json:"retentionDays"`
+	ReadOnly      bool   `json:"readOnly"`
+}
+
+// handleConversationSettings updates the message retention and read-only
+// mode of a conversation. Fields missing from the request keep their value.
+// Any participant may change direct messages; group conversations require the
+// participant to be an administrator. Only groups can be read-only.
+func (s *Server) handleConversationSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	conv, err := s.queries.GetConversationByID(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Conversation not found")
+		return
+	}
+
+	req := conversationSettingsRequest{
+		RetentionDays: conv.RetentionDays,
+		ReadOnly:      conv.ReadonlyForMembers,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	if req.RetentionDays != nil && (*req.RetentionDays < 1 || *req.RetentionDays > maxRetentionDays) {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Retention must be between 1 and 36500 days", "retentionDays")
+		return
+	}
+
+	if req.ReadOnly && conv.Type == "dm" {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Direct messages cannot be read-only", "readOnly")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if conv.Type != "dm" {
+		requester, err := s.queries.GetConversationMember(r.Context(), conversationID, userID)
+		if err != nil || requester.Role != memberRoleAdmin {
+			WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Conversation admin rights required")
+			return
+		}
+	}
+
+	if err := s.queries.UpdateConversationSettings(r.Context(), req.RetentionDays, req.ReadOnly, conversationID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update conversation settings")
+		return
+	}
+
+	go s.BroadcastConversationUpdate(conversationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":       true,
+		"retentionDays": req.RetentionDays,
+		"readOnly":      req.ReadOnly,
+	})
+}
+
+// expireMessages wipes messages past the retention of their conversation every
+// retentionTick until stop is closed. Expired messages are not announced to
+// clients, which drop them on their next sync.
+func (s *Server) expireMessages(stop <-chan struct{}) {
+	ticker := time.NewTicker(retentionTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.expireConversationMessages()
+		}
+	}
+}
+
+func (s *Server) expireConversationMessages() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	conversations, err := s.queries.ListConversationsWithRetention(ctx)
+	if err != nil {
+		log.Printf("failed to list conversations with retention: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, conv := range conversations {
+		cutoff := now.Add(-time.Duration(*conv.RetentionDays) * 24 * time.Hour)
+
+		expired, err := s.queries.ExpireConversationMessages(ctx, conv.ID, cutoff)
+		if err != nil {
+			log.Printf("failed to expire messages of conversation %d: %v", conv.ID, err)
+			continue
+		}
+		if expired > 0 {
+			s.decryptCache.removeConversation(conv.ID)
+			log.Printf("expired %d messages of conversation %d", expired, conv.ID)
+		}
+	}
+}
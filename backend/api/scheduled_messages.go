@@ -0,0 +1,313 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/crypto"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+const (
+	// minScheduleDelay is how far in the future sendAt has to be for a
+	// message to be scheduled; earlier messages are sent right away.
+	minScheduleDelay = 30 * time.Second
+	// maxScheduleAhead limits how far in the future messages can be scheduled.
+	maxScheduleAhead      = 365 * 24 * time.Hour
+	scheduledMessagesTick = 30 * time.Second
+)
+
+type scheduledMessageResponse struct {
+	ID             int64   `json:"id"`
+	ConversationID int64   `json:"conversationId"`
+	ContentType    string  `json:"contentType"`
+	Body           string  `json:"body"`
+	ReplyToID      *int64  `json:"replyToId,omitempty"`
+	CreatedAt      string  `json:"createdAt"`
+	SendAt         string  `json:"sendAt"`
+	FailedAt       *string `json:"failedAt,omitempty"`
+	FailureReason  *string `json:"failureReason,omitempty"`
+}
+
+func newScheduledMessageResponse(message db.ScheduledMessage) (scheduledMessageResponse, error) {
+	body, err := crypto.DecryptMessage(message.Body, message.ConversationID)
+	if err != nil {
+		return scheduledMessageResponse{}, err
+	}
+
+	response := scheduledMessageResponse{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		ContentType:    message.ContentType,
+		Body:           body,
+		ReplyToID:      message.ReplyToID,
+		CreatedAt:      message.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		SendAt:         message.SendAt.UTC().Format("2006-01-02T15:04:05Z"),
+		FailureReason:  message.FailureReason,
+	}
+	if message.FailedAt != nil {
+		failedAt := message.FailedAt.UTC().Format("2006-01-02T15:04:05Z")
+		response.FailedAt = &failedAt
+	}
+	return response, nil
+}
+
+// scheduleMessage stores a validated message of handleSendMessage to be sent
+// at req.SendAt and answers with 202. Client message IDs only apply to
+// messages sent right away.
+func (s *Server) scheduleMessage(w http.ResponseWriter, r *http.Request, userID, conversationID int64, contentType string, req sendMessageRequest) {
+	if req.SendAt.After(time.Now().Add(maxScheduleAhead)) {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Messages can be scheduled at most one year ahead", "sendAt")
+		return
+	}
+
+	encryptedBody, err := crypto.EncryptMessage(req.Body, conversationID)
+	if err != nil {
+		log.Printf("Error encrypting message: %v", err)
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	message, err := s.queries.CreateScheduledMessage(r.Context(), conversationID, userID, contentType, encryptedBody, req.ReplyToID, req.SendAt.UTC())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to schedule message")
+		return
+	}
+
+	response, err := newScheduledMessageResponse(message)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleScheduledMessages lists the messages the current user scheduled in a
+// conversation, the next one first.
+func (s *Server) handleScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.URL.Query().Get("conversationId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	messages, err := s.queries.ListScheduledMessages(r.Context(), conversationID, userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := make([]scheduledMessageResponse, 0, len(messages))
+	for _, message := range messages {
+		resp, err := newScheduledMessageResponse(message)
+		if err != nil {
+			log.Printf("Failed to decrypt scheduled message %d: %v", message.ID, err)
+			continue
+		}
+		response = append(response, resp)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleScheduledMessage cancels a message the current user scheduled.
+func (s *Server) handleScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid scheduled message ID")
+		return
+	}
+
+	message, err := s.queries.GetScheduledMessage(r.Context(), id)
+	if err != nil || message.SenderID != userID {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Scheduled message not found")
+		return
+	}
+
+	deleted, err := s.queries.DeleteScheduledMessage(r.Context(), id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to cancel scheduled message")
+		return
+	}
+	if deleted == 0 {
+		// It was sent in the meantime.
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Scheduled message not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deliverScheduledMessages sends the scheduled messages that are due every
+// scheduledMessagesTick until stop is closed.
+func (s *Server) deliverScheduledMessages(stop <-chan struct{}) {
+	ticker := time.NewTicker(scheduledMessagesTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			messages, err := s.queries.ListDueScheduledMessages(ctx, time.Now().UTC())
+			if err != nil {
+				log.Printf("failed to list due scheduled messages: %v", err)
+			}
+			for _, message := range messages {
+				s.deliverScheduledMessage(ctx, message)
+			}
+			cancel()
+		}
+	}
+}
+
+// deliverScheduledMessage sends a scheduled message like handleSendMessage
+// and removes it from the schedule. Messages that can never be sent, because
+// they cannot be decrypted or the sender may no longer post to the
+// conversation, are marked as failed and not retried.
+func (s *Server) deliverScheduledMessage(ctx context.Context, scheduled db.ScheduledMessage) {
+	body, err := crypto.DecryptMessage(scheduled.Body, scheduled.ConversationID)
+	if err != nil {
+		log.Printf("failed to decrypt scheduled message %d: %v", scheduled.ID, err)
+		failScheduledMessage(ctx, s.queries, scheduled.ID, "Message could not be decrypted")
+		return
+	}
+
+	tx, err := s.queries.Begin()
+	if err != nil {
+		log.Printf("failed to send scheduled message %d: %v", scheduled.ID, err)
+		return
+	}
+	defer tx.Rollback()
+
+	reason, err := scheduledMessageRejection(ctx, tx.Queries, scheduled)
+	if err != nil {
+		log.Printf("failed to send scheduled message %d: %v", scheduled.ID, err)
+		return
+	}
+	if reason != "" {
+		failScheduledMessage(ctx, tx.Queries, scheduled.ID, reason)
+		if err := tx.Commit(); err != nil {
+			log.Printf("failed to mark scheduled message %d as failed: %v", scheduled.ID, err)
+		}
+		return
+	}
+
+	// Cancelled in the meantime.
+	if deleted, err := tx.DeleteScheduledMessage(ctx, scheduled.ID); err != nil || deleted == 0 {
+		return
+	}
+
+	message, err := storeMessage(ctx, tx, scheduled.ConversationID, scheduled.SenderID, scheduled.ContentType, body, scheduled.ReplyToID, nil)
+	if err != nil {
+		log.Printf("failed to send scheduled message %d: %v", scheduled.ID, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("failed to send scheduled message %d: %v", scheduled.ID, err)
+		return
+	}
+
+	if _, err := s.announceMessage(ctx, message, body); err != nil {
+		log.Printf("failed to announce scheduled message %d: %v", scheduled.ID, err)
+	}
+}
+
+// scheduledMessageRejection returns why the sender of a scheduled message may
+// no longer post it, or an empty string if it can be sent. The checks are
+// those handleSendMessage applies to the sender when a message is sent right
+// away.
+func scheduledMessageRejection(ctx context.Context, q *db.Queries, scheduled db.ScheduledMessage) (string, error) {
+	sender, err := q.GetUser(ctx, scheduled.SenderID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && sender.DeletedAt != nil) {
+		return "Sender account was deleted", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if sender.SuspendedUntil != nil && time.Now().Before(*sender.SuspendedUntil) {
+		return "Sender account is suspended", nil
+	}
+
+	conv, err := q.GetConversationByID(ctx, scheduled.ConversationID)
+	if err != nil {
+		return "", err
+	}
+
+	member, err := q.GetConversationMember(ctx, scheduled.ConversationID, scheduled.SenderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "Sender is no longer a member of the conversation", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if conv.ReadonlyForMembers && member.Role != memberRoleAdmin {
+		return "Conversation is read-only", nil
+	}
+
+	if conv.Type == "dm" {
+		participants, err := q.GetConversationParticipants(ctx, scheduled.ConversationID)
+		if err != nil {
+			return "", err
+		}
+		for _, p := range participants {
+			if p.ID == scheduled.SenderID {
+				continue
+			}
+			blocks, err := q.IsBlockedBetween(ctx, scheduled.SenderID, p.ID)
+			if err != nil {
+				return "", err
+			}
+			if blocks > 0 {
+				return "User is blocked", nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// failScheduledMessage marks a scheduled message as failed so that it is no
+// longer delivered but still shown to its sender.
+func failScheduledMessage(ctx context.Context, q *db.Queries, id int64, reason string) {
+	log.Printf("scheduled message %d failed: %s", id, reason)
+	now := time.Now().UTC()
+	if err := q.FailScheduledMessage(ctx, &now, &reason, id); err != nil {
+		log.Printf("failed to mark scheduled message %d as failed: %v", id, err)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+func TestScheduledMessageRejection(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	sender := createTestUser(t, s, "sender")
+	recipient := createTestUser(t, s, "recipient")
+
+	conv, err := s.queries.CreateConversation(ctx, "dm", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []db.User{sender, recipient} {
+		if err := s.queries.AddConversationParticipant(ctx, conv.ID, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scheduled := db.ScheduledMessage{ConversationID: conv.ID, SenderID: sender.ID}
+
+	reject := func() string {
+		t.Helper()
+		reason, err := scheduledMessageRejection(ctx, s.queries, scheduled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reason
+	}
+
+	if reason := reject(); reason != "" {
+		t.Fatalf("rejected with %q before any change", reason)
+	}
+
+	if err := s.queries.BlockUser(ctx, recipient.ID, sender.ID); err != nil {
+		t.Fatal(err)
+	}
+	if reason := reject(); reason != "User is blocked" {
+		t.Errorf("blocked DM: reason = %q", reason)
+	}
+
+	until := time.Now().Add(time.Hour)
+	if err := s.queries.SuspendUser(ctx, &until, nil, sender.ID); err != nil {
+		t.Fatal(err)
+	}
+	if reason := reject(); reason != "Sender account is suspended" {
+		t.Errorf("suspended sender: reason = %q", reason)
+	}
+
+	if err := s.queries.SoftDeleteUser(ctx, sender.ID); err != nil {
+		t.Fatal(err)
+	}
+	if reason := reject(); reason != "Sender account was deleted" {
+		t.Errorf("deleted sender: reason = %q", reason)
+	}
+}
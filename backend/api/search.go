@@ -0,0 +1,284 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"html"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/crypto"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchTerms     = 10
+	// maxSearchScan is the number of most recent messages matching the
+	// filters that are decrypted and ranked per search.
+	maxSearchScan = 5000
+
+	snippetBefore = 40
+	snippetAfter  = 80
+
+	// BM25 parameters as used by SQLite FTS5.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// searchResult is a message found by a search together with the name of its
+// conversation and an HTML snippet in which the matches are wrapped in <mark>.
+type searchResult struct {
+	messageResponse
+	ConversationName string `json:"conversationName"`
+	Snippet          string `json:"snippet"`
+}
+
+type searchCandidate struct {
+	result searchResult
+	body   string
+	counts []int
+	length int
+	score  float64
+}
+
+// handleSearch searches the messages of all conversations of the current
+// user. Message bodies are encrypted at rest, so instead of a full-text index
+// the most recent messages matching the filters are decrypted and ranked by
+// BM25. Every term of q must occur in a message, ignoring case.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	query := r.URL.Query()
+
+	terms := strings.Fields(query.Get("q"))
+	if len(terms) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]searchResult{})
+		return
+	}
+	if len(terms) > maxSearchTerms {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Search may contain at most 10 terms", "q")
+		return
+	}
+
+	limit := int64(defaultSearchLimit)
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxSearchLimit {
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Limit must be between 1 and 100")
+			return
+		}
+		limit = parsedLimit
+	}
+
+	var offset int64
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || parsedOffset < 0 {
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid offset")
+			return
+		}
+		offset = parsedOffset
+	}
+
+	var sender *string
+	if senderStr := strings.TrimSpace(query.Get("sender")); senderStr != "" {
+		sender = &senderStr
+	}
+
+	after, err := parseSearchTime(query.Get("after"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid after timestamp")
+		return
+	}
+	before, err := parseSearchTime(query.Get("before"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid before timestamp")
+		return
+	}
+
+	rows, err := s.queries.GetSearchableMessages(r.Context(), userID, sender, after, before, maxSearchScan)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	patterns := make([]*regexp.Regexp, len(terms))
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+		patterns[i] = regexp.MustCompile("(?i)" + quoted[i])
+	}
+	anyTerm := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	// Term and length statistics cover every scanned message, like the
+	// statistics of a full-text index cover the whole table.
+	var candidates []searchCandidate
+	docFreq := make([]int, len(terms))
+	scanned, totalLength := 0, 0
+	for _, row := range rows {
+		body := row.Body
+		if crypto.IsEncrypted(body) {
+			body, err = s.decryptCache.decrypt(row.ID, row.ConversationID, body)
+			if err != nil {
+				log.Printf("Failed to decrypt message %d in conversation %d: %v", row.ID, row.ConversationID, err)
+				continue
+			}
+		}
+
+		length := len(strings.Fields(body))
+		scanned++
+		totalLength += length
+
+		counts := make([]int, len(terms))
+		matchesAll := true
+		for i, pattern := range patterns {
+			counts[i] = len(pattern.FindAllStringIndex(body, -1))
+			if counts[i] > 0 {
+				docFreq[i]++
+			} else {
+				matchesAll = false
+			}
+		}
+		if !matchesAll {
+			continue
+		}
+
+		candidates = append(candidates, searchCandidate{
+			result: searchResult{
+				messageResponse: s.convertToMessageResponse(row.ID, row.ConversationID, row.Seq, row.SenderID,
+					row.SenderUsername, row.SenderProfileImageHash, row.CreatedAt, row.EditedAt,
+					row.ContentType, row.Body, row.ReplyToID, row.ReactionsJson, row.UserReactionsJson),
+				ConversationName: row.ConversationName,
+			},
+			body:   body,
+			counts: counts,
+			length: length,
+		})
+	}
+
+	if len(candidates) > 0 {
+		n := float64(scanned)
+		avgLength := float64(totalLength) / n
+		for i := range candidates {
+			candidates[i].score = bm25(candidates[i].counts, docFreq, candidates[i].length, avgLength, n)
+		}
+	}
+
+	// Rows arrive newest first, which breaks ties between equal scores.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	if offset > int64(len(candidates)) {
+		offset = int64(len(candidates))
+	}
+	end := offset + limit
+	if end > int64(len(candidates)) {
+		end = int64(len(candidates))
+	}
+	page := candidates[offset:end]
+
+	messages := make([]messageResponse, len(page))
+	for i, candidate := range page {
+		messages[i] = candidate.result.messageResponse
+	}
+	if err := s.attachThreadSummaries(r.Context(), userID, messages); err != nil {
+		log.Printf("Failed to attach thread summaries: %v", err)
+	}
+
+	results := make([]searchResult, len(page))
+	for i, candidate := range page {
+		results[i] = candidate.result
+		results[i].messageResponse = messages[i]
+		results[i].Snippet = searchSnippet(candidate.body, anyTerm)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+func parseSearchTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// bm25 scores a message by the number of times each term occurs in it
+// (counts), the number of scanned messages containing each term (docFreq) and
+// its length in words relative to the average.
+func bm25(counts, docFreq []int, length int, avgLength, n float64) float64 {
+	score := 0.0
+	for i, count := range counts {
+		idf := math.Log((n-float64(docFreq[i])+0.5)/(float64(docFreq[i])+0.5) + 1)
+		tf := float64(count)
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(length)/avgLength))
+	}
+	return score
+}
+
+// searchSnippet returns the HTML-escaped part of body around the first match
+// of pattern, with every match inside it wrapped in <mark>.
+func searchSnippet(body string, pattern *regexp.Regexp) string {
+	matches := pattern.FindAllStringIndex(body, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	start := max(matches[0][0]-snippetBefore, 0)
+	for start > 0 && !utf8.RuneStart(body[start]) {
+		start--
+	}
+	end := min(matches[0][1]+snippetAfter, len(body))
+	for end < len(body) && !utf8.RuneStart(body[end]) {
+		end++
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+	pos := start
+	for _, match := range matches {
+		if match[0] < start {
+			continue
+		}
+		if match[1] > end {
+			break
+		}
+		snippet.WriteString(html.EscapeString(body[pos:match[0]]))
+		snippet.WriteString("<mark>")
+		snippet.WriteString(html.EscapeString(body[match[0]:match[1]]))
+		snippet.WriteString("</mark>")
+		pos = match[1]
+	}
+	snippet.WriteString(html.EscapeString(body[pos:end]))
+	if end < len(body) {
+		snippet.WriteString("…")
+	}
+
+	return strings.Join(strings.Fields(snippet.String()), " ")
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+// maxSeenByUsers caps the participants listed by handleMessageSeenBy.
+const maxSeenByUsers = 50
+
+type seenByUser struct {
+	UserID   int64   `json:"userId"`
+	Username string  `json:"username"`
+	SeenAt   *string `json:"seenAt"`
+}
+
+type seenByResponse struct {
+	SeenBy []seenByUser `json:"seenBy"`
+	Total  int64        `json:"total"`
+}
+
+// handleMessageSeenBy lists the participants other than the sender whose read
+// state reached the message, at most maxSeenByUsers of them. seenAt is the
+// time the participant last updated their read state.
+func (s *Server) handleMessageSeenBy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	messageID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid message ID")
+		return
+	}
+
+	message, err := s.queries.GetMessageByID(r.Context(), messageID)
+	if err != nil || message.DeletedAt != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Message not found")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), message.ConversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	total, err := s.queries.CountMessageSeenBy(r.Context(), message.ConversationID, message.Seq, message.SenderID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	rows, err := s.queries.ListMessageSeenBy(r.Context(), message.ConversationID, message.Seq, message.SenderID, maxSeenByUsers)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := seenByResponse{SeenBy: make([]seenByUser, len(rows)), Total: total}
+	for i, row := range rows {
+		response.SeenBy[i] = seenByUser{UserID: row.ID, Username: row.Username}
+		if row.LastReadAt != nil {
+			seenAt := row.LastReadAt.Format("2006-01-02T15:04:05Z")
+			response.SeenBy[i].SeenAt = &seenAt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// attachSeenByCounts sets the number of participants other than the sender
+// who have read each message of a conversation page.
+func (s *Server) attachSeenByCounts(ctx context.Context, conversationID int64, messages []messageResponse) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	readSeqs, err := s.queries.GetConversationReadSeqs(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		var count int64
+		for _, rs := range readSeqs {
+			if rs.UserID != messages[i].SenderID && rs.LastReadSeq >= messages[i].Seq {
+				count++
+			}
+		}
+		messages[i].SeenByCount = &count
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bloodmagesoftware/teamsync/config"
+	"github.com/bloodmagesoftware/teamsync/crypto"
+)
+
+type serverConfigResponse struct {
+	Features         map[config.Feature]bool `json:"features"`
+	MaxUploadBytes   int64                   `json:"maxUploadBytes"`
+	MaxMessageLength int                     `json:"maxMessageLength"`
+	// InvitationsPerUser is the number of open invitation codes a user who
+	// is not an administrator may have.
+	InvitationsPerUser int `json:"invitationsPerUser"`
+
+	Version string `json:"version"`
+	// APIVersions lists the supported route prefixes, e.g. /api/v1; those in
+	// Deprecated will be removed.
+	APIVersions []string `json:"apiVersions"`
+	Deprecated  []string `json:"deprecated"`
+}
+
+// handleServerConfig tells clients which optional features this instance
+// has enabled so they can hide the UI of the others. It requires no
+// authentication because the login page needs it too.
+func (s *Server) handleServerConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	s.turnConfigMutex.RLock()
+	turnEnabled := s.turnConfig.ListenAddress != ""
+	s.turnConfigMutex.RUnlock()
+
+	features := make(map[config.Feature]bool, len(config.Features))
+	for _, feature := range config.Features {
+		switch feature {
+		case config.FeatureEncryption:
+			features[feature] = crypto.Initialized()
+		case config.FeatureGroupCalls:
+			features[feature] = !s.groupCallsDisabled
+		case config.FeatureFileUploads:
+			features[feature] = !s.fileUploadsDisabled
+		case config.FeatureTURN:
+			features[feature] = turnEnabled
+		case config.FeaturePushNotifications:
+			features[feature] = s.pushEnabled()
+		case config.FeatureLDAPAuth:
+			features[feature] = s.ldap != nil
+		case config.FeatureMarkdown:
+			features[feature] = !s.markdownDisabled
+		default:
+			features[feature] = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(serverConfigResponse{
+		Features:           features,
+		MaxUploadBytes:     s.maxUploadBodySize,
+		MaxMessageLength:   s.maxMessageLength,
+		InvitationsPerUser: s.invitationsPerUser,
+		Version:            config.Version,
+		APIVersions:        []string{apiVersion},
+		Deprecated:         []string{},
+	})
+}
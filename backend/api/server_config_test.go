@@ -0,0 +1,104 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bloodmagesoftware/teamsync/config"
+	"github.com/bloodmagesoftware/teamsync/rtc"
+)
+
+func getServerConfig(t *testing.T, s *Server) serverConfigResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.handleServerConfig(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp serverConfigResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	return resp
+}
+
+func TestServerConfigFeatures(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *Server
+		want   map[config.Feature]bool
+	}{
+		{
+			name:   "defaults",
+			server: &Server{},
+			want: map[config.Feature]bool{
+				config.FeatureEncryption:        false,
+				config.FeatureGroupCalls:        true,
+				config.FeatureFileUploads:       true,
+				config.FeatureTURN:              false,
+				config.FeaturePushNotifications: false,
+				config.FeatureLDAPAuth:          false,
+				config.FeatureMarkdown:          true,
+			},
+		},
+		{
+			name: "everything toggled",
+			server: &Server{
+				groupCallsDisabled:  true,
+				fileUploadsDisabled: true,
+				markdownDisabled:    true,
+				turnConfig:          rtc.Config{ListenAddress: "0.0.0.0:3478"},
+				vapidPublicKey:      "public",
+				vapidPrivateKey:     "private",
+			},
+			want: map[config.Feature]bool{
+				config.FeatureEncryption:        false,
+				config.FeatureGroupCalls:        false,
+				config.FeatureFileUploads:       false,
+				config.FeatureTURN:              true,
+				config.FeaturePushNotifications: true,
+				config.FeatureLDAPAuth:          false,
+				config.FeatureMarkdown:          false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := getServerConfig(t, tt.server)
+			if len(resp.Features) != len(config.Features) {
+				t.Errorf("features = %v, want all of %v", resp.Features, config.Features)
+			}
+			for _, feature := range config.Features {
+				got, ok := resp.Features[feature]
+				if !ok {
+					t.Errorf("feature %s missing", feature)
+					continue
+				}
+				if want, ok := tt.want[feature]; !ok {
+					t.Errorf("feature %s is not covered by this test", feature)
+				} else if got != want {
+					t.Errorf("feature %s = %v, want %v", feature, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestServerConfigLimits(t *testing.T) {
+	s := &Server{maxUploadBodySize: 1 << 20, maxMessageLength: 500, invitationsPerUser: 3}
+	resp := getServerConfig(t, s)
+
+	if resp.MaxUploadBytes != 1<<20 || resp.MaxMessageLength != 500 || resp.InvitationsPerUser != 3 {
+		t.Errorf("limits = %d, %d, %d", resp.MaxUploadBytes, resp.MaxMessageLength, resp.InvitationsPerUser)
+	}
+	if resp.Version != config.Version {
+		t.Errorf("version = %q, want %q", resp.Version, config.Version)
+	}
+	if len(resp.APIVersions) != 1 || resp.APIVersions[0] != apiVersion || resp.Deprecated == nil {
+		t.Errorf("apiVersions = %v, deprecated = %v", resp.APIVersions, resp.Deprecated)
+	}
+}
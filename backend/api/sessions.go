@@ -0,0 +1,119 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const maxDeviceNameLength = 64
+
+type sessionResponse struct {
+	ID                    int64      `json:"id"`
+	DeviceName            *string    `json:"deviceName"`
+	CreatedAt             *time.Time `json:"createdAt"`
+	AccessTokenExpiresAt  time.Time  `json:"accessTokenExpiresAt"`
+	RefreshTokenExpiresAt time.Time  `json:"refreshTokenExpiresAt"`
+}
+
+type revokeDeviceRequest struct {
+	DeviceName string `json:"deviceName"`
+}
+
+// parseDeviceName normalizes the optional device label sent on login and
+// registration. An empty label is stored as NULL.
+func parseDeviceName(name string) (*string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	if utf8.RuneCountInString(name) > maxDeviceNameLength {
+		return nil, errors.New("Device name must be at most 64 characters")
+	}
+	return &name, nil
+}
+
+// handleSessions lists the tokens issued to the current user.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	tokens, err := s.queries.GetUserTokens(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	sessions := make([]sessionResponse, len(tokens))
+	for i, token := range tokens {
+		sessions[i] = sessionResponse{
+			ID:                    token.ID,
+			DeviceName:            token.DeviceName,
+			CreatedAt:             token.CreatedAt,
+			AccessTokenExpiresAt:  token.AccessTokenExpiresAt,
+			RefreshTokenExpiresAt: token.RefreshTokenExpiresAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// handleRevokeDevice deletes every token of the current user that was issued
+// to the given device name.
+func (s *Server) handleRevokeDevice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	var req revokeDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteDecodeError(w, err)
+		return
+	}
+
+	deviceName, err := parseDeviceName(req.DeviceName)
+	if err != nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, err.Error(), "deviceName")
+		return
+	}
+	if deviceName == nil {
+		WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Device name is required", "deviceName")
+		return
+	}
+
+	revoked, err := s.queries.DeleteUserDeviceTokens(r.Context(), userID, deviceName)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to revoke sessions")
+		return
+	}
+
+	s.auditLog(r, userID, auditActionSessionsRevoke, "user", userID, map[string]any{"deviceName": *deviceName})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"revoked": revoked,
+	})
+}
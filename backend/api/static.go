@@ -0,0 +1,105 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/public"
+)
+
+// staticMIMETypes are registered explicitly because the system MIME tables
+// Go falls back to differ between platforms.
+var staticMIMETypes = map[string]string{
+	".js":          "application/javascript",
+	".css":         "text/css",
+	".svg":         "image/svg+xml",
+	".png":         "image/png",
+	".jpg":         "image/jpeg",
+	".jpeg":        "image/jpeg",
+	".webp":        "image/webp",
+	".avif":        "image/avif",
+	".mp4":         "video/mp4",
+	".webm":        "video/webm",
+	".wasm":        "application/wasm",
+	".webmanifest": "application/manifest+json",
+}
+
+// hashedAssetPattern matches the file names Vite gives to build output, which
+// carry a content hash like index-BxY12abc.js and never change.
+var hashedAssetPattern = regexp.MustCompile(`^assets/.+-[A-Za-z0-9_-]{8}\.[A-Za-z0-9]+$`)
+
+// staticETags caches the ETag of every embedded file by path.
+var staticETags sync.Map
+
+func init() {
+	for ext, typ := range staticMIMETypes {
+		mime.AddExtensionType(ext, typ)
+	}
+}
+
+// handleStaticFiles serves the embedded frontend. Paths that are not files
+// are routes of the single page app and get index.html. Hashed assets may be
+// cached forever; everything else is revalidated through its ETag.
+func (s *Server) handleStaticFiles(w http.ResponseWriter, r *http.Request) {
+	fsPath := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+
+	info, err := fs.Stat(public.Public, fsPath)
+	if err != nil || info.IsDir() {
+		fsPath = "index.html"
+	}
+
+	etag, err := staticETag(fsPath)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	if hashedAssetPattern.MatchString(fsPath) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if fsPath == "index.html" {
+		// ServeFileFS would redirect requests for index.html to the directory.
+		data, err := fs.ReadFile(public.Public, fsPath)
+		if err != nil {
+			WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		http.ServeContent(w, r, fsPath, time.Time{}, bytes.NewReader(data))
+		return
+	}
+
+	http.ServeFileFS(w, r, public.Public, fsPath)
+}
+
+// staticETag returns the strong ETag of an embedded file, computed from its
+// content on first use.
+func staticETag(fsPath string) (string, error) {
+	if etag, ok := staticETags.Load(fsPath); ok {
+		return etag.(string), nil
+	}
+
+	data, err := fs.ReadFile(public.Public, fsPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+
+	staticETags.Store(fsPath, etag)
+	return etag, nil
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	defaultSyncWindow = 30 * time.Minute
+	maxSyncEvents     = 500
+)
+
+// handleConversationSync returns the events of a conversation the client may
+// have missed since the given time, in the format of the event stream. Clients
+// call it after reconnecting and before subscribing again. It contains the
+// new messages (message.new, followed by thread.reply for replies), the
+// current tallies of polls voted on (poll.vote) and finally the unread counts
+// of the user (notification.unread). Reactions are part of the messages.
+//
+// At most 500 message and poll events are returned, oldest messages first. If
+// there are more, the X-Sync-Truncated header is set and the client should
+// continue from the createdAt of the last message.
+func (s *Server) handleConversationSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	since := time.Now().Add(-defaultSyncWindow)
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid since timestamp")
+			return
+		}
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	// Every message is at least one event, so one more than maxSyncEvents
+	// messages is enough to tell that the events are truncated. Messages
+	// beyond that are not loaded or decrypted.
+	msgs, err := s.queries.GetMessagesSince(r.Context(), userID, conversationID, since, maxSyncEvents+1)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	messages := make([]messageResponse, len(msgs))
+	for i, msg := range msgs {
+		messages[i] = s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
+			msg.SenderUsername, msg.SenderProfileImageHash, msg.CreatedAt, msg.EditedAt,
+			msg.ContentType, msg.Body, msg.ReplyToID, msg.ReactionsJson, msg.UserReactionsJson)
+	}
+
+	if err := s.attachPollTallies(r.Context(), messages); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	polls, err := s.pollVotesSince(r.Context(), conversationID, since)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	events := make([]Event, 0, min(len(messages)+len(polls), maxSyncEvents)+1)
+	truncated := false
+	add := func(event Event) {
+		if len(events) >= maxSyncEvents {
+			truncated = true
+			return
+		}
+		events = append(events, event)
+	}
+
+	for _, message := range messages {
+		add(Event{Type: EventTypeMessageNew, Data: message})
+		if message.ReplyToID != nil {
+			add(Event{Type: EventTypeThreadReply, Data: message})
+		}
+	}
+	for _, poll := range polls {
+		add(Event{Type: EventTypePollVote, Data: poll})
+	}
+
+	unread, err := s.unreadCounts(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	events = append(events, Event{Type: EventTypeUnreadCount, Data: unread})
+
+	w.Header().Set("Content-Type", "application/json")
+	if truncated {
+		w.Header().Set("X-Sync-Truncated", "true")
+	}
+	json.NewEncoder(w).Encode(events)
+}
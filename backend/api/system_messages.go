@@ -0,0 +1,23 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/messaging"
+)
+
+// storeSystemMessage records a change to a conversation as an
+// application/system message within tx. The actor of the event is the sender
+// of the message. Announce the returned message with its body once tx is
+// committed.
+func storeSystemMessage(ctx context.Context, tx *db.QuerierTx, conversationID int64, event messaging.SystemEvent) (db.Message, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return db.Message{}, err
+	}
+
+	return appendMessage(ctx, tx, conversationID, event.ActorID, messaging.ContentTypeSystem, string(body), nil, nil)
+}
@@ -0,0 +1,173 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+// maxThreadDepth limits how many levels of replies below the requested
+// message are returned by handleMessageThread.
+const maxThreadDepth = 3
+
+type threadParticipant struct {
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+}
+
+type threadResponse struct {
+	Root    messageResponse   `json:"root"`
+	Replies []messageResponse `json:"replies"`
+}
+
+// handleMessageThread returns a message together with its replies up to
+// maxThreadDepth levels deep, ordered by seq. Deleted replies and replies by
+// blocked users are left out, but their own replies are still included.
+func (s *Server) handleMessageThread(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	messageID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid message ID")
+		return
+	}
+
+	message, err := s.queries.GetMessageByID(r.Context(), messageID)
+	if err != nil || message.DeletedAt != nil {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Message not found")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), message.ConversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	blocked := s.blockRelatedUsers(userID)
+	if blocked[message.SenderID] {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "Message not found")
+		return
+	}
+
+	threadRootID := message.ID
+	if message.ThreadRootID != nil {
+		threadRootID = *message.ThreadRootID
+	}
+
+	msgs, err := s.queries.GetThreadMessages(r.Context(), userID, &threadRootID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	sender, err := s.queries.GetUser(r.Context(), message.SenderID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := threadResponse{
+		Root: s.convertToMessageResponse(message.ID, message.ConversationID, message.Seq, message.SenderID,
+			sender.Username, sender.ProfileImageHash, message.CreatedAt, message.EditedAt,
+			message.ContentType, message.Body, message.ReplyToID, "", ""),
+		Replies: []messageResponse{},
+	}
+
+	// Replies are ordered by seq, so every parent is seen before its replies.
+	depth := map[int64]int{message.ID: 0}
+	for _, msg := range msgs {
+		if msg.ReplyToID == nil {
+			continue
+		}
+		parentDepth, ok := depth[*msg.ReplyToID]
+		if !ok || parentDepth >= maxThreadDepth {
+			continue
+		}
+		depth[msg.ID] = parentDepth + 1
+
+		if msg.DeletedAt != nil || blocked[msg.SenderID] {
+			continue
+		}
+		response.Replies = append(response.Replies, s.convertToMessageResponse(msg.ID, msg.ConversationID, msg.Seq, msg.SenderID,
+			msg.SenderUsername, msg.SenderProfileImageHash, msg.CreatedAt, msg.EditedAt,
+			msg.ContentType, msg.Body, msg.ReplyToID, msg.ReactionsJson, msg.UserReactionsJson))
+	}
+
+	all := append([]messageResponse{response.Root}, response.Replies...)
+	if err := s.attachThreadSummaries(r.Context(), userID, all); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	if err := s.attachPollTallies(r.Context(), all); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+	response.Root = all[0]
+	response.Replies = all[1:]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// attachThreadSummaries fills in the reply count and the distinct senders of
+// direct replies for every message, skipping replies hidden from userID.
+func (s *Server) attachThreadSummaries(ctx context.Context, userID int64, messages []messageResponse) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	index := make(map[int64]int, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+		index[msg.ID] = i
+	}
+
+	rows, err := s.queries.GetReplySenders(ctx, ids, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if row.ReplyToID == nil {
+			continue
+		}
+		i, ok := index[*row.ReplyToID]
+		if !ok {
+			continue
+		}
+		messages[i].ReplyCount += row.ReplyCount
+		messages[i].ThreadParticipants = append(messages[i].ThreadParticipants, threadParticipant{
+			UserID:   row.SenderID,
+			Username: row.Username,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultHTTPRedirectAddress = ":80"
+	// hstsMaxAge is two years, the minimum for the HSTS preload list.
+	hstsMaxAge = 2 * 365 * 24 * time.Hour
+)
+
+// HSTSMiddleware tells browsers to only use HTTPS for this host.
+// includeSubDomains extends the policy to all subdomains. preload asks for
+// inclusion in the browser preload lists, which requires includeSubDomains, so
+// it implies it.
+func HSTSMiddleware(includeSubDomains, preload bool) func(http.Handler) http.Handler {
+	value := fmt.Sprintf("max-age=%d", int64(hstsMaxAge.Seconds()))
+	if includeSubDomains || preload {
+		value += "; includeSubDomains"
+	}
+	if preload {
+		value += "; preload"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Strict-Transport-Security", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// redirectToHTTPS answers every request with a permanent redirect to the same
+// path and query on publicURL. The Host header is not used, as it is chosen by
+// the client.
+func redirectToHTTPS(publicURL *url.URL) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://"+publicURL.Host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// warnSelfSignedCertificate logs a warning when the certificate in certFile
+// signed itself.
+func warnSelfSignedCertificate(certFile, keyFile string) error {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse TLS certificate: %w", err)
+	}
+
+	if bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil {
+		slog.Warn("TLS cert is self-signed, HSTS preload not recommended")
+	}
+	return nil
+}
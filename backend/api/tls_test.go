@@ -0,0 +1,43 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHSTSMiddleware(t *testing.T) {
+	tests := []struct {
+		includeSubDomains, preload bool
+		want                       string
+	}{
+		{false, false, "max-age=63072000"},
+		{true, false, "max-age=63072000; includeSubDomains"},
+		{false, true, "max-age=63072000; includeSubDomains; preload"},
+	}
+	for _, tt := range tests {
+		handler := HSTSMiddleware(tt.includeSubDomains, tt.preload)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := rec.Header().Get("Strict-Transport-Security"); got != tt.want {
+			t.Errorf("HSTSMiddleware(%v, %v) = %q, want %q", tt.includeSubDomains, tt.preload, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectToHTTPSIgnoresHostHeader(t *testing.T) {
+	publicURL, _ := url.Parse("https://chat.example.com:8443")
+
+	r := httptest.NewRequest(http.MethodGet, "http://evil.example/login?next=%2F", nil)
+	rec := httptest.NewRecorder()
+	redirectToHTTPS(publicURL).ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMovedPermanently)
+	}
+	if got, want := rec.Header().Get("Location"), "https://chat.example.com:8443/login?next=%2F"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	// typingTimeout is how long a user counts as typing after their last
+	// typing request.
+	typingTimeout = 5 * time.Second
+	typingTick    = 2 * time.Second
+)
+
+type typingKey struct {
+	userID         int64
+	conversationID int64
+}
+
+// typingEvent is the payload of conversation.typing and
+// conversation.stopped_typing events.
+type typingEvent struct {
+	ConversationID int64 `json:"conversationId"`
+	UserID         int64 `json:"userId"`
+}
+
+// typingState remembers when each user last reported typing in a
+// conversation, so that a stop can be announced for clients that went away.
+type typingState struct {
+	mu      sync.RWMutex
+	entries map[typingKey]time.Time
+}
+
+func newTypingState() *typingState {
+	return &typingState{entries: make(map[typingKey]time.Time)}
+}
+
+// touch records that a user is typing and reports whether they were not
+// already known to be typing.
+func (t *typingState) touch(userID, conversationID int64, now time.Time) bool {
+	key := typingKey{userID: userID, conversationID: conversationID}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, typing := t.entries[key]
+	t.entries[key] = now
+	return !typing
+}
+
+// expire removes and returns the entries last touched before cutoff.
+func (t *typingState) expire(cutoff time.Time) []typingKey {
+	t.mu.RLock()
+	var stale []typingKey
+	for key, last := range t.entries {
+		if last.Before(cutoff) {
+			stale = append(stale, key)
+		}
+	}
+	t.mu.RUnlock()
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expired := stale[:0]
+	for _, key := range stale {
+		// The user may have typed again since the read lock was released.
+		if last, ok := t.entries[key]; ok && last.Before(cutoff) {
+			delete(t.entries, key)
+			expired = append(expired, key)
+		}
+	}
+	return expired
+}
+
+// handleTyping tells the other participants of a conversation that the
+// current user is typing. Clients repeat the request while the user keeps
+// typing; typingTimeout after the last request a stop is announced.
+func (s *Server) handleTyping(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid conversation ID")
+		return
+	}
+
+	participants, err := s.queries.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	isParticipant := false
+	for _, p := range participants {
+		if p.ID == userID {
+			isParticipant = true
+			break
+		}
+	}
+
+	if !isParticipant {
+		WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+		return
+	}
+
+	if s.typing.touch(userID, conversationID, time.Now()) {
+		go s.broadcastTyping(EventTypeTyping, typingKey{userID: userID, conversationID: conversationID})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) broadcastTyping(eventType EventType, key typingKey) {
+	exclude := map[int64]bool{key.userID: true}
+	for id := range s.blockRelatedUsers(key.userID) {
+		exclude[id] = true
+	}
+
+	evtMgr.broadcastToConversationExcept(s, key.conversationID, Event{
+		Type: eventType,
+		Data: typingEvent{ConversationID: key.conversationID, UserID: key.userID},
+	}, exclude)
+}
+
+// expireTyping announces a stop for every user whose last typing request is
+// older than typingTimeout, every typingTick until stop is closed.
+func (s *Server) expireTyping(stop <-chan struct{}) {
+	ticker := time.NewTicker(typingTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, key := range s.typing.expire(time.Now().Add(-typingTimeout)) {
+				s.broadcastTyping(EventTypeStoppedTyping, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+)
+
+const (
+	webhookAttempts       = 3
+	webhookInitialBackoff = time.Second
+	webhookTimeout        = 10 * time.Second
+	webhookWorkers        = 4
+	webhookQueueSize      = 256
+)
+
+// webhookClient refuses to connect to private addresses, like previewClient,
+// and does not follow redirects, so a hook cannot be bounced to an internal
+// service.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: denyPrivateAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        16,
+		IdleConnTimeout:     time.Minute,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+type createWebhookRequest struct {
+	URL            string `json:"url"`
+	ConversationID int64  `json:"conversationId"`
+	Secret         string `json:"secret"`
+}
+
+type webhookResponse struct {
+	ID             int64  `json:"id"`
+	ConversationID int64  `json:"conversationId"`
+	URL            string `json:"url"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		webhooks, err := s.queries.ListWebhooksByUser(r.Context(), userID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+
+		response := make([]webhookResponse, len(webhooks))
+		for i, hook := range webhooks {
+			response[i] = webhookResponse{
+				ID:             hook.ID,
+				ConversationID: hook.ConversationID,
+				URL:            hook.Url,
+				CreatedAt:      hook.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodPost:
+		var req createWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteDecodeError(w, err)
+			return
+		}
+
+		hookURL, err := url.Parse(req.URL)
+		if err != nil || hookURL.Scheme != "https" || hookURL.Host == "" {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Webhook URL must use HTTPS", "url")
+			return
+		}
+
+		if err := checkPublicHost(r.Context(), hookURL.Hostname()); err != nil {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Webhook URL must point to a public address", "url")
+			return
+		}
+
+		if req.Secret == "" {
+			WriteFieldError(w, http.StatusBadRequest, ErrCodeValidation, "Secret is required", "secret")
+			return
+		}
+
+		participants, err := s.queries.GetConversationParticipants(r.Context(), req.ConversationID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			return
+		}
+
+		isParticipant := false
+		for _, p := range participants {
+			if p.ID == userID {
+				isParticipant = true
+				break
+			}
+		}
+
+		if !isParticipant {
+			WriteError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+			return
+		}
+
+		hook, err := s.queries.CreateWebhook(r.Context(), userID, req.ConversationID, hookURL.String(), req.Secret)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to create webhook")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(webhookResponse{
+			ID:             hook.ID,
+			ConversationID: hook.ConversationID,
+			URL:            hook.Url,
+			CreatedAt:      hook.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		})
+
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := auth.GetUserID(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeInvalidInput, "Invalid request")
+		return
+	}
+
+	if err := s.queries.DeleteWebhook(r.Context(), webhookID, userID); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// deliverWebhooks queues a new message for every webhook registered for its
+// conversation whose owner may still read it. Deliveries are dropped when
+// the queue is full rather than holding up the sender.
+func (s *Server) deliverWebhooks(conversationID int64, message messageResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	webhooks, err := s.queries.ListDeliverableWebhooks(ctx, conversationID, &now, message.SenderID)
+	if err != nil {
+		log.Printf("Failed to load webhooks of conversation %d: %v", conversationID, err)
+		return
+	}
+
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	for _, hook := range webhooks {
+		delivery := webhookDelivery{webhookID: hook.ID, url: hook.Url, secret: hook.Secret, body: body}
+		select {
+		case s.webhookDeliveries <- delivery:
+		default:
+			log.Printf("Webhook %d delivery dropped: queue full", hook.ID)
+		}
+	}
+}
+
+type webhookDelivery struct {
+	webhookID int64
+	url       string
+	secret    string
+	body      []byte
+}
+
+// startWebhookWorkers starts webhookWorkers goroutines that deliver queued
+// webhooks until stopWebhooks is called.
+func (s *Server) startWebhookWorkers() {
+	s.webhookDeliveries = make(chan webhookDelivery, webhookQueueSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelWebhooks = cancel
+
+	for range webhookWorkers {
+		s.webhookWorkers.Add(1)
+		go func() {
+			defer s.webhookWorkers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery := <-s.webhookDeliveries:
+					deliverWebhook(ctx, delivery)
+				}
+			}
+		}()
+	}
+}
+
+// stopWebhooks cancels deliveries in progress, drops queued ones and waits
+// for the workers to return.
+func (s *Server) stopWebhooks() {
+	if s.cancelWebhooks == nil {
+		return
+	}
+	s.cancelWebhooks()
+	s.webhookWorkers.Wait()
+}
+
+func deliverWebhook(ctx context.Context, delivery webhookDelivery) {
+	mac := hmac.New(sha256.New, []byte(delivery.secret))
+	mac.Write(delivery.body)
+	signature := "hmac-sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	backoff := webhookInitialBackoff
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		err := postWebhook(ctx, delivery.url, signature, delivery.body)
+		if err == nil {
+			return
+		}
+
+		log.Printf("Webhook %d delivery attempt %d failed: %v", delivery.webhookID, attempt, err)
+		if attempt < webhookAttempts {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+}
+
+func postWebhook(ctx context.Context, hookURL, signature string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Teamsync-Signature", signature)
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/auth"
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+func TestCreateWebhookRejectsPrivateAddress(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, s, "owner")
+
+	for _, hookURL := range []string{"https://127.0.0.1/hook", "https://10.0.0.1/hook", "https://localhost/hook"} {
+		body := `{"url":"` + hookURL + `","conversationId":1,"secret":"s"}`
+		r := httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, owner.ID))
+
+		rec := httptest.NewRecorder()
+		s.handleWebhooks(rec, r)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", hookURL, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestListDeliverableWebhooks(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	sender := createTestUser(t, s, "sender")
+	active := createTestUser(t, s, "active")
+	suspended := createTestUser(t, s, "suspended")
+	deleted := createTestUser(t, s, "deleted")
+	blocking := createTestUser(t, s, "blocking")
+	outsider := createTestUser(t, s, "outsider")
+
+	conv, err := s.queries.CreateConversation(ctx, "group", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []db.User{sender, active, suspended, deleted, blocking} {
+		if err := s.queries.AddConversationParticipant(ctx, conv.ID, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hooks := map[int64]string{}
+	for _, u := range []db.User{active, suspended, deleted, blocking, outsider} {
+		hook, err := s.queries.CreateWebhook(ctx, u.ID, conv.ID, "https://example.com/"+u.Username, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hooks[hook.ID] = u.Username
+	}
+
+	until := time.Now().Add(time.Hour)
+	if err := s.queries.SuspendUser(ctx, &until, nil, suspended.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.queries.SoftDeleteUser(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.queries.BlockUser(ctx, blocking.ID, sender.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	deliverable, err := s.queries.ListDeliverableWebhooks(ctx, conv.ID, &now, sender.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliverable) != 1 || hooks[deliverable[0].ID] != "active" {
+		var owners []string
+		for _, hook := range deliverable {
+			owners = append(owners, hooks[hook.ID])
+		}
+		t.Fatalf("deliverable webhooks of %v, want only active", owners)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34": true,
+		"2606:4700::1":  true,
+		"127.0.0.1":     false,
+		"10.1.2.3":      false,
+		"172.16.0.1":    false,
+		"192.168.1.1":   false,
+		"100.64.0.1":    false,
+		"100.127.255.1": false,
+		"100.128.0.1":   true,
+		"169.254.1.1":   false,
+		"0.0.0.0":       false,
+		"::1":           false,
+		"fe80::1":       false,
+		"fd00::1":       false,
+		"224.0.0.1":     false,
+	}
+	for addr, want := range tests {
+		if got := isPublicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
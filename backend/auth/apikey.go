@@ -0,0 +1,41 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+// apiKeyHashPrefix marks hashed API keys in the database, so that keys stored
+// before they were hashed can be told apart.
+const apiKeyHashPrefix = "sha256:"
+
+// HashAPIKey returns the form of a bot API key that is stored in the
+// database. API keys are random and long, so a fast unsalted hash suffices
+// and keeps them searchable.
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return apiKeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// HashStoredAPIKeys replaces API keys that are still stored in plain text by
+// their hash and returns how many it replaced. Bots keep working with the
+// keys they already have.
+func HashStoredAPIKeys(ctx context.Context, queries *db.Queries) (int, error) {
+	rows, err := queries.ListUnhashedAPIKeys(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	for i, row := range rows {
+		hash := HashAPIKey(*row.ApiKey)
+		if err := queries.UpdateUserAPIKey(ctx, &hash, row.ID); err != nil {
+			return i, fmt.Errorf("failed to hash API key of user %d: %w", row.ID, err)
+		}
+	}
+	return len(rows), nil
+}
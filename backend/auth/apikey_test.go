@@ -0,0 +1,110 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+func newTestQueries(t *testing.T) *db.Queries {
+	t.Helper()
+	queries, err := db.Init(filepath.Join(t.TempDir(), "teamsync.db"))
+	if err != nil {
+		t.Fatalf("db.Init: %v", err)
+	}
+	t.Cleanup(func() { queries.Close() })
+	return queries
+}
+
+// requestWithAPIKey passes a request authenticated with apiKey through
+// RequireAuth and returns the status and the authenticated user.
+func requestWithAPIKey(queries *db.Queries, apiKey string) (int, int64) {
+	var userID int64
+	handler := RequireAuth(queries)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = GetUserID(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	r.Header.Set("Authorization", "ApiKey "+apiKey)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec.Code, userID
+}
+
+func TestRequireAuthWithHashedAPIKey(t *testing.T) {
+	queries := newTestQueries(t)
+	hash := HashAPIKey("secret-key")
+	bot, err := queries.CreateBotUser(context.Background(), "bot", &hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		apiKey string
+		status int
+	}{
+		{"key", "secret-key", http.StatusOK},
+		{"stored hash", hash, http.StatusUnauthorized},
+		{"other key", "other-key", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		status, userID := requestWithAPIKey(queries, tt.apiKey)
+		if status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.status)
+		}
+		if status == http.StatusOK && userID != bot.ID {
+			t.Errorf("%s: user = %d, want %d", tt.name, userID, bot.ID)
+		}
+	}
+}
+
+func TestHashStoredAPIKeys(t *testing.T) {
+	queries := newTestQueries(t)
+	ctx := context.Background()
+
+	legacyKey := "legacy-key"
+	legacy, err := queries.CreateBotUser(ctx, "legacy", &legacyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := HashAPIKey("current-key")
+	if _, err := queries.CreateBotUser(ctx, "current", &hash); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _ := requestWithAPIKey(queries, legacyKey); status != http.StatusUnauthorized {
+		t.Fatalf("unhashed key accepted before hashing: status %d", status)
+	}
+
+	hashed, err := HashStoredAPIKeys(ctx, queries)
+	if err != nil {
+		t.Fatalf("HashStoredAPIKeys: %v", err)
+	}
+	if hashed != 1 {
+		t.Errorf("hashed %d keys, want 1", hashed)
+	}
+
+	user, err := queries.GetUser(ctx, legacy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ApiKey == nil || *user.ApiKey != HashAPIKey(legacyKey) {
+		t.Errorf("stored key = %v, want its hash", user.ApiKey)
+	}
+	if status, userID := requestWithAPIKey(queries, legacyKey); status != http.StatusOK || userID != legacy.ID {
+		t.Errorf("legacy key after hashing: status %d, user %d", status, userID)
+	}
+	if status, _ := requestWithAPIKey(queries, "current-key"); status != http.StatusOK {
+		t.Errorf("current key after hashing: status %d", status)
+	}
+
+	if hashed, err := HashStoredAPIKeys(ctx, queries); err != nil || hashed != 0 {
+		t.Errorf("second run hashed %d keys, %v; want none", hashed, err)
+	}
+}
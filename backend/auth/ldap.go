@@ -0,0 +1,167 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	ldapTimeout             = 10 * time.Second
+	DefaultLDAPPort         = "389"
+	DefaultLDAPSPort        = "636"
+	DefaultLDAPSearchFilter = "(uid=%s)"
+)
+
+// Connection security of the LDAP server. Passwords are only sent in plain
+// text with LDAPSecurityNone, which has to be chosen explicitly.
+const (
+	LDAPSecurityLDAPS    = "ldaps"
+	LDAPSecurityStartTLS = "starttls"
+	LDAPSecurityNone     = "none"
+)
+
+// LDAPConfig describes the directory that verifies passwords. The user is
+// looked up below UserSearchBase with UserSearchFilter, in which %s is
+// replaced by the escaped username. The search binds as BindDN when it is set
+// and anonymously otherwise. Security defaults to LDAPSecurityLDAPS.
+type LDAPConfig struct {
+	Host             string
+	Port             string
+	Security         string
+	BindDN           string
+	BindPassword     string
+	UserSearchBase   string
+	UserSearchFilter string
+}
+
+// LDAPAuthenticator verifies passwords against an LDAP directory.
+type LDAPAuthenticator struct {
+	config LDAPConfig
+
+	// OnAuthenticated, if set, is called with the groups (memberOf) of a user
+	// after a successful bind. It is the place to map directory groups to
+	// roles.
+	OnAuthenticated func(username string, groups []string)
+}
+
+func NewLDAPAuthenticator(config LDAPConfig) (*LDAPAuthenticator, error) {
+	switch config.Security {
+	case "":
+		config.Security = LDAPSecurityLDAPS
+	case LDAPSecurityLDAPS, LDAPSecurityStartTLS, LDAPSecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown LDAP security %q, expected %q, %q or %q",
+			config.Security, LDAPSecurityLDAPS, LDAPSecurityStartTLS, LDAPSecurityNone)
+	}
+	if config.Port == "" {
+		config.Port = DefaultLDAPPort
+		if config.Security == LDAPSecurityLDAPS {
+			config.Port = DefaultLDAPSPort
+		}
+	}
+	if config.UserSearchFilter == "" {
+		config.UserSearchFilter = DefaultLDAPSearchFilter
+	}
+	return &LDAPAuthenticator{config: config}, nil
+}
+
+// dial connects to the LDAP server, upgrading the connection to TLS unless
+// the configuration opted out.
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	address := net.JoinHostPort(a.config.Host, a.config.Port)
+	dialer := ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout})
+	tlsConfig := &tls.Config{ServerName: a.config.Host, MinVersion: tls.VersionTLS12}
+
+	if a.config.Security == LDAPSecurityLDAPS {
+		return ldap.DialURL("ldaps://"+address, dialer, ldap.DialWithTLSConfig(tlsConfig))
+	}
+
+	conn, err := ldap.DialURL("ldap://"+address, dialer)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.Security == LDAPSecurityStartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// Authenticate reports whether password is the directory password of
+// username. Unknown users and wrong passwords are not errors.
+func (a *LDAPAuthenticator) Authenticate(username, password string) (bool, error) {
+	// An empty password would be an unauthenticated bind, which many servers
+	// accept for any DN.
+	if username == "" || password == "" {
+		return false, nil
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(ldapTimeout)
+
+	if a.config.BindDN != "" {
+		if err := conn.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+			return false, fmt.Errorf("failed to bind as search user: %w", err)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.UserSearchBase,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		strings.ReplaceAll(a.config.UserSearchFilter, "%s", ldap.EscapeFilter(username)),
+		[]string{"dn", "memberOf"},
+		nil,
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to search LDAP user: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return false, nil
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to bind as user: %w", err)
+	}
+
+	if a.OnAuthenticated != nil {
+		a.OnAuthenticated(username, entry.GetAttributeValues("memberOf"))
+	}
+	return true, nil
+}
+
+// UnusablePassword returns the salt and hash of a random password nobody
+// knows, for accounts whose password is verified elsewhere.
+func UnusablePassword() (salt, hash string, err error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return "", "", fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	salt, err = GenerateSalt()
+	if err != nil {
+		return "", "", err
+	}
+	hash, err = HashPassword(base64.StdEncoding.EncodeToString(password), salt)
+	if err != nil {
+		return "", "", err
+	}
+	return salt, hash, nil
+}
@@ -0,0 +1,33 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package auth
+
+import "testing"
+
+func TestNewLDAPAuthenticatorSecurity(t *testing.T) {
+	tests := []struct {
+		security     string
+		port         string
+		wantSecurity string
+		wantPort     string
+	}{
+		{"", "", LDAPSecurityLDAPS, DefaultLDAPSPort},
+		{LDAPSecurityStartTLS, "", LDAPSecurityStartTLS, DefaultLDAPPort},
+		{LDAPSecurityNone, "", LDAPSecurityNone, DefaultLDAPPort},
+		{LDAPSecurityLDAPS, "10636", LDAPSecurityLDAPS, "10636"},
+	}
+
+	for _, tt := range tests {
+		a, err := NewLDAPAuthenticator(LDAPConfig{Host: "ldap.example.com", Port: tt.port, Security: tt.security})
+		if err != nil {
+			t.Fatalf("NewLDAPAuthenticator(%q): %v", tt.security, err)
+		}
+		if a.config.Security != tt.wantSecurity || a.config.Port != tt.wantPort {
+			t.Errorf("security %q: got %s on port %s, want %s on port %s",
+				tt.security, a.config.Security, a.config.Port, tt.wantSecurity, tt.wantPort)
+		}
+	}
+
+	if _, err := NewLDAPAuthenticator(LDAPConfig{Host: "ldap.example.com", Security: "plain"}); err == nil {
+		t.Error("unknown security was accepted")
+	}
+}
@@ -3,6 +3,7 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
@@ -22,6 +23,22 @@ func RequireAuth(queries *db.Queries) func(http.Handler) http.Handler {
 			authHeader := r.Header.Get("Authorization")
 			if authHeader != "" {
 				parts := strings.Split(authHeader, " ")
+				if len(parts) == 2 && parts[0] == "ApiKey" {
+					apiKeyHash := HashAPIKey(parts[1])
+					user, err := queries.GetUserByAPIKey(r.Context(), &apiKeyHash)
+					if err != nil || user.DeletedAt != nil {
+						writeError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Authentication required")
+						return
+					}
+
+					if isSuspended(w, user) {
+						return
+					}
+
+					ctx := context.WithValue(r.Context(), UserIDKey, user.ID)
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
 				if len(parts) == 2 && parts[0] == "Bearer" {
 					accessToken = parts[1]
 				}
@@ -32,18 +49,28 @@ func RequireAuth(queries *db.Queries) func(http.Handler) http.Handler {
 			}
 
 			if accessToken == "" {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Authentication required")
 				return
 			}
 
 			token, err := queries.GetTokenByAccessToken(r.Context(), accessToken)
 			if err != nil {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Authentication required")
 				return
 			}
 
 			if time.Now().After(token.AccessTokenExpiresAt) {
-				http.Error(w, "Token expired", http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Token expired")
+				return
+			}
+
+			user, err := queries.GetUser(r.Context(), token.UserID)
+			if err != nil || user.DeletedAt != nil {
+				writeError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Authentication required")
+				return
+			}
+
+			if isSuspended(w, user) {
 				return
 			}
 
@@ -53,6 +80,53 @@ func RequireAuth(queries *db.Queries) func(http.Handler) http.Handler {
 	}
 }
 
+// RequireAdmin rejects requests of users without administrator rights. It must
+// be wrapped by RequireAuth.
+func RequireAdmin(queries *db.Queries) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Authentication required")
+				return
+			}
+
+			user, err := queries.GetUser(r.Context(), userID)
+			if err != nil || !user.IsAdmin {
+				writeError(w, http.StatusForbidden, "FORBIDDEN", "Administrator rights required")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isSuspended rejects the request with 403 if the user is currently suspended.
+func isSuspended(w http.ResponseWriter, user db.User) bool {
+	if user.SuspendedUntil == nil || !time.Now().Before(*user.SuspendedUntil) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{"code": "ACCOUNT_SUSPENDED", "message": "Account suspended"},
+		"until": user.SuspendedUntil.UTC().Format(time.RFC3339),
+	})
+	return true
+}
+
+// writeError mirrors api.WriteError, which cannot be imported here without an
+// import cycle.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]map[string]string{
+		"error": {"code": code, "message": message},
+	})
+}
+
 func GetUserID(ctx context.Context) (int64, bool) {
 	userID, ok := ctx.Value(UserIDKey).(int64)
 	return userID, ok
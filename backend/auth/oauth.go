@@ -13,6 +13,7 @@ const (
 	refreshTokenLength = 32
 	accessTokenTTL     = 24 * time.Hour
 	refreshTokenTTL    = 30 * 24 * time.Hour
+	apiKeyLength       = 48
 )
 
 type TokenPair struct {
@@ -42,6 +43,11 @@ func GenerateTokenPair() (*TokenPair, error) {
 	}, nil
 }
 
+// GenerateToken creates a random token suitable for permanent API keys.
+func GenerateToken() (string, error) {
+	return generateToken(apiKeyLength)
+}
+
 func generateToken(length int) (string, error) {
 	bytes := make([]byte, length)
 	if _, err := rand.Read(bytes); err != nil {
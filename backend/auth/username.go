@@ -0,0 +1,35 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package auth
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	minUsernameLength = 2
+	maxUsernameLength = 32
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// NormalizeUsername trims username and lowercases it, so that usernames are
+// unique regardless of case. The error describes why a username is not
+// accepted and can be shown to the user.
+func NormalizeUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return "", errors.New("Username is required")
+	}
+	if len(username) < minUsernameLength {
+		return "", errors.New("Username must be at least 2 characters")
+	}
+	if len(username) > maxUsernameLength {
+		return "", errors.New("Username must be at most 32 characters")
+	}
+	if !usernamePattern.MatchString(username) {
+		return "", errors.New("Username may only contain letters, digits, '_', '.' and '-'")
+	}
+	return strings.ToLower(username), nil
+}
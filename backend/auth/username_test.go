@@ -0,0 +1,37 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package auth
+
+import "testing"
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"alice", "alice", false},
+		{"Alice", "alice", false},
+		{"ALICE", "alice", false},
+		{"  alice  ", "alice", false},
+		{"\tAlIcE\n", "alice", false},
+		{"john.doe-2_x", "john.doe-2_x", false},
+		{"", "", true},
+		{"   ", "", true},
+		{"a", "", true},
+		{" a ", "", true},
+		{"abcdefghijklmnopqrstuvwxyz0123456", "", true},
+		{"al ice", "", true},
+		{"alice!", "", true},
+		{"älice", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeUsername(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeUsername(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeUsername(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
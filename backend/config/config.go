@@ -0,0 +1,39 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+// Package config names the optional features of a server instance that
+// clients learn about from GET /api/config.
+package config
+
+// Version is the version of the server. Release builds set it with
+// -ldflags "-X github.com/bloodmagesoftware/teamsync/config.Version=1.2.3".
+var Version = "dev"
+
+// DefaultMaxMessageLength is the number of characters a message body may
+// have unless MAX_MESSAGE_LENGTH says otherwise.
+const DefaultMaxMessageLength = 10000
+
+// Feature is the name of a feature flag as it appears in the response of
+// GET /api/config.
+type Feature string
+
+const (
+	FeatureEncryption        Feature = "encryption"
+	FeatureGroupCalls        Feature = "groupCalls"
+	FeatureFileUploads       Feature = "fileUploads"
+	FeatureTURN              Feature = "turnEnabled"
+	FeaturePushNotifications Feature = "pushNotifications"
+	FeatureLDAPAuth          Feature = "ldapAuth"
+	FeatureMarkdown          Feature = "markdownEnabled"
+)
+
+// Features lists every feature flag. GET /api/config reports all of them,
+// so clients can tell a disabled feature from one the server does not know.
+var Features = []Feature{
+	FeatureEncryption,
+	FeatureGroupCalls,
+	FeatureFileUploads,
+	FeatureTURN,
+	FeaturePushNotifications,
+	FeatureLDAPAuth,
+	FeatureMarkdown,
+}
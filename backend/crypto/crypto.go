@@ -5,6 +5,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -38,12 +39,16 @@ func InitializeEncryption() error {
 
 		keyBytes, err := base64.StdEncoding.DecodeString(keyBase64)
 		if err != nil {
-			initErr = fmt.Errorf("failed to decode encryption key: %w", err)
+			if _, urlErr := base64.URLEncoding.DecodeString(keyBase64); urlErr == nil {
+				initErr = fmt.Errorf("failed to decode encryption key: %w (the key uses the URL-safe alphabet, standard base64 is required)", err)
+				return
+			}
+			initErr = fmt.Errorf("failed to decode encryption key of %d characters: %w", len(keyBase64), err)
 			return
 		}
 
 		if len(keyBytes) != 32 {
-			initErr = errors.New("encryption key must be 32 bytes (256 bits)")
+			initErr = fmt.Errorf("encryption key must be 32 bytes (256 bits), got %d bytes from %d base64 characters", len(keyBytes), len(keyBase64))
 			return
 		}
 
@@ -70,6 +75,11 @@ func InitializeEncryption() error {
 			return
 		}
 
+		if err := selfTest(gcm); err != nil {
+			initErr = fmt.Errorf("encryption self-test failed with a %d byte key from %d base64 characters: %w", lockedBuffer.Size(), len(keyBase64), err)
+			return
+		}
+
 		encryptor = &MessageEncryptor{
 			key:    enclave,
 			cipher: gcm,
@@ -79,6 +89,68 @@ func InitializeEncryption() error {
 	return initErr
 }
 
+// selfTestPlaintext is encrypted and decrypted again by selfTest. It is fixed
+// so that failures are reproducible.
+const selfTestPlaintext = "teamsync encryption self-test"
+
+// selfTest checks that gcm decrypts what it encrypted. Any valid key passes,
+// so a key that differs from the one the stored messages were encrypted with
+// is only caught by VerifyKeyCanary.
+func selfTest(gcm cipher.AEAD) error {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	additionalData := []byte("conv:0")
+	sealed := gcm.Seal(nil, nonce, []byte(selfTestPlaintext), additionalData)
+
+	opened, err := gcm.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt test vector: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare(opened, []byte(selfTestPlaintext)) != 1 {
+		return errors.New("decrypted test vector does not match")
+	}
+	return nil
+}
+
+// keyCanaryPlaintext is encrypted by NewKeyCanary. Conversation ids start at
+// 1, so the canary cannot be mistaken for a message.
+const (
+	keyCanaryPlaintext      = "teamsync encryption key canary"
+	keyCanaryConversationID = 0
+)
+
+// ErrWrongKey is returned by VerifyKeyCanary when the canary was encrypted with
+// another key.
+var ErrWrongKey = errors.New("encryption key differs from the key the database was encrypted with")
+
+// NewKeyCanary encrypts a fixed plaintext with the current key. It is stored
+// along with the messages the first time the key is used, so that later
+// starts can check the key with VerifyKeyCanary.
+func NewKeyCanary() (string, error) {
+	return EncryptMessage(keyCanaryPlaintext, keyCanaryConversationID)
+}
+
+// VerifyKeyCanary decrypts a canary created by NewKeyCanary and returns
+// ErrWrongKey unless the current key encrypted it.
+func VerifyKeyCanary(canary string) error {
+	plaintext, err := DecryptMessage(canary, keyCanaryConversationID)
+	if errors.Is(err, ErrNotInitialized) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWrongKey, err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(plaintext), []byte(keyCanaryPlaintext)) != 1 {
+		return ErrWrongKey
+	}
+	return nil
+}
+
 func EncryptMessage(plaintext string, conversationID int64) (string, error) {
 	if encryptor == nil {
 		return "", ErrNotInitialized
@@ -130,6 +202,12 @@ func DecryptMessage(ciphertext string, conversationID int64) (string, error) {
 	return string(plaintext), nil
 }
 
+// Initialized reports whether InitializeEncryption succeeded and Shutdown has
+// not been called since.
+func Initialized() bool {
+	return encryptor != nil
+}
+
 func IsEncrypted(text string) bool {
 	_, err := base64.StdEncoding.DecodeString(text)
 	return err == nil && len(text) > 24
@@ -0,0 +1,139 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// initializeWithKey runs InitializeEncryption with key as if the process had
+// just started.
+func initializeWithKey(t *testing.T, key string) error {
+	t.Helper()
+	t.Setenv("TEAMSYNC_ENCRYPTION_KEY", key)
+	encryptor = nil
+	encryptorOnce = sync.Once{}
+	t.Cleanup(func() {
+		Shutdown()
+		encryptorOnce = sync.Once{}
+	})
+	return InitializeEncryption()
+}
+
+func TestInitializeEncryptionKeyErrors(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = 0xfb
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr string
+	}{
+		{"missing", "", "not set"},
+		{"not base64", "not base64!", "failed to decode encryption key of 11 characters"},
+		{"URL alphabet", base64.URLEncoding.EncodeToString(key), "standard base64 is required"},
+		{"too short", base64.StdEncoding.EncodeToString(key[:16]), "got 16 bytes from 24 base64 characters"},
+		{"too long", base64.StdEncoding.EncodeToString(append(key, 0)), "got 33 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := initializeWithKey(t, tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("InitializeEncryption() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if Initialized() {
+				t.Error("encryption initialized with an invalid key")
+			}
+		})
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	if err := initializeWithKey(t, base64.StdEncoding.EncodeToString(make([]byte, 32))); err != nil {
+		t.Fatalf("InitializeEncryption: %v", err)
+	}
+
+	ciphertext, err := EncryptMessage("hello", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DecryptMessage(ciphertext, 7); err != nil || got != "hello" {
+		t.Errorf("DecryptMessage = %q, %v, want %q", got, err, "hello")
+	}
+	if _, err := DecryptMessage(ciphertext, 8); err == nil {
+		t.Error("ciphertext of another conversation decrypted")
+	}
+}
+
+// corruptingAEAD decrypts successfully but returns the wrong plaintext.
+type corruptingAEAD struct {
+	cipher.AEAD
+}
+
+func (a corruptingAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	plaintext, err := a.AEAD.Open(dst, nonce, ciphertext, additionalData)
+	if err == nil && len(plaintext) > 0 {
+		plaintext[0] ^= 1
+	}
+	return plaintext, err
+}
+
+// failingAEAD rejects everything it is asked to decrypt.
+type failingAEAD struct {
+	cipher.AEAD
+}
+
+func (a failingAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return a.AEAD.Open(dst, nonce, ciphertext, []byte("other"))
+}
+
+func TestSelfTest(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := selfTest(gcm); err != nil {
+		t.Errorf("selfTest of a working cipher: %v", err)
+	}
+	if err := selfTest(corruptingAEAD{gcm}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("selfTest of a corrupting cipher = %v, want a mismatch", err)
+	}
+	if err := selfTest(failingAEAD{gcm}); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("selfTest of a failing cipher = %v, want a decryption error", err)
+	}
+}
+
+func TestKeyCanary(t *testing.T) {
+	key := make([]byte, 32)
+	if err := initializeWithKey(t, base64.StdEncoding.EncodeToString(key)); err != nil {
+		t.Fatalf("InitializeEncryption: %v", err)
+	}
+	canary, err := NewKeyCanary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyKeyCanary(canary); err != nil {
+		t.Errorf("VerifyKeyCanary with the same key: %v", err)
+	}
+
+	// A single flipped bit still makes a valid key, which passes selfTest.
+	key[0] ^= 1
+	if err := initializeWithKey(t, base64.StdEncoding.EncodeToString(key)); err != nil {
+		t.Fatalf("InitializeEncryption with a flipped bit: %v", err)
+	}
+	if err := VerifyKeyCanary(canary); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("VerifyKeyCanary with a flipped bit = %v, want ErrWrongKey", err)
+	}
+}
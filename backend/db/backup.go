@@ -0,0 +1,60 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"modernc.org/sqlite"
+)
+
+// Backup writes a consistent snapshot of the database to dstPath using
+// SQLite's online backup API. The pages are copied in a single step, which
+// holds a connection and a read transaction until the copy is complete; use
+// the querier of InitReader, where in WAL mode this does not block writers.
+// Copying in smaller steps would not help: the backup restarts whenever
+// another connection writes between two steps, so it might never finish on a
+// busy server.
+func (q *Queries) Backup(ctx context.Context, dstPath string) error {
+	db, err := q.sqlDB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		backuper, ok := driverConn.(interface {
+			NewBackup(dstUri string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("driver connection %T does not support backups", driverConn)
+		}
+
+		backup, err := backuper.NewBackup(dstPath)
+		if err != nil {
+			return fmt.Errorf("failed to start backup: %w", err)
+		}
+
+		for {
+			more, err := backup.Step(-1)
+			if err != nil {
+				backup.Finish()
+				return fmt.Errorf("failed to copy pages: %w", err)
+			}
+			if !more {
+				break
+			}
+		}
+
+		if err := backup.Finish(); err != nil {
+			return fmt.Errorf("failed to finish backup: %w", err)
+		}
+		return nil
+	})
+}
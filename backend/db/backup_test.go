@@ -0,0 +1,46 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupFromReadPool(t *testing.T) {
+	queries, path := initTestDB(t)
+	writeDB, err := queries.sqlDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeDB.Exec("INSERT INTO users (username, password_hash, password_salt) VALUES ('alice', '', '')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	reader, err := InitReader(path)
+	if err != nil {
+		t.Fatalf("InitReader: %v", err)
+	}
+	defer reader.Close()
+
+	dst := filepath.Join(t.TempDir(), "backup.db")
+	if err := reader.Backup(context.Background(), dst); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	backup, err := sql.Open("sqlite", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	var username string
+	if err := backup.QueryRow("SELECT username FROM users").Scan(&username); err != nil {
+		t.Fatalf("reading backup: %v", err)
+	}
+	if username != "alice" {
+		t.Errorf("username = %q, want alice", username)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrDatabaseBusy is returned by Checkpoint while a connection is in use.
+var ErrDatabaseBusy = errors.New("database busy")
+
+// CheckpointResult reports how many WAL frames a checkpoint copied into the
+// database file.
+type CheckpointResult struct {
+	// Busy is set when a reader or writer kept the checkpoint from completing.
+	Busy               bool
+	WALFrames          int64
+	CheckpointedFrames int64
+}
+
+// Checkpoint copies the WAL into the database file and truncates it. It only
+// runs while neither q nor any of the reader pools has a connection in use and
+// returns ErrDatabaseBusy otherwise. Readers that start after that check do not
+// make it wait either: the checkpoint gives up right away and reports Busy
+// instead of blocking writers until the busy timeout expires.
+func (q *Queries) Checkpoint(ctx context.Context, readers ...*Queries) (CheckpointResult, error) {
+	db, err := q.sqlDB()
+	if err != nil {
+		return CheckpointResult{}, err
+	}
+
+	pools := []*sql.DB{db}
+	for _, reader := range readers {
+		readerDB, err := reader.sqlDB()
+		if err != nil {
+			return CheckpointResult{}, err
+		}
+		pools = append(pools, readerDB)
+	}
+	for _, pool := range pools {
+		if pool.Stats().InUse > 0 {
+			return CheckpointResult{}, ErrDatabaseBusy
+		}
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return CheckpointResult{}, err
+	}
+	defer conn.Close()
+
+	var busyTimeout int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+		return CheckpointResult{}, fmt.Errorf("failed to read busy timeout: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA busy_timeout = 0"); err != nil {
+		return CheckpointResult{}, fmt.Errorf("failed to disable busy timeout: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), fmt.Sprintf("PRAGMA busy_timeout = %d", busyTimeout))
+
+	var result CheckpointResult
+	if err := conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(
+		&result.Busy, &result.WALFrames, &result.CheckpointedFrames,
+	); err != nil {
+		return CheckpointResult{}, fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	return result, nil
+}
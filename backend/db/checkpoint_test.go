@@ -0,0 +1,84 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckpointSkipsBusyReaders(t *testing.T) {
+	queries, path := initTestDB(t)
+	reader, err := InitReader(path)
+	if err != nil {
+		t.Fatalf("InitReader: %v", err)
+	}
+	defer reader.Close()
+
+	writeDB, err := queries.sqlDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeDB.Exec("INSERT INTO users (username, password_hash, password_salt) VALUES ('alice', '', '')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	readDB, err := reader.sqlDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := readDB.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queries.Checkpoint(context.Background(), reader); !errors.Is(err, ErrDatabaseBusy) {
+		t.Errorf("Checkpoint with an open reader = %v, want ErrDatabaseBusy", err)
+	}
+	rows.Close()
+
+	// A reader outside the pools holds a snapshot, so the WAL cannot be
+	// truncated. The checkpoint has to give up instead of waiting.
+	other, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	tx, err := other.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	result, err := queries.Checkpoint(context.Background(), reader)
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if !result.Busy {
+		t.Error("Busy = false, want true while a reader holds a snapshot")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Checkpoint took %s, want it not to wait for the reader", elapsed)
+	}
+
+	var busyTimeout int
+	if err := writeDB.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if busyTimeout != 5000 {
+		t.Errorf("busy_timeout after Checkpoint = %d, want 5000", busyTimeout)
+	}
+
+	tx.Rollback()
+	result, err = queries.Checkpoint(context.Background(), reader)
+	if err != nil || result.Busy {
+		t.Errorf("Checkpoint when idle = %+v, %v, want not busy", result, err)
+	}
+}
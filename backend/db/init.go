@@ -6,16 +6,87 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+const (
+	defaultMaxOpenConns = 1
+	defaultMaxIdleConns = 1
+	defaultMaxReadConns = 4
+)
+
 func Init(dbPath string) (*Queries, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := runMigrations(db); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return newQueries(db), nil
+}
+
+// InitReader opens a separate pool of read-only connections to a database
+// that Init has migrated. All writes go through the single connection of
+// Init; long-running readers such as exports and backups use this pool
+// instead, so that they do not keep every other request waiting. WAL mode
+// lets them read while the writer commits.
+func InitReader(dbPath string) (*Queries, error) {
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=query_only(1)&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	maxConns := intFromEnv("DB_MAX_READ_CONNS", defaultMaxReadConns)
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+	db.SetConnMaxLifetime(0)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return newQueries(db), nil
+}
+
+// newQueries returns a querier for db that logs slow queries if
+// DB_SLOW_QUERY_MS is set.
+func newQueries(db *sql.DB) *Queries {
+	if os.Getenv("DB_SLOW_QUERY_MS") != "" {
+		if ms := intFromEnv("DB_SLOW_QUERY_MS", 0); ms > 0 {
+			return New(NewQueryTracer(db, time.Duration(ms)*time.Millisecond))
+		}
+	}
+	return New(db)
+}
+
+// Open connects to the database without applying migrations.
+func Open(dbPath string) (*sql.DB, error) {
+	// foreign_keys, busy_timeout and wal_autocheckpoint are per-connection
+	// settings, so they are passed in the DSN to apply to every connection of
+	// the pool instead of a single one. Checkpointing every 500 instead of
+	// 1000 pages keeps the WAL small under heavy writes.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=wal_autocheckpoint(500)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite allows a single writer at a time. Serializing access through one
+	// connection avoids SQLITE_BUSY errors under concurrent load, but a
+	// statement holding the connection delays every other one; long reads
+	// belong on the pool of InitReader.
+	db.SetMaxOpenConns(intFromEnv("DB_MAX_OPEN_CONNS", defaultMaxOpenConns))
+	db.SetMaxIdleConns(intFromEnv("DB_MAX_IDLE_CONNS", defaultMaxIdleConns))
+	db.SetConnMaxLifetime(0)
+
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
@@ -24,11 +95,29 @@ func Init(dbPath string) (*Queries, error) {
 		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
 	}
 
-	if err := runMigrations(db); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	var foreignKeys bool
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return nil, fmt.Errorf("failed to query foreign key enforcement: %w", err)
+	}
+	if !foreignKeys {
+		return nil, errors.New("failed to enable foreign key enforcement")
 	}
 
-	return New(db), nil
+	return db, nil
+}
+
+func intFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		log.Printf("invalid %s: %q", name, value)
+		return fallback
+	}
+	return parsed
 }
 
 // Close closes the querier.
@@ -43,6 +132,9 @@ func (q *Queries) Close() error {
 	case *sql.Tx:
 		q.db = nil
 		return db.Rollback()
+	case *QueryTracer:
+		q.db = db.db
+		return q.Close()
 	default:
 		return fmt.Errorf("unexpected type %T for querier db", q.db)
 	}
@@ -53,13 +145,34 @@ func (q *Queries) Begin() (*QuerierTx, error) {
 	if q.db == nil {
 		return nil, errors.New("db is nil")
 	}
-	if db, ok := q.db.(*sql.DB); ok {
-		sqlTx, err := db.Begin()
-		if err != nil {
-			return nil, fmt.Errorf("failed to begin transaction: %w", err)
-		}
-		return NewTx(sqlTx), nil
-	} else {
+	db, err := q.sqlDB()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlTx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Statements of the transaction are traced like those outside of it.
+	if tracer, ok := q.db.(*QueryTracer); ok {
+		return &QuerierTx{Queries: New(NewQueryTracer(sqlTx, tracer.threshold)), tx: sqlTx}, nil
+	}
+	return NewTx(sqlTx), nil
+}
+
+// sqlDB returns the connection pool behind the querier, unwrapping a
+// QueryTracer.
+func (q *Queries) sqlDB() (*sql.DB, error) {
+	dbtx := q.db
+	if tracer, ok := dbtx.(*QueryTracer); ok {
+		dbtx = tracer.db
+	}
+
+	db, ok := dbtx.(*sql.DB)
+	if !ok {
 		return nil, fmt.Errorf("unexpected type %T for querier db", q.db)
 	}
+	return db, nil
 }
@@ -0,0 +1,92 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func initTestDB(t *testing.T) (*Queries, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "teamsync.db")
+	queries, err := Init(path)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { queries.Close() })
+	return queries, path
+}
+
+func TestInitReaderReadsWhileWriterIsBusy(t *testing.T) {
+	queries, path := initTestDB(t)
+	reader, err := InitReader(path)
+	if err != nil {
+		t.Fatalf("InitReader: %v", err)
+	}
+	defer reader.Close()
+
+	readDB, err := reader.sqlDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDB.Exec("INSERT INTO users (username, password_hash, password_salt) VALUES ('x', '', '')"); err == nil {
+		t.Error("read-only connection accepted a write")
+	}
+
+	// An open write transaction holds the only writer connection.
+	tx, err := queries.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.tx.Exec("INSERT INTO users (username, password_hash, password_salt) VALUES ('writer', '', '')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var count int
+	if err := readDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("read while a write transaction is open: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("reader sees %d users, want the uncommitted insert to be invisible", count)
+	}
+}
+
+func TestInitConnectionPragmas(t *testing.T) {
+	queries, path := initTestDB(t)
+	reader, err := InitReader(path)
+	if err != nil {
+		t.Fatalf("InitReader: %v", err)
+	}
+	defer reader.Close()
+
+	tests := []struct {
+		name    string
+		queries *Queries
+		pragma  string
+		want    int
+	}{
+		{"writer", queries, "busy_timeout", 5000},
+		{"writer", queries, "foreign_keys", 1},
+		{"reader", reader, "busy_timeout", 5000},
+		{"reader", reader, "query_only", 1},
+	}
+	for _, tt := range tests {
+		db, err := tt.queries.sqlDB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got int
+		if err := db.QueryRow("PRAGMA " + tt.pragma).Scan(&got); err != nil {
+			t.Fatalf("%s: PRAGMA %s: %v", tt.name, tt.pragma, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: PRAGMA %s = %d, want %d", tt.name, tt.pragma, got, tt.want)
+		}
+	}
+}
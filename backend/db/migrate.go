@@ -3,65 +3,263 @@
 package db
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
+	"log/slog"
+	"slices"
 	"sort"
+	"strings"
+	"time"
 )
 
 //go:embed migrations/*.sql
 var migrationFiles embed.FS
 
-func runMigrations(db *sql.DB) error {
-	if err := createMigrationsTable(db); err != nil {
-		return fmt.Errorf("failed to create migrations table: %w", err)
+// downSeparator starts the rollback section of a migration file. It uses the
+// sql-migrate notation because sqlc strips everything below it when reading
+// the migrations as schema.
+const downSeparator = "-- +migrate Down"
+
+type migration struct {
+	up   string
+	down string
+}
+
+func parseMigration(content string) migration {
+	var up, down strings.Builder
+	target := &up
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if target == &up && strings.EqualFold(strings.TrimSpace(line), downSeparator) {
+			target = &down
+			continue
+		}
+		target.WriteString(line)
+		target.WriteString("\n")
+	}
+
+	return migration{up: up.String(), down: down.String()}
+}
+
+func readMigration(name string) (migration, error) {
+	content, err := migrationFiles.ReadFile("migrations/" + name)
+	if err != nil {
+		return migration{}, err
 	}
+	return parseMigration(string(content)), nil
+}
 
+func migrationNames() ([]string, error) {
 	entries, err := migrationFiles.ReadDir("migrations")
 	if err != nil {
-		return fmt.Errorf("failed to read migrations directory: %w", err)
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
 	}
 
-	var migrationNames []string
+	var names []string
 	for _, entry := range entries {
 		if !entry.IsDir() {
-			migrationNames = append(migrationNames, entry.Name())
+			names = append(names, entry.Name())
 		}
 	}
-	sort.Strings(migrationNames)
+	sort.Strings(names)
+	return names, nil
+}
 
-	for _, name := range migrationNames {
+func runMigrations(db *sql.DB) error {
+	if err := createMigrationsTable(db); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	names, err := migrationNames()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, name := range names {
 		applied, err := isMigrationApplied(db, name)
 		if err != nil {
 			return fmt.Errorf("failed to check migration %s: %w", name, err)
 		}
 
-		if applied {
-			continue
+		if !applied {
+			pending = append(pending, name)
 		}
+	}
 
-		content, err := migrationFiles.ReadFile("migrations/" + name)
-		if err != nil {
-			return fmt.Errorf("failed to read migration %s: %w", name, err)
+	if len(pending) > 0 {
+		slog.Warn("database has pending migrations", "count", len(pending), "migrations", pending)
+	}
+
+	for _, name := range pending {
+		if err := applyMigration(db, name); err != nil {
+			return err
 		}
+	}
 
-		tx, err := db.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction for migration %s: %w", name, err)
+	return nil
+}
+
+// applyMigration executes the up section of a migration and records it in the
+// migrations table.
+func applyMigration(db *sql.DB, name string) error {
+	m, err := readMigration(name)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %w", name, err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for migration %s: %w", name, err)
+	}
+
+	if _, err := tx.Exec(m.up); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to execute migration %s: %w", name, err)
+	}
+
+	if _, err := tx.Exec("INSERT INTO migrations (name) VALUES (?)", name); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration %s: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// MigrationStatus describes an embedded or recorded migration. AppliedAt is nil
+// for migrations that have not been applied yet.
+type MigrationStatus struct {
+	Name      string
+	AppliedAt *time.Time
+}
+
+// MigrationStatuses lists applied migrations in the order they were applied,
+// followed by pending migrations in the order they will be applied.
+func (q *Queries) MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	rows, err := q.db.QueryContext(ctx, "SELECT name, applied_at FROM migrations ORDER BY applied_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var statuses []MigrationStatus
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var status MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&status.Name, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
 		}
+		status.AppliedAt = &appliedAt
+		statuses = append(statuses, status)
+		applied[status.Name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query migrations: %w", err)
+	}
 
-		if _, err := tx.Exec(string(content)); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to execute migration %s: %w", name, err)
+	names, err := migrationNames()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if !applied[name] {
+			statuses = append(statuses, MigrationStatus{Name: name})
 		}
+	}
+
+	return statuses, nil
+}
+
+// RollbackMigration executes the down section of an applied migration and
+// removes it from the migrations table.
+func RollbackMigration(db *sql.DB, name string) error {
+	applied, err := isMigrationApplied(db, name)
+	if err != nil {
+		return fmt.Errorf("failed to check migration %s: %w", name, err)
+	}
+	if !applied {
+		return fmt.Errorf("migration %s is not applied", name)
+	}
+
+	m, err := readMigration(name)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %w", name, err)
+	}
+
+	if strings.TrimSpace(m.down) == "" {
+		return fmt.Errorf("migration %s has no down section", name)
+	}
 
-		if _, err := tx.Exec("INSERT INTO migrations (name) VALUES (?)", name); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %s: %w", name, err)
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for rollback of %s: %w", name, err)
+	}
+
+	if _, err := tx.Exec(m.down); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to roll back migration %s: %w", name, err)
+	}
+
+	result, err := tx.Exec("DELETE FROM migrations WHERE name = ?", name)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to unrecord migration %s: %w", name, err)
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected != 1 {
+		tx.Rollback()
+		return fmt.Errorf("failed to unrecord migration %s", name)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollback of %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// RollbackTo rolls back every applied migration newer than target, newest
+// first. The target migration itself stays applied.
+func RollbackTo(db *sql.DB, target string) error {
+	names, err := migrationNames()
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(names, target) {
+		return fmt.Errorf("unknown migration %s", target)
+	}
+
+	if err := createMigrationsTable(db); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	for _, name := range slices.Backward(names) {
+		if name <= target {
+			break
+		}
+
+		applied, err := isMigrationApplied(db, name)
+		if err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", name, err)
+		}
+		if !applied {
+			continue
 		}
 
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %s: %w", name, err)
+		if err := RollbackMigration(db, name); err != nil {
+			return err
 		}
 	}
 
@@ -0,0 +1,308 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func openMigratedTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	_, path := initTestDB(t)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func columnNames(t *testing.T, db *sql.DB, table string) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// schemaSnapshot describes every table, view, index and trigger except the
+// migrations table. Tables and views are described by their PRAGMA table_info
+// and foreign_key_list rows, so that rebuilt tables compare equal to the
+// original; indexes and triggers by their SQL.
+func schemaSnapshot(t *testing.T, db *sql.DB) map[string]string {
+	t.Helper()
+	rows, err := db.Query("SELECT type, name, COALESCE(sql, '') FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' AND name != 'migrations'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := make(map[string]string)
+	for rows.Next() {
+		var typ, name, sql string
+		if err := rows.Scan(&typ, &name, &sql); err != nil {
+			t.Fatal(err)
+		}
+		objects[typ+" "+name] = strings.Join(strings.Fields(sql), " ")
+	}
+	rows.Close()
+
+	for key := range objects {
+		typ, name, _ := strings.Cut(key, " ")
+		if typ != "table" && typ != "view" {
+			continue
+		}
+		rows, err := db.Query("SELECT name, type, \"notnull\", COALESCE(dflt_value, ''), pk FROM pragma_table_info(?) ORDER BY cid", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var columns []string
+		for rows.Next() {
+			var column, columnType, dflt string
+			var notNull, pk int
+			if err := rows.Scan(&column, &columnType, &notNull, &dflt, &pk); err != nil {
+				t.Fatal(err)
+			}
+			columns = append(columns, fmt.Sprintf("%s %s notnull=%d default=%s pk=%d", column, columnType, notNull, dflt, pk))
+		}
+		rows.Close()
+
+		rows, err = db.Query("SELECT \"table\", \"from\", COALESCE(\"to\", ''), on_update, on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var table, from, to, onUpdate, onDelete string
+			if err := rows.Scan(&table, &from, &to, &onUpdate, &onDelete); err != nil {
+				t.Fatal(err)
+			}
+			columns = append(columns, fmt.Sprintf("%s references %s(%s) on update %s on delete %s", from, table, to, onUpdate, onDelete))
+		}
+		rows.Close()
+
+		objects[key] = strings.Join(columns, ", ")
+	}
+	return objects
+}
+
+// TestMigrationsRollBackToThePriorSchema applies every migration on its own,
+// rolls it back and checks that the schema is exactly the one before.
+func TestMigrationsRollBackToThePriorSchema(t *testing.T) {
+	db := openMigratedTestDB(t)
+
+	names, err := migrationNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RollbackTo(db, names[0]); err != nil {
+		t.Fatalf("RollbackTo: %v", err)
+	}
+	if err := RollbackMigration(db, names[0]); err != nil {
+		t.Fatalf("RollbackMigration: %v", err)
+	}
+
+	for _, name := range names {
+		before := schemaSnapshot(t, db)
+
+		if err := applyMigration(db, name); err != nil {
+			t.Fatalf("applying %s: %v", name, err)
+		}
+		applied := schemaSnapshot(t, db)
+		if maps.Equal(before, applied) {
+			t.Errorf("%s did not change the schema", name)
+		}
+
+		if err := RollbackMigration(db, name); err != nil {
+			t.Fatalf("rolling back %s: %v", name, err)
+		}
+		after := schemaSnapshot(t, db)
+		for _, key := range slices.Sorted(maps.Keys(before)) {
+			if after[key] != before[key] {
+				t.Errorf("%s: %s after rollback = %q, want %q", name, key, after[key], before[key])
+			}
+		}
+		for key := range after {
+			if _, ok := before[key]; !ok {
+				t.Errorf("%s: %s left after rollback", name, key)
+			}
+		}
+
+		if err := applyMigration(db, name); err != nil {
+			t.Fatalf("reapplying %s: %v", name, err)
+		}
+		if reapplied := schemaSnapshot(t, db); !maps.Equal(reapplied, applied) {
+			t.Errorf("%s: schema after reapplying differs from the first application", name)
+		}
+	}
+}
+
+func TestRollbackToAndReapply(t *testing.T) {
+	db := openMigratedTestDB(t)
+
+	if err := RollbackTo(db, "000008_webhooks.sql"); err != nil {
+		t.Fatalf("RollbackTo: %v", err)
+	}
+	if !tableExists(t, db, "webhooks") {
+		t.Error("target migration was rolled back")
+	}
+	if tableExists(t, db, "user_blocks") {
+		t.Error("user_blocks survived the rollback")
+	}
+	if cols := columnNames(t, db, "users"); slices.Contains(cols, "is_bot") || slices.Contains(cols, "api_key") {
+		t.Errorf("users columns after rollback = %v", cols)
+	}
+	if applied, err := isMigrationApplied(db, "000009_bot_users.sql"); err != nil || applied {
+		t.Errorf("000009_bot_users.sql still recorded: %v, %v", applied, err)
+	}
+
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("reapplying migrations: %v", err)
+	}
+	if !tableExists(t, db, "user_blocks") {
+		t.Error("user_blocks missing after reapplying")
+	}
+	if cols := columnNames(t, db, "users"); !slices.Contains(cols, "api_key") {
+		t.Errorf("users columns after reapplying = %v", cols)
+	}
+}
+
+func TestRollbackEverything(t *testing.T) {
+	db := openMigratedTestDB(t)
+
+	names, err := migrationNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RollbackTo(db, names[0]); err != nil {
+		t.Fatalf("RollbackTo: %v", err)
+	}
+	if err := RollbackMigration(db, names[0]); err != nil {
+		t.Fatalf("RollbackMigration: %v", err)
+	}
+
+	var tables []string
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != 'migrations'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	if len(tables) != 0 {
+		t.Errorf("tables left after rolling back every migration: %v", tables)
+	}
+}
+
+func TestRollbackErrors(t *testing.T) {
+	db := openMigratedTestDB(t)
+
+	if err := RollbackTo(db, "999999_unknown.sql"); err == nil {
+		t.Error("RollbackTo accepted an unknown migration")
+	}
+	if err := RollbackTo(db, "000008_webhooks.sql"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RollbackMigration(db, "000010_user_blocks.sql"); err == nil {
+		t.Error("RollbackMigration accepted a migration that is not applied")
+	}
+}
+
+func TestUniqueUsernameResolvesCaseDuplicates(t *testing.T) {
+	db := openMigratedTestDB(t)
+
+	if err := RollbackTo(db, "000038_admin_users.sql"); err != nil {
+		t.Fatalf("RollbackTo: %v", err)
+	}
+	// Usernames were not normalized before, so they could differ in case only.
+	legacy := []string{"alice", "Alice", "ALICE", "bob", "a_very_long_legacy_username_xyzw", "A_VERY_LONG_LEGACY_USERNAME_XYZW"}
+	for _, username := range legacy {
+		if _, err := db.Exec("INSERT INTO users (username, password_hash, password_salt) VALUES (?, '', '')", username); err != nil {
+			t.Fatalf("inserting %q: %v", username, err)
+		}
+	}
+
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("reapplying migrations: %v", err)
+	}
+
+	rows, err := db.Query("SELECT username FROM users ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			t.Fatal(err)
+		}
+		usernames = append(usernames, username)
+	}
+	want := []string{"alice", "Alice_2", "ALICE_3", "bob", "a_very_long_legacy_username_xyzw", "A_VERY_LONG_LEGACY_USERNAME_XY_6"}
+	if !slices.Equal(usernames, want) {
+		t.Errorf("usernames = %v, want %v", usernames, want)
+	}
+
+	if _, err := db.Exec("INSERT INTO users (username, password_hash, password_salt) VALUES ('Bob', '', '')"); err == nil {
+		t.Error("inserted a username that differs from an existing one in case only")
+	}
+}
+
+func TestPendingMigrationsWarning(t *testing.T) {
+	db := openMigratedTestDB(t)
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("boot without pending migrations logged %q", logs.String())
+	}
+
+	names, err := migrationNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RollbackTo(db, names[len(names)-3]); err != nil {
+		t.Fatalf("RollbackTo: %v", err)
+	}
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+	if got := logs.String(); strings.Count(got, "pending migrations") != 1 || !strings.Contains(got, "count=2") {
+		t.Errorf("boot with pending migrations logged %q", got)
+	}
+}
@@ -124,3 +124,16 @@ CREATE TABLE calls (
 
 CREATE INDEX idx_calls_conversation ON calls(conversation_id);
 CREATE INDEX idx_calls_message ON calls(message_id);
+
+-- +migrate Down
+
+DROP TABLE calls;
+DROP TABLE conversation_read_state;
+DROP TABLE message_attachments;
+DROP TABLE messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
+DROP TABLE user_settings;
+DROP TABLE oauth_tokens;
+DROP TABLE invitation_codes;
+DROP TABLE users;
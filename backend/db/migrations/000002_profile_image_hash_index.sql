@@ -0,0 +1,7 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+CREATE INDEX idx_users_profile_image_hash ON users(profile_image_hash);
+
+-- +migrate Down
+
+DROP INDEX idx_users_profile_image_hash;
@@ -0,0 +1,10 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- profile_image_hash refers to the full-size (512px) variant
+ALTER TABLE users ADD COLUMN profile_image_hash_32 TEXT;
+ALTER TABLE users ADD COLUMN profile_image_hash_128 TEXT;
+
+-- +migrate Down
+
+ALTER TABLE users DROP COLUMN profile_image_hash_128;
+ALTER TABLE users DROP COLUMN profile_image_hash_32;
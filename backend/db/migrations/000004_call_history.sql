@@ -0,0 +1,13 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+ALTER TABLE calls ADD COLUMN initiator_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE calls ADD COLUMN participant_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_calls_conversation_history ON calls(conversation_id, id DESC) WHERE ended_at IS NOT NULL;
+
+-- +migrate Down
+
+DROP INDEX idx_calls_conversation_history;
+
+ALTER TABLE calls DROP COLUMN participant_count;
+ALTER TABLE calls DROP COLUMN initiator_id;
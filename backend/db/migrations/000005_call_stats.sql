@@ -0,0 +1,18 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Client-reported WebRTC statistics, stored as raw JSON
+CREATE TABLE call_stats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    call_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    collected_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    payload TEXT NOT NULL CHECK(json_valid(payload)),
+    FOREIGN KEY (call_id) REFERENCES calls(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_call_stats_call ON call_stats(call_id);
+
+-- +migrate Down
+
+DROP TABLE call_stats;
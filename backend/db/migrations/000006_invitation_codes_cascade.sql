@@ -0,0 +1,33 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- SQLite cannot alter foreign keys, so the table is rebuilt to add the
+-- missing ON DELETE action now that foreign keys are enforced.
+CREATE TABLE invitation_codes_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    created_by INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO invitation_codes_new (id, code, created_by, created_at)
+SELECT id, code, created_by, created_at FROM invitation_codes;
+
+DROP TABLE invitation_codes;
+
+ALTER TABLE invitation_codes_new RENAME TO invitation_codes;
+
+-- +migrate Down
+
+CREATE TABLE invitation_codes_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    created_by INTEGER REFERENCES users(id),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO invitation_codes_old (id, code, created_by, created_at)
+SELECT id, code, created_by, created_at FROM invitation_codes;
+
+DROP TABLE invitation_codes;
+
+ALTER TABLE invitation_codes_old RENAME TO invitation_codes;
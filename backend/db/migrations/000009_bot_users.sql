@@ -0,0 +1,15 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Bot users authenticate with a permanent API key instead of a password.
+-- SQLite cannot add UNIQUE columns, so uniqueness comes from the index.
+ALTER TABLE users ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN api_key VARCHAR(128);
+
+CREATE UNIQUE INDEX idx_users_api_key ON users(api_key);
+
+-- +migrate Down
+
+DROP INDEX idx_users_api_key;
+
+ALTER TABLE users DROP COLUMN api_key;
+ALTER TABLE users DROP COLUMN is_bot;
@@ -0,0 +1,10 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Suspended users are rejected by the API until suspended_until has passed
+ALTER TABLE users ADD COLUMN suspended_until DATETIME;
+ALTER TABLE users ADD COLUMN suspension_reason TEXT;
+
+-- +migrate Down
+
+ALTER TABLE users DROP COLUMN suspension_reason;
+ALTER TABLE users DROP COLUMN suspended_until;
@@ -0,0 +1,9 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Deleted users keep their row so that the messages they sent can still be
+-- attributed to them
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+
+-- +migrate Down
+
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,16 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Open Graph metadata of linked pages, keyed by the SHA-256 of the URL
+CREATE TABLE IF NOT EXISTS link_previews (
+    url_hash TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    title TEXT,
+    description TEXT,
+    image_url TEXT,
+    site_name TEXT,
+    fetched_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS link_previews;
@@ -0,0 +1,8 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Messages older than retention_days are wiped. NULL keeps them forever.
+ALTER TABLE conversations ADD COLUMN retention_days INTEGER;
+
+-- +migrate Down
+
+ALTER TABLE conversations DROP COLUMN retention_days;
@@ -0,0 +1,8 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Only administrators may post to read-only conversations
+ALTER TABLE conversations ADD COLUMN readonly_for_members BOOLEAN NOT NULL DEFAULT 0;
+
+-- +migrate Down
+
+ALTER TABLE conversations DROP COLUMN readonly_for_members;
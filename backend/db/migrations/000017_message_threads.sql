@@ -0,0 +1,23 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- thread_root_id points replies at the top-level message of their thread so
+-- that a thread can be loaded without walking reply_to_id recursively
+ALTER TABLE messages ADD COLUMN thread_root_id INTEGER;
+
+WITH RECURSIVE ancestry(id, root_id) AS (
+    SELECT id, id FROM messages WHERE reply_to_id IS NULL
+    UNION ALL
+    SELECT m.id, a.root_id FROM messages m INNER JOIN ancestry a ON m.reply_to_id = a.id
+)
+UPDATE messages
+SET thread_root_id = (SELECT root_id FROM ancestry WHERE ancestry.id = messages.id)
+WHERE reply_to_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_thread_root ON messages(thread_root_id);
+CREATE INDEX IF NOT EXISTS idx_messages_reply_to ON messages(reply_to_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_messages_reply_to;
+DROP INDEX IF EXISTS idx_messages_thread_root;
+ALTER TABLE messages DROP COLUMN thread_root_id;
@@ -0,0 +1,16 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Votes on application/poll messages; a user holds at most one row per option
+CREATE TABLE poll_votes (
+    poll_message_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    option_index INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (poll_message_id, user_id, option_index),
+    FOREIGN KEY (poll_message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +migrate Down
+
+DROP TABLE poll_votes;
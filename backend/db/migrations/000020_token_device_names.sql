@@ -0,0 +1,12 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Optional label of the device a token was issued to
+ALTER TABLE oauth_tokens ADD COLUMN device_name VARCHAR(64);
+
+CREATE INDEX idx_oauth_tokens_user_device ON oauth_tokens(user_id, device_name);
+
+-- +migrate Down
+
+DROP INDEX idx_oauth_tokens_user_device;
+
+ALTER TABLE oauth_tokens DROP COLUMN device_name;
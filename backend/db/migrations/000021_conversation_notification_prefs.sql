@@ -0,0 +1,17 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Which messages of a conversation a participant is notified about; a
+-- missing row means all
+CREATE TABLE conversation_notification_prefs (
+    conversation_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    level TEXT NOT NULL DEFAULT 'all' CHECK (level IN ('all', 'mentions', 'none')),
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +migrate Down
+
+DROP TABLE conversation_notification_prefs;
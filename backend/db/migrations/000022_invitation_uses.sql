@@ -0,0 +1,20 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Redeemed invitation codes; the code itself is deleted on registration, so
+-- its ID, creator and creation time are copied here
+CREATE TABLE invitation_uses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    invitation_id INTEGER NOT NULL,
+    code TEXT NOT NULL,
+    created_by INTEGER REFERENCES users(id),
+    invitation_created_at DATETIME NOT NULL,
+    used_by_user_id INTEGER NOT NULL,
+    used_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (used_by_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_invitation_uses_created_by ON invitation_uses(created_by);
+
+-- +migrate Down
+
+DROP TABLE invitation_uses;
@@ -0,0 +1,8 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Optional time after which an invitation code can no longer be redeemed
+ALTER TABLE invitation_codes ADD COLUMN expires_at DATETIME;
+
+-- +migrate Down
+
+ALTER TABLE invitation_codes DROP COLUMN expires_at;
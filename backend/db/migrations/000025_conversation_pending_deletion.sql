@@ -0,0 +1,11 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- A requested deletion of a conversation that still has to be confirmed with
+-- archive_token
+ALTER TABLE conversations ADD COLUMN pending_deletion_at DATETIME;
+ALTER TABLE conversations ADD COLUMN archive_token TEXT;
+
+-- +migrate Down
+
+ALTER TABLE conversations DROP COLUMN archive_token;
+ALTER TABLE conversations DROP COLUMN pending_deletion_at;
@@ -0,0 +1,10 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Order of the conversation list when the client does not ask for one:
+-- last_activity, unread_count or name
+ALTER TABLE user_settings ADD COLUMN conversation_sort TEXT NOT NULL DEFAULT 'last_activity'
+    CHECK(conversation_sort IN ('last_activity', 'unread_count', 'name'));
+
+-- +migrate Down
+
+ALTER TABLE user_settings DROP COLUMN conversation_sort;
@@ -0,0 +1,22 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Role of a participant in a group conversation. The earliest participant of
+-- every existing group becomes its admin.
+ALTER TABLE conversation_participants ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+    CHECK(role IN ('admin', 'member'));
+
+UPDATE conversation_participants SET role = 'admin'
+WHERE rowid IN (
+    SELECT (
+        SELECT earliest.rowid FROM conversation_participants earliest
+        WHERE earliest.conversation_id = c.id
+        ORDER BY earliest.joined_at, earliest.user_id
+        LIMIT 1
+    )
+    FROM conversations c
+    WHERE c.type = 'group'
+);
+
+-- +migrate Down
+
+ALTER TABLE conversation_participants DROP COLUMN role;
@@ -0,0 +1,12 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Identifier chosen by the client when sending a message, so that a retried
+-- send does not create the message twice. It is cleared after a day.
+ALTER TABLE messages ADD COLUMN client_message_id VARCHAR(64);
+
+CREATE UNIQUE INDEX idx_messages_client_message_id ON messages(client_message_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_messages_client_message_id;
+ALTER TABLE messages DROP COLUMN client_message_id;
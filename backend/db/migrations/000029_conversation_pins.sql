@@ -0,0 +1,15 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Conversations a user pinned to the top of their own conversation list
+CREATE TABLE conversation_pins (
+    user_id INTEGER NOT NULL,
+    conversation_id INTEGER NOT NULL,
+    pinned_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, conversation_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+);
+
+-- +migrate Down
+
+DROP TABLE conversation_pins;
@@ -0,0 +1,25 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Messages that are sent to their conversation at send_at
+CREATE TABLE scheduled_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id INTEGER NOT NULL,
+    sender_id INTEGER NOT NULL,
+    content_type TEXT NOT NULL,
+    body TEXT NOT NULL,
+    reply_to_id INTEGER,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    send_at DATETIME NOT NULL,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (reply_to_id) REFERENCES messages(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_scheduled_messages_send_at ON scheduled_messages(send_at);
+CREATE INDEX idx_scheduled_messages_conversation ON scheduled_messages(conversation_id, sender_id);
+
+-- +migrate Down
+
+DROP INDEX idx_scheduled_messages_conversation;
+DROP INDEX idx_scheduled_messages_send_at;
+DROP TABLE scheduled_messages;
@@ -0,0 +1,8 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- The message a forwarded message was copied from
+ALTER TABLE messages ADD COLUMN forwarded_from_message_id INTEGER REFERENCES messages(id) ON DELETE SET NULL;
+
+-- +migrate Down
+
+ALTER TABLE messages DROP COLUMN forwarded_from_message_id;
@@ -0,0 +1,15 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Users a user marked as contacts for quick access to direct messages
+CREATE TABLE user_contacts (
+    user_id INTEGER NOT NULL,
+    contact_user_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, contact_user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (contact_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +migrate Down
+
+DROP TABLE user_contacts;
@@ -0,0 +1,8 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Usernames are looked up regardless of case
+CREATE INDEX idx_users_username_lower ON users(LOWER(username));
+
+-- +migrate Down
+
+DROP INDEX idx_users_username_lower;
@@ -0,0 +1,11 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Scheduled messages that cannot be sent are kept with the reason instead of
+-- being retried on every tick
+ALTER TABLE scheduled_messages ADD COLUMN failed_at DATETIME;
+ALTER TABLE scheduled_messages ADD COLUMN failure_reason TEXT;
+
+-- +migrate Down
+
+ALTER TABLE scheduled_messages DROP COLUMN failure_reason;
+ALTER TABLE scheduled_messages DROP COLUMN failed_at;
@@ -0,0 +1,22 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Messages each participant has not read yet. Deleted messages, system
+-- messages and messages between users who blocked each other are never
+-- unread. All unread counts are taken from this view.
+CREATE VIEW unread_messages AS
+SELECT cp.user_id, m.conversation_id, m.id AS message_id
+FROM messages m
+INNER JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id
+LEFT JOIN conversation_read_state crs ON crs.conversation_id = m.conversation_id AND crs.user_id = cp.user_id
+WHERE m.seq > COALESCE(crs.last_read_seq, 0)
+    AND m.deleted_at IS NULL
+    AND m.content_type != 'application/system'
+    AND NOT EXISTS (
+        SELECT 1 FROM user_blocks b
+        WHERE (b.blocker_id = cp.user_id AND b.blocked_id = m.sender_id)
+            OR (b.blocker_id = m.sender_id AND b.blocked_id = cp.user_id)
+    );
+
+-- +migrate Down
+
+DROP VIEW unread_messages;
@@ -0,0 +1,54 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- The conversation list of each participant (viewer_id) with the unread
+-- count, the last message the viewer may see and the keys the list is sorted
+-- by. It is shared by the queries that list, search and load conversations.
+CREATE VIEW user_conversations AS
+SELECT
+    cp.user_id AS viewer_id,
+    c.*,
+    crs.last_read_seq,
+    (SELECT COUNT(*) FROM unread_messages um WHERE um.conversation_id = c.id AND um.user_id = cp.user_id) AS unread_count,
+    (SELECT COUNT(*) FROM conversation_participants mc WHERE mc.conversation_id = c.id) AS member_count,
+    CAST(COALESCE(np.level, 'all') AS TEXT) AS notification_level,
+    lm.id AS last_message_id,
+    lu.username AS last_message_sender_username,
+    lm.content_type AS last_message_content_type,
+    lm.body AS last_message_body,
+    lm.created_at AS last_message_created_at,
+    CAST(COALESCE(
+        (SELECT MAX(am.created_at) FROM messages am WHERE am.conversation_id = c.id),
+        c.created_at
+    ) AS TEXT) AS last_activity_at,
+    CAST(LOWER(COALESCE(
+        c.name,
+        (SELECT su.username
+         FROM conversation_participants sp
+         INNER JOIN users su ON sp.user_id = su.id
+         WHERE sp.conversation_id = c.id AND sp.user_id != cp.user_id
+         LIMIT 1),
+        ''
+    )) AS TEXT) AS sort_name,
+    pin.pinned_at,
+    CAST(pin.pinned_at IS NOT NULL AS BOOLEAN) AS pinned
+FROM conversations c
+INNER JOIN conversation_participants cp ON c.id = cp.conversation_id
+LEFT JOIN conversation_read_state crs ON c.id = crs.conversation_id AND crs.user_id = cp.user_id
+LEFT JOIN conversation_notification_prefs np ON c.id = np.conversation_id AND np.user_id = cp.user_id
+LEFT JOIN messages lm ON lm.id = (
+    SELECT vm.id FROM messages vm
+    WHERE vm.conversation_id = c.id AND vm.deleted_at IS NULL
+        AND NOT EXISTS (
+            SELECT 1 FROM user_blocks b
+            WHERE (b.blocker_id = cp.user_id AND b.blocked_id = vm.sender_id)
+                OR (b.blocker_id = vm.sender_id AND b.blocked_id = cp.user_id)
+        )
+    ORDER BY vm.seq DESC
+    LIMIT 1
+)
+LEFT JOIN users lu ON lm.sender_id = lu.id
+LEFT JOIN conversation_pins pin ON c.id = pin.conversation_id AND pin.user_id = cp.user_id;
+
+-- +migrate Down
+
+DROP VIEW user_conversations;
@@ -0,0 +1,13 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Profile fields shown to other users. Unset fields are empty strings so that
+-- clients do not have to handle null
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_emoji TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+
+ALTER TABLE users DROP COLUMN status_emoji;
+ALTER TABLE users DROP COLUMN status_message;
+ALTER TABLE users DROP COLUMN bio;
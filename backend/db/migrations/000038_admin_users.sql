@@ -0,0 +1,10 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Administrators can access diagnostic and management endpoints.
+-- The very first account of an instance is its administrator.
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT 0;
+UPDATE users SET is_admin = 1 WHERE id = (SELECT MIN(id) FROM users);
+
+-- +migrate Down
+
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,17 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- Usernames are unique regardless of case, so a login matches exactly one
+-- account. Accounts created before usernames were normalized may differ in
+-- case only: the oldest keeps its name, the others get their id appended.
+-- The renames are not undone when rolling back.
+UPDATE users
+SET username = substr(username, 1, 32 - length('_' || id)) || '_' || id
+WHERE id NOT IN (SELECT MIN(id) FROM users GROUP BY LOWER(username));
+
+DROP INDEX idx_users_username_lower;
+CREATE UNIQUE INDEX idx_users_username_lower ON users(LOWER(username));
+
+-- +migrate Down
+
+DROP INDEX idx_users_username_lower;
+CREATE INDEX idx_users_username_lower ON users(LOWER(username));
@@ -0,0 +1,14 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- A fixed plaintext encrypted with the key of the instance. It is decrypted
+-- at startup, so that a wrong TEAMSYNC_ENCRYPTION_KEY is detected before
+-- any message is read or written.
+CREATE TABLE encryption_key_canary (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    ciphertext TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +migrate Down
+
+DROP TABLE encryption_key_canary;
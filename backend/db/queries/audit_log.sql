@@ -0,0 +1,24 @@
+-- name: CreateAuditLogEntry :exec
+INSERT INTO audit_log (user_id, action, target_type, target_id, ip_address, metadata)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: ListAuditLogEntries :many
+SELECT a.*, u.username
+FROM audit_log a
+LEFT JOIN users u ON a.user_id = u.id
+WHERE (a.user_id = sqlc.narg(user_id) OR sqlc.narg(user_id) IS NULL)
+    AND (a.action = sqlc.narg(action) OR sqlc.narg(action) IS NULL)
+    AND (a.created_at >= sqlc.narg(from_time) OR sqlc.narg(from_time) IS NULL)
+    AND (a.created_at <= sqlc.narg(to_time) OR sqlc.narg(to_time) IS NULL)
+ORDER BY a.id DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: CountAuditLogEntries :one
+SELECT COUNT(*) FROM audit_log
+WHERE (user_id = sqlc.narg(user_id) OR sqlc.narg(user_id) IS NULL)
+    AND (action = sqlc.narg(action) OR sqlc.narg(action) IS NULL)
+    AND (created_at >= sqlc.narg(from_time) OR sqlc.narg(from_time) IS NULL)
+    AND (created_at <= sqlc.narg(to_time) OR sqlc.narg(to_time) IS NULL);
+
+-- name: ListAuditLogActions :many
+SELECT DISTINCT action FROM audit_log ORDER BY action;
@@ -0,0 +1,77 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+-- name: UpsertBookmark :exec
+INSERT INTO message_bookmarks (user_id, message_id, note)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, message_id) DO UPDATE SET note = excluded.note;
+
+-- name: CountUserBookmarks :one
+SELECT COUNT(*) FROM message_bookmarks WHERE user_id = ?;
+
+-- name: IsBookmarked :one
+SELECT COUNT(*) FROM message_bookmarks WHERE user_id = ? AND message_id = ?;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM message_bookmarks WHERE user_id = ? AND message_id = ?;
+
+-- name: GetUserBookmark :one
+SELECT
+    b.id AS bookmark_id,
+    b.note AS bookmark_note,
+    b.created_at AS bookmarked_at,
+    m.*,
+    u.username as sender_username,
+    u.profile_image_hash as sender_profile_image_hash,
+    CAST(COALESCE(c.name, (
+        SELECT ou.username
+        FROM conversation_participants ocp
+        INNER JOIN users ou ON ou.id = ocp.user_id
+        WHERE ocp.conversation_id = c.id AND ocp.user_id != sqlc.arg(user_id)
+        LIMIT 1
+    ), '') AS TEXT) AS conversation_name,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(user_id)
+    ) AS TEXT) AS user_reactions_json
+FROM message_bookmarks b
+INNER JOIN messages m ON m.id = b.message_id
+INNER JOIN users u ON m.sender_id = u.id
+INNER JOIN conversations c ON m.conversation_id = c.id
+INNER JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = sqlc.arg(user_id)
+WHERE b.user_id = sqlc.arg(user_id) AND b.message_id = sqlc.arg(message_id);
+
+-- name: GetUserBookmarks :many
+SELECT
+    b.id AS bookmark_id,
+    b.note AS bookmark_note,
+    b.created_at AS bookmarked_at,
+    m.*,
+    u.username as sender_username,
+    u.profile_image_hash as sender_profile_image_hash,
+    CAST(COALESCE(c.name, (
+        SELECT ou.username
+        FROM conversation_participants ocp
+        INNER JOIN users ou ON ou.id = ocp.user_id
+        WHERE ocp.conversation_id = c.id AND ocp.user_id != sqlc.arg(user_id)
+        LIMIT 1
+    ), '') AS TEXT) AS conversation_name,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(user_id)
+    ) AS TEXT) AS user_reactions_json
+FROM message_bookmarks b
+INNER JOIN messages m ON m.id = b.message_id
+INNER JOIN users u ON m.sender_id = u.id
+INNER JOIN conversations c ON m.conversation_id = c.id
+INNER JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = sqlc.arg(user_id)
+WHERE b.user_id = sqlc.arg(user_id)
+    AND (b.id < sqlc.narg(cursor) OR sqlc.narg(cursor) IS NULL)
+ORDER BY b.id DESC
+LIMIT sqlc.arg(limit);
@@ -1,8 +1,8 @@
 -- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
 
 -- name: CreateCall :one
-INSERT INTO calls (conversation_id, message_id, created_at)
-VALUES (?, ?, CURRENT_TIMESTAMP)
+INSERT INTO calls (conversation_id, message_id, initiator_id, created_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP)
 RETURNING *;
 
 -- name: GetCallByMessageID :one
@@ -11,13 +11,49 @@ SELECT * FROM calls WHERE message_id = ? AND deleted_at IS NULL;
 -- name: GetCallByID :one
 SELECT * FROM calls WHERE id = ? AND deleted_at IS NULL;
 
--- name: EndCall :exec
+-- name: EndCall :execrows
 UPDATE calls 
 SET ended_at = CURRENT_TIMESTAMP, deleted_at = CURRENT_TIMESTAMP
-WHERE id = ?;
+WHERE id = ? AND ended_at IS NULL;
+
+-- name: UpdateCallMessage :exec
+WITH call AS (
+    SELECT message_id, ended_at FROM calls WHERE id = sqlc.arg(call_id)
+)
+UPDATE messages
+SET body = json_object(
+        'durationSeconds', CAST(sqlc.arg(duration_seconds) AS INTEGER),
+        'endedAt', strftime('%Y-%m-%dT%H:%M:%SZ', call.ended_at)
+    ),
+    edited_at = CURRENT_TIMESTAMP
+FROM call
+WHERE messages.id = call.message_id;
 
 -- name: GetActiveCallByConversation :one
 SELECT * FROM calls 
 WHERE conversation_id = ? AND deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT 1;
+
+-- name: UpdateCallParticipantCount :exec
+UPDATE calls
+SET participant_count = sqlc.arg(participant_count)
+WHERE id = sqlc.arg(id) AND participant_count < sqlc.arg(participant_count);
+
+-- name: GetCallHistory :many
+SELECT * FROM calls
+WHERE conversation_id = ? AND ended_at IS NOT NULL AND id < ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: GetCallConversationID :one
+SELECT conversation_id FROM calls WHERE id = ?;
+
+-- name: CreateCallStats :exec
+INSERT INTO call_stats (call_id, user_id, payload, collected_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: ListCallStats :many
+SELECT * FROM call_stats
+WHERE call_id = ?
+ORDER BY collected_at ASC, id ASC;
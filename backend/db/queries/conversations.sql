@@ -10,24 +10,137 @@ INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
 VALUES (?, ?, CURRENT_TIMESTAMP);
 
 -- name: GetUserConversations :many
-SELECT 
-    c.*,
-    crs.last_read_seq,
-    (SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.id AND m.seq > COALESCE(crs.last_read_seq, 0)) as unread_count
-FROM conversations c
-INNER JOIN conversation_participants cp ON c.id = cp.conversation_id
-LEFT JOIN conversation_read_state crs ON c.id = crs.conversation_id AND crs.user_id = ?
-WHERE cp.user_id = ?
-ORDER BY c.last_message_seq DESC;
+SELECT
+    uc.id, uc.type, uc.created_at, uc.name, uc.last_message_seq, uc.retention_days,
+    uc.readonly_for_members, uc.pending_deletion_at, uc.archive_token, uc.last_read_seq,
+    uc.unread_count, uc.member_count, uc.notification_level, uc.last_message_id,
+    uc.last_message_sender_username, uc.last_message_content_type, uc.last_message_body,
+    uc.last_message_created_at, uc.last_activity_at, uc.sort_name, uc.pinned_at, uc.pinned
+FROM user_conversations uc
+WHERE uc.viewer_id = sqlc.arg(user_id)
+    AND (NOT CAST(sqlc.arg(contacts_only) AS BOOLEAN) OR (uc.type = 'dm' AND EXISTS (
+        SELECT 1
+        FROM conversation_participants op
+        INNER JOIN user_contacts con ON con.user_id = sqlc.arg(user_id) AND con.contact_user_id = op.user_id
+        WHERE op.conversation_id = uc.id AND op.user_id != sqlc.arg(user_id)
+    )))
+    AND (NOT EXISTS (SELECT 1 FROM user_conversations cur WHERE cur.viewer_id = sqlc.arg(user_id) AND cur.id = sqlc.arg(before_id))
+        OR CASE sqlc.arg(sort)
+            WHEN 'name' THEN (NOT uc.pinned, uc.sort_name, uc.id) > (
+                SELECT NOT cur.pinned, cur.sort_name, cur.id FROM user_conversations cur WHERE cur.viewer_id = sqlc.arg(user_id) AND cur.id = sqlc.arg(before_id)
+            )
+            WHEN 'unread_count' THEN (uc.pinned, uc.unread_count, uc.last_message_seq, uc.id) < (
+                SELECT cur.pinned, cur.unread_count, cur.last_message_seq, cur.id FROM user_conversations cur WHERE cur.viewer_id = sqlc.arg(user_id) AND cur.id = sqlc.arg(before_id)
+            )
+            ELSE (uc.pinned, uc.unread_count > 0, uc.last_activity_at, uc.id) < (
+                SELECT cur.pinned, cur.unread_count > 0, cur.last_activity_at, cur.id FROM user_conversations cur WHERE cur.viewer_id = sqlc.arg(user_id) AND cur.id = sqlc.arg(before_id)
+            )
+        END)
+ORDER BY
+    uc.pinned DESC,
+    CASE WHEN sqlc.arg(sort) = 'name' THEN uc.sort_name END ASC,
+    CASE WHEN sqlc.arg(sort) = 'name' THEN uc.id END ASC,
+    CASE WHEN sqlc.arg(sort) = 'unread_count' THEN uc.unread_count END DESC,
+    CASE WHEN sqlc.arg(sort) = 'unread_count' THEN uc.last_message_seq END DESC,
+    uc.unread_count > 0 DESC,
+    uc.last_activity_at DESC,
+    uc.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: SearchUserConversations :many
+SELECT
+    uc.id, uc.type, uc.created_at, uc.name, uc.last_message_seq, uc.retention_days,
+    uc.readonly_for_members, uc.pending_deletion_at, uc.archive_token, uc.last_read_seq,
+    uc.unread_count, uc.member_count, uc.notification_level, uc.last_message_id,
+    uc.last_message_sender_username, uc.last_message_content_type, uc.last_message_body,
+    uc.last_message_created_at, uc.last_activity_at, uc.sort_name, uc.pinned_at, uc.pinned
+FROM user_conversations uc
+WHERE uc.viewer_id = sqlc.arg(user_id)
+    AND (uc.type = sqlc.narg(conversation_type) OR sqlc.narg(conversation_type) IS NULL)
+    AND (
+        (uc.type = 'group' AND uc.name LIKE sqlc.arg(pattern) ESCAPE '\')
+        OR (uc.type = 'dm' AND EXISTS (
+            SELECT 1
+            FROM conversation_participants op
+            INNER JOIN users u ON op.user_id = u.id
+            WHERE op.conversation_id = uc.id AND op.user_id != sqlc.arg(user_id)
+                AND u.username LIKE sqlc.arg(pattern) ESCAPE '\'
+        ))
+    )
+    AND (NOT EXISTS (SELECT 1 FROM user_conversations cur WHERE cur.viewer_id = sqlc.arg(user_id) AND cur.id = sqlc.arg(before_id))
+        OR CASE sqlc.arg(sort)
+            WHEN 'name' THEN (NOT uc.pinned, uc.sort_name, uc.id) > (
+                SELECT NOT cur.pinned, cur.sort_name, cur.id FROM user_conversations cur WHERE cur.viewer_id = sqlc.arg(user_id) AND cur.id = sqlc.arg(before_id)
+            )
+            WHEN 'unread_count' THEN (uc.pinned, uc.unread_count, uc.last_message_seq, uc.id) < (
+                SELECT cur.pinned, cur.unread_count, cur.last_message_seq, cur.id FROM user_conversations cur WHERE cur.viewer_id = sqlc.arg(user_id) AND cur.id = sqlc.arg(before_id)
+            )
+            ELSE (uc.pinned, uc.unread_count > 0, uc.last_activity_at, uc.id) < (
+                SELECT cur.pinned, cur.unread_count > 0, cur.last_activity_at, cur.id FROM user_conversations cur WHERE cur.viewer_id = sqlc.arg(user_id) AND cur.id = sqlc.arg(before_id)
+            )
+        END)
+ORDER BY
+    uc.pinned DESC,
+    CASE WHEN sqlc.arg(sort) = 'name' THEN uc.sort_name END ASC,
+    CASE WHEN sqlc.arg(sort) = 'name' THEN uc.id END ASC,
+    CASE WHEN sqlc.arg(sort) = 'unread_count' THEN uc.unread_count END DESC,
+    CASE WHEN sqlc.arg(sort) = 'unread_count' THEN uc.last_message_seq END DESC,
+    uc.unread_count > 0 DESC,
+    uc.last_activity_at DESC,
+    uc.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetUserConversation :one
+SELECT
+    uc.id, uc.type, uc.created_at, uc.name, uc.last_message_seq, uc.retention_days,
+    uc.readonly_for_members, uc.pending_deletion_at, uc.archive_token, uc.last_read_seq,
+    uc.unread_count, uc.member_count, uc.notification_level, uc.last_message_id,
+    uc.last_message_sender_username, uc.last_message_content_type, uc.last_message_body,
+    uc.last_message_created_at, uc.last_activity_at, uc.sort_name, uc.pinned_at, uc.pinned
+FROM user_conversations uc
+WHERE uc.viewer_id = sqlc.arg(user_id) AND uc.id = sqlc.arg(conversation_id);
+
+-- name: GetUnreadCounts :many
+SELECT conversation_id, COUNT(*) AS unread_count
+FROM unread_messages
+WHERE user_id = sqlc.arg(user_id)
+    AND (conversation_id = sqlc.narg(conversation_id) OR sqlc.narg(conversation_id) IS NULL)
+GROUP BY conversation_id
+ORDER BY conversation_id;
 
 -- name: GetConversationByID :one
 SELECT * FROM conversations WHERE id = ?;
 
+-- name: UpdateConversationSettings :exec
+UPDATE conversations SET retention_days = ?, readonly_for_members = ? WHERE id = ?;
+
+-- name: UpdateConversationName :exec
+UPDATE conversations SET name = ? WHERE id = ?;
+
+-- name: SetConversationPendingDeletion :exec
+UPDATE conversations SET pending_deletion_at = ?, archive_token = ? WHERE id = ?;
+
+-- name: ClearStalePendingDeletions :execrows
+UPDATE conversations SET pending_deletion_at = NULL, archive_token = NULL
+WHERE pending_deletion_at < ?;
+
+-- name: DeleteConversationMessages :exec
+DELETE FROM messages WHERE conversation_id = ?;
+
+-- name: DeleteConversationParticipants :exec
+DELETE FROM conversation_participants WHERE conversation_id = ?;
+
+-- name: DeleteConversation :exec
+DELETE FROM conversations WHERE id = ?;
+
+-- name: ListConversationsWithRetention :many
+SELECT id, retention_days FROM conversations WHERE retention_days IS NOT NULL;
+
 -- name: GetConversationParticipants :many
 SELECT u.id, u.username, u.profile_image_hash
 FROM users u
 INNER JOIN conversation_participants cp ON u.id = cp.user_id
-WHERE cp.conversation_id = ?;
+WHERE cp.conversation_id = ? AND u.deleted_at IS NULL;
 
 -- name: GetOrCreateDMConversation :one
 SELECT c.* FROM conversations c
@@ -47,3 +160,81 @@ WHERE id = ?;
 -- name: UpdateReadState :exec
 INSERT OR REPLACE INTO conversation_read_state (conversation_id, user_id, last_read_seq, last_read_at)
 VALUES (?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: PinConversation :exec
+INSERT INTO conversation_pins (user_id, conversation_id, pinned_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (user_id, conversation_id) DO NOTHING;
+
+-- name: UnpinConversation :exec
+DELETE FROM conversation_pins WHERE user_id = ? AND conversation_id = ?;
+
+-- name: CountUserPins :one
+SELECT COUNT(*)
+FROM conversation_pins pin
+INNER JOIN conversation_participants cp ON cp.conversation_id = pin.conversation_id AND cp.user_id = pin.user_id
+WHERE pin.user_id = ?;
+
+-- name: GetConversationPin :one
+SELECT * FROM conversation_pins WHERE user_id = ? AND conversation_id = ?;
+
+-- name: GetReadSeq :one
+SELECT last_read_seq FROM conversation_read_state WHERE conversation_id = ? AND user_id = ?;
+
+-- name: ListUserReadStates :many
+SELECT cp.conversation_id, CAST(COALESCE(crs.last_read_seq, 0) AS INTEGER) AS last_read_seq, crs.last_read_at
+FROM conversation_participants cp
+LEFT JOIN conversation_read_state crs ON crs.conversation_id = cp.conversation_id AND crs.user_id = cp.user_id
+WHERE cp.user_id = ?
+ORDER BY cp.conversation_id;
+
+-- name: GetConversationReadSeqs :many
+SELECT crs.user_id, crs.last_read_seq
+FROM conversation_read_state crs
+INNER JOIN conversation_participants cp ON cp.conversation_id = crs.conversation_id AND cp.user_id = crs.user_id
+INNER JOIN users u ON u.id = crs.user_id
+WHERE crs.conversation_id = ? AND u.deleted_at IS NULL;
+
+-- name: ListMessageSeenBy :many
+SELECT u.id, u.username, crs.last_read_at
+FROM conversation_read_state crs
+INNER JOIN conversation_participants cp ON cp.conversation_id = crs.conversation_id AND cp.user_id = crs.user_id
+INNER JOIN users u ON u.id = crs.user_id
+WHERE crs.conversation_id = sqlc.arg(conversation_id)
+    AND crs.last_read_seq >= sqlc.arg(seq)
+    AND crs.user_id != sqlc.arg(sender_id)
+    AND u.deleted_at IS NULL
+ORDER BY crs.last_read_at, u.id
+LIMIT sqlc.arg(limit);
+
+-- name: CountMessageSeenBy :one
+SELECT COUNT(*)
+FROM conversation_read_state crs
+INNER JOIN conversation_participants cp ON cp.conversation_id = crs.conversation_id AND cp.user_id = crs.user_id
+INNER JOIN users u ON u.id = crs.user_id
+WHERE crs.conversation_id = sqlc.arg(conversation_id)
+    AND crs.last_read_seq >= sqlc.arg(seq)
+    AND crs.user_id != sqlc.arg(sender_id)
+    AND u.deleted_at IS NULL;
+
+-- name: ListConversationMembers :many
+SELECT u.id, u.username, u.profile_image_hash, cp.role, cp.joined_at
+FROM conversation_participants cp
+INNER JOIN users u ON cp.user_id = u.id
+WHERE cp.conversation_id = ? AND u.deleted_at IS NULL
+ORDER BY cp.joined_at, u.id;
+
+-- name: GetConversationMember :one
+SELECT u.id, u.username, u.profile_image_hash, cp.role, cp.joined_at
+FROM conversation_participants cp
+INNER JOIN users u ON cp.user_id = u.id
+WHERE cp.conversation_id = ? AND cp.user_id = ? AND u.deleted_at IS NULL;
+
+-- name: CountConversationAdmins :one
+SELECT COUNT(*) FROM conversation_participants cp
+INNER JOIN users u ON cp.user_id = u.id
+WHERE cp.conversation_id = ? AND cp.role = 'admin' AND u.deleted_at IS NULL;
+
+-- name: SetConversationMemberRole :exec
+UPDATE conversation_participants SET role = ?
+WHERE conversation_id = ? AND user_id = ?;
@@ -0,0 +1,7 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- name: GetEncryptionKeyCanary :one
+SELECT ciphertext FROM encryption_key_canary WHERE id = 1;
+
+-- name: CreateEncryptionKeyCanary :exec
+INSERT INTO encryption_key_canary (id, ciphertext) VALUES (1, ?);
@@ -0,0 +1,15 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- name: GetLinkPreview :one
+SELECT * FROM link_previews WHERE url_hash = ? LIMIT 1;
+
+-- name: UpsertLinkPreview :one
+INSERT INTO link_previews (url_hash, url, title, description, image_url, site_name)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (url_hash) DO UPDATE SET
+    title = excluded.title,
+    description = excluded.description,
+    image_url = excluded.image_url,
+    site_name = excluded.site_name,
+    fetched_at = CURRENT_TIMESTAMP
+RETURNING *;
@@ -1,54 +1,184 @@
 -- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
 
 -- name: CreateMessage :one
-INSERT INTO messages (conversation_id, seq, sender_id, content_type, body, reply_to_id, created_at)
-VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+INSERT INTO messages (conversation_id, seq, sender_id, content_type, body, reply_to_id, thread_root_id, client_message_id, created_at)
+VALUES (
+    sqlc.arg(conversation_id), sqlc.arg(seq), sqlc.arg(sender_id), sqlc.arg(content_type), sqlc.arg(body), sqlc.narg(reply_to_id),
+    (SELECT COALESCE(p.thread_root_id, p.id) FROM messages p WHERE p.id = sqlc.narg(reply_to_id)),
+    sqlc.narg(client_message_id),
+    CURRENT_TIMESTAMP
+)
 RETURNING *;
 
 -- name: GetConversationMessages :many
 SELECT 
     m.*,
     u.username as sender_username,
-    u.profile_image_hash as sender_profile_image_hash
+    u.profile_image_hash as sender_profile_image_hash,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(viewer_id)
+    ) AS TEXT) AS user_reactions_json
 FROM messages m
 INNER JOIN users u ON m.sender_id = u.id
-WHERE m.conversation_id = ? AND m.deleted_at IS NULL
+WHERE m.conversation_id = sqlc.arg(conversation_id) AND m.deleted_at IS NULL
+    AND m.sender_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(viewer_id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(viewer_id)
+    )
 ORDER BY m.seq DESC
-LIMIT ? OFFSET ?;
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
--- name: GetMessagesSince :many
+-- name: GetConversationRootMessages :many
 SELECT 
     m.*,
     u.username as sender_username,
-    u.profile_image_hash as sender_profile_image_hash
+    u.profile_image_hash as sender_profile_image_hash,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(viewer_id)
+    ) AS TEXT) AS user_reactions_json
+FROM messages m
+INNER JOIN users u ON m.sender_id = u.id
+WHERE m.conversation_id = sqlc.arg(conversation_id) AND m.deleted_at IS NULL AND m.reply_to_id IS NULL
+    AND m.sender_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(viewer_id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(viewer_id)
+    )
+ORDER BY m.seq DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: GetThreadMessages :many
+SELECT 
+    m.*,
+    u.username as sender_username,
+    u.profile_image_hash as sender_profile_image_hash,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(viewer_id)
+    ) AS TEXT) AS user_reactions_json
 FROM messages m
 INNER JOIN users u ON m.sender_id = u.id
-WHERE m.conversation_id = ? AND m.created_at > ? AND m.deleted_at IS NULL
+WHERE m.thread_root_id = sqlc.arg(thread_root_id)
 ORDER BY m.seq ASC;
 
+-- name: GetReplySenders :many
+SELECT m.reply_to_id, m.sender_id, u.username, COUNT(*) AS reply_count
+FROM messages m
+INNER JOIN users u ON m.sender_id = u.id
+WHERE m.reply_to_id IN (sqlc.slice(message_ids)) AND m.deleted_at IS NULL
+    AND m.sender_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(viewer_id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(viewer_id)
+    )
+GROUP BY m.reply_to_id, m.sender_id, u.username
+ORDER BY m.reply_to_id, MIN(m.seq);
+
+-- name: GetMessagesSince :many
+SELECT 
+    m.*,
+    u.username as sender_username,
+    u.profile_image_hash as sender_profile_image_hash,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(viewer_id)
+    ) AS TEXT) AS user_reactions_json
+FROM messages m
+INNER JOIN users u ON m.sender_id = u.id
+WHERE m.conversation_id = sqlc.arg(conversation_id) AND m.created_at > sqlc.arg(created_at) AND m.deleted_at IS NULL
+    AND m.sender_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(viewer_id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(viewer_id)
+    )
+ORDER BY m.seq ASC
+LIMIT sqlc.arg(limit);
+
 -- name: GetMessagesBefore :many
 SELECT 
     m.*,
     u.username as sender_username,
-    u.profile_image_hash as sender_profile_image_hash
+    u.profile_image_hash as sender_profile_image_hash,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(viewer_id)
+    ) AS TEXT) AS user_reactions_json
 FROM messages m
 INNER JOIN users u ON m.sender_id = u.id
-WHERE m.conversation_id = ? AND m.created_at < ? AND m.deleted_at IS NULL
+WHERE m.conversation_id = sqlc.arg(conversation_id) AND m.created_at < sqlc.arg(created_at) AND m.deleted_at IS NULL
+    AND m.sender_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(viewer_id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(viewer_id)
+    )
 ORDER BY m.seq DESC
-LIMIT ?;
+LIMIT sqlc.arg(limit);
 
 -- name: GetMessageByID :one
 SELECT * FROM messages WHERE id = ?;
 
+-- name: SetMessageForwardedFrom :exec
+UPDATE messages SET forwarded_from_message_id = ? WHERE id = ?;
+
+-- name: GetForwardedFrom :many
+SELECT m.id, src.id AS source_id, src.conversation_id AS source_conversation_id, u.username AS source_sender_username
+FROM messages m
+INNER JOIN messages src ON m.forwarded_from_message_id = src.id
+INNER JOIN users u ON src.sender_id = u.id
+WHERE m.id IN (sqlc.slice(message_ids));
+
+-- name: SampleStoredMessages :many
+SELECT id, conversation_id, content_type, body FROM messages
+WHERE deleted_at IS NULL
+ORDER BY RANDOM()
+LIMIT ?;
+
+-- name: ListStoredMessagesAfter :many
+SELECT id, conversation_id, content_type, body FROM messages
+WHERE id > ? AND deleted_at IS NULL
+ORDER BY id
+LIMIT ?;
+
+-- name: GetMessageByClientID :one
+SELECT * FROM messages WHERE client_message_id = ?;
+
+-- name: ClearExpiredClientMessageIDs :execrows
+UPDATE messages
+SET client_message_id = NULL
+WHERE client_message_id IS NOT NULL AND created_at < ?;
+
 -- name: UpdateMessage :exec
 UPDATE messages 
 SET body = ?, edited_at = CURRENT_TIMESTAMP
-WHERE id = ? AND sender_id = ? AND deleted_at IS NULL;
+WHERE id = ? AND sender_id = ? AND deleted_at IS NULL AND content_type != 'application/system';
 
 -- name: DeleteMessage :exec
 UPDATE messages 
 SET deleted_at = CURRENT_TIMESTAMP
-WHERE id = ? AND sender_id = ?;
+WHERE id = ? AND sender_id = ? AND content_type != 'application/system';
 
 -- name: AddMessageAttachment :exec
 INSERT INTO message_attachments (message_id, attachment_id, filename, mime_type, size_bytes)
@@ -61,9 +191,80 @@ SELECT * FROM message_attachments WHERE message_id = ?;
 SELECT
     m.*,
     u.username AS sender_username,
-    u.profile_image_hash AS sender_profile_image_hash
+    u.profile_image_hash AS sender_profile_image_hash,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(user_id)
+    ) AS TEXT) AS user_reactions_json
 FROM messages m
 INNER JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id
 INNER JOIN users u ON m.sender_id = u.id
-WHERE cp.user_id = ? AND m.id > ? AND m.deleted_at IS NULL
+WHERE cp.user_id = sqlc.arg(user_id) AND m.id > sqlc.arg(id) AND m.deleted_at IS NULL
+    AND m.sender_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(user_id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(user_id)
+    )
 ORDER BY m.id ASC;
+
+-- name: ListMessagesBySender :many
+SELECT * FROM messages
+WHERE sender_id = ? AND id > ?
+ORDER BY id ASC
+LIMIT ?;
+
+-- name: GetConversationMessagesForExport :many
+SELECT 
+    m.*,
+    u.username as sender_username,
+    u.profile_image_hash as sender_profile_image_hash
+FROM messages m
+INNER JOIN users u ON m.sender_id = u.id
+WHERE m.conversation_id = ? AND m.seq > ?
+ORDER BY m.seq ASC
+LIMIT ?;
+
+-- name: ExpireConversationMessages :execrows
+UPDATE messages
+SET body = '', deleted_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ? AND created_at < ? AND deleted_at IS NULL;
+
+-- name: GetSearchableMessages :many
+SELECT 
+    m.*,
+    u.username as sender_username,
+    u.profile_image_hash as sender_profile_image_hash,
+    CAST(COALESCE(c.name, (
+        SELECT ou.username
+        FROM conversation_participants ocp
+        INNER JOIN users ou ON ou.id = ocp.user_id
+        WHERE ocp.conversation_id = c.id AND ocp.user_id != sqlc.arg(viewer_id)
+        LIMIT 1
+    ), '') AS TEXT) AS conversation_name,
+    CAST((
+        SELECT json_group_object(emoji, reaction_count)
+        FROM (SELECT emoji, COUNT(*) AS reaction_count FROM message_reactions WHERE message_id = m.id GROUP BY emoji)
+    ) AS TEXT) AS reactions_json,
+    CAST((
+        SELECT json_group_array(emoji)
+        FROM message_reactions WHERE message_id = m.id AND user_id = sqlc.arg(viewer_id)
+    ) AS TEXT) AS user_reactions_json
+FROM messages m
+INNER JOIN users u ON m.sender_id = u.id
+INNER JOIN conversations c ON m.conversation_id = c.id
+INNER JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = sqlc.arg(viewer_id)
+WHERE m.deleted_at IS NULL AND m.content_type IN ('text/plain', 'text/markdown')
+    AND (u.username = sqlc.narg(sender) OR sqlc.narg(sender) IS NULL)
+    AND (m.created_at > sqlc.narg(after) OR sqlc.narg(after) IS NULL)
+    AND (m.created_at < sqlc.narg(before) OR sqlc.narg(before) IS NULL)
+    AND m.sender_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(viewer_id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(viewer_id)
+    )
+ORDER BY m.created_at DESC, m.id DESC
+LIMIT sqlc.arg(scan_limit);
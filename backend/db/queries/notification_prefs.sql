@@ -0,0 +1,13 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+-- name: SetNotificationLevel :exec
+INSERT INTO conversation_notification_prefs (conversation_id, user_id, level, updated_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (conversation_id, user_id) DO UPDATE SET level = excluded.level, updated_at = excluded.updated_at;
+
+-- name: GetNotificationLevel :one
+SELECT level FROM conversation_notification_prefs
+WHERE conversation_id = ? AND user_id = ?;
+
+-- name: GetConversationNotificationLevels :many
+SELECT user_id, level FROM conversation_notification_prefs
+WHERE conversation_id = ? AND level != 'all';
@@ -0,0 +1,22 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- name: UpsertPollVote :exec
+INSERT INTO poll_votes (poll_message_id, user_id, option_index, created_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (poll_message_id, user_id, option_index) DO UPDATE SET created_at = excluded.created_at;
+
+-- name: DeletePollVotesByUser :exec
+DELETE FROM poll_votes WHERE poll_message_id = ? AND user_id = ?;
+
+-- name: GetPollVoteCounts :many
+SELECT poll_message_id, option_index, COUNT(*) AS votes
+FROM poll_votes
+WHERE poll_message_id IN (sqlc.slice(poll_message_ids))
+GROUP BY poll_message_id, option_index;
+
+-- name: GetPollsVotedSince :many
+SELECT DISTINCT m.id, m.conversation_id, m.body
+FROM messages m
+INNER JOIN poll_votes pv ON pv.poll_message_id = m.id
+WHERE m.conversation_id = ? AND pv.created_at > ? AND m.deleted_at IS NULL AND m.content_type = 'application/poll'
+ORDER BY m.id ASC;
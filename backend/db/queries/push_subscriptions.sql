@@ -0,0 +1,17 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- name: UpsertPushSubscription :execrows
+INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth, created_at)
+VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (endpoint) DO UPDATE
+SET p256dh = excluded.p256dh, auth = excluded.auth
+WHERE push_subscriptions.user_id = excluded.user_id;
+
+-- name: DeletePushSubscription :exec
+DELETE FROM push_subscriptions WHERE endpoint = ? AND user_id = ?;
+
+-- name: DeletePushSubscriptionByEndpoint :exec
+DELETE FROM push_subscriptions WHERE endpoint = ?;
+
+-- name: ListPushSubscriptionsByUser :many
+SELECT * FROM push_subscriptions WHERE user_id = ?;
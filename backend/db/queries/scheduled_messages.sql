@@ -0,0 +1,24 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+-- name: CreateScheduledMessage :one
+INSERT INTO scheduled_messages (conversation_id, sender_id, content_type, body, reply_to_id, send_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListScheduledMessages :many
+SELECT * FROM scheduled_messages
+WHERE conversation_id = ? AND sender_id = ?
+ORDER BY send_at, id;
+
+-- name: GetScheduledMessage :one
+SELECT * FROM scheduled_messages WHERE id = ?;
+
+-- name: DeleteScheduledMessage :execrows
+DELETE FROM scheduled_messages WHERE id = ?;
+
+-- name: ListDueScheduledMessages :many
+SELECT * FROM scheduled_messages
+WHERE send_at <= ? AND failed_at IS NULL
+ORDER BY send_at, id;
+
+-- name: FailScheduledMessage :exec
+UPDATE scheduled_messages SET failed_at = ?, failure_reason = ? WHERE id = ?;
@@ -1,7 +1,7 @@
 -- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
 -- name: CreateOAuthToken :one
-INSERT INTO oauth_tokens (user_id, access_token, refresh_token, access_token_expires_at, refresh_token_expires_at)
-VALUES (?, ?, ?, ?, ?)
+INSERT INTO oauth_tokens (user_id, access_token, refresh_token, access_token_expires_at, refresh_token_expires_at, device_name)
+VALUES (?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetTokenByAccessToken :one
@@ -16,5 +16,15 @@ DELETE FROM oauth_tokens WHERE access_token = ?;
 -- name: DeleteUserTokens :exec
 DELETE FROM oauth_tokens WHERE user_id = ?;
 
--- name: DeleteExpiredTokens :exec
-DELETE FROM oauth_tokens WHERE access_token_expires_at < datetime('now');
+-- name: DeleteUserDeviceTokens :execrows
+DELETE FROM oauth_tokens
+WHERE user_id = sqlc.arg(user_id) AND device_name IS sqlc.narg(device_name);
+
+-- name: GetUserTokens :many
+SELECT id, device_name, created_at, access_token_expires_at, refresh_token_expires_at
+FROM oauth_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC, id DESC;
+
+-- name: DeleteExpiredTokens :execrows
+DELETE FROM oauth_tokens WHERE refresh_token_expires_at < ?;
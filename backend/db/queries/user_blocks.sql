@@ -0,0 +1,27 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?;
+
+-- name: ListBlockedUserIDs :many
+SELECT blocked_id FROM user_blocks
+WHERE blocker_id = ?
+ORDER BY created_at;
+
+-- name: ListBlockRelatedUserIDs :many
+SELECT blocked_id AS user_id FROM user_blocks WHERE blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id AS user_id FROM user_blocks WHERE blocked_id = sqlc.arg(user_id);
+
+-- name: IsBlockedBetween :one
+SELECT COUNT(*) FROM user_blocks
+WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = sqlc.arg(other_user_id))
+    OR (blocker_id = sqlc.arg(other_user_id) AND blocked_id = sqlc.arg(user_id));
+
+-- name: HasBlocked :one
+SELECT COUNT(*) FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?;
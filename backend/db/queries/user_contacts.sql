@@ -0,0 +1,27 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- name: AddContact :exec
+INSERT INTO user_contacts (user_id, contact_user_id, created_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (user_id, contact_user_id) DO NOTHING;
+
+-- name: RemoveContact :execrows
+DELETE FROM user_contacts WHERE user_id = ? AND contact_user_id = ?;
+
+-- name: CountContacts :one
+SELECT COUNT(*) FROM user_contacts WHERE user_id = ?;
+
+-- name: IsContact :one
+SELECT COUNT(*) FROM user_contacts WHERE user_id = ? AND contact_user_id = ?;
+
+-- name: ListContacts :many
+SELECT u.id, u.username, u.profile_image_hash, u.status_message
+FROM user_contacts uc
+INNER JOIN users u ON uc.contact_user_id = u.id
+WHERE uc.user_id = sqlc.arg(user_id) AND u.deleted_at IS NULL
+    AND u.id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(user_id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(user_id)
+    )
+ORDER BY u.username COLLATE NOCASE, u.id;
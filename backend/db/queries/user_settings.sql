@@ -13,9 +13,10 @@ UPDATE user_settings SET enter_sends_message = ? WHERE user_id = ?;
 UPDATE user_settings SET markdown_enabled = ? WHERE user_id = ?;
 
 -- name: UpsertUserSettings :one
-INSERT INTO user_settings (user_id, enter_sends_message, markdown_enabled)
-VALUES (?, ?, ?)
+INSERT INTO user_settings (user_id, enter_sends_message, markdown_enabled, conversation_sort)
+VALUES (?, ?, ?, ?)
 ON CONFLICT(user_id) DO UPDATE SET 
     enter_sends_message = excluded.enter_sends_message,
-    markdown_enabled = excluded.markdown_enabled
+    markdown_enabled = excluded.markdown_enabled,
+    conversation_sort = excluded.conversation_sort
 RETURNING *;
@@ -7,10 +7,24 @@ SELECT * FROM users
 ORDER BY username;
 
 -- name: CreateUser :one
-INSERT INTO users (username, password_hash, password_salt)
-VALUES (?, ?, ?)
+INSERT INTO users (username, password_hash, password_salt, is_admin)
+VALUES (?, ?, ?, ?)
 RETURNING *;
 
+-- name: CreateBotUser :one
+INSERT INTO users (username, password_hash, password_salt, is_bot, api_key)
+VALUES (?, '', '', 1, ?)
+RETURNING *;
+
+-- name: GetUserByAPIKey :one
+SELECT * FROM users WHERE api_key = ? AND is_bot = 1 LIMIT 1;
+
+-- name: ListUnhashedAPIKeys :many
+SELECT id, api_key FROM users WHERE api_key IS NOT NULL AND api_key NOT LIKE 'sha256:%';
+
+-- name: UpdateUserAPIKey :exec
+UPDATE users SET api_key = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?;
+
 -- name: DeleteUser :one
 SELECT profile_image_hash FROM users WHERE id = ? LIMIT 1;
 
@@ -19,13 +33,13 @@ DELETE FROM users
 WHERE id = ?;
 
 -- name: GetUserByUsername :one
-SELECT * FROM users WHERE username = ? LIMIT 1;
+SELECT * FROM users WHERE LOWER(username) = LOWER(sqlc.arg(username)) AND deleted_at IS NULL LIMIT 1;
 
 -- name: CountUsers :one
 SELECT COUNT(*) FROM users;
 
 -- name: CreateInvitationCode :one
-INSERT INTO invitation_codes (code, created_by) VALUES (?, ?) RETURNING *;
+INSERT INTO invitation_codes (code, created_by, expires_at) VALUES (?, ?, ?) RETURNING *;
 
 -- name: GetInvitationByCode :one
 SELECT * FROM invitation_codes WHERE code = ? LIMIT 1;
@@ -36,20 +50,123 @@ DELETE FROM invitation_codes WHERE code = ?;
 -- name: ListInvitationsByUser :many
 SELECT * FROM invitation_codes WHERE created_by = ? ORDER BY created_at DESC;
 
+-- name: ListUnexpiredInvitations :many
+SELECT ic.id, ic.code, ic.created_by, ic.created_at, ic.expires_at, u.username AS created_by_username
+FROM invitation_codes ic
+LEFT JOIN users u ON u.id = ic.created_by
+WHERE ic.expires_at IS NULL OR ic.expires_at > sqlc.arg(now)
+ORDER BY ic.created_at DESC, ic.id DESC;
+
+-- name: CountActiveInvitationsByUser :one
+SELECT COUNT(*) FROM invitation_codes
+WHERE created_by = sqlc.arg(created_by) AND (expires_at IS NULL OR expires_at > sqlc.arg(now));
+
 -- name: DeleteInvitationById :exec
 DELETE FROM invitation_codes WHERE id = ? AND created_by = ?;
 
+-- name: RecordInvitationUse :exec
+INSERT INTO invitation_uses (invitation_id, code, created_by, invitation_created_at, used_by_user_id)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ListInvitationUsesByCreator :many
+SELECT iu.*, u.username AS used_by_username
+FROM invitation_uses iu
+INNER JOIN users u ON u.id = iu.used_by_user_id
+WHERE iu.created_by = ?
+ORDER BY iu.invitation_created_at DESC;
+
+-- name: ListInvitationUses :many
+SELECT iu.*, u.username AS used_by_username, cu.username AS created_by_username
+FROM invitation_uses iu
+INNER JOIN users u ON u.id = iu.used_by_user_id
+LEFT JOIN users cu ON cu.id = iu.created_by
+ORDER BY iu.used_at DESC, iu.id DESC;
+
 -- name: UpdateUserProfileImageHash :exec
-UPDATE users SET profile_image_hash = ? WHERE id = ?;
+UPDATE users
+SET profile_image_hash = ?, profile_image_hash_32 = ?, profile_image_hash_128 = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
 
 -- name: GetOldUserProfileImageHash :one
-SELECT profile_image_hash FROM users WHERE id = ? LIMIT 1;
+SELECT profile_image_hash, profile_image_hash_32, profile_image_hash_128 FROM users WHERE id = ? LIMIT 1;
+
+-- name: GetUserByProfileImageHash :one
+SELECT * FROM users WHERE profile_image_hash = ? LIMIT 1;
+
+-- name: ListProfileImageHashes :many
+SELECT profile_image_hash, profile_image_hash_32, profile_image_hash_128 FROM users
+WHERE profile_image_hash IS NOT NULL
+   OR profile_image_hash_32 IS NOT NULL
+   OR profile_image_hash_128 IS NOT NULL;
 
 -- name: CountProfileImageUsage :one
-SELECT COUNT(*) FROM users WHERE profile_image_hash = ?;
+SELECT COUNT(*) FROM users
+WHERE profile_image_hash = sqlc.arg(hash)
+   OR profile_image_hash_32 = sqlc.arg(hash)
+   OR profile_image_hash_128 = sqlc.arg(hash);
 
 -- name: SearchUsers :many
 SELECT id, username, profile_image_hash FROM users 
-WHERE username LIKE ? AND id != ?
-ORDER BY username
+WHERE username LIKE sqlc.arg(username) AND id != sqlc.arg(id)
+    AND (is_bot = 0 OR is_bot = sqlc.arg(include_bots))
+    AND deleted_at IS NULL
+    AND id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(id)
+    )
+ORDER BY
+    EXISTS (SELECT 1 FROM user_contacts uc WHERE uc.user_id = sqlc.arg(id) AND uc.contact_user_id = users.id) DESC,
+    username
 LIMIT 10;
+
+-- name: ListDirectoryUsers :many
+SELECT id, username, profile_image_hash, status_message FROM users
+WHERE deleted_at IS NULL
+    AND (CAST(sqlc.arg(include_inactive) AS BOOLEAN) OR (is_bot = 0 AND (suspended_until IS NULL OR suspended_until <= sqlc.arg(now))))
+    AND id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(id)
+    )
+ORDER BY username COLLATE NOCASE, id
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: CountDirectoryUsers :one
+SELECT COUNT(*) FROM users
+WHERE deleted_at IS NULL
+    AND (CAST(sqlc.arg(include_inactive) AS BOOLEAN) OR (is_bot = 0 AND (suspended_until IS NULL OR suspended_until <= sqlc.arg(now))))
+    AND id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(id)
+        UNION
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(id)
+    );
+
+-- name: ListUsersPage :many
+SELECT id, username, profile_image_hash, is_admin, is_bot, suspended_until, suspension_reason, created_at
+FROM users
+WHERE username LIKE ?
+ORDER BY id
+LIMIT ? OFFSET ?;
+
+-- name: CountUsersMatching :one
+SELECT COUNT(*) FROM users WHERE username LIKE ?;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = ?, suspension_reason = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: UnsuspendUser :exec
+UPDATE users
+SET suspended_until = NULL, suspension_reason = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: SoftDeleteUser :exec
+UPDATE users
+SET deleted_at = CURRENT_TIMESTAMP, api_key = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: UpdateUserProfileStatus :exec
+UPDATE users SET bio = ?, status_message = ?, status_emoji = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
@@ -0,0 +1,25 @@
+-- Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (user_id, conversation_id, url, secret, created_at)
+VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+RETURNING *;
+
+-- name: ListWebhooksByUser :many
+SELECT * FROM webhooks WHERE user_id = ? ORDER BY created_at DESC;
+
+-- name: ListDeliverableWebhooks :many
+SELECT w.* FROM webhooks w
+INNER JOIN conversation_participants cp ON cp.conversation_id = w.conversation_id AND cp.user_id = w.user_id
+INNER JOIN users u ON u.id = w.user_id
+WHERE w.conversation_id = sqlc.arg(conversation_id)
+    AND u.deleted_at IS NULL
+    AND (u.suspended_until IS NULL OR u.suspended_until <= sqlc.arg(now))
+    AND NOT EXISTS (
+        SELECT 1 FROM user_blocks b
+        WHERE (b.blocker_id = w.user_id AND b.blocked_id = sqlc.arg(sender_id))
+            OR (b.blocker_id = sqlc.arg(sender_id) AND b.blocked_id = w.user_id)
+    );
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks WHERE id = ? AND user_id = ?;
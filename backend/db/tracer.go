@@ -0,0 +1,126 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the HTTP request it
+// belongs to, which QueryTracer includes in slow query logs.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// QueryTracer wraps a DBTX and logs every statement that takes longer than
+// its threshold. QueryContext is timed until the first rows are available,
+// not until they are consumed.
+type QueryTracer struct {
+	db        DBTX
+	threshold time.Duration
+}
+
+// NewQueryTracer wraps db so that statements slower than threshold are logged.
+func NewQueryTracer(db DBTX, threshold time.Duration) *QueryTracer {
+	return &QueryTracer{db: db, threshold: threshold}
+}
+
+func (t *QueryTracer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := t.db.ExecContext(ctx, query, args...)
+	t.observe(ctx, start, query, args)
+	return result, err
+}
+
+func (t *QueryTracer) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	start := time.Now()
+	stmt, err := t.db.PrepareContext(ctx, query)
+	t.observe(ctx, start, query, nil)
+	return stmt, err
+}
+
+func (t *QueryTracer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	t.observe(ctx, start, query, args)
+	return rows, err
+}
+
+func (t *QueryTracer) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := t.db.QueryRowContext(ctx, query, args...)
+	t.observe(ctx, start, query, args)
+	return row
+}
+
+func (t *QueryTracer) observe(ctx context.Context, start time.Time, query string, args []interface{}) {
+	duration := time.Since(start)
+	if duration < t.threshold {
+		return
+	}
+
+	slog.Warn("slow database query",
+		"duration", duration,
+		"query", strings.Join(strings.Fields(query), " "),
+		"args", traceArgs(args),
+		"request_id", RequestIDFromContext(ctx),
+	)
+}
+
+// traceArgs formats query arguments for logging. Strings and byte slices are
+// reduced to their length because they may hold tokens, password hashes or
+// message bodies.
+func traceArgs(args []interface{}) []string {
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			formatted[i] = fmt.Sprintf("string(%d)", len(v))
+		case *string:
+			if v == nil {
+				formatted[i] = "<nil>"
+			} else {
+				formatted[i] = fmt.Sprintf("string(%d)", len(*v))
+			}
+		case []byte:
+			formatted[i] = fmt.Sprintf("bytes(%d)", len(v))
+		default:
+			formatted[i] = fmt.Sprint(sqlValue(arg))
+		}
+	}
+	return formatted
+}
+
+// sqlValue dereferences the pointer types sqlc uses for nullable columns.
+func sqlValue(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case *int64:
+		if v != nil {
+			return *v
+		}
+	case *time.Time:
+		if v != nil {
+			return *v
+		}
+	case *bool:
+		if v != nil {
+			return *v
+		}
+	default:
+		return arg
+	}
+	return nil
+}
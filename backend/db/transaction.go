@@ -1,26 +1,36 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"sync/atomic"
+)
 
 type QuerierTx struct {
 	*Queries
-	tx *sql.Tx
+	tx        *sql.Tx
+	committed atomic.Bool
 }
 
-// Rollback aborts the transaction.
+// Rollback aborts the transaction. It does nothing once Commit was called,
+// so that it can be deferred right after the transaction is begun.
 func (q *QuerierTx) Rollback() error {
+	if q.committed.Load() {
+		return nil
+	}
 	return q.tx.Rollback()
 }
 
-// Commit commits the transaction.
+// Commit commits the transaction. The transaction is finished afterwards
+// even if committing failed.
 func (q *QuerierTx) Commit() error {
+	q.committed.Store(true)
 	return q.tx.Commit()
 }
 
 // NewTx creates a new transaction querier.
 func NewTx(tx *sql.Tx) *QuerierTx {
 	return &QuerierTx{
-		New(tx),
-		tx,
+		Queries: New(tx),
+		tx:      tx,
 	}
 }
@@ -3,11 +3,16 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -17,9 +22,37 @@ import (
 	"github.com/bloodmagesoftware/teamsync/crypto"
 	"github.com/bloodmagesoftware/teamsync/db"
 	"github.com/bloodmagesoftware/teamsync/rtc"
+	"github.com/bloodmagesoftware/teamsync/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+const (
+	defaultTokenPruneInterval   = time.Hour
+	defaultCheckpointInterval   = 5 * time.Minute
+	profileImageCleanupInterval = 7 * 24 * time.Hour
+	// defaultS3Prefix keeps the objects of teamsync apart from other data in
+	// a shared bucket.
+	defaultS3Prefix = "teamsync/"
+)
+
+var tokensPruned = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "teamsync_tokens_pruned_total",
+	Help: "OAuth tokens deleted after their refresh token expired.",
+})
+
 func main() {
+	rollbackTo := flag.String("rollback-to", "", "roll back all migrations applied after the given migration file and exit")
+	flag.Parse()
+
+	if *rollbackTo != "" {
+		if err := rollbackMigrations(*rollbackTo); err != nil {
+			log.Fatalf("failed to roll back migrations: %v", err)
+		}
+		log.Printf("rolled back migrations to %s", *rollbackTo)
+		return
+	}
+
 	if err := crypto.InitializeEncryption(); err != nil {
 		log.Fatalf("failed to initialize encryption: %v", err)
 	}
@@ -38,14 +71,75 @@ func main() {
 		}
 	}()
 
+	readDatabase, err := db.InitReader("data/teamsync.db")
+	if err != nil {
+		log.Fatalf("failed to open read-only database connections: %v", err)
+	}
+	defer readDatabase.Close()
+
+	if err := checkEncryptionKey(database); err != nil {
+		log.Fatalf("failed to check encryption key: %v", err)
+	}
+
 	if err := ensureInitialInvitation(database); err != nil {
 		log.Fatalf("failed to ensure initial invitation: %v", err)
 	}
 
+	if hashed, err := auth.HashStoredAPIKeys(context.Background(), database); err != nil {
+		log.Fatalf("failed to hash stored API keys: %v", err)
+	} else if hashed > 0 {
+		log.Printf("hashed %d API keys stored in plain text", hashed)
+	}
+
+	pruneInterval := durationFromEnv("TOKEN_PRUNE_INTERVAL")
+	if pruneInterval <= 0 {
+		pruneInterval = defaultTokenPruneInterval
+	}
+	pruneCtx, stopPruning := context.WithCancel(context.Background())
+	defer stopPruning()
+	go pruneExpiredTokens(pruneCtx, database, pruneInterval)
+
+	objectStorage, err := newStorageBackend(context.Background())
+	if err != nil {
+		log.Fatalf("failed to set up storage: %v", err)
+	}
+	go cleanupOrphanedProfileImages(pruneCtx, database, objectStorage, boolFromEnv("PROFILE_IMAGE_CLEANUP_DRY_RUN", false))
+
+	checkpointInterval := durationFromEnv("DB_CHECKPOINT_INTERVAL")
+	if checkpointInterval <= 0 {
+		checkpointInterval = defaultCheckpointInterval
+	}
+	go checkpointDatabase(pruneCtx, database, readDatabase, checkpointInterval)
+
 	turnConfig := rtc.Config{
 		ListenAddress:  strings.TrimSpace(os.Getenv("TURN_LISTEN_ADDRESS")),
 		Realm:          strings.TrimSpace(os.Getenv("TURN_REALM")),
 		UsernamePrefix: strings.TrimSpace(os.Getenv("TURN_USERNAME_PREFIX")),
+		Mode:           strings.TrimSpace(os.Getenv("TURN_MODE")),
+	}
+
+	if limitEnv := strings.TrimSpace(os.Getenv("TURN_AUTH_RATE_LIMIT")); limitEnv != "" {
+		if limit, err := strconv.Atoi(limitEnv); err == nil && limit > 0 {
+			turnConfig.AuthRateLimit = limit
+		} else {
+			log.Printf("invalid TURN_AUTH_RATE_LIMIT: %q", limitEnv)
+		}
+	}
+
+	if portEnv := strings.TrimSpace(os.Getenv("TURN_RELAY_PORT_MIN")); portEnv != "" {
+		if port, err := strconv.ParseUint(portEnv, 10, 16); err == nil && port > 0 {
+			turnConfig.RelayPortMin = uint16(port)
+		} else {
+			log.Printf("invalid TURN_RELAY_PORT_MIN: %q", portEnv)
+		}
+	}
+
+	if portEnv := strings.TrimSpace(os.Getenv("TURN_RELAY_PORT_MAX")); portEnv != "" {
+		if port, err := strconv.ParseUint(portEnv, 10, 16); err == nil && port > 0 {
+			turnConfig.RelayPortMax = uint16(port)
+		} else {
+			log.Printf("invalid TURN_RELAY_PORT_MAX: %q", portEnv)
+		}
 	}
 
 	if relayEnv := strings.TrimSpace(os.Getenv("TURN_RELAY_IP")); relayEnv != "" {
@@ -60,6 +154,12 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to start TURN server: %v", err)
 	}
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "teamsync_turn_auth_rejected_total",
+		Help: "TURN authentication attempts rejected, including rate limited ones.",
+	}, func() float64 {
+		return float64(turnServer.AuthRejections())
+	})
 	defer func() {
 		if err := turnServer.Close(); err != nil {
 			log.Printf("error during TURN shutdown: %v", err)
@@ -68,7 +168,147 @@ func main() {
 		}
 	}()
 
-	server := api.New(database, turnServer.Config())
+	apiConfig := api.Config{
+		ListenAddress: strings.TrimSpace(os.Getenv("API_LISTEN_ADDRESS")),
+		ReadTimeout:   firstDurationFromEnv("HTTP_READ_TIMEOUT", "API_READ_TIMEOUT"),
+		WriteTimeout:  firstDurationFromEnv("HTTP_WRITE_TIMEOUT", "API_WRITE_TIMEOUT"),
+		IdleTimeout:   firstDurationFromEnv("HTTP_IDLE_TIMEOUT", "API_IDLE_TIMEOUT"),
+
+		SSEKeepAliveInterval: durationFromEnv("SSE_KEEPALIVE_INTERVAL"),
+
+		VAPIDPublicKey:  strings.TrimSpace(os.Getenv("VAPID_PUBLIC_KEY")),
+		VAPIDPrivateKey: strings.TrimSpace(os.Getenv("VAPID_PRIVATE_KEY")),
+		VAPIDSubject:    strings.TrimSpace(os.Getenv("VAPID_SUBJECT")),
+
+		PublicURL:   strings.TrimSpace(os.Getenv("PUBLIC_URL")),
+		CORSOrigins: listFromEnv("CORS_ORIGINS"),
+		TURNHealth:  turnServer.Health,
+
+		TLSCertFile:           strings.TrimSpace(os.Getenv("TLS_CERT_FILE")),
+		TLSKeyFile:            strings.TrimSpace(os.Getenv("TLS_KEY_FILE")),
+		HTTPRedirectAddress:   strings.TrimSpace(os.Getenv("HTTP_REDIRECT_ADDRESS")),
+		HSTSIncludeSubdomains: boolFromEnv("HSTS_INCLUDE_SUBDOMAINS", false),
+		HSTSPreload:           boolFromEnv("HSTS_PRELOAD", false),
+	}
+
+	if strings.TrimSpace(os.Getenv("LDAP_ENABLED")) == "true" {
+		ldapAuth, err := auth.NewLDAPAuthenticator(auth.LDAPConfig{
+			Host:             strings.TrimSpace(os.Getenv("LDAP_HOST")),
+			Port:             strings.TrimSpace(os.Getenv("LDAP_PORT")),
+			Security:         strings.TrimSpace(os.Getenv("LDAP_SECURITY")),
+			BindDN:           strings.TrimSpace(os.Getenv("LDAP_BIND_DN")),
+			BindPassword:     os.Getenv("LDAP_BIND_PASSWORD"),
+			UserSearchBase:   strings.TrimSpace(os.Getenv("LDAP_USER_SEARCH_BASE")),
+			UserSearchFilter: strings.TrimSpace(os.Getenv("LDAP_USER_SEARCH_FILTER")),
+		})
+		if err != nil {
+			log.Fatalf("invalid LDAP configuration: %v", err)
+		}
+		apiConfig.LDAP = ldapAuth
+		log.Printf("verifying passwords against LDAP server %s", os.Getenv("LDAP_HOST"))
+	}
+
+	if limitEnv := strings.TrimSpace(os.Getenv("MESSAGE_RATE_LIMIT")); limitEnv != "" {
+		if limit, err := strconv.Atoi(limitEnv); err == nil && limit > 0 {
+			apiConfig.MessageRateLimit = limit
+		} else {
+			log.Printf("invalid MESSAGE_RATE_LIMIT: %q", limitEnv)
+		}
+	}
+
+	if limitEnv := strings.TrimSpace(os.Getenv("CONVERSATION_MESSAGE_RATE_LIMIT")); limitEnv != "" {
+		if limit, err := strconv.Atoi(limitEnv); err == nil && limit > 0 {
+			apiConfig.ConversationMessageRateLimit = limit
+		} else {
+			log.Printf("invalid CONVERSATION_MESSAGE_RATE_LIMIT: %q", limitEnv)
+		}
+	}
+
+	if clientsEnv := strings.TrimSpace(os.Getenv("SSE_MAX_CLIENTS_PER_USER")); clientsEnv != "" {
+		if clients, err := strconv.Atoi(clientsEnv); err == nil && clients > 0 {
+			apiConfig.SSEMaxClientsPerUser = clients
+		} else {
+			log.Printf("invalid SSE_MAX_CLIENTS_PER_USER: %q", clientsEnv)
+		}
+	}
+
+	if sizeEnv := strings.TrimSpace(os.Getenv("MAX_JSON_BODY_SIZE")); sizeEnv != "" {
+		if size, err := strconv.ParseInt(sizeEnv, 10, 64); err == nil && size > 0 {
+			apiConfig.MaxJSONBodySize = size
+		} else {
+			log.Printf("invalid MAX_JSON_BODY_SIZE: %q", sizeEnv)
+		}
+	}
+
+	if sizeEnv := strings.TrimSpace(os.Getenv("MAX_UPLOAD_BODY_SIZE")); sizeEnv != "" {
+		if size, err := strconv.ParseInt(sizeEnv, 10, 64); err == nil && size > 0 {
+			apiConfig.MaxUploadBodySize = size
+		} else {
+			log.Printf("invalid MAX_UPLOAD_BODY_SIZE: %q", sizeEnv)
+		}
+	}
+
+	if lengthEnv := strings.TrimSpace(os.Getenv("MAX_MESSAGE_LENGTH")); lengthEnv != "" {
+		if length, err := strconv.Atoi(lengthEnv); err == nil && length > 0 {
+			apiConfig.MaxMessageLength = length
+		} else {
+			log.Printf("invalid MAX_MESSAGE_LENGTH: %q", lengthEnv)
+		}
+	}
+
+	if limitEnv := strings.TrimSpace(os.Getenv("INVITATIONS_PER_USER")); limitEnv != "" {
+		if limit, err := strconv.Atoi(limitEnv); err == nil && limit > 0 {
+			apiConfig.InvitationsPerUser = limit
+		} else {
+			log.Printf("invalid INVITATIONS_PER_USER: %q", limitEnv)
+		}
+	}
+
+	if sizeEnv := strings.TrimSpace(os.Getenv("DECRYPT_CACHE_SIZE")); sizeEnv != "" {
+		if size, err := strconv.Atoi(sizeEnv); err == nil && size > 0 {
+			apiConfig.DecryptCacheSize = size
+		} else {
+			log.Printf("invalid DECRYPT_CACHE_SIZE: %q", sizeEnv)
+		}
+	}
+
+	if pinsEnv := strings.TrimSpace(os.Getenv("MAX_PINNED_CONVERSATIONS")); pinsEnv != "" {
+		if pins, err := strconv.Atoi(pinsEnv); err == nil && pins > 0 {
+			apiConfig.MaxPinnedConversations = pins
+		} else {
+			log.Printf("invalid MAX_PINNED_CONVERSATIONS: %q", pinsEnv)
+		}
+	}
+
+	if sampleEnv := strings.TrimSpace(os.Getenv("MESSAGE_INTEGRITY_SAMPLE_SIZE")); sampleEnv != "" {
+		if sample, err := strconv.Atoi(sampleEnv); err == nil && sample > 0 {
+			apiConfig.IntegritySampleSize = sample
+		} else {
+			log.Printf("invalid MESSAGE_INTEGRITY_SAMPLE_SIZE: %q", sampleEnv)
+		}
+	}
+
+	apiConfig.Storage = objectStorage
+	apiConfig.ReadQueries = readDatabase
+	apiConfig.GroupCallsDisabled = !boolFromEnv("GROUP_CALLS_ENABLED", true)
+	apiConfig.FileUploadsDisabled = !boolFromEnv("FILE_UPLOADS_ENABLED", true)
+	apiConfig.MarkdownDisabled = !boolFromEnv("MARKDOWN_ENABLED", true)
+
+	server := api.New(database, turnServer.Config(), apiConfig)
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "teamsync_active_http_connections",
+		Help: "Open connections of the HTTP API, excluding WebSockets.",
+	}, func() float64 {
+		return float64(server.ActiveConnections())
+	})
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "teamsync_message_integrity_failures_total",
+		Help: "Stored messages whose ciphertext failed authentication.",
+	}, func() float64 {
+		return float64(server.IntegrityFailures())
+	})
+	// db.Init has applied all migrations at this point.
+	server.SetReady(true)
 	defer func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -85,11 +325,234 @@ func main() {
 		}
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
-	<-quit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	waitForShutdown(signals, func() { reloadTURN(turnServer, turnConfig, server) })
 
 	log.Printf("shutdown signal received")
+	server.SetReady(false)
+}
+
+// waitForShutdown calls reload for every SIGHUP and returns on any other
+// signal.
+func waitForShutdown(signals <-chan os.Signal, reload func()) {
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			return
+		}
+		reload()
+	}
+}
+
+// reloadTURN restarts the TURN server with a freshly resolved relay address
+// while the HTTP API keeps serving.
+func reloadTURN(turnServer *rtc.Server, cfg rtc.Config, server *api.Server) {
+	log.Printf("reloading TURN server")
+
+	cfg.RelayAddress = nil
+	if err := turnServer.Reload(cfg); err != nil {
+		log.Printf("failed to reload TURN server: %v", err)
+	}
+	server.SetTURNConfig(turnServer.Config())
+}
+
+// pruneExpiredTokens deletes tokens whose refresh token has expired, once at
+// startup and then every interval until ctx is cancelled.
+func pruneExpiredTokens(ctx context.Context, queries *db.Queries, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Expiry times are stored in the server's time zone, so the cutoff is
+		// passed from Go instead of comparing against CURRENT_TIMESTAMP.
+		pruned, err := queries.DeleteExpiredTokens(ctx, time.Now())
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("failed to prune expired tokens: %v", err)
+			}
+		} else if pruned > 0 {
+			tokensPruned.Add(float64(pruned))
+			log.Printf("pruned %d expired tokens", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkpointDatabase truncates the WAL every interval until ctx is cancelled.
+// Checkpoints are skipped while the writer or the readers use the database.
+func checkpointDatabase(ctx context.Context, queries, readQueries *db.Queries, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result, err := queries.Checkpoint(ctx, readQueries)
+		if errors.Is(err, db.ErrDatabaseBusy) {
+			slog.Debug("database busy, skipped WAL checkpoint")
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("failed to checkpoint database: %v", err)
+			}
+			continue
+		}
+		slog.Debug("checkpointed database WAL",
+			"checkpointedFrames", result.CheckpointedFrames,
+			"walFrames", result.WALFrames,
+			"busy", result.Busy,
+		)
+	}
+}
+
+// cleanupOrphanedProfileImages deletes profile images no user refers to
+// weekly until ctx is cancelled. The run at startup only logs what would be
+// deleted, so that unexpected candidates show up before anything is lost;
+// with dryRun set every run does.
+func cleanupOrphanedProfileImages(ctx context.Context, queries *db.Queries, backend storage.Backend, dryRun bool) {
+	ticker := time.NewTicker(profileImageCleanupInterval)
+	defer ticker.Stop()
+
+	firstRun := true
+	for {
+		logOnly := dryRun || firstRun
+		deleted, reclaimed, err := api.CleanupOrphanedProfileImages(ctx, queries, backend, logOnly)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("failed to clean up orphaned profile images: %v", err)
+			}
+		} else if deleted > 0 {
+			if logOnly {
+				log.Printf("found %d orphaned profile images (%d bytes), not deleting them in this run", deleted, reclaimed)
+			} else {
+				log.Printf("deleted %d orphaned profile images, reclaimed %d bytes", deleted, reclaimed)
+			}
+		}
+		firstRun = false
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func rollbackMigrations(target string) error {
+	database, err := db.Open("data/teamsync.db")
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	return db.RollbackTo(database, target)
+}
+
+func durationFromEnv(name string) time.Duration {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid %s: %q", name, value)
+		return 0
+	}
+	return duration
+}
+
+// firstDurationFromEnv returns the duration of the first of names that is
+// set, for variables that were renamed.
+func firstDurationFromEnv(names ...string) time.Duration {
+	for _, name := range names {
+		if duration := durationFromEnv(name); duration != 0 {
+			return duration
+		}
+	}
+	return 0
+}
+
+func boolFromEnv(name string, fallback bool) bool {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("invalid %s: %q", name, value)
+		return fallback
+	}
+	return enabled
+}
+
+// listFromEnv splits a comma-separated environment variable, dropping empty
+// entries.
+func listFromEnv(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// newStorageBackend returns the object storage selected by STORAGE_BACKEND,
+// local files unless it is set to s3.
+func newStorageBackend(ctx context.Context) (storage.Backend, error) {
+	switch backend := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND"))); backend {
+	case "", "local":
+		return storage.NewLocalBackend(storage.DefaultLocalDir), nil
+	case "s3":
+		prefix, ok := os.LookupEnv("S3_PREFIX")
+		if !ok {
+			prefix = defaultS3Prefix
+		}
+		return storage.NewS3Backend(ctx, storage.S3Config{
+			Bucket:   strings.TrimSpace(os.Getenv("S3_BUCKET")),
+			Endpoint: strings.TrimSpace(os.Getenv("S3_ENDPOINT")),
+			Region:   strings.TrimSpace(os.Getenv("S3_REGION")),
+			Prefix:   strings.TrimSpace(prefix),
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// checkEncryptionKey decrypts the key canary of the database, so that a wrong
+// key fails at startup. A database without a canary gets one encrypted with
+// the current key.
+func checkEncryptionKey(queries *db.Queries) error {
+	ctx := context.Background()
+
+	canary, err := queries.GetEncryptionKeyCanary(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		canary, err = crypto.NewKeyCanary()
+		if err != nil {
+			return fmt.Errorf("failed to encrypt key canary: %w", err)
+		}
+		if err := queries.CreateEncryptionKeyCanary(ctx, canary); err != nil {
+			return fmt.Errorf("failed to store key canary: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load key canary: %w", err)
+	}
+
+	return crypto.VerifyKeyCanary(canary)
 }
 
 func ensureInitialInvitation(queries *db.Queries) error {
@@ -106,7 +569,7 @@ func ensureInitialInvitation(queries *db.Queries) error {
 			return fmt.Errorf("failed to generate invitation code: %w", err)
 		}
 
-		_, err = queries.CreateInvitationCode(ctx, code, nil)
+		_, err = queries.CreateInvitationCode(ctx, code, nil, nil)
 		if err != nil {
 			return fmt.Errorf("failed to create invitation code: %w", err)
 		}
@@ -0,0 +1,185 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bloodmagesoftware/teamsync/api"
+	"github.com/bloodmagesoftware/teamsync/crypto"
+	"github.com/bloodmagesoftware/teamsync/db"
+	"github.com/bloodmagesoftware/teamsync/rtc"
+)
+
+func TestSIGHUPKeepsInFlightRequests(t *testing.T) {
+	t.Setenv("TURN_RELAY_IP", "127.0.0.1")
+
+	queries, err := db.Init(filepath.Join(t.TempDir(), "teamsync.db"))
+	if err != nil {
+		t.Fatalf("db.Init: %v", err)
+	}
+	t.Cleanup(func() { queries.Close() })
+
+	turnConfig := rtc.Config{ListenAddress: "127.0.0.1:0"}
+	turnServer, err := rtc.NewServer(queries, turnConfig, nil)
+	if err != nil {
+		t.Fatalf("rtc.NewServer: %v", err)
+	}
+	t.Cleanup(func() { turnServer.Close() })
+
+	server := api.New(queries, turnServer.Config(), api.Config{ListenAddress: "127.0.0.1:0"})
+	started := make(chan error, 1)
+	go func() { started <- server.Start() }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		if err := <-started; err != nil {
+			t.Errorf("Start: %v", err)
+		}
+	})
+
+	addr := server.Addr()
+	for deadline := time.Now().Add(2 * time.Second); ; addr = server.Addr() {
+		if _, port, _ := net.SplitHostPort(addr); port != "0" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The request body is sent in two parts, so the login handler is still
+	// reading it while the TURN server reloads.
+	body, bodyWriter := io.Pipe()
+	type result struct {
+		status int
+		err    error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Post("http://"+addr+"/api/v1/auth/login", "application/json", body)
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		resp.Body.Close()
+		responses <- result{status: resp.StatusCode}
+	}()
+	if _, err := io.WriteString(bodyWriter, `{"username":"alice",`); err != nil {
+		t.Fatalf("writing the first part of the body: %v", err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); server.ActiveConnections() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("request did not reach the server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	reloaded := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		waitForShutdown(signals, func() {
+			reloadTURN(turnServer, turnConfig, server)
+			reloaded <- struct{}{}
+		})
+	}()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("sending SIGHUP: %v", err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("TURN server was not reloaded")
+	}
+
+	if _, err := io.WriteString(bodyWriter, `"password":"wrong"}`); err != nil {
+		t.Fatalf("writing the rest of the body: %v", err)
+	}
+	bodyWriter.Close()
+
+	select {
+	case res := <-responses:
+		if res.err != nil {
+			t.Fatalf("in-flight request failed: %v", res.err)
+		}
+		if res.status != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", res.status, http.StatusUnauthorized)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight request did not complete")
+	}
+
+	signals <- syscall.SIGTERM
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("waitForShutdown did not return on SIGTERM")
+	}
+}
+
+func TestCheckEncryptionKey(t *testing.T) {
+	t.Setenv("TEAMSYNC_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err := crypto.InitializeEncryption(); err != nil {
+		t.Fatalf("InitializeEncryption: %v", err)
+	}
+
+	queries, err := db.Init(filepath.Join(t.TempDir(), "teamsync.db"))
+	if err != nil {
+		t.Fatalf("db.Init: %v", err)
+	}
+	t.Cleanup(func() { queries.Close() })
+
+	// The first start stores the canary, later ones decrypt it.
+	for range 2 {
+		if err := checkEncryptionKey(queries); err != nil {
+			t.Fatalf("checkEncryptionKey: %v", err)
+		}
+	}
+
+	other, err := db.Init(filepath.Join(t.TempDir(), "teamsync.db"))
+	if err != nil {
+		t.Fatalf("db.Init: %v", err)
+	}
+	t.Cleanup(func() { other.Close() })
+
+	// A canary of a key that differs in a single bit.
+	key := make([]byte, 32)
+	key[0] = 1
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	sealed := gcm.Seal(nonce, nonce, []byte("teamsync encryption key canary"), []byte("conv:0"))
+	if err := other.CreateEncryptionKeyCanary(context.Background(), base64.StdEncoding.EncodeToString(sealed)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkEncryptionKey(other); !errors.Is(err, crypto.ErrWrongKey) {
+		t.Errorf("checkEncryptionKey with the canary of another key = %v, want ErrWrongKey", err)
+	}
+}
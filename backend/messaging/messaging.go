@@ -0,0 +1,61 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package messaging
+
+// Content types of messages. Users may send the ones accepted by
+// ValidateUserContentType; the others are only created by the server.
+const (
+	ContentTypeMarkdown = "text/markdown"
+	ContentTypePlain    = "text/plain"
+	ContentTypePoll     = "application/poll"
+	ContentTypeCall     = "application/call"
+	ContentTypeSystem   = "application/system"
+)
+
+// ValidateUserContentType reports whether clients may send messages of
+// content type ct. New user-selectable content types have to be added here.
+func ValidateUserContentType(ct string) bool {
+	switch ct {
+	case ContentTypeMarkdown, ContentTypePlain, ContentTypePoll:
+		return true
+	default:
+		return false
+	}
+}
+
+// StoredEncrypted reports whether bodies of content type ct are encrypted
+// before they are stored. This is the case for all content types users send.
+func StoredEncrypted(ct string) bool {
+	return ValidateUserContentType(ct)
+}
+
+// Actions of system messages.
+const (
+	ActionMemberAdded         = "member_added"
+	ActionMemberRemoved       = "member_removed"
+	ActionConversationRenamed = "conversation_renamed"
+)
+
+// SystemEvent is the body of an application/system message. System messages
+// record changes to a conversation in its history and are stored
+// unencrypted.
+type SystemEvent struct {
+	Action         string `json:"action"`
+	ActorID        int64  `json:"actorId"`
+	TargetID       int64  `json:"targetId,omitempty"`
+	TargetUsername string `json:"targetUsername,omitempty"`
+	Name           string `json:"name,omitempty"`
+}
+
+// Summary describes the event in a short sentence, without the actor.
+func (e SystemEvent) Summary() string {
+	switch e.Action {
+	case ActionMemberAdded:
+		return e.TargetUsername + " was added"
+	case ActionMemberRemoved:
+		return e.TargetUsername + " was removed"
+	case ActionConversationRenamed:
+		return "Conversation renamed to " + e.Name
+	default:
+		return "Conversation changed"
+	}
+}
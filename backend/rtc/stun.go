@@ -0,0 +1,74 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package rtc
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	"github.com/pion/stun/v2"
+)
+
+// stunServer answers STUN binding requests on a UDP socket. It is used in
+// ModeSTUNOnly, where clients only need to learn their public address and no
+// traffic is relayed.
+type stunServer struct {
+	conn   net.PacketConn
+	logger *log.Logger
+	done   chan struct{}
+}
+
+func newSTUNServer(conn net.PacketConn, logger *log.Logger) *stunServer {
+	s := &stunServer{conn: conn, logger: logger, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *stunServer) serve() {
+	defer close(s.done)
+
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Printf("STUN read failed: %v", err)
+			continue
+		}
+
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+
+		request := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if err := request.Decode(); err != nil || request.Type != stun.BindingRequest {
+			continue
+		}
+
+		response, err := stun.Build(
+			stun.NewTransactionIDSetter(request.TransactionID),
+			stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port},
+			stun.Fingerprint,
+		)
+		if err != nil {
+			s.logger.Printf("STUN response for %s failed: %v", addr, err)
+			continue
+		}
+
+		if _, err := s.conn.WriteTo(response.Raw, addr); err != nil {
+			s.logger.Printf("STUN response to %s failed: %v", addr, err)
+		}
+	}
+}
+
+// Close stops answering requests and waits for the serving goroutine.
+func (s *stunServer) Close() error {
+	err := s.conn.Close()
+	<-s.done
+	return err
+}
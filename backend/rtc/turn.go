@@ -12,12 +12,14 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bloodmagesoftware/teamsync/db"
 	"github.com/pion/ice/v2"
 	"github.com/pion/stun/v2"
 	"github.com/pion/turn/v4"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -25,6 +27,18 @@ const (
 	defaultUsernamePrefix = "teamsync:"
 	defaultListenAddress  = ":3478"
 	turnAuthTimeout       = 2 * time.Second
+	healthTimeout         = 2 * time.Second
+	defaultAuthRateLimit  = 10
+	authLimiterIdleTTL    = 5 * time.Minute
+	authLimiterPruneTick  = time.Minute
+)
+
+// Modes of the embedded server.
+const (
+	// ModeTURN serves STUN and relays traffic through TURN allocations.
+	ModeTURN = "turn"
+	// ModeSTUNOnly only answers STUN binding requests over UDP.
+	ModeSTUNOnly = "stun-only"
 )
 
 // Config controls the embedded TURN/STUN server behaviour.
@@ -33,14 +47,39 @@ type Config struct {
 	Realm          string
 	UsernamePrefix string
 	RelayAddress   net.IP
+	// AuthRateLimit is the number of failed authentication attempts a
+	// client address may make per minute.
+	AuthRateLimit int
+	// Mode is ModeTURN or ModeSTUNOnly; empty means ModeTURN.
+	Mode string
+	// RelayPortMin and RelayPortMax limit the ports of relay allocations,
+	// both inclusive. Relays use ephemeral ports of the OS when both are
+	// zero.
+	RelayPortMin uint16
+	RelayPortMax uint16
 }
 
 // Server hosts TURN (and by extension STUN) services for the application.
 type Server struct {
+	queries *db.Queries
+	logger  *log.Logger
+
+	mu         sync.Mutex
 	turnServer *turn.Server
-	logger     *log.Logger
-	closeOnce  sync.Once
+	stunServer *stunServer
 	config     Config
+
+	// authLimiters holds an *authLimiter per client IP address. They outlive
+	// reloads, so a reload does not reset the quota of a client.
+	authLimiters   sync.Map
+	authRejections atomic.Int64
+	stopPruning    chan struct{}
+	stopOnce       sync.Once
+}
+
+type authLimiter struct {
+	limiter    *rate.Limiter
+	lastAccess atomic.Int64
 }
 
 // NewServer creates and starts a TURN server that shares credentials with the
@@ -54,6 +93,71 @@ func NewServer(queries *db.Queries, cfg Config, logger *log.Logger) (*Server, er
 		logger = log.Default()
 	}
 
+	s := &Server{queries: queries, logger: logger, stopPruning: make(chan struct{})}
+	if err := s.start(cfg); err != nil {
+		return nil, err
+	}
+	go s.pruneAuthLimiters()
+	return s, nil
+}
+
+// authLimiterFor returns the limiter of failed authentication attempts for
+// the IP address of srcAddr, allowing perMinute of them.
+func (s *Server) authLimiterFor(srcAddr net.Addr, perMinute int) *authLimiter {
+	key := srcAddr.String()
+	if host, _, err := net.SplitHostPort(key); err == nil {
+		key = host
+	}
+
+	value, ok := s.authLimiters.Load(key)
+	if !ok {
+		entry := &authLimiter{
+			limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute),
+		}
+		value, _ = s.authLimiters.LoadOrStore(key, entry)
+	}
+
+	entry := value.(*authLimiter)
+	entry.lastAccess.Store(time.Now().UnixNano())
+	return entry
+}
+
+// pruneAuthLimiters drops the limiters of clients that did not try to
+// authenticate for authLimiterIdleTTL until the server is closed.
+func (s *Server) pruneAuthLimiters() {
+	ticker := time.NewTicker(authLimiterPruneTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopPruning:
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-authLimiterIdleTTL).UnixNano()
+			s.authLimiters.Range(func(key, value any) bool {
+				if value.(*authLimiter).lastAccess.Load() < cutoff {
+					s.authLimiters.Delete(key)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// AuthRejections returns the number of rejected authentication attempts,
+// including rate limited ones, since the server was created.
+func (s *Server) AuthRejections() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.authRejections.Load()
+}
+
+// start creates the TURN server for cfg and stores it together with the
+// effective configuration. The caller must hold s.mu or own s exclusively.
+func (s *Server) start(cfg Config) error {
+	queries, logger := s.queries, s.logger
+
 	listenAddress := cfg.ListenAddress
 	if listenAddress == "" {
 		listenAddress = defaultListenAddress
@@ -69,46 +173,99 @@ func NewServer(queries *db.Queries, cfg Config, logger *log.Logger) (*Server, er
 		usernamePrefix = defaultUsernamePrefix
 	}
 
+	authRateLimit := cfg.AuthRateLimit
+	if authRateLimit <= 0 {
+		authRateLimit = defaultAuthRateLimit
+	}
+
+	mode := cfg.Mode
+	if mode == "" {
+		mode = ModeTURN
+	}
+	if mode != ModeTURN && mode != ModeSTUNOnly {
+		return fmt.Errorf("turn: unknown mode %q", mode)
+	}
+
+	portRangeSet := cfg.RelayPortMin != 0 || cfg.RelayPortMax != 0
+	if portRangeSet && (cfg.RelayPortMin == 0 || cfg.RelayPortMax == 0 || cfg.RelayPortMin > cfg.RelayPortMax) {
+		return fmt.Errorf("turn: invalid relay port range %d-%d", cfg.RelayPortMin, cfg.RelayPortMax)
+	}
+
+	// Clients reach the server at the relay address in both modes.
 	relayIP, err := resolveRelayIP(cfg.RelayAddress)
 	if err != nil {
-		return nil, fmt.Errorf("turn: resolve relay IP: %w", err)
+		return fmt.Errorf("turn: resolve relay IP: %w", err)
 	}
 
 	udpAddr, err := net.ResolveUDPAddr("udp", listenAddress)
 	if err != nil {
-		return nil, fmt.Errorf("turn: resolve udp listen address: %w", err)
+		return fmt.Errorf("turn: resolve udp listen address: %w", err)
+	}
+
+	effective := Config{
+		ListenAddress:  listenAddress,
+		Realm:          realm,
+		UsernamePrefix: usernamePrefix,
+		RelayAddress:   relayIP,
+		AuthRateLimit:  authRateLimit,
+		Mode:           mode,
+		RelayPortMin:   cfg.RelayPortMin,
+		RelayPortMax:   cfg.RelayPortMax,
+	}
+
+	if mode == ModeSTUNOnly {
+		packetConn, err := net.ListenUDP("udp", udpAddr)
+		if err != nil {
+			return fmt.Errorf("turn: udp listen failed: %w", err)
+		}
+
+		logger.Printf("STUN server ready on %s (relay disabled, address=%s)", listenAddress, relayIP.String())
+
+		s.stunServer = newSTUNServer(packetConn, logger)
+		s.config = effective
+		return nil
 	}
 
 	tcpAddr, err := net.ResolveTCPAddr("tcp", listenAddress)
 	if err != nil {
-		return nil, fmt.Errorf("turn: resolve tcp listen address: %w", err)
+		return fmt.Errorf("turn: resolve tcp listen address: %w", err)
 	}
 
 	packetConn, err := net.ListenUDP("udp", udpAddr)
 	if err != nil {
-		return nil, fmt.Errorf("turn: udp listen failed: %w", err)
+		return fmt.Errorf("turn: udp listen failed: %w", err)
 	}
 
 	listener, err := net.ListenTCP("tcp", tcpAddr)
 	if err != nil {
 		packetConn.Close()
-		return nil, fmt.Errorf("turn: tcp listen failed: %w", err)
+		return fmt.Errorf("turn: tcp listen failed: %w", err)
 	}
 
-	relayGenerator := &turn.RelayAddressGeneratorStatic{
+	relayBindAddress := udpAddr.IP.String()
+	if udpAddr.IP == nil || udpAddr.IP.IsUnspecified() {
+		relayBindAddress = "::"
+		if relayIP.To4() != nil {
+			relayBindAddress = "0.0.0.0"
+		}
+	}
+
+	var relayGenerator turn.RelayAddressGenerator = &turn.RelayAddressGeneratorStatic{
 		RelayAddress: relayIP,
-		Address: func() string {
-			if udpAddr.IP == nil || udpAddr.IP.IsUnspecified() {
-				if relayIP.To4() != nil {
-					return "0.0.0.0"
-				}
-				return "::"
-			}
-			return udpAddr.IP.String()
-		}(),
+		Address:      relayBindAddress,
+	}
+	relayPorts := "ephemeral"
+	if portRangeSet {
+		relayGenerator = &turn.RelayAddressGeneratorPortRange{
+			RelayAddress: relayIP,
+			MinPort:      cfg.RelayPortMin,
+			MaxPort:      cfg.RelayPortMax,
+			Address:      relayBindAddress,
+		}
+		relayPorts = fmt.Sprintf("%d-%d", cfg.RelayPortMin, cfg.RelayPortMax)
 	}
 
-	authHandler := func(username, realmParam string, srcAddr net.Addr) ([]byte, bool) {
+	authenticate := func(username, realmParam string, srcAddr net.Addr) ([]byte, bool) {
 		if realmParam != realm {
 			logger.Printf("TURN auth rejected for %s: unexpected realm %s", srcAddr, realmParam)
 			return nil, false
@@ -147,6 +304,25 @@ func NewServer(queries *db.Queries, cfg Config, logger *log.Logger) (*Server, er
 		return key, true
 	}
 
+	// The handler runs for every authenticated request of an allocation, so
+	// only failed attempts count against the limit. Once it is exceeded the
+	// client is rejected before its token is looked up.
+	authHandler := func(username, realmParam string, srcAddr net.Addr) ([]byte, bool) {
+		limiter := s.authLimiterFor(srcAddr, authRateLimit).limiter
+		if limiter.Tokens() < 1 {
+			s.authRejections.Add(1)
+			logger.Printf("TURN auth rejected for %s: too many failed attempts", srcAddr)
+			return nil, false
+		}
+
+		key, ok := authenticate(username, realmParam, srcAddr)
+		if !ok {
+			limiter.Allow()
+			s.authRejections.Add(1)
+		}
+		return key, ok
+	}
+
 	turnServer, err := turn.NewServer(turn.ServerConfig{
 		Realm:       realm,
 		AuthHandler: authHandler,
@@ -166,7 +342,7 @@ func NewServer(queries *db.Queries, cfg Config, logger *log.Logger) (*Server, er
 	if err != nil {
 		listener.Close()
 		packetConn.Close()
-		return nil, fmt.Errorf("turn: create server: %w", err)
+		return fmt.Errorf("turn: create server: %w", err)
 	}
 
 	ipFamily := relayIP.To4()
@@ -179,38 +355,150 @@ func NewServer(queries *db.Queries, cfg Config, logger *log.Logger) (*Server, er
 
 	transactionID := stun.NewTransactionID()
 	logger.Printf(
-		"TURN server ready on %s (realm=%s, relay=%s, network=%s, tid=%x)",
+		"TURN server ready on %s (realm=%s, relay=%s, ports=%s, network=%s, tid=%x)",
 		listenAddress,
 		realm,
 		relayIP.String(),
+		relayPorts,
 		networkType.String(),
 		transactionID,
 	)
 
-	return &Server{turnServer: turnServer, logger: logger, config: Config{
-		ListenAddress:  listenAddress,
-		Realm:          realm,
-		UsernamePrefix: usernamePrefix,
-		RelayAddress:   relayIP,
-	}}, nil
+	s.turnServer = turnServer
+	s.config = effective
+	return nil
+}
+
+// running reports whether a TURN or STUN server is running. The caller must
+// hold s.mu.
+func (s *Server) running() bool {
+	return s.turnServer != nil || s.stunServer != nil
+}
+
+// stop closes the running TURN or STUN server. The caller must hold s.mu.
+func (s *Server) stop() error {
+	var err error
+	if s.turnServer != nil {
+		err = s.turnServer.Close()
+		s.turnServer = nil
+	}
+	if s.stunServer != nil {
+		err = s.stunServer.Close()
+		s.stunServer = nil
+	}
+	return err
+}
+
+// Reload closes the running TURN server and starts a new one with cfg. An
+// empty RelayAddress is resolved again, so a changed host address is picked
+// up. Allocations of calls in progress are dropped. When cfg fails to start,
+// the previous configuration is restored and the error is returned.
+func (s *Server) Reload(cfg Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.config
+	if err := s.stop(); err != nil {
+		s.logger.Printf("TURN close before reload failed: %v", err)
+	}
+
+	if err := s.start(cfg); err != nil {
+		if restoreErr := s.start(previous); restoreErr != nil {
+			return fmt.Errorf("%w; restoring previous configuration failed: %v", err, restoreErr)
+		}
+		return err
+	}
+	return nil
 }
 
 // Close stops the TURN server and releases listeners.
 func (s *Server) Close() error {
-	var closeErr error
-	s.closeOnce.Do(func() {
-		if s.turnServer != nil {
-			closeErr = s.turnServer.Close()
-		}
-	})
-	return closeErr
+	if s == nil {
+		return nil
+	}
+
+	s.stopOnce.Do(func() { close(s.stopPruning) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stop()
 }
 
-// Config returns the effective TURN/STUN configuration in use.
+// Config returns the effective TURN/STUN configuration in use, or the zero
+// Config when the server is not running.
 func (s *Server) Config() Config {
+	if s == nil {
+		return Config{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running() {
+		return Config{}
+	}
 	return s.config
 }
 
+// Health sends a STUN binding request to the UDP listener and returns an error
+// unless it is answered within healthTimeout.
+func (s *Server) Health() error {
+	if s == nil {
+		return errors.New("turn: server not started")
+	}
+
+	s.mu.Lock()
+	running := s.running()
+	listenAddress := s.config.ListenAddress
+	s.mu.Unlock()
+
+	if !running {
+		return errors.New("turn: server not running")
+	}
+
+	host, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return fmt.Errorf("turn: invalid listen address: %w", err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+		if ip != nil && ip.To4() == nil {
+			host = "::1"
+		}
+	}
+
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(host, port), healthTimeout)
+	if err != nil {
+		return fmt.Errorf("turn: dial: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(healthTimeout)); err != nil {
+		return fmt.Errorf("turn: set deadline: %w", err)
+	}
+
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	if _, err := conn.Write(request.Raw); err != nil {
+		return fmt.Errorf("turn: send binding request: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return fmt.Errorf("turn: no binding response: %w", err)
+	}
+
+	response := &stun.Message{Raw: buf[:n]}
+	if err := response.Decode(); err != nil {
+		return fmt.Errorf("turn: invalid binding response: %w", err)
+	}
+	if response.TransactionID != request.TransactionID {
+		return errors.New("turn: binding response for another transaction")
+	}
+	return nil
+}
+
 func resolveRelayIP(provided net.IP) (net.IP, error) {
 	if provided != nil {
 		return provided, nil
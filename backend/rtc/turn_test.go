@@ -0,0 +1,123 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+
+package rtc
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/bloodmagesoftware/teamsync/db"
+)
+
+// freeListenAddress returns a loopback address whose port is free for both
+// TCP and UDP, as the TURN server listens on both.
+func freeListenAddress(t *testing.T) string {
+	t.Helper()
+	for range 10 {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+
+		packetConn, err := net.ListenPacket("udp", "127.0.0.1:"+strconv.Itoa(port))
+		if err != nil {
+			continue
+		}
+		packetConn.Close()
+		return "127.0.0.1:" + strconv.Itoa(port)
+	}
+	t.Fatal("no free port")
+	return ""
+}
+
+func newTestServer(t *testing.T, cfg Config) *Server {
+	t.Helper()
+	queries, err := db.Init(filepath.Join(t.TempDir(), "teamsync.db"))
+	if err != nil {
+		t.Fatalf("db.Init: %v", err)
+	}
+	t.Cleanup(func() { queries.Close() })
+
+	s, err := NewServer(queries, cfg, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestReloadResolvesRelayAddressAgain(t *testing.T) {
+	t.Setenv("TURN_RELAY_IP", "127.0.0.2")
+	cfg := Config{ListenAddress: freeListenAddress(t)}
+	s := newTestServer(t, cfg)
+
+	if got := s.Config().RelayAddress.String(); got != "127.0.0.2" {
+		t.Fatalf("relay address = %s, want 127.0.0.2", got)
+	}
+	if err := s.Health(); err != nil {
+		t.Fatalf("Health before reload: %v", err)
+	}
+
+	t.Setenv("TURN_RELAY_IP", "127.0.0.3")
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := s.Config().RelayAddress.String(); got != "127.0.0.3" {
+		t.Errorf("relay address after reload = %s, want 127.0.0.3", got)
+	}
+	if err := s.Health(); err != nil {
+		t.Errorf("Health after reload: %v", err)
+	}
+}
+
+func TestReloadSwitchesMode(t *testing.T) {
+	cfg := Config{ListenAddress: freeListenAddress(t), RelayAddress: net.ParseIP("127.0.0.1")}
+	s := newTestServer(t, cfg)
+
+	cfg.Mode = ModeSTUNOnly
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := s.Config().Mode; got != ModeSTUNOnly {
+		t.Errorf("mode = %q, want %q", got, ModeSTUNOnly)
+	}
+	if err := s.Health(); err != nil {
+		t.Errorf("Health in STUN-only mode: %v", err)
+	}
+}
+
+func TestReloadKeepsPreviousConfigOnError(t *testing.T) {
+	cfg := Config{ListenAddress: freeListenAddress(t), RelayAddress: net.ParseIP("127.0.0.1"), Realm: "before"}
+	s := newTestServer(t, cfg)
+
+	invalid := cfg
+	invalid.Realm = "after"
+	invalid.RelayPortMin = 50000
+	if err := s.Reload(invalid); err == nil {
+		t.Fatal("Reload accepted an invalid relay port range")
+	}
+
+	if got := s.Config().Realm; got != "before" {
+		t.Errorf("realm = %q, want the previous %q", got, "before")
+	}
+	if err := s.Health(); err != nil {
+		t.Errorf("Health after failed reload: %v", err)
+	}
+}
+
+func TestCloseStopsServer(t *testing.T) {
+	s := newTestServer(t, Config{ListenAddress: freeListenAddress(t), RelayAddress: net.ParseIP("127.0.0.1")})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := s.Health(); err == nil {
+		t.Error("Health succeeded after Close")
+	}
+	if got := s.Config(); got.ListenAddress != "" {
+		t.Errorf("Config after Close = %+v, want the zero Config", got)
+	}
+}
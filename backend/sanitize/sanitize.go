@@ -0,0 +1,183 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package sanitize
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var (
+	policy = bluemonday.UGCPolicy()
+
+	tagPattern      = regexp.MustCompile(`^</?[a-zA-Z][a-zA-Z0-9-]*(?:\s(?:[^>"']|"[^"]*"|'[^']*')*)?/?>`)
+	autolinkPattern = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}):[^\s<>]*>`)
+	fencePattern    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	escapePattern   = regexp.MustCompile(`\\([!-/:-@\[-` + "`" + `{-~])`)
+
+	inlineLinkPattern = regexp.MustCompile(`\]\(\s*(<[^<>\n]*>|[^\s()<>]+)`)
+	refLinkPattern    = regexp.MustCompile(`(?m)^ {0,3}\[[^\]\n]+\]:[ \t]*(<[^<>\n]*>|\S+)`)
+)
+
+// SanitizeMarkdown removes raw HTML that can run script from markdown source
+// and replaces link destinations with unsafe schemes such as javascript: by
+// "#". The result is still markdown. Fenced code blocks and code spans are
+// kept verbatim because renderers escape their content.
+func SanitizeMarkdown(body string) string {
+	var out, text strings.Builder
+	fence := ""
+
+	flush := func() {
+		out.WriteString(sanitizeInline(text.String()))
+		text.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if fence != "" {
+			out.WriteString(line)
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+
+		if match := fencePattern.FindStringSubmatch(line); match != nil {
+			flush()
+			fence = match[1]
+			out.WriteString(line)
+			continue
+		}
+
+		text.WriteString(line)
+	}
+	flush()
+
+	return out.String()
+}
+
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// sanitizeInline scans text left to right like a markdown renderer does, so
+// that a backtick inside an HTML attribute cannot hide the tag in a code span.
+func sanitizeInline(text string) string {
+	var out, pending strings.Builder
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '\\':
+			if i+1 < len(text) && isASCIIPunct(text[i+1]) {
+				pending.WriteString(text[i : i+2])
+				i += 2
+				continue
+			}
+
+		case '<':
+			if match := autolinkPattern.FindStringSubmatch(text[i:]); match != nil {
+				if safeScheme(match[1]) {
+					pending.WriteString(match[0])
+				}
+				i += len(match[0])
+				continue
+			}
+			if tag := tagPattern.FindString(text[i:]); tag != "" {
+				pending.WriteString(policy.Sanitize(tag))
+				i += len(tag)
+				continue
+			}
+
+		case '`':
+			n := runLength(text[i:], '`')
+			end := closingRun(text[i+n:], n)
+			if end < 0 {
+				pending.WriteString(text[i : i+n])
+				i += n
+				continue
+			}
+			out.WriteString(sanitizeLinks(pending.String()))
+			pending.Reset()
+			out.WriteString(text[i : i+n+end+n])
+			i += n + end + n
+			continue
+		}
+
+		pending.WriteByte(text[i])
+		i++
+	}
+	out.WriteString(sanitizeLinks(pending.String()))
+
+	return out.String()
+}
+
+// sanitizeLinks replaces unsafe destinations of inline links, images and
+// link reference definitions.
+func sanitizeLinks(text string) string {
+	for _, pattern := range []*regexp.Regexp{inlineLinkPattern, refLinkPattern} {
+		matches := pattern.FindAllStringSubmatchIndex(text, -1)
+		for i := len(matches) - 1; i >= 0; i-- {
+			start, end := matches[i][2], matches[i][3]
+			if !safeURL(text[start:end]) {
+				text = text[:start] + "#" + text[end:]
+			}
+		}
+	}
+	return text
+}
+
+func safeURL(dest string) bool {
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	dest = html.UnescapeString(escapePattern.ReplaceAllString(dest, "$1"))
+	dest = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, dest)
+
+	scheme, _, found := strings.Cut(dest, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	return safeScheme(scheme)
+}
+
+func safeScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+func isASCIIPunct(c byte) bool {
+	return c >= '!' && c <= '/' || c >= ':' && c <= '@' || c >= '[' && c <= '`' || c >= '{' && c <= '~'
+}
+
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// closingRun returns the offset of the next run of exactly n backticks in s
+// or -1 if the code span is not closed.
+func closingRun(s string, n int) int {
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		run := runLength(s[i:], '`')
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package sanitize
+
+import "testing"
+
+func TestSanitizeMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain markdown", "**bold** and _italic_", "**bold** and _italic_"},
+		{"script tag", "<script>alert(1)</script>", "alert(1)"},
+		{"img onerror", "<img src=x onerror=alert(1)>", `<img src="x">`},
+		{"event handler", `<div onclick="alert(1)">hi</div>`, "<div>hi</div>"},
+		{"iframe", `<iframe src="https://evil.example"></iframe>`, ""},
+		{"html javascript link", `<a href="javascript:alert(1)">x</a>`, "x</a>"},
+		{"inline javascript link", "[click](javascript:alert(1))", "[click](#(1))"},
+		{"mixed case scheme", "[click](JaVaScRiPt:alert(1))", "[click](#(1))"},
+		{"data image", "![img](data:text/html;base64,PHNjcmlwdD4=)", "![img](#)"},
+		{"reference definition", "[ref]: javascript:alert(1)", "[ref]: #"},
+		{"javascript autolink", "<javascript:alert(1)>", ""},
+		{"https link", "[site](https://example.com)", "[site](https://example.com)"},
+		{"https autolink", "<https://example.com>", "<https://example.com>"},
+		{"relative link", "[home](/conversations)", "[home](/conversations)"},
+		{"code span", "`<script>alert(1)</script>`", "`<script>alert(1)</script>`"},
+		{"fenced code", "```\n<script>alert(1)</script>\n```\n", "```\n<script>alert(1)</script>\n```\n"},
+		{"backtick inside attribute", "<img src=\"x` `<script>alert(1)</script>\"` `>", ""},
+		{"unclosed code span", "`<img src=x onerror=alert(1)>", "`<img src=\"x\">"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeMarkdown(tt.in); got != tt.want {
+				t.Errorf("SanitizeMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
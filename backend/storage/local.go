@@ -0,0 +1,117 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DefaultLocalDir is the directory of the local backend unless configured
+// otherwise.
+const DefaultLocalDir = "./data/objects"
+
+// LocalBackend stores every object as a file named after its key in a
+// directory, which is created on the first save.
+type LocalBackend struct {
+	dir string
+}
+
+// NewLocalBackend returns a backend storing its objects in dir.
+func NewLocalBackend(dir string) *LocalBackend {
+	return &LocalBackend{dir: dir}
+}
+
+func (b *LocalBackend) path(key string) (string, error) {
+	if !filepath.IsLocal(key) || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(b.dir, key), nil
+}
+
+func (b *LocalBackend) Save(ctx context.Context, key string, data []byte) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	return nil
+}
+
+func (b *LocalBackend) Load(ctx context.Context, key string) ([]byte, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	return data, nil
+}
+
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func (b *LocalBackend) Exists(ctx context.Context, key string) (bool, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return true, nil
+}
+
+// List returns the regular files of the directory.
+func (b *LocalBackend) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	objects := make([]Object, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, Object{Key: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return objects, nil
+}
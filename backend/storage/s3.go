@@ -0,0 +1,152 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Config selects the bucket of an S3Backend. Credentials are read the usual
+// AWS way, e.g. from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+type S3Config struct {
+	Bucket string
+	// Endpoint is the URL of an S3-compatible service such as MinIO. Empty
+	// means AWS. Custom endpoints are addressed path-style.
+	Endpoint string
+	Region   string
+	// Prefix is prepended to every key, e.g. "teamsync/", so that the bucket
+	// can be shared with other data. Listing requires a prefix.
+	Prefix string
+}
+
+// S3Backend stores every object under its prefixed key in an S3 bucket.
+type S3Backend struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Backend returns a backend storing its objects in the bucket selected
+// by cfg.
+func NewS3Backend(ctx context.Context, cfg S3Config) (*S3Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket is required")
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &S3Backend{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+func (b *S3Backend) key(key string) *string {
+	return aws.String(b.prefix + key)
+}
+
+func (b *S3Backend) Save(ctx context.Context, key string, data []byte) error {
+	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(b.bucket),
+		Key:           b.key(key),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	return nil
+}
+
+func (b *S3Backend) Load(ctx context.Context, key string) ([]byte, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    b.key(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	return data, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    b.key(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func (b *S3Backend) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    b.key(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to look up object: %w", err)
+	}
+	return true, nil
+}
+
+// List returns the objects below the prefix with the prefix removed from
+// their keys. Without a prefix it fails instead of listing a bucket that may
+// hold unrelated data.
+func (b *S3Backend) List(ctx context.Context) ([]Object, error) {
+	if b.prefix == "" {
+		return nil, errors.New("listing S3 objects requires a key prefix")
+	}
+
+	var objects []Object
+
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(b.prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, Object{
+				Key:     strings.TrimPrefix(aws.ToString(obj.Key), b.prefix),
+				Size:    aws.ToInt64(obj.Size),
+				ModTime: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
@@ -0,0 +1,23 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestS3BackendKeyPrefix(t *testing.T) {
+	b := &S3Backend{bucket: "shared", prefix: "teamsync/"}
+	if got := aws.ToString(b.key("abc.webp")); got != "teamsync/abc.webp" {
+		t.Errorf("key = %q, want %q", got, "teamsync/abc.webp")
+	}
+}
+
+func TestS3BackendListRequiresPrefix(t *testing.T) {
+	b := &S3Backend{bucket: "shared"}
+	if _, err := b.List(context.Background()); err == nil {
+		t.Fatal("List of an unprefixed bucket succeeded")
+	}
+}
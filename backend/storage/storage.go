@@ -0,0 +1,37 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by Load for keys without an object.
+var ErrNotFound = errors.New("object not found")
+
+// Backend stores binary objects such as profile images by key.
+type Backend interface {
+	// Save stores data under key, replacing an existing object.
+	Save(ctx context.Context, key string, data []byte) error
+	// Load returns the object stored under key or ErrNotFound.
+	Load(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the object stored under key. Deleting a missing object
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+	// Exists reports whether an object is stored under key.
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Lister is implemented by backends that can enumerate their objects, which
+// is needed to find objects nothing refers to anymore.
+type Lister interface {
+	List(ctx context.Context) ([]Object, error)
+}
@@ -12,14 +12,12 @@ import {
 export interface CallState {
 	ws: WebSocket | null;
 	isInitiator: boolean;
-	pc: RTCPeerConnection | null;
+	// peers holds one connection per other participant, keyed by user ID.
+	peers: Map<number, RTCPeerConnection>;
 	localStream: MediaStream | null;
 	localCameraStream: MediaStream | null;
 	screenTrack: MediaStreamTrack | null;
 	remoteStreams: MediaStream[];
-	pendingRemoteCandidates: RTCIceCandidateInit[];
-	pendingLocalCandidates: RTCIceCandidateInit[];
-	hasPeerJoined: boolean;
 	status: string;
 	username: string;
 	profileImageUrl: string | null;
@@ -102,15 +100,10 @@ export function CallProvider({ children }: { children: ReactNode }) {
 			} catch {}
 		}
 
-		if (state.pc) {
-			try {
-				state.pc.onicecandidate = null;
-				state.pc.ontrack = null;
-				state.pc.oniceconnectionstatechange = null;
-				state.pc.onconnectionstatechange = null;
-				state.pc.close();
-			} catch {}
+		for (const pc of state.peers.values()) {
+			closePeerConnection(pc);
 		}
+		state.peers.clear();
 
 		setActiveCall(null);
 	}, []);
@@ -145,14 +138,11 @@ export function CallProvider({ children }: { children: ReactNode }) {
 			const newCallState: CallState = {
 				ws: null,
 				isInitiator: true,
-				pc: null,
+				peers: new Map(),
 				localStream: null,
 				localCameraStream: null,
 				screenTrack: null,
 				remoteStreams: [],
-				pendingRemoteCandidates: [],
-				pendingLocalCandidates: [],
-				hasPeerJoined: false,
 				status: "Connecting...",
 				username: params.username,
 				profileImageUrl: params.profileImageUrl,
@@ -163,10 +153,9 @@ export function CallProvider({ children }: { children: ReactNode }) {
 
 			setActiveCall(newCallState);
 
-			const { ws, pc, localStream, localCameraStream, remoteStreams } =
+			const { ws, peers, localStream, localCameraStream, remoteStreams } =
 				await initializeCall(
 					messageId,
-					true,
 					updateStatus,
 					endCall,
 					forceUpdate,
@@ -174,7 +163,7 @@ export function CallProvider({ children }: { children: ReactNode }) {
 
 			setActiveCall((prev) =>
 				prev
-					? { ...prev, ws, pc, localStream, localCameraStream, remoteStreams }
+					? { ...prev, ws, peers, localStream, localCameraStream, remoteStreams }
 					: null,
 			);
 		},
@@ -194,14 +183,11 @@ export function CallProvider({ children }: { children: ReactNode }) {
 			const newCallState: CallState = {
 				ws: null,
 				isInitiator: false,
-				pc: null,
+				peers: new Map(),
 				localStream: null,
 				localCameraStream: null,
 				screenTrack: null,
 				remoteStreams: [],
-				pendingRemoteCandidates: [],
-				pendingLocalCandidates: [],
-				hasPeerJoined: false,
 				status: "Connecting...",
 				username: params.username,
 				profileImageUrl: params.profileImageUrl,
@@ -212,10 +198,9 @@ export function CallProvider({ children }: { children: ReactNode }) {
 
 			setActiveCall(newCallState);
 
-			const { ws, pc, localStream, localCameraStream, remoteStreams } =
+			const { ws, peers, localStream, localCameraStream, remoteStreams } =
 				await initializeCall(
 					params.messageId,
-					false,
 					updateStatus,
 					endCall,
 					forceUpdate,
@@ -223,7 +208,7 @@ export function CallProvider({ children }: { children: ReactNode }) {
 
 			setActiveCall((prev) =>
 				prev
-					? { ...prev, ws, pc, localStream, localCameraStream, remoteStreams }
+					? { ...prev, ws, peers, localStream, localCameraStream, remoteStreams }
 					: null,
 			);
 		},
@@ -260,32 +245,29 @@ export function CallProvider({ children }: { children: ReactNode }) {
 
 	const toggleScreenShare = useCallback(async () => {
 		const state = callStateRef.current;
-		if (!state?.pc || !state.localStream || !state.ws) return;
+		if (!state?.localStream || !state.ws) return;
+		const ws = state.ws;
+
+		const removeScreenTrack = async (screenTrack: MediaStreamTrack) => {
+			for (const [userId, pc] of state.peers) {
+				const screenSender = pc
+					.getSenders()
+					.find((s) => s.track === screenTrack);
+				if (!screenSender) {
+					continue;
+				}
+				pc.removeTrack(screenSender);
+				await sendRenegotiation(ws, pc, userId);
+			}
+		};
 
 		if (state.isScreenSharing && state.screenTrack) {
-			const senders = state.pc.getSenders();
-			const screenSender = senders.find((s) => s.track === state.screenTrack);
-
-			if (screenSender) {
-				state.pc.removeTrack(screenSender);
-			}
+			await removeScreenTrack(state.screenTrack);
 
 			if (state.screenTrack.readyState === "live") {
 				state.screenTrack.stop();
 			}
 
-			const offer = await state.pc.createOffer();
-			await state.pc.setLocalDescription(offer);
-			state.ws.send(
-				JSON.stringify({
-					type: "renegotiate",
-					payload: {
-						sdp: state.pc.localDescription?.sdp,
-						type: state.pc.localDescription?.type,
-					},
-				}),
-			);
-
 			setActiveCall((prev) =>
 				prev ? { ...prev, isScreenSharing: false, screenTrack: null } : null,
 			);
@@ -295,39 +277,13 @@ export function CallProvider({ children }: { children: ReactNode }) {
 			});
 			const screenTrack = screenStream.getVideoTracks()[0];
 
-			state.pc.addTrack(screenTrack, screenStream);
-
-			const offer = await state.pc.createOffer();
-			await state.pc.setLocalDescription(offer);
-			state.ws.send(
-				JSON.stringify({
-					type: "renegotiate",
-					payload: {
-						sdp: state.pc.localDescription?.sdp,
-						type: state.pc.localDescription?.type,
-					},
-				}),
-			);
+			for (const [userId, pc] of state.peers) {
+				pc.addTrack(screenTrack, screenStream);
+				await sendRenegotiation(ws, pc, userId);
+			}
 
 			screenTrack.onended = async () => {
-				const senders = state.pc!.getSenders();
-				const screenSender = senders.find((s) => s.track === screenTrack);
-
-				if (screenSender && state.ws) {
-					state.pc!.removeTrack(screenSender);
-
-					const offer = await state.pc!.createOffer();
-					await state.pc!.setLocalDescription(offer);
-					state.ws.send(
-						JSON.stringify({
-							type: "renegotiate",
-							payload: {
-								sdp: state.pc!.localDescription?.sdp,
-								type: state.pc!.localDescription?.type,
-							},
-						}),
-					);
-				}
+				await removeScreenTrack(screenTrack);
 
 				setActiveCall((prev) =>
 					prev ? { ...prev, isScreenSharing: false, screenTrack: null } : null,
@@ -360,13 +316,12 @@ export function CallProvider({ children }: { children: ReactNode }) {
 
 async function initializeCall(
 	messageId: number,
-	isInitiator: boolean,
 	updateStatus: (status: string) => void,
 	endCall: () => void,
 	forceUpdate: () => void,
 ): Promise<{
 	ws: WebSocket;
-	pc: RTCPeerConnection;
+	peers: Map<number, RTCPeerConnection>;
 	localStream: MediaStream;
 	localCameraStream: MediaStream;
 	remoteStreams: MediaStream[];
@@ -403,18 +358,13 @@ async function initializeCall(
 	const localCameraStream = localStream.clone();
 
 	const remoteStreamsArray: MediaStream[] = [];
-
-	const pc = new RTCPeerConnection({ iceServers });
-	(globalThis as unknown as { pc: RTCPeerConnection }).pc = pc;
-
-	for (const track of localStream.getTracks()) {
-		pc.addTrack(track, localStream);
-	}
+	const peers = new Map<number, RTCPeerConnection>();
 
 	const ws = await connectWebSocket(
 		messageId,
-		isInitiator,
-		pc,
+		iceServers,
+		localStream,
+		peers,
 		remoteStreamsArray,
 		updateStatus,
 		endCall,
@@ -423,13 +373,42 @@ async function initializeCall(
 
 	return {
 		ws,
-		pc,
+		peers,
 		localStream,
 		localCameraStream,
 		remoteStreams: remoteStreamsArray,
 	};
 }
 
+function closePeerConnection(pc: RTCPeerConnection) {
+	try {
+		pc.onicecandidate = null;
+		pc.ontrack = null;
+		pc.oniceconnectionstatechange = null;
+		pc.onconnectionstatechange = null;
+		pc.close();
+	} catch {}
+}
+
+async function sendRenegotiation(
+	ws: WebSocket,
+	pc: RTCPeerConnection,
+	to: number,
+) {
+	const offer = await pc.createOffer();
+	await pc.setLocalDescription(offer);
+	ws.send(
+		JSON.stringify({
+			type: "renegotiate",
+			to,
+			payload: {
+				sdp: pc.localDescription?.sdp,
+				type: pc.localDescription?.type,
+			},
+		}),
+	);
+}
+
 interface CallConfigResponse {
 	iceServers: { urls: string[] }[];
 	usernamePrefix: string;
@@ -522,10 +501,17 @@ function buildFallbackIceServers(
 	return servers;
 }
 
+interface PeerSession {
+	pc: RTCPeerConnection;
+	pendingRemoteCandidates: RTCIceCandidateInit[];
+	streams: MediaStream[];
+}
+
 function connectWebSocket(
 	messageId: number,
-	isInitiator: boolean,
-	pc: RTCPeerConnection,
+	iceServers: RTCIceServer[],
+	localStream: MediaStream,
+	peers: Map<number, RTCPeerConnection>,
 	remoteStreams: MediaStream[],
 	updateStatus: (status: string) => void,
 	endCall: () => void,
@@ -544,64 +530,27 @@ function connectWebSocket(
 		console.log("Creating WebSocket...");
 		const ws = new WebSocket(wsUrl);
 
-		const state = {
-			hasPeerJoined: false,
-			pendingLocalCandidates: [] as RTCIceCandidateInit[],
-			pendingRemoteCandidates: [] as RTCIceCandidateInit[],
-		};
+		// Each other participant gets its own peer connection; signals are
+		// addressed with "to" and routed by the "from" the server fills in.
+		const sessions = new Map<number, PeerSession>();
 
-		const flushPendingLocalCandidates = () => {
-			if (!ws || !state.hasPeerJoined) {
-				return;
-			}
-
-			if (state.pendingLocalCandidates.length === 0) {
-				return;
-			}
-
-			console.log(
-				`Flushing ${state.pendingLocalCandidates.length} local candidates`,
-			);
-
-			const queued = state.pendingLocalCandidates.slice();
-			state.pendingLocalCandidates = [];
-
-			for (let index = 0; index < queued.length; index += 1) {
-				const candidate = queued[index];
-				try {
-					console.log(
-						`Sending ICE candidate from flush: ${candidate.candidate}`,
-					);
-					ws.send(
-						JSON.stringify({
-							type: "ice-candidate",
-							payload: candidate,
-						}),
-					);
-				} catch (error) {
-					console.error(
-						"Sending buffered ICE candidate failed:",
-						error,
-						candidate,
-					);
-					break;
-				}
-			}
+		const send = (type: string, to: number, payload: unknown) => {
+			ws.send(JSON.stringify({ type, to, payload }));
 		};
 
-		const flushPendingRemoteCandidates = async () => {
-			if (state.pendingRemoteCandidates.length > 0) {
+		const flushPendingRemoteCandidates = async (session: PeerSession) => {
+			if (session.pendingRemoteCandidates.length > 0) {
 				console.log(
-					`Flushing ${state.pendingRemoteCandidates.length} candidates`,
+					`Flushing ${session.pendingRemoteCandidates.length} candidates`,
 				);
-				for (const candidate of state.pendingRemoteCandidates) {
+				for (const candidate of session.pendingRemoteCandidates) {
 					try {
-						await pc.addIceCandidate(new RTCIceCandidate(candidate));
+						await session.pc.addIceCandidate(new RTCIceCandidate(candidate));
 					} catch (error) {
 						console.error("Flushing ICE candidate failed:", error, candidate);
 					}
 				}
-				state.pendingRemoteCandidates = [];
+				session.pendingRemoteCandidates = [];
 			}
 		};
 
@@ -621,37 +570,6 @@ function connectWebSocket(
 			});
 		};
 
-		pc.onicecandidate = (event) => {
-			if (event.candidate) {
-				const payload = event.candidate.toJSON();
-				if (!ws || !state.hasPeerJoined) {
-					console.log(`Buffering ICE candidate: ${payload.candidate}`);
-					state.pendingLocalCandidates.push(payload);
-				} else {
-					try {
-						console.log(`Sending ICE candidate: ${payload.candidate}`);
-						ws.send(
-							JSON.stringify({
-								type: "ice-candidate",
-								payload,
-							}),
-						);
-					} catch (error) {
-						console.error("Sending ICE candidate failed:", error, payload);
-						state.pendingLocalCandidates.push(payload);
-					}
-				}
-			}
-		};
-
-		pc.oniceconnectionstatechange = () => {
-			console.log(`ICE: ${pc.iceConnectionState}`);
-		};
-
-		pc.onconnectionstatechange = () => {
-			console.log(`PC: ${pc.connectionState}`);
-		};
-
 		const cleanupEmptyStreams = () => {
 			const activeStreams = remoteStreams.filter(
 				(s) => s.getTracks().length > 0,
@@ -663,63 +581,140 @@ function connectWebSocket(
 			}
 		};
 
-		pc.ontrack = (event) => {
-			console.log(`Received: track ${event.track.kind}`);
+		const addRemoteStream = (session: PeerSession, stream: MediaStream) => {
+			remoteStreams.push(stream);
+			session.streams.push(stream);
 
-			const attach = () => {
-				let needsUpdate = false;
+			stream.addEventListener("removetrack", () => {
+				console.log(`Track removed from stream ${stream.id}`);
+				cleanupEmptyStreams();
+			});
+		};
 
-				if (event.streams && event.streams[0]) {
-					const streamId = event.streams[0].id;
-					let stream = remoteStreams.find((s) => s.id === streamId);
+		const getSession = (userId: number): PeerSession => {
+			const existing = sessions.get(userId);
+			if (existing) {
+				return existing;
+			}
 
-					if (!stream) {
-						stream = event.streams[0];
-						remoteStreams.push(stream);
-						needsUpdate = true;
+			const pc = new RTCPeerConnection({ iceServers });
+			for (const track of localStream.getTracks()) {
+				pc.addTrack(track, localStream);
+			}
 
-						stream.addEventListener("removetrack", () => {
-							console.log(`Track removed from stream ${streamId}`);
-							cleanupEmptyStreams();
-						});
-					}
-				} else {
-					let stream = remoteStreams.find((s) =>
-						s.getTracks().some((t) => t.id === event.track.id),
-					);
-
-					if (!stream) {
-						stream = new MediaStream();
-						remoteStreams.push(stream);
-						needsUpdate = true;
-
-						stream.addEventListener("removetrack", () => {
-							console.log("Track removed from stream");
-							cleanupEmptyStreams();
-						});
-					}
+			const session: PeerSession = {
+				pc,
+				pendingRemoteCandidates: [],
+				streams: [],
+			};
+			sessions.set(userId, session);
+			peers.set(userId, pc);
 
-					if (!stream.getTracks().includes(event.track)) {
-						stream.addTrack(event.track);
-						needsUpdate = true;
+			pc.onicecandidate = (event) => {
+				if (event.candidate) {
+					const payload = event.candidate.toJSON();
+					try {
+						console.log(
+							`Sending ICE candidate to ${userId}: ${payload.candidate}`,
+						);
+						send("ice-candidate", userId, payload);
+					} catch (error) {
+						console.error("Sending ICE candidate failed:", error, payload);
 					}
 				}
+			};
 
-				event.track.addEventListener("ended", () => {
-					console.log(`Track ended: ${event.track.kind}`);
-					cleanupEmptyStreams();
-				});
+			pc.oniceconnectionstatechange = () => {
+				console.log(`ICE ${userId}: ${pc.iceConnectionState}`);
+			};
+
+			pc.onconnectionstatechange = () => {
+				console.log(`PC ${userId}: ${pc.connectionState}`);
+			};
+
+			pc.ontrack = (event) => {
+				console.log(`Received: track ${event.track.kind} from ${userId}`);
 
-				if (needsUpdate) {
-					forceUpdate();
+				const attach = () => {
+					let needsUpdate = false;
+
+					if (event.streams && event.streams[0]) {
+						const streamId = event.streams[0].id;
+						if (!remoteStreams.some((s) => s.id === streamId)) {
+							addRemoteStream(session, event.streams[0]);
+							needsUpdate = true;
+						}
+					} else {
+						let stream = remoteStreams.find((s) =>
+							s.getTracks().some((t) => t.id === event.track.id),
+						);
+
+						if (!stream) {
+							stream = new MediaStream();
+							addRemoteStream(session, stream);
+							needsUpdate = true;
+						}
+
+						if (!stream.getTracks().includes(event.track)) {
+							stream.addTrack(event.track);
+							needsUpdate = true;
+						}
+					}
+
+					event.track.addEventListener("ended", () => {
+						console.log(`Track ended: ${event.track.kind}`);
+						cleanupEmptyStreams();
+					});
+
+					if (needsUpdate) {
+						forceUpdate();
+					}
+				};
+
+				if (event.track.muted) {
+					event.track.onunmute = () => attach();
+				} else {
+					attach();
 				}
 			};
 
-			if (event.track.muted) {
-				event.track.onunmute = () => attach();
-			} else {
-				attach();
+			return session;
+		};
+
+		const closeSession = (userId: number) => {
+			const session = sessions.get(userId);
+			if (!session) {
+				return;
+			}
+			sessions.delete(userId);
+			peers.delete(userId);
+			closePeerConnection(session.pc);
+
+			const remaining = remoteStreams.filter(
+				(s) => !session.streams.includes(s),
+			);
+			remoteStreams.length = 0;
+			remoteStreams.push(...remaining);
+			forceUpdate();
+		};
+
+		const sendDescription = async (
+			type: "offer" | "answer",
+			to: number,
+			pc: RTCPeerConnection,
+		) => {
+			await waitForIceGatheringComplete(pc);
+
+			const localDescription = pc.localDescription;
+			if (!localDescription) {
+				throw new Error("Local description missing after ICE gathering");
 			}
+
+			send(type, to, {
+				sdp: localDescription.sdp,
+				type: localDescription.type,
+			});
+			console.log(`Sent: ${type} to ${to}`);
 		};
 
 		ws.onopen = () => {
@@ -731,90 +726,79 @@ function connectWebSocket(
 		ws.onmessage = async (event: MessageEvent) => {
 			try {
 				const message = JSON.parse(event.data);
+				const from: number = message.from ?? 0;
 
 				switch (message.type) {
-					case "peer-joined": {
-						console.log("Peer joined the call");
-						updateStatus("");
+					case "participants": {
+						const participants: { userId: number; username: string }[] =
+							message.payload ?? [];
+						if (participants.length === 0) {
+							break;
+						}
 
-						if (isInitiator) {
-							console.log("I am the initiator, sending offer");
-							const offerDescription = await pc.createOffer();
-							await pc.setLocalDescription(offerDescription);
-							await waitForIceGatheringComplete(pc);
-
-							const localDescription = pc.localDescription;
-							if (!localDescription) {
-								throw new Error(
-									"Local description missing after ICE gathering",
-								);
-							}
+						console.log(
+							`Joined a running call, sending ${participants.length} offers`,
+						);
+						updateStatus("");
 
-							ws.send(
-								JSON.stringify({
-									type: "offer",
-									payload: {
-										sdp: localDescription.sdp,
-										type: localDescription.type,
-									},
-								}),
-							);
-							console.log("Sent: offer");
-						} else {
-							console.log("Waiting for offer...");
+						await Promise.all(
+							participants.map(async (peer) => {
+								const { pc } = getSession(peer.userId);
+								const offerDescription = await pc.createOffer();
+								await pc.setLocalDescription(offerDescription);
+								await sendDescription("offer", peer.userId, pc);
+							}),
+						);
+						break;
+					}
+					case "peer-joined": {
+						console.log(`Peer ${from} joined the call, waiting for offer...`);
+						updateStatus("");
+						break;
+					}
+					case "peer-left": {
+						if (!from) break;
+						console.log(`Peer ${from} left the call`);
+						closeSession(from);
+						if (sessions.size === 0) {
+							endCall();
 						}
-
-						state.hasPeerJoined = true;
-						flushPendingLocalCandidates();
 						break;
 					}
 					case "offer": {
-						console.log("Received: offer");
-						await pc.setRemoteDescription(
+						if (!from) break;
+						console.log(`Received: offer from ${from}`);
+						const session = getSession(from);
+						await session.pc.setRemoteDescription(
 							new RTCSessionDescription(message.payload),
 						);
-						await flushPendingRemoteCandidates();
-
-						const answerDescription = await pc.createAnswer();
-						await pc.setLocalDescription(answerDescription);
-						await waitForIceGatheringComplete(pc);
-
-						const localDescription = pc.localDescription;
-						if (!localDescription) {
-							throw new Error("Local description missing after ICE gathering");
-						}
+						await flushPendingRemoteCandidates(session);
 
-						ws.send(
-							JSON.stringify({
-								type: "answer",
-								payload: {
-									sdp: localDescription.sdp,
-									type: localDescription.type,
-								},
-							}),
-						);
-						console.log("Sent: answer");
-						flushPendingLocalCandidates();
+						const answerDescription = await session.pc.createAnswer();
+						await session.pc.setLocalDescription(answerDescription);
+						await sendDescription("answer", from, session.pc);
 						break;
 					}
 					case "answer": {
-						console.log("Received: answer");
-						const answerDescription = new RTCSessionDescription(
-							message.payload,
+						const session = sessions.get(from);
+						if (!session) break;
+						console.log(`Received: answer from ${from}`);
+						await session.pc.setRemoteDescription(
+							new RTCSessionDescription(message.payload),
 						);
-						await pc.setRemoteDescription(answerDescription);
-						await flushPendingRemoteCandidates();
-						flushPendingLocalCandidates();
+						await flushPendingRemoteCandidates(session);
 						break;
 					}
 					case "ice-candidate": {
-						console.log("Received: ICE candidate");
+						if (!from) break;
+						console.log(`Received: ICE candidate from ${from}`);
+						const session = getSession(from);
 						const init: RTCIceCandidateInit = message.payload;
-						if (!pc.remoteDescription) {
-							state.pendingRemoteCandidates.push(init);
+						if (!session.pc.remoteDescription) {
+							session.pendingRemoteCandidates.push(init);
 						} else {
 							try {
-								await pc.addIceCandidate(new RTCIceCandidate(init));
+								await session.pc.addIceCandidate(new RTCIceCandidate(init));
 							} catch (error) {
 								console.error("Adding ICE candidate failed:", error, init);
 							}
@@ -822,32 +806,30 @@ function connectWebSocket(
 						break;
 					}
 					case "renegotiate": {
-						console.log("Received: renegotiate");
-						await pc.setRemoteDescription(
+						const session = sessions.get(from);
+						if (!session) break;
+						console.log(`Received: renegotiate from ${from}`);
+						await session.pc.setRemoteDescription(
 							new RTCSessionDescription(message.payload),
 						);
 
-						const answerDescription = await pc.createAnswer();
-						await pc.setLocalDescription(answerDescription);
+						const answerDescription = await session.pc.createAnswer();
+						await session.pc.setLocalDescription(answerDescription);
 
-						ws.send(
-							JSON.stringify({
-								type: "renegotiate-answer",
-								payload: {
-									sdp: answerDescription.sdp,
-									type: answerDescription.type,
-								},
-							}),
-						);
-						console.log("Sent: renegotiate-answer");
+						send("renegotiate-answer", from, {
+							sdp: answerDescription.sdp,
+							type: answerDescription.type,
+						});
+						console.log(`Sent: renegotiate-answer to ${from}`);
 						break;
 					}
 					case "renegotiate-answer": {
-						console.log("Received: renegotiate-answer");
-						const answerDescription = new RTCSessionDescription(
-							message.payload,
+						const session = sessions.get(from);
+						if (!session) break;
+						console.log(`Received: renegotiate-answer from ${from}`);
+						await session.pc.setRemoteDescription(
+							new RTCSessionDescription(message.payload),
 						);
-						await pc.setRemoteDescription(answerDescription);
 						break;
 					}
 				}
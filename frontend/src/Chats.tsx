@@ -126,7 +126,7 @@ export default function Chats() {
 
 	useEffect(() => {
 		const handleEvent = async (event: Event) => {
-			if (event.type !== "message.new") {
+			if (event.type !== "message.new" && event.type !== "message.edited") {
 				return;
 			}
 
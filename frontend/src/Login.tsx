@@ -9,8 +9,12 @@ interface LoginRequest {
 }
 
 interface AuthResponse {
-	success: boolean;
-	message?: string;
+	success?: boolean;
+	error?: {
+		code: string;
+		message: string;
+		field?: string;
+	};
 	userId?: number;
 	username?: string;
 	profileImageUrl?: string | null;
@@ -75,8 +79,8 @@ export default function Login() {
 							required
 						/>
 					</div>
-					{!loginMutation.data?.success && loginMutation.data?.message && (
-						<div className="mb-4 text-ctp-red">{loginMutation.data.message}</div>
+					{loginMutation.data?.error && (
+						<div className="mb-4 text-ctp-red">{loginMutation.data.error.message}</div>
 					)}
 					{loginMutation.isError && (
 						<div className="mb-4 text-ctp-red">Network error</div>
@@ -11,8 +11,12 @@ interface RegisterRequest {
 }
 
 interface AuthResponse {
-	success: boolean;
-	message?: string;
+	success?: boolean;
+	error?: {
+		code: string;
+		message: string;
+		field?: string;
+	};
 	userId?: number;
 	username?: string;
 	accessToken?: string;
@@ -100,10 +104,9 @@ export default function Register() {
 						/>
 					</div>
 					{error && <div className="mb-4 text-ctp-red">{error}</div>}
-					{!registerMutation.data?.success &&
-						registerMutation.data?.message && (
+					{registerMutation.data?.error && (
 						<div className="mb-4 text-ctp-red">
-							{registerMutation.data.message}
+							{registerMutation.data.error.message}
 						</div>
 					)}
 					{registerMutation.isError && (
@@ -325,7 +325,7 @@ function ProfileSettings() {
 				await checkAuth();
 			} else {
 				const error = await response.json();
-				alert(error.error || "Failed to upload image");
+				alert(error.error?.message || "Failed to upload image");
 			}
 		} catch (error) {
 			console.error("Failed to upload profile image:", error);
@@ -77,6 +77,7 @@ export async function sendMessage(
 		body: JSON.stringify({
 			conversationId,
 			body,
+			clientMessageId: crypto.randomUUID(),
 		}),
 	});
 
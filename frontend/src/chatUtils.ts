@@ -6,11 +6,26 @@ export interface Conversation {
 	name: string | null;
 	lastMessageSeq: number;
 	unreadCount: number;
+	retentionDays?: number | null;
+	readOnly?: boolean;
+	memberCount?: number;
+	notificationLevel?: "all" | "mentions" | "none";
+	lastMessage?: {
+		senderUsername: string;
+		snippet: string;
+		createdAt: string;
+		contentType: string;
+	};
 	otherUser?: {
 		id: number;
 		username: string;
 		profileImageUrl: string | null;
+		online: boolean;
+		lastSeen: string | null;
 	};
+	activeParticipants?: number[];
+	pinned: boolean;
+	pinnedAt?: string;
 }
 
 export interface Message {
@@ -25,6 +40,33 @@ export interface Message {
 	contentType: string;
 	body: string;
 	replyToId?: number;
+	replyCount: number;
+	threadParticipants?: ThreadParticipant[];
+	reactions?: Record<string, number>;
+	currentUserReactions?: string[];
+	mentions?: number[];
+	seenByCount?: number;
+	forwardedFrom?: ForwardedFrom;
+	systemEvent?: SystemEvent;
+}
+
+export interface SystemEvent {
+	action: "member_added" | "member_removed" | "conversation_renamed";
+	actorId: number;
+	targetId?: number;
+	targetUsername?: string;
+	name?: string;
+}
+
+export interface ForwardedFrom {
+	messageId: number;
+	conversationId: number;
+	senderUsername: string;
+}
+
+export interface ThreadParticipant {
+	userId: number;
+	username: string;
 }
 
 export function getConversationName(conv: Conversation): string {
@@ -1,6 +1,19 @@
 // Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
 
-type EventType = "message.new" | "keepalive";
+type EventType =
+	| "message.new"
+	| "message.edited"
+	| "keepalive"
+	| "evicted"
+	| "notification.unread"
+	| "thread.reply"
+	| "poll.vote"
+	| "server.shutdown"
+	| "conversation.updated"
+	| "conversation.member.updated"
+	| "conversation.deleted"
+	| "conversation.typing"
+	| "conversation.stopped_typing";
 
 interface Event {
 	type: EventType;
@@ -66,6 +79,10 @@ class EventManager {
 		this.eventSource.onmessage = (evt) => {
 			try {
 				const event: Event = JSON.parse(evt.data);
+				if (event.type === "evicted") {
+					this.handleEviction();
+					return;
+				}
 				this.notifyListeners(event);
 			} catch (error) {
 				console.error("Failed to parse SSE event:", error);
@@ -82,6 +99,27 @@ class EventManager {
 		};
 	}
 
+	// The server closed this stream because the user opened too many others.
+	// Reconnecting right away would evict another tab in turn, so wait until
+	// this tab is in the foreground again.
+	private handleEviction(): void {
+		this.eventSource?.close();
+		this.eventSource = null;
+
+		const reconnectWhenVisible = () => {
+			if (this.isIntentionallyClosed) {
+				document.removeEventListener("visibilitychange", reconnectWhenVisible);
+				return;
+			}
+			if (document.visibilityState !== "visible") {
+				return;
+			}
+			document.removeEventListener("visibilitychange", reconnectWhenVisible);
+			void this.connect();
+		};
+		document.addEventListener("visibilitychange", reconnectWhenVisible);
+	}
+
 	private scheduleReconnect(): void {
 		if (this.reconnectTimeout !== null) {
 			return;
@@ -0,0 +1,33 @@
+// Copyright (C) 2025  Mayer & Ott GbR AGPL v3 (license file is attached)
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+func main() {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating key: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Browsers expect the uncompressed public point and the raw private
+	// scalar, both URL-safe base64 without padding.
+	publicKey := base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+	privateKey := base64.RawURLEncoding.EncodeToString(key.Bytes())
+
+	fmt.Println("Generated VAPID key pair for TeamSync Web Push notifications:")
+	fmt.Println("==========================================")
+	fmt.Printf("VAPID_PUBLIC_KEY=%s\n", publicKey)
+	fmt.Printf("VAPID_PRIVATE_KEY=%s\n", privateKey)
+	fmt.Println("==========================================")
+	fmt.Println()
+	fmt.Println("Set both as environment variables together with VAPID_SUBJECT (e.g. mailto:admin@example.com).")
+	fmt.Println("The public key is handed to browsers; keep the private key secret.")
+	fmt.Println("WARNING: Changing the keys invalidates all existing push subscriptions.")
+}